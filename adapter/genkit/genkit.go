@@ -0,0 +1,128 @@
+// Package genkit converts between neko tools and Firebase Genkit tools,
+// so tools written for either can be used by agents of the other.
+package genkit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/gocnn/neko"
+)
+
+// FromTool wraps a Genkit tool as a neko tool. Inputs come from the
+// properties of the tool's input schema; a tool whose input is not an
+// object takes it as a single input named "input".
+func FromTool(t ai.Tool) neko.Tool {
+	def := t.Definition()
+	nt := &nekoTool{tool: t, description: def.Description, outputType: schemaType(def.OutputSchema)}
+	props, _ := def.InputSchema["properties"].(map[string]any)
+	if schemaType(def.InputSchema) != "object" || props == nil {
+		nt.scalar = true
+		nt.inputs = map[string]neko.ToolInput{
+			"input": {Type: schemaType(def.InputSchema), Description: schemaDescription(def.InputSchema), Required: true},
+		}
+		return nt
+	}
+	required := map[string]bool{}
+	if req, ok := def.InputSchema["required"].([]any); ok {
+		for _, name := range req {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	} else if req, ok := def.InputSchema["required"].([]string); ok {
+		for _, name := range req {
+			required[name] = true
+		}
+	}
+	nt.inputs = make(map[string]neko.ToolInput, len(props))
+	for name, p := range props {
+		prop, _ := p.(map[string]any)
+		nt.inputs[name] = neko.ToolInput{Type: schemaType(prop), Description: schemaDescription(prop), Required: required[name]}
+	}
+	return nt
+}
+
+type nekoTool struct {
+	tool        ai.Tool
+	description string
+	inputs      map[string]neko.ToolInput
+	outputType  string
+	scalar      bool // the tool's input is args["input"] rather than args
+}
+
+func (t *nekoTool) Name() string                      { return t.tool.Name() }
+func (t *nekoTool) Description() string               { return t.description }
+func (t *nekoTool) Inputs() map[string]neko.ToolInput { return t.inputs }
+func (t *nekoTool) OutputType() string                { return t.outputType }
+
+func (t *nekoTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *nekoTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	if t.scalar {
+		return t.tool.RunRaw(ctx, args["input"])
+	}
+	return t.tool.RunRaw(ctx, args)
+}
+
+// ToTool wraps a neko tool as a Genkit tool whose input is an object of
+// the tool's arguments. The tool is not registered; pass it to
+// ai.WithTools or register it with a Genkit instance.
+func ToTool(t neko.Tool) ai.Tool {
+	props := make(map[string]any)
+	required := []string{}
+	for name, in := range t.Inputs() {
+		prop := map[string]any{"description": in.Description}
+		if in.Type != "" && in.Type != "any" {
+			prop["type"] = in.Type
+		}
+		props[name] = prop
+		if in.Required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]any{"type": "object", "properties": props, "required": required}
+
+	return ai.NewTool(t.Name(), t.Description(), func(tc *ai.ToolContext, input any) (any, error) {
+		args, ok := input.(map[string]any)
+		if !ok && input != nil {
+			return nil, fmt.Errorf("%s: input must be an object, got %T", t.Name(), input)
+		}
+		if args == nil {
+			args = map[string]any{}
+		}
+		if err := neko.ValidateToolArgs(t, args); err != nil {
+			return nil, err
+		}
+		if ct, ok := t.(neko.ContextTool); ok {
+			return ct.ExecuteContext(tc, args)
+		}
+		return t.Execute(args)
+	}, ai.WithInputSchema(schema))
+}
+
+// schemaType returns the type of a JSON schema, or "any" if it has none.
+func schemaType(schema map[string]any) string {
+	switch typ := schema["type"].(type) {
+	case string:
+		return typ
+	case []any:
+		// e.g. ["string", "null"] for optional values
+		for _, t := range typ {
+			if s, ok := t.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return "any"
+}
+
+func schemaDescription(schema map[string]any) string {
+	desc, _ := schema["description"].(string)
+	return desc
+}
@@ -0,0 +1,146 @@
+// Package langchain converts between neko tools and langchaingo tools,
+// so tools written for either can be used by agents of the other.
+package langchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gocnn/neko"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// FromTool wraps a langchaingo tool as a neko tool taking a single string
+// input named "input".
+func FromTool(t tools.Tool) neko.Tool {
+	return &nekoTool{tool: t}
+}
+
+type nekoTool struct {
+	tool tools.Tool
+}
+
+func (t *nekoTool) Name() string        { return t.tool.Name() }
+func (t *nekoTool) Description() string { return t.tool.Description() }
+func (t *nekoTool) OutputType() string  { return "string" }
+
+func (t *nekoTool) Inputs() map[string]neko.ToolInput {
+	return map[string]neko.ToolInput{
+		"input": {Type: "string", Description: "Input for the tool", Required: true},
+	}
+}
+
+func (t *nekoTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *nekoTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	input, ok := args["input"].(string)
+	if !ok {
+		data, err := json.Marshal(args["input"])
+		if err != nil {
+			return nil, err
+		}
+		input = string(data)
+	}
+	return t.tool.Call(ctx, input)
+}
+
+// ToTool wraps a neko tool as a langchaingo tool. The tool's input is a
+// JSON object of its arguments or, for tools with a single input, the
+// value of that input; the description tells the model which. Outputs
+// other than strings are returned as JSON.
+func ToTool(t neko.Tool) tools.Tool {
+	return &lcTool{tool: t}
+}
+
+type lcTool struct {
+	tool neko.Tool
+}
+
+func (t *lcTool) Name() string { return t.tool.Name() }
+
+func (t *lcTool) Description() string {
+	inputs := t.tool.Inputs()
+	names := neko.ToolParamNames(t.tool)
+	var sb strings.Builder
+	sb.WriteString(t.tool.Description())
+	switch len(names) {
+	case 0:
+	case 1:
+		in := inputs[names[0]]
+		fmt.Fprintf(&sb, "\nInput: %s (%s).", in.Description, in.Type)
+	default:
+		sb.WriteString("\nInput: a JSON object with the fields:")
+		for _, name := range names {
+			in := inputs[name]
+			req := ""
+			if in.Required {
+				req = ", required"
+			}
+			fmt.Fprintf(&sb, "\n- %s (%s%s): %s", name, in.Type, req, in.Description)
+		}
+	}
+	return sb.String()
+}
+
+func (t *lcTool) Call(ctx context.Context, input string) (string, error) {
+	args, err := t.args(input)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", t.tool.Name(), err)
+	}
+	if err := neko.ValidateToolArgs(t.tool, args); err != nil {
+		return "", err
+	}
+	var out any
+	if ct, ok := t.tool.(neko.ContextTool); ok {
+		out, err = ct.ExecuteContext(ctx, args)
+	} else {
+		out, err = t.tool.Execute(args)
+	}
+	if err != nil {
+		return "", err
+	}
+	if s, ok := out.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Sprint(out), nil
+	}
+	return string(data), nil
+}
+
+// args decodes a tool input: a JSON object of arguments, or the value of
+// the only input.
+func (t *lcTool) args(input string) (map[string]any, error) {
+	names := neko.ToolParamNames(t.tool)
+	trimmed := strings.TrimSpace(input)
+	if strings.HasPrefix(trimmed, "{") {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(trimmed), &args); err == nil {
+			if len(names) != 1 {
+				return args, nil
+			}
+			if _, ok := args[names[0]]; ok {
+				return args, nil
+			}
+		}
+	}
+	switch len(names) {
+	case 0:
+		return map[string]any{}, nil
+	case 1:
+		var v any = input
+		if typ := t.tool.Inputs()[names[0]].Type; typ != "string" && typ != "" {
+			if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+				return nil, fmt.Errorf("input must be a %s: %w", typ, err)
+			}
+		}
+		return map[string]any{names[0]: v}, nil
+	default:
+		return nil, fmt.Errorf("input must be a JSON object of arguments")
+	}
+}
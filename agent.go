@@ -1,12 +1,17 @@
 package neko
 
 import (
+	"cmp"
 	"context"
 	"fmt"
-	"regexp"
+	"log/slog"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Agent is the core interface for all agent types.
@@ -22,6 +27,13 @@ type RunOptions struct {
 	Reset     bool
 	Images    [][]byte
 	ExtraArgs map[string]any
+	Resume    *Memory
+	Memory    *Memory
+	Budget    *Budget
+	Tools     []string
+	Models    []string
+	Files     []string
+	Locale    *LocaleContext
 }
 
 // RunOption is a functional option for Run.
@@ -37,18 +49,96 @@ func WithReset(reset bool) RunOption {
 	return func(o *RunOptions) { o.Reset = reset }
 }
 
+// WithImages attaches images to the task, for vision-capable models.
+func WithImages(images ...[]byte) RunOption {
+	return func(o *RunOptions) { o.Images = images }
+}
+
+// WithResume continues the run recorded in m instead of starting a new
+// one: the agent's memory is replaced with a copy of m, no task step is
+// added, and step numbers continue after m's last action step. MaxSteps
+// limits the new steps only. A CodeAgent starts with fresh executor
+// state, so variables defined in earlier steps are undefined.
+func WithResume(m *Memory) RunOption {
+	return func(o *RunOptions) { o.Resume = m }
+}
+
+// WithMemory starts the run from a copy of m's steps, with the agent's
+// own system prompt, and records the task after them, like a run without
+// reset on an agent whose memory is m. It restores a conversation saved
+// from an earlier agent, e.g. an archived session. The tokens m's steps
+// used are not counted toward the run's budget or its result's usage.
+func WithMemory(m *Memory) RunOption {
+	return func(o *RunOptions) { o.Memory = m }
+}
+
+// WithAllowedTools limits the run to the named tools and managed agents;
+// final_answer is always allowed. Calls to other tools are rejected, and a
+// ToolCallingAgent is not offered them. A CodeAgent's system prompt still
+// lists every tool, but only the allowed ones are defined in its code.
+// The limit also applies to the runs of the managed and spawned agents the
+// run starts, and within a run that is itself limited, the limits combine.
+func WithAllowedTools(names ...string) RunOption {
+	return func(o *RunOptions) { o.Tools = names }
+}
+
 // BaseAgent provides common agent functionality.
 type BaseAgent struct {
-	name          string
-	description   string
-	model         Model
-	tools         *ToolRegistry
-	memory        *Memory
-	managedAgents map[string]Agent
-	callbacks     *CallbackRegistry
-	maxSteps      int
-	systemPrompt  string
-	mu            sync.Mutex
+	name              string
+	description       string
+	model             Model
+	tools             *ToolRegistry
+	memory            *Memory
+	managedAgents     map[string]Agent
+	events            *EventBus
+	maxSteps          int
+	systemPrompt      string
+	packagePolicy     *PackagePolicy
+	codeParser        *CodeParser
+	promptedTools     *bool
+	stepTimeout       time.Duration
+	stepTimeoutPolicy StepTimeoutPolicy
+	finalChecks       []FinalAnswerCheck
+	skills            []*Skill
+	output            *OutputConstraints
+	predictor         ToolPredictor
+	profiling         bool
+	sampling          *ActionSampling
+	forceFinal        *bool
+	callbackPool      *CallbackPool
+	syncCallbacks     bool
+	recovery          *ErrorRecovery
+	recoveryState     recoveryState
+	tempSchedule      TemperatureSchedule
+	tempState         temperatureState
+	workspace         *workspaceConfig
+	templates         map[string]AgentTemplate
+	toolSelector      *toolSelector
+	limiter           *Limiter
+	responsePolicies  map[string]ResponsePolicy
+	confidence        *float64
+	typedOutputs      bool
+	streamAnswer      bool
+	secrets           *secretStore
+	capabilities      *Capabilities
+	examples          []Example
+	exampleBudget     int
+	imageObs          bool
+	tracer            trace.Tracer
+	log               *slog.Logger
+	pricing           Pricing
+	budget            *Budget
+	tracker           *budgetTracker // the current run's budget
+	restored          TokenUsage     // usage of the steps WithMemory restored
+	allowed           map[string]bool
+	allowedModels     []string // the current run's, nil if unrestricted
+	approveTool       func(ctx context.Context, tc ToolCall) (bool, error)
+	toolProgress      *ToolProgressPolicy
+	locale            *LocaleContext
+	turns             *RunQueue // admits the agent's runs one at a time
+	runQueue          *RunQueue
+	queued            atomic.Int64
+	mu                sync.Mutex
 }
 
 // AgentOption configures a BaseAgent.
@@ -69,6 +159,12 @@ func WithModel(m Model) AgentOption {
 	return func(a *BaseAgent) { a.model = m }
 }
 
+// WithSystemPrompt replaces the default system prompt. For code agents
+// the prompt should describe the tools, which the default prompt lists.
+func WithSystemPrompt(prompt string) AgentOption {
+	return func(a *BaseAgent) { a.systemPrompt = prompt }
+}
+
 // WithAgentMaxSteps sets maximum execution steps.
 func WithAgentMaxSteps(n int) AgentOption {
 	return func(a *BaseAgent) { a.maxSteps = n }
@@ -92,9 +188,94 @@ func WithToolList(tools ...Tool) AgentOption {
 	}
 }
 
+// WithPricing sets the model's token prices, used to report run cost.
+func WithPricing(p Pricing) AgentOption {
+	return func(a *BaseAgent) { a.pricing = p }
+}
+
+// WithEventBus publishes the agent's events on bus instead of a bus of
+// its own, e.g. to observe several agents through one set of subscribers.
+// It should come before options that subscribe to events.
+func WithEventBus(bus *EventBus) AgentOption {
+	return func(a *BaseAgent) { a.events = bus }
+}
+
+// WithStepCallback calls fn with each step of stepType ("task", "action",
+// "planning" or "final_answer") as it is recorded, or with every step if
+// stepType is "all". Like the agent's other callbacks, fn runs on its
+// CallbackPool unless WithSyncCallbacks is set.
+func WithStepCallback(stepType string, fn func(Step)) AgentOption {
+	return func(a *BaseAgent) {
+		a.events.Subscribe(EventStep, func(e Event) {
+			step := e.(StepEvent).Step
+			if stepType == "all" || step.StepType() == stepType {
+				a.runCallback("step", func() { fn(step) })
+			}
+		})
+	}
+}
+
+// WithRunCallback calls fn when a run ends, with its result or error. fn
+// may run after Run returns; see WaitCallbacks.
+func WithRunCallback(fn func(*RunResult, error)) AgentOption {
+	return func(a *BaseAgent) {
+		a.events.Subscribe(EventRunCompleted, func(e Event) {
+			ev := e.(RunCompletedEvent)
+			a.runCallback("run", func() { fn(ev.Result, ev.Err) })
+		})
+	}
+}
+
+// WithModelStreamCallback streams model output as it is generated,
+// tagged with the step number. It only takes effect with a StreamingModel.
+func WithModelStreamCallback(fn func(step int, delta string)) AgentOption {
+	return func(a *BaseAgent) {
+		a.events.Subscribe(EventModelDelta, func(e Event) {
+			ev := e.(ModelDeltaEvent)
+			a.runCallback("model_stream", func() { fn(ev.StepNumber, ev.Delta) })
+		})
+	}
+}
+
+// WithToolApproval asks fn before every tool call, including calls made
+// from executed code. A false answer fails the call with
+// ErrToolCallRejected, which the model sees as the tool's error. A
+// non-nil error fails the call with that error.
+func WithToolApproval(fn func(ctx context.Context, tc ToolCall) (bool, error)) AgentOption {
+	return func(a *BaseAgent) { a.approveTool = fn }
+}
+
+// WithExecutionLogCallback streams output from a CodeAgent's executor
+// line by line while code runs, tagged with the step number. Executors
+// that cannot stream deliver their whole output once execution ends.
+func WithExecutionLogCallback(fn func(step int, line string)) AgentOption {
+	return func(a *BaseAgent) {
+		a.events.Subscribe(EventExecutionLog, func(e Event) {
+			ev := e.(ExecutionLogEvent)
+			a.runCallback("execution_log", func() { fn(ev.StepNumber, ev.Line) })
+		})
+	}
+}
+
+// WithImageObservations makes a CodeAgent send image artifacts, such as
+// captured plots, back to the model with the step's observations. The
+// model must accept image input.
+func WithImageObservations(enabled bool) AgentOption {
+	return func(a *BaseAgent) { a.imageObs = enabled }
+}
+
 func (a *BaseAgent) Name() string        { return a.name }
 func (a *BaseAgent) Description() string { return a.description }
 
+// Events returns the bus the agent publishes its events on.
+func (a *BaseAgent) Events() *EventBus { return a.events }
+
+// Model returns the agent's model.
+func (a *BaseAgent) Model() Model { return a.model }
+
+// Pricing returns the token prices set with WithPricing.
+func (a *BaseAgent) Pricing() Pricing { return a.pricing }
+
 // ToolCallingAgent uses JSON tool calls.
 type ToolCallingAgent struct {
 	BaseAgent
@@ -106,8 +287,9 @@ func NewToolCallingAgent(opts ...AgentOption) *ToolCallingAgent {
 		BaseAgent: BaseAgent{
 			tools:         NewToolRegistry(),
 			managedAgents: make(map[string]Agent),
-			callbacks:     NewCallbackRegistry(),
+			events:        NewEventBus(),
 			maxSteps:      20,
+			turns:         NewRunQueue(1),
 		},
 	}
 	a.tools.Register(NewFinalAnswerTool())
@@ -116,9 +298,20 @@ func NewToolCallingAgent(opts ...AgentOption) *ToolCallingAgent {
 		opt(&a.BaseAgent)
 	}
 
+	if a.confidence != nil || a.typedOutputs {
+		final := NewFinalAnswerTool()
+		if a.confidence != nil {
+			final = newConfidentFinalAnswerTool()
+		}
+		if a.typedOutputs {
+			final.addOutputInputs()
+		}
+		a.tools.Register(final)
+	}
 	if a.systemPrompt == "" {
 		a.systemPrompt = defaultToolCallingPrompt(a.tools)
 	}
+	a.systemPrompt += a.skillsPrompt() + a.outputPrompt() + a.confidencePrompt(false) + a.typedOutputPrompt(false)
 	a.memory = NewMemory(a.systemPrompt)
 
 	return a
@@ -131,109 +324,497 @@ func (a *ToolCallingAgent) Run(ctx context.Context, task string, opts ...RunOpti
 		opt(options)
 	}
 
+	release, err := a.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	ctx, span := a.startRun(ctx, task, options)
+	result, err := a.run(ctx, task, options)
+	a.endRun(ctx, span, result, err)
+	return result, err
+}
+
+func (a *ToolCallingAgent) run(ctx context.Context, task string, options *RunOptions) (*RunResult, error) {
 	startTime := time.Now()
-	if options.Reset {
-		a.memory.Reset()
+	if err := checkModel(ctx, a.model); err != nil {
+		return nil, err
+	}
+	files, err := stageFiles(ctx, options.Files)
+	if err != nil {
+		return nil, &AgentError{Message: "failed to attach files", Cause: err}
 	}
-	a.memory.AddStep(&TaskStep{Task: task, Images: options.Images})
+	first := a.startMemory(options, &TaskStep{Task: task, Images: options.Images, Files: files})
 
 	var finalOutput any
+	var confidence answerConfidence
 	state := "success"
+	prompted := a.usePromptedTools()
 
-	for step := 1; step <= options.MaxSteps; step++ {
+	for step := first; step < first+options.MaxSteps; step++ {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
+		stepCtx, stepSpan := a.startStepSpan(ctx, step)
+		stepCtx, cancelStep := a.withStepTimeout(stepCtx)
 		actionStep := &ActionStep{StepNumber: step, Timing: Timing{StartTime: time.Now()}}
+		stepCtx = withLatencyRecorder(stepCtx, &actionStep.Latency)
+		stepCtx = withStepRecorder(stepCtx, actionStep)
+		stepCtx = a.startPrefetch(stepCtx)
 		msgs := a.memory.ToMessages()
-		toolList := a.allTools()
+		toolList := a.selectTools(stepCtx, task, a.allTools())
+		choice, notice := a.lastStepChoice(step, first+options.MaxSteps-1)
+		streamAnswer := choice == "final_answer" && a.streamsAnswer()
+		if notice != nil && !streamAnswer {
+			msgs = append(msgs, *notice)
+		}
 
-		resp, err := a.model.Generate(ctx, msgs, WithTools(toolList...))
+		var resp *Message
+		var err error
+		if streamAnswer {
+			resp, err = a.generateAnswer(stepCtx, step, msgs)
+		} else {
+			resp, err = a.sampleAction(stepCtx, actionStep, func() (*Message, error) {
+				return a.generateToolCalls(stepCtx, step, msgs, toolList, choice, &prompted)
+			}, nil)
+		}
 		if err != nil {
+			if resp != nil {
+				actionStep.ModelOutput, actionStep.TokenUsage = resp.Content, resp.TokenUsage
+			}
 			actionStep.Error = err
+			timedOut := a.stepTimedOut(ctx, stepCtx, actionStep)
+			cancelStep()
 			actionStep.Timing = NewTiming(actionStep.Timing.StartTime)
+			redactStep(stepCtx, actionStep)
 			a.memory.AddStep(actionStep)
+			a.endStep(stepSpan, actionStep)
+			if timedOut && a.stepTimeoutPolicy == StepTimeoutAbort {
+				state = "step_timeout"
+				break
+			}
 			continue
 		}
 
 		actionStep.ModelOutput = resp.Content
+		actionStep.Reasoning = resp.Reasoning
 		actionStep.TokenUsage = resp.TokenUsage
 
 		if len(resp.ToolCalls) > 0 {
 			actionStep.ToolCalls = resp.ToolCalls
 			var observations []string
 
-			for _, tc := range resp.ToolCalls {
-				result, err := a.executeTool(tc)
+			for i, tc := range resp.ToolCalls {
+				if stepCtx.Err() != nil {
+					observations = append(observations, fmt.Sprintf("Not executed %s: %v", tc.Name, context.Cause(stepCtx)))
+					continue
+				}
+				result, err := a.executeTool(stepCtx, tc)
+				var conf answerConfidence
+				if err == nil && tc.Name == "final_answer" {
+					result, conf, err = a.takeConfidence(result, tc.Arguments)
+					if err == nil {
+						args := tc.Arguments
+						if conf.abstained {
+							args = nil
+						}
+						result, err = a.takeOutput(stepCtx, result, args, actionStep)
+					}
+				}
+				if err == nil && tc.Name == "final_answer" && !conf.abstained {
+					err = a.checkFinalAnswer(stepCtx, result, actionStep)
+				}
 				if err != nil {
 					observations = append(observations, fmt.Sprintf("Error executing %s: %v", tc.Name, err))
 				} else {
-					observations = append(observations, fmt.Sprintf("%v", result))
+					observations = append(observations, a.reduceResponse(stepCtx, tc, i, fmt.Sprintf("%v", result)))
 					if tc.Name == "final_answer" {
 						actionStep.IsFinal = true
 						finalOutput = result
+						confidence = conf
 					}
 				}
 			}
-			actionStep.Observations = strings.Join(observations, "\n")
+			actionStep.Observations = redactSecrets(stepCtx, strings.Join(observations, "\n"))
 		}
+		timedOut := !actionStep.IsFinal && a.stepTimedOut(ctx, stepCtx, actionStep)
+		cancelStep()
 
 		actionStep.Timing = NewTiming(actionStep.Timing.StartTime)
+		redactStep(stepCtx, actionStep)
 		a.memory.AddStep(actionStep)
-		a.callbacks.Trigger(actionStep)
+		a.endStep(stepSpan, actionStep)
 
 		if actionStep.IsFinal {
-			a.memory.AddStep(&FinalAnswerStep{Output: finalOutput})
+			a.addStep(&FinalAnswerStep{Output: finalOutput})
+			break
+		}
+		if timedOut && a.stepTimeoutPolicy == StepTimeoutAbort {
+			state = "step_timeout"
+			break
+		}
+		if a.tracker.stop() {
 			break
 		}
 	}
 
-	if finalOutput == nil {
+	if finalOutput == nil && state == "success" {
 		state = "max_steps_error"
+		if a.tracker.stop() {
+			state = "budget_exceeded"
+		}
 	}
 
-	tokens := a.memory.TotalTokens()
+	tokens := a.runTokens()
 	return &RunResult{
-		Output:     finalOutput,
-		State:      state,
-		Steps:      a.memory.Steps,
-		TokenUsage: &tokens,
-		Timing:     NewTiming(startTime),
+		Output:         finalOutput,
+		State:          state,
+		Steps:          a.memory.CopySteps(),
+		Artifacts:      a.memory.Artifacts(),
+		Citations:      a.memory.Citations(),
+		TokenUsage:     &tokens,
+		Cost:           tokens.Cost(a.pricing),
+		Latency:        a.memory.Latency(),
+		UsageBreakdown: a.memory.UsageBreakdown(a.pricing),
+		Profile:        a.profile(ctx),
+		Audit:          AuditLogFromContext(ctx).Entries(),
+		Confidence:     confidence.score,
+		Abstained:      confidence.abstained,
+		Timing:         NewTiming(startTime),
 	}, nil
 }
 
+// Tools returns the tools the agent can call, including its managed agents.
+func (a *BaseAgent) Tools() []Tool { return a.allTools() }
+
 func (a *BaseAgent) allTools() []Tool {
 	tools := make([]Tool, 0)
 	for _, t := range a.tools.All() {
-		tools = append(tools, t)
+		if a.isAllowed(t.Name()) {
+			tools = append(tools, t)
+		}
 	}
 	for name, agent := range a.managedAgents {
-		tools = append(tools, &agentTool{name: name, agent: agent})
+		if a.isAllowed(name) {
+			tools = append(tools, &agentTool{name: name, agent: agent})
+		}
 	}
 	return tools
 }
 
-func (a *BaseAgent) executeTool(tc ToolCall) (any, error) {
-	if agent, ok := a.managedAgents[tc.Name]; ok {
-		taskArg, _ := tc.Arguments["task"].(string)
-		result, err := agent.Run(context.Background(), taskArg)
+type allowedToolsKey struct{}
+
+// startAllowedTools sets the run's tool allowlist, from options and any
+// inherited from the run that started this one, and attaches it to ctx
+// for the agent's sub-agents.
+func (a *BaseAgent) startAllowedTools(ctx context.Context, options *RunOptions) context.Context {
+	allowed, limited := ctx.Value(allowedToolsKey{}).([]string)
+	if options.Tools != nil {
+		if limited {
+			allowed = slices.DeleteFunc(slices.Clone(options.Tools), func(name string) bool { return !slices.Contains(allowed, name) })
+		} else {
+			allowed, limited = options.Tools, true
+		}
+	}
+	a.allowed = nil
+	if !limited {
+		return ctx
+	}
+	a.allowed = make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		a.allowed[name] = true
+	}
+	return context.WithValue(ctx, allowedToolsKey{}, slices.Clone(allowed))
+}
+
+// isAllowed reports whether the current run may call the named tool.
+func (a *BaseAgent) isAllowed(name string) bool {
+	return a.allowed == nil || a.allowed[name] || name == "final_answer"
+}
+
+// generate calls the model, tracing and logging the call.
+func (a *BaseAgent) generate(ctx context.Context, step int, msgs []Message, opts ...GenerateOption) (*Message, error) {
+	if opt, ok := a.scheduledTemperature(step); ok {
+		opts = append(opts, opt)
+	}
+	if t := a.recoveryState.temperature; t != nil {
+		opts = append(opts, WithTemperature(*t))
+	}
+	if err := checkModel(ctx, a.model); err != nil {
+		return nil, err
+	}
+	if err := a.pace(ctx); err != nil {
+		return nil, err
+	}
+	msgs = a.withoutImages(msgs)
+	ctx, span := a.startChatSpan(ctx)
+	start := time.Now()
+	var resp *Message
+	var err error
+	answer, _ := ctx.Value(answerStreamKey{}).(bool)
+	deltas := EventModelDelta
+	if answer {
+		deltas = EventAnswerDelta
+	}
+	if sm, ok := a.model.(StreamingModel); ok && a.events.HasSubscribers(deltas) {
+		resp, err = a.generateStream(ctx, sm, step, msgs, opts...)
+	} else {
+		resp, err = a.model.Generate(ctx, msgs, opts...)
+		if answer && err == nil && resp != nil && resp.Content != "" {
+			a.events.Publish(AnswerDeltaEvent{Agent: a.name, StepNumber: step, Delta: resp.Content})
+		}
+	}
+	err = redactError(ctx, err)
+	elapsed := time.Since(start)
+	recordLatency(ctx, func(l *StepLatency) { l.Model += elapsed })
+	a.recordModelCall(ctx, msgs, resp, elapsed)
+	a.logGenerate(resp, elapsed, err)
+	endChatSpan(span, resp, err)
+	return resp, err
+}
+
+// generateStream calls a streaming model, publishing content deltas, as
+// answer deltas for a final answer written as text, and assembling the
+// full response. Looked-up secrets are removed from the deltas.
+func (a *BaseAgent) generateStream(ctx context.Context, sm StreamingModel, step int, msgs []Message, opts ...GenerateOption) (*Message, error) {
+	ch, err := sm.GenerateStream(ctx, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Message{Role: RoleAssistant}
+	var content, reasoning strings.Builder
+	answer, _ := ctx.Value(answerStreamKey{}).(bool)
+	secrets := newSecretStream(ctx)
+	publish := func(text string) {
+		if text == "" {
+			return
+		}
+		if answer {
+			a.events.Publish(AnswerDeltaEvent{Agent: a.name, StepNumber: step, Delta: text})
+		} else {
+			a.events.Publish(ModelDeltaEvent{Agent: a.name, StepNumber: step, Delta: text})
+		}
+	}
+	defer func() { publish(secrets.flush()) }()
+	for delta := range ch {
+		if delta.Error != nil {
+			// Keep what was streamed, for steps cut short by a timeout.
+			resp.Content = content.String()
+			return resp, delta.Error
+		}
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			publish(secrets.write(delta.Content))
+		}
+		reasoning.WriteString(delta.Reasoning)
+		resp.ToolCalls = append(resp.ToolCalls, delta.ToolCalls...)
+		if delta.TokenUsage != nil {
+			resp.TokenUsage = delta.TokenUsage
+		}
+	}
+	resp.Content = content.String()
+	resp.Reasoning = reasoning.String()
+	return resp, nil
+}
+
+// endStep publishes a finished action step, logs it and ends its span.
+func (a *BaseAgent) endStep(span trace.Span, step *ActionStep) {
+	if step.Timing.EndTime.IsZero() {
+		step.Timing = NewTiming(step.Timing.StartTime)
+	}
+	a.events.Publish(StepEvent{Agent: a.name, Step: step})
+	if a.tracker != nil {
+		a.tracker.check(step)
+	}
+	a.checkRecovery(step)
+	a.tempState.observe(step)
+	a.logStep(step)
+	endStepSpan(span, step)
+}
+
+// startRun publishes and logs a starting run, starts its span and
+// attaches its audit log to the returned context.
+func (a *BaseAgent) startRun(ctx context.Context, task string, options *RunOptions) (context.Context, trace.Span) {
+	ctx, span := a.startRunSpan(ctx)
+	ctx = WithAuditLog(ctx, &AuditLog{parent: AuditLogFromContext(ctx)})
+	ctx = context.WithValue(ctx, cleanupKey{}, &cleanups{})
+	ctx = a.startWorkspace(ctx, options)
+	ctx = a.startSpawning(ctx)
+	ctx = a.startLimiter(ctx)
+	ctx = a.startSecrets(ctx)
+	if a.profiling {
+		ctx = context.WithValue(ctx, profileKey{}, &profiler{steps: map[int]*StepProfile{}})
+	}
+	a.tracker = nil
+	if b := cmp.Or(options.Budget, a.budget); b != nil {
+		a.tracker = newBudgetTracker(a, *b)
+	}
+	ctx = a.startAllowedModels(ctx, options)
+	ctx = a.startAllowedTools(ctx, options)
+	var systemPrompt string
+	if options.Resume == nil {
+		systemPrompt = a.systemPrompt + a.examplesPrompt(task) + a.localePrompt(options)
+		a.memory.SystemPrompt = systemPrompt
+	} else {
+		systemPrompt = options.Resume.SystemPrompt
+	}
+	a.events.Publish(RunStartedEvent{Agent: a.name, Task: task, Reset: options.Reset, MaxSteps: options.MaxSteps, Resume: options.Resume, Memory: options.Memory, SystemPrompt: systemPrompt})
+	a.logger().Debug("run started", "max_steps", options.MaxSteps)
+	if c, ok := a.modelCapabilities(); ok && !c.Vision && len(options.Images) > 0 {
+		a.logger().Warn("model does not accept images, dropping them", "model", a.model.ModelID(), "images", len(options.Images))
+	}
+	return ctx, span
+}
+
+// endRun runs the run's cleanup hooks, publishes and logs the finished
+// run and ends its span.
+func (a *BaseAgent) endRun(ctx context.Context, span trace.Span, result *RunResult, err error) {
+	a.runCleanups(ctx)
+	a.endRecovery()
+	a.tempState = temperatureState{}
+	a.allowed, a.allowedModels = nil, nil
+	a.events.Publish(RunCompletedEvent{Agent: a.name, Result: result, Err: err})
+	a.logRun(result, err)
+	endRunSpan(span, result, err)
+}
+
+// startMemory prepares memory for a run, either resuming a recorded run or
+// recording task, and returns the number of the run's first action step.
+// The run's budget counts usage from the memory it starts with, and usage
+// restored with WithMemory is left out of the run's result.
+func (a *BaseAgent) startMemory(options *RunOptions, task *TaskStep) int {
+	defer func() {
+		if a.tracker != nil {
+			a.tracker.base = a.memory.TotalTokens()
+		}
+	}()
+	if options.Resume != nil {
+		a.memory = options.Resume.clone()
+		a.restored = TokenUsage{}
+		first := 1
+		if steps := a.memory.ActionSteps(); len(steps) > 0 {
+			first = steps[len(steps)-1].StepNumber + 1
+		}
+		return first
+	}
+	if options.Memory != nil {
+		a.memory = &Memory{SystemPrompt: a.memory.SystemPrompt, Steps: append([]Step(nil), options.Memory.Steps...)}
+		a.restored = a.memory.TotalTokens()
+	} else if options.Reset {
+		a.memory.Reset()
+		a.restored = TokenUsage{}
+	}
+	a.addStep(task)
+	return 1
+}
+
+// runTokens returns the tokens in memory, less those of steps restored
+// with WithMemory, which were spent by an earlier agent.
+func (a *BaseAgent) runTokens() TokenUsage {
+	total := a.memory.TotalTokens()
+	return TokenUsage{
+		InputTokens:       total.InputTokens - a.restored.InputTokens,
+		OutputTokens:      total.OutputTokens - a.restored.OutputTokens,
+		CachedInputTokens: total.CachedInputTokens - a.restored.CachedInputTokens,
+	}
+}
+
+// addStep records a step in memory and publishes it.
+func (a *BaseAgent) addStep(step Step) {
+	a.memory.AddStep(step)
+	a.events.Publish(StepEvent{Agent: a.name, Step: step})
+}
+
+func (a *BaseAgent) executeTool(ctx context.Context, tc ToolCall) (any, error) {
+	ctx, span := a.startToolSpan(ctx, tc)
+	ctx = context.WithValue(ctx, toolNameKey{}, tc.Name)
+	start := time.Now()
+	result, err := a.approveAndCallTool(ctx, tc)
+	err = redactError(ctx, err)
+	elapsed := time.Since(start)
+	recordLatency(ctx, func(l *StepLatency) { l.addTool(tc.Name, elapsed) })
+	recordProfile(ctx, func(p *StepProfile) {
+		p.ToolCalls++
+		p.ToolLatency += elapsed
+		if err != nil {
+			p.ToolErrors++
+		}
+	})
+	a.logToolCall(tc, elapsed, err)
+	endSpan(span, err)
+	return result, err
+}
+
+func (a *BaseAgent) approveAndCallTool(ctx context.Context, tc ToolCall) (any, error) {
+	if !a.isAllowed(tc.Name) {
+		return nil, fmt.Errorf("%w: %s is not allowed in this run", ErrToolCallRejected, tc.Name)
+	}
+	if a.approveTool != nil && tc.Name != "final_answer" {
+		ok, err := a.approveTool(ctx, tc)
 		if err != nil {
 			return nil, err
 		}
-		return result.Output, nil
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrToolCallRejected, tc.Name)
+		}
+	}
+	return a.watchTool(ctx, tc, func(ctx context.Context) (any, error) {
+		return a.callTool(ctx, tc)
+	})
+}
+
+func (a *BaseAgent) callTool(ctx context.Context, tc ToolCall) (any, error) {
+	if agent, ok := a.managedAgents[tc.Name]; ok {
+		taskArg, _ := tc.Arguments["task"].(string)
+		return runManagedAgent(ctx, tc.Name, agent, taskArg)
 	}
 
 	tool, ok := a.tools.Get(tc.Name)
 	if !ok {
 		return nil, fmt.Errorf("unknown tool: %s", tc.Name)
 	}
+	if p := a.takePrefetch(ctx, tc); p != nil {
+		return p.result, p.err
+	}
+	if ct, ok := tool.(ContextTool); ok {
+		return ct.ExecuteContext(ctx, tc.Arguments)
+	}
 	return tool.Execute(tc.Arguments)
 }
 
+// runManagedAgent runs a managed agent for a tool call and records its
+// usage, citations and artifacts on the calling step.
+func runManagedAgent(ctx context.Context, name string, agent Agent, task string, opts ...RunOption) (any, error) {
+	result, err := agent.Run(ctx, task, opts...)
+	if err != nil {
+		return nil, err
+	}
+	recordAgentUsage(ctx, name, result)
+	for _, c := range result.Citations {
+		RecordCitation(ctx, c)
+	}
+	for _, art := range result.Artifacts {
+		RecordArtifact(ctx, art)
+	}
+	return result.Output, nil
+}
+
+// boundTool routes calls made by executed code through the agent, so they
+// are traced and managed agents run under the step's context.
+type boundTool struct {
+	Tool
+	ctx   context.Context
+	agent *BaseAgent
+}
+
+func (t *boundTool) Execute(args map[string]any) (any, error) {
+	return t.agent.executeTool(t.ctx, ToolCall{Name: t.Name(), Arguments: args})
+}
+
 type agentTool struct {
 	name  string
 	agent Agent
@@ -256,11 +837,6 @@ func (t *agentTool) Execute(args map[string]any) (any, error) {
 	return result.Output, nil
 }
 
-// CodeExecutor runs code and returns results.
-type CodeExecutor interface {
-	Execute(code string, state map[string]any) (output any, logs string, err error)
-}
-
 // CodeAgent executes actions as code.
 type CodeAgent struct {
 	BaseAgent
@@ -274,8 +850,9 @@ func NewCodeAgent(executor CodeExecutor, opts ...AgentOption) *CodeAgent {
 		BaseAgent: BaseAgent{
 			tools:         NewToolRegistry(),
 			managedAgents: make(map[string]Agent),
-			callbacks:     NewCallbackRegistry(),
+			events:        NewEventBus(),
 			maxSteps:      20,
+			turns:         NewRunQueue(1),
 		},
 		executor:  executor,
 		execState: make(map[string]any),
@@ -286,9 +863,17 @@ func NewCodeAgent(executor CodeExecutor, opts ...AgentOption) *CodeAgent {
 		opt(&a.BaseAgent)
 	}
 
+	if a.codeParser == nil {
+		a.codeParser = DefaultCodeParser()
+	}
 	if a.systemPrompt == "" {
-		a.systemPrompt = defaultCodeAgentPrompt(a.tools)
+		a.systemPrompt = defaultCodeAgentPrompt(a.tools, executorLanguage(executor))
+		if a.packagePolicy != nil && len(a.packagePolicy.Allowed) > 0 && executorLanguage(executor) == "python" {
+			a.systemPrompt += fmt.Sprintf("\n\nYou may install Python packages by writing a line `!pip install <package>` in your code. Allowed packages: %s",
+				strings.Join(a.packagePolicy.Allowed, ", "))
+		}
 	}
+	a.systemPrompt += a.skillsPrompt() + a.outputPrompt() + a.confidencePrompt(true) + a.typedOutputPrompt(true)
 	a.memory = NewMemory(a.systemPrompt)
 
 	return a
@@ -301,95 +886,274 @@ func (a *CodeAgent) Run(ctx context.Context, task string, opts ...RunOption) (*R
 		opt(options)
 	}
 
+	release, err := a.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	ctx, span := a.startRun(ctx, task, options)
+	result, err := a.run(ctx, task, options)
+	a.endRun(ctx, span, result, err)
+	return result, err
+}
+
+func (a *CodeAgent) run(ctx context.Context, task string, options *RunOptions) (*RunResult, error) {
 	startTime := time.Now()
-	if options.Reset {
-		a.memory.Reset()
+	if err := checkModel(ctx, a.model); err != nil {
+		return nil, err
+	}
+	if options.Reset || options.Resume != nil {
 		a.execState = make(map[string]any)
 	}
-	a.memory.AddStep(&TaskStep{Task: task})
+	if se, ok := a.executor.(SessionExecutor); ok {
+		if err := se.Start(ctx); err != nil {
+			return nil, &AgentError{Message: "failed to start executor session", Cause: err}
+		}
+		defer se.Close()
+	}
+	packages := newPackageSession(a.packagePolicy, a.executor)
+	if a.packagePolicy != nil && len(a.packagePolicy.Preinstall) > 0 {
+		if notes := packages.install(ctx, a.packagePolicy.Preinstall); len(notes) > 0 {
+			return nil, &AgentError{Message: "failed to preinstall packages: " + strings.Join(notes, "; ")}
+		}
+	}
+	files, err := stageFiles(ctx, options.Files)
+	if err != nil {
+		return nil, &AgentError{Message: "failed to attach files", Cause: err}
+	}
+	first := a.startMemory(options, &TaskStep{Task: task, Images: options.Images, Files: files})
 
 	var finalOutput any
+	var confidence answerConfidence
 	state := "success"
 
-	for step := 1; step <= options.MaxSteps; step++ {
+	for step := first; step < first+options.MaxSteps; step++ {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
+		stepCtx, stepSpan := a.startStepSpan(ctx, step)
+		stepCtx, cancelStep := a.withStepTimeout(stepCtx)
 		actionStep := &ActionStep{StepNumber: step, Timing: Timing{StartTime: time.Now()}}
+		stepCtx = withLatencyRecorder(stepCtx, &actionStep.Latency)
+		stepCtx = withStepRecorder(stepCtx, actionStep)
+		stepCtx = a.startPrefetch(stepCtx)
 		msgs := a.memory.ToMessages()
 
-		resp, err := a.model.Generate(ctx, msgs, WithStopSequences("Observation:", "</code>"))
+		stops := append([]string{"Observation:"}, a.codeParser.closeTags()...)
+		resp, err := a.sampleAction(stepCtx, actionStep, func() (*Message, error) {
+			return a.generate(stepCtx, step, msgs, WithStopSequences(stops...))
+		}, func(output string) string { return strings.Join(a.codeBlocks(output), "\n\n") })
 		if err != nil {
+			if resp != nil {
+				actionStep.ModelOutput, actionStep.TokenUsage = resp.Content, resp.TokenUsage
+			}
 			actionStep.Error = err
+			timedOut := a.stepTimedOut(ctx, stepCtx, actionStep)
+			cancelStep()
+			redactStep(stepCtx, actionStep)
 			a.memory.AddStep(actionStep)
+			a.endStep(stepSpan, actionStep)
+			if timedOut && a.stepTimeoutPolicy == StepTimeoutAbort {
+				state = "step_timeout"
+				break
+			}
 			continue
 		}
 
 		actionStep.ModelOutput = resp.Content
+		actionStep.Reasoning = resp.Reasoning
 		actionStep.TokenUsage = resp.TokenUsage
 
-		code := parseCodeBlock(resp.Content)
-		if code == "" {
+		codes := a.codeBlocks(resp.Content)
+		if len(codes) == 0 {
+			cancelStep()
 			actionStep.Error = fmt.Errorf("no code block found")
+			redactStep(stepCtx, actionStep)
 			a.memory.AddStep(actionStep)
+			a.endStep(stepSpan, actionStep)
 			continue
 		}
-		actionStep.CodeAction = code
-
-		output, logs, err := a.executor.Execute(code, a.execState)
-		if err != nil {
-			actionStep.Error = err
-			actionStep.Observations = logs
-		} else {
-			actionStep.Observations = logs
-			if isFinalAnswer(code) {
+		actionStep.CodeAction = strings.Join(codes, "\n\n")
+
+		// Run the blocks in order, sharing state, until one fails or gives
+		// the final answer.
+		var observations []string
+		var lastOutput any
+		for _, code := range codes {
+			code, pkgs := extractPipInstalls(code)
+			observations = append(observations, packages.install(stepCtx, pkgs)...)
+
+			res, err := a.execute(stepCtx, step, code)
+			if res != nil {
+				if res.Logs != "" {
+					observations = append(observations, res.Logs)
+				}
+				actionStep.Artifacts = append(actionStep.Artifacts, res.Artifacts...)
+				if a.imageObs {
+					for _, art := range res.Artifacts {
+						if strings.HasPrefix(art.MIMEType, "image/") && len(art.Data) > 0 {
+							actionStep.ObservationImages = append(actionStep.ObservationImages, art.Data)
+						}
+					}
+				}
+				if res.State != nil {
+					a.execState = res.State
+				}
+			}
+			if err != nil {
+				actionStep.Error = err
+				break
+			}
+			if res != nil && res.IsFinal {
+				output, conf, err := a.takeConfidence(res.Output, nil)
+				if err == nil {
+					output, err = a.takeOutput(stepCtx, output, nil, actionStep)
+				}
+				if err == nil && !conf.abstained {
+					err = a.checkFinalAnswer(stepCtx, output, actionStep)
+				}
+				if err != nil {
+					actionStep.Error = err
+					break
+				}
 				actionStep.IsFinal = true
 				finalOutput = output
+				confidence = conf
+				break
 			}
+			lastOutput = nil
+			if res != nil {
+				lastOutput = res.Output
+			}
+		}
+		if actionStep.Error == nil && !actionStep.IsFinal && lastOutput != nil {
+			observations = append(observations, fmt.Sprintf("Last output from code snippet:\n%v", lastOutput))
 		}
+		actionStep.Observations = redactSecrets(stepCtx, strings.Join(observations, "\n"))
+		timedOut := !actionStep.IsFinal && a.stepTimedOut(ctx, stepCtx, actionStep)
+		cancelStep()
 
 		actionStep.Timing = NewTiming(actionStep.Timing.StartTime)
+		redactStep(stepCtx, actionStep)
 		a.memory.AddStep(actionStep)
-		a.callbacks.Trigger(actionStep)
+		a.endStep(stepSpan, actionStep)
 
 		if actionStep.IsFinal {
-			a.memory.AddStep(&FinalAnswerStep{Output: finalOutput})
+			a.addStep(&FinalAnswerStep{Output: finalOutput})
+			break
+		}
+		if timedOut && a.stepTimeoutPolicy == StepTimeoutAbort {
+			state = "step_timeout"
+			break
+		}
+		if a.tracker.stop() {
 			break
 		}
 	}
 
-	if finalOutput == nil {
+	if finalOutput == nil && state == "success" {
 		state = "max_steps_error"
+		if a.tracker.stop() {
+			state = "budget_exceeded"
+		}
 	}
 
-	tokens := a.memory.TotalTokens()
+	tokens := a.runTokens()
 	return &RunResult{
-		Output:     finalOutput,
-		State:      state,
-		Steps:      a.memory.Steps,
-		TokenUsage: &tokens,
-		Timing:     NewTiming(startTime),
+		Output:         finalOutput,
+		State:          state,
+		Steps:          a.memory.CopySteps(),
+		Artifacts:      a.memory.Artifacts(),
+		Citations:      a.memory.Citations(),
+		TokenUsage:     &tokens,
+		Cost:           tokens.Cost(a.pricing),
+		Latency:        a.memory.Latency(),
+		UsageBreakdown: a.memory.UsageBreakdown(a.pricing),
+		Profile:        a.profile(ctx),
+		Audit:          AuditLogFromContext(ctx).Entries(),
+		Confidence:     confidence.score,
+		Abstained:      confidence.abstained,
+		Timing:         NewTiming(startTime),
 	}, nil
 }
 
-func parseCodeBlock(text string) string {
-	// Try <code>...</code> pattern (may be cut off by stop sequence)
-	re := regexp.MustCompile(`(?s)<code>(.*?)(?:</code>|$)`)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 && strings.TrimSpace(matches[1]) != "" {
-		return strings.TrimSpace(matches[1])
+// execute runs code on the executor with the agent's tools attached,
+// streaming its output to the log callback if one is set.
+func (a *CodeAgent) execute(ctx context.Context, step int, code string) (*ExecutionResult, error) {
+	language := executorLanguage(a.executor)
+	ctx, span := a.startCodeSpan(ctx, language)
+	start := time.Now()
+	res, err := a.runCode(ctx, step, code)
+	err = redactError(ctx, err)
+	a.auditExecution(ctx, language, code, res, err)
+	elapsed := time.Since(start)
+	recordLatency(ctx, func(l *StepLatency) { l.Execution += elapsed })
+	a.logExecution(step, language, elapsed, err)
+	endSpan(span, err)
+	return res, err
+}
+
+// auditExecution records a code execution and the files it wrote.
+func (a *CodeAgent) auditExecution(ctx context.Context, language, code string, res *ExecutionResult, err error) {
+	entry := AuditEntry{Kind: AuditCodeExecution, Source: language, Detail: code}
+	if err != nil {
+		entry.Error = err.Error()
 	}
-	// Try markdown code blocks
-	re = regexp.MustCompile("(?s)```(?:python|py)?\\n?(.*?)(?:```|$)")
-	matches = re.FindStringSubmatch(text)
-	if len(matches) > 1 && strings.TrimSpace(matches[1]) != "" {
-		return strings.TrimSpace(matches[1])
+	RecordAudit(ctx, entry)
+	if res == nil {
+		return
+	}
+	for _, art := range res.Artifacts {
+		if art.Path != "" {
+			RecordAudit(ctx, AuditEntry{Kind: AuditFileWrite, Source: language, Detail: art.Path})
+		}
 	}
-	return ""
+}
+
+func (a *CodeAgent) runCode(ctx context.Context, step int, code string) (*ExecutionResult, error) {
+	tools := a.allTools()
+	for i, t := range tools {
+		tools[i] = &boundTool{Tool: t, ctx: ctx, agent: &a.BaseAgent}
+	}
+	ctx = WithExecutionTools(ctx, tools...)
+	if !a.events.HasSubscribers(EventExecutionLog) {
+		return a.executor.Execute(ctx, code, a.execState)
+	}
+	ch, err := ExecuteStream(ctx, a.executor, code, a.execState)
+	if err != nil {
+		return nil, err
+	}
+	var logs strings.Builder
+	for delta := range ch {
+		if delta.Done {
+			if delta.Result == nil && delta.Error != nil && logs.Len() > 0 {
+				// Keep the output so far, for steps cut short by a timeout.
+				return &ExecutionResult{Logs: logs.String()}, delta.Error
+			}
+			return delta.Result, delta.Error
+		}
+		logs.WriteString(delta.Log)
+		logs.WriteByte('\n')
+		a.events.Publish(ExecutionLogEvent{Agent: a.name, StepNumber: step, Line: redactSecrets(ctx, delta.Log)})
+	}
+	return nil, fmt.Errorf("execution stream closed without a result")
+}
+
+// codeBlocks returns the code to execute from model output: every block
+// not tagged with a language other than the executor's.
+func (a *CodeAgent) codeBlocks(text string) []string {
+	language := executorLanguage(a.executor)
+	var codes []string
+	for _, b := range a.codeParser.Parse(text) {
+		if !b.Tagged || b.Language == language {
+			codes = append(codes, b.Code)
+		}
+	}
+	return codes
 }
 
 func isFinalAnswer(code string) bool {
@@ -405,7 +1169,53 @@ Available tools:
 Always use tools when needed. Call final_answer when done.`, tools.ToCodePrompt())
 }
 
-func defaultCodeAgentPrompt(tools *ToolRegistry) string {
+// executorLanguage returns the language e runs, defaulting to Python.
+func executorLanguage(e CodeExecutor) string {
+	if le, ok := e.(LanguageExecutor); ok {
+		return le.Language()
+	}
+	return "python"
+}
+
+// defaultCodeAgentPrompt returns the system prompt for a CodeAgent whose
+// executor runs language. Languages without a dedicated prompt get a
+// generic one naming the language rather than the Python prompt.
+func defaultCodeAgentPrompt(tools *ToolRegistry, language string) string {
+	switch language {
+	case "python", "":
+	case "bash":
+		return `You are an expert assistant who solves tasks using shell commands.
+
+Write bash scripts in <code></code> blocks. Command output is returned to you as observations.
+Run final_answer "<result>" when done.
+
+Example:
+Thought: I need to find how much disk space is free.
+<code>
+df -h /
+</code>`
+	case "javascript":
+		return fmt.Sprintf(`You are an expert assistant who solves tasks using JavaScript.
+
+Write JavaScript in <code></code> blocks. Use console.log() for intermediate results.
+Declare values you want to keep between steps with var; let and const do not persist.
+Call final_answer(result) when done.
+
+Available tools as functions, called with an object of named arguments:
+%s
+
+Example:
+Thought: I need to search for information.
+<code>
+var result = web_search({query: "query"});
+console.log(result);
+</code>`, tools.toJSPrompt())
+	default:
+		return fmt.Sprintf(`You are an expert assistant who solves tasks using %s code.
+
+Write %s code in <code></code> blocks. Its output is returned to you as observations.
+Call final_answer with the result when done.`, language, language)
+	}
 	return fmt.Sprintf(`You are an expert assistant who solves tasks using code.
 
 Write Python code in <code></code> blocks. Use print() for intermediate results.
@@ -0,0 +1,31 @@
+package neko
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultCodeAgentPromptLanguage(t *testing.T) {
+	tools := NewToolRegistry()
+	tools.Register(NewFinalAnswerTool())
+
+	tests := []struct {
+		language string
+		want     string
+		notWant  string
+	}{
+		{"python", "Write Python code", ""},
+		{"bash", "Write bash scripts", "Python"},
+		{"javascript", "function final_answer({answer})", "Python"},
+		{"ruby", "Write ruby code", "Python"},
+	}
+	for _, tt := range tests {
+		prompt := defaultCodeAgentPrompt(tools, tt.language)
+		if !strings.Contains(prompt, tt.want) {
+			t.Errorf("%s prompt lacks %q:\n%s", tt.language, tt.want, prompt)
+		}
+		if tt.notWant != "" && strings.Contains(prompt, tt.notWant) {
+			t.Errorf("%s prompt mentions %q:\n%s", tt.language, tt.notWant, prompt)
+		}
+	}
+}
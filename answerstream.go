@@ -0,0 +1,122 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// AnswerDeltaEvent carries a chunk of a final answer the model writes as
+// text, with WithStreamedFinalAnswer.
+type AnswerDeltaEvent struct {
+	Agent      string
+	StepNumber int
+	Delta      string
+}
+
+func (AnswerDeltaEvent) EventType() string { return EventAnswerDelta }
+
+// WithStreamedFinalAnswer sets whether a ToolCallingAgent has the model
+// write its forced final answer, on the last step a run allows, as plain
+// text instead of a final_answer call, so the answer streams to clients
+// as AnswerDeltaEvents while it is generated; see RunStream. It only
+// streams with a StreamingModel, and is ignored with WithConfidence or
+// with forced final answers disabled.
+func WithStreamedFinalAnswer(enabled bool) AgentOption {
+	return func(a *BaseAgent) { a.streamAnswer = enabled }
+}
+
+// streamsAnswer reports whether the answer forced on the last step is
+// written as text.
+func (a *BaseAgent) streamsAnswer() bool {
+	return a.streamAnswer && a.confidence == nil
+}
+
+type answerStreamKey struct{}
+
+// generateAnswer asks the model to write the final answer as text,
+// publishing it as it streams, and returns the response with the answer
+// as a final_answer call.
+func (a *BaseAgent) generateAnswer(ctx context.Context, step int, msgs []Message) (*Message, error) {
+	msgs = append(msgs, Message{Role: RoleUser, Content: "This is the last step allowed. Write your final answer now, with the best answer you can give from what you have found so far. Write only the answer, for the user, without calling tools."})
+	resp, err := a.generate(context.WithValue(ctx, answerStreamKey{}, true), step, msgs)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if resp.Content == "" {
+		return resp, fmt.Errorf("the model wrote an empty final answer")
+	}
+	resp.ToolCalls = []ToolCall{{ID: fmt.Sprintf("answer_%d", step), Name: "final_answer", Arguments: map[string]any{"answer": resp.Content}}}
+	return resp, nil
+}
+
+// AnswerStream is a run started with RunStream.
+type AnswerStream struct {
+	*RunHandle
+	deltas chan string
+}
+
+// RunStream starts agent on task like RunAsync, and streams the run's
+// final answer: chunks of the answer are sent on Deltas as the model
+// writes it, with WithStreamedFinalAnswer, or the whole answer once it is
+// given otherwise. Deltas is closed when the run ends. Answers are only
+// streamed from agents with an Events method, such as ToolCallingAgent.
+func RunStream(ctx context.Context, agent Agent, task string, opts ...RunOption) *AnswerStream {
+	s := &AnswerStream{deltas: make(chan string, 64)}
+	src, ok := agent.(interface{ Events() *EventBus })
+	if !ok {
+		s.RunHandle = RunAsync(ctx, agent, task, opts...)
+		close(s.deltas)
+		return s
+	}
+	var (
+		mu       sync.Mutex
+		streamed bool
+		ended    bool
+	)
+	send := func(delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !ended {
+			s.deltas <- delta
+		}
+	}
+	bus := src.Events()
+	unsubAnswer := bus.Subscribe(EventAnswerDelta, func(e Event) {
+		ev := e.(AnswerDeltaEvent)
+		if ev.Agent != agent.Name() {
+			return // a managed agent's answer
+		}
+		mu.Lock()
+		streamed = true
+		mu.Unlock()
+		send(ev.Delta)
+	})
+	unsubDone := bus.Subscribe(EventRunCompleted, func(e Event) {
+		ev := e.(RunCompletedEvent)
+		if ev.Agent != agent.Name() {
+			return
+		}
+		mu.Lock()
+		whole := !streamed && ev.Result != nil && ev.Result.Output != nil
+		mu.Unlock()
+		if whole {
+			send(fmt.Sprint(ev.Result.Output))
+		}
+	})
+	s.RunHandle = RunAsync(ctx, agent, task, opts...)
+	go func() {
+		<-s.Done()
+		unsubAnswer()
+		unsubDone()
+		mu.Lock()
+		ended = true
+		close(s.deltas)
+		mu.Unlock()
+	}()
+	return s
+}
+
+// Deltas returns the channel the final answer is streamed on. It must be
+// drained for the run to make progress once its buffer is full.
+func (s *AnswerStream) Deltas() <-chan string { return s.deltas }
@@ -0,0 +1,113 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment is a file attached to a task with WithFiles.
+type Attachment struct {
+	Name     string `json:"name"` // the file's base name
+	Path     string `json:"path"` // where it is in the workspace
+	Size     int64  `json:"size"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Preview  string `json:"preview,omitempty"` // the start of a text file
+}
+
+// attachmentDir is the workspace directory attached files are staged in.
+const attachmentDir = "attachments"
+
+// attachmentPreview is how many bytes of a text file the task shows.
+const attachmentPreview = 500
+
+// WithFiles attaches files to the task. They are copied into the run's
+// workspace under attachments/, where tools and executors using the
+// workspace can read them, and the task lists each with its path, size
+// and type, and the start of text files. A run without WithWorkspace
+// gets a temporary workspace for the files.
+func WithFiles(paths ...string) RunOption {
+	return func(o *RunOptions) { o.Files = paths }
+}
+
+// stageFiles copies files into the workspace attached to ctx.
+func stageFiles(ctx context.Context, files []string) ([]Attachment, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	w := WorkspaceFromContext(ctx)
+	if w == nil {
+		return nil, fmt.Errorf("attaching files: no workspace")
+	}
+	attachments := make([]Attachment, 0, len(files))
+	used := make(map[string]bool)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("attaching %s: %w", file, err)
+		}
+		name := filepath.Base(file)
+		p := path.Join(attachmentDir, name)
+		for i := 2; used[p]; i++ {
+			ext := path.Ext(name)
+			p = path.Join(attachmentDir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+		}
+		used[p] = true
+		if err := w.WriteFile(p, data); err != nil {
+			return nil, fmt.Errorf("attaching %s: %w", file, err)
+		}
+		attachments = append(attachments, describeAttachment(name, p, data))
+	}
+	return attachments, nil
+}
+
+// describeAttachment returns the attachment of data staged at p.
+func describeAttachment(name, p string, data []byte) Attachment {
+	a := Attachment{Name: name, Path: p, Size: int64(len(data)), MIMEType: mime.TypeByExtension(path.Ext(name))}
+	if a.MIMEType == "" {
+		a.MIMEType = http.DetectContentType(data)
+	}
+	if isText(a.MIMEType) && utf8.Valid(data) {
+		preview := truncateBytes(string(data), attachmentPreview)
+		if len(preview) < len(data) {
+			preview += "\n..."
+		}
+		a.Preview = strings.TrimRight(preview, "\n")
+	}
+	return a
+}
+
+// isText reports whether files of a MIME type are readable as text.
+func isText(mimeType string) bool {
+	t, _, _ := mime.ParseMediaType(mimeType)
+	if strings.HasPrefix(t, "text/") {
+		return true
+	}
+	switch t {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml", "application/toml", "application/javascript", "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// attachmentsPrompt describes attached files for the task message.
+func attachmentsPrompt(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nAttached files, in the workspace:")
+	for _, a := range attachments {
+		fmt.Fprintf(&sb, "\n- %s (%d bytes, %s)", a.Path, a.Size, a.MIMEType)
+		if a.Preview != "" {
+			fmt.Fprintf(&sb, ", starting:\n```\n%s\n```", a.Preview)
+		}
+	}
+	return sb.String()
+}
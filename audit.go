@@ -0,0 +1,139 @@
+package neko
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit entry kinds.
+const (
+	AuditHTTP          = "http"
+	AuditCommand       = "command"
+	AuditFileWrite     = "file_write"
+	AuditCodeExecution = "code_execution"
+)
+
+// AuditEntry records one external side effect of a run.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Source string    `json:"source"` // tool or executor responsible
+	Detail string    `json:"detail"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// AuditTrail is the ordered list of a run's audit entries.
+type AuditTrail []AuditEntry
+
+// Filter returns the entries of the given kind.
+func (t AuditTrail) Filter(kind string) AuditTrail {
+	var out AuditTrail
+	for _, e := range t {
+		if e.Kind == kind {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// AuditLog collects audit entries during a run. Agents create one per
+// run and attach it to the context passed to tools and executors; a
+// managed agent's log also forwards its entries to the calling agent's.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries AuditTrail
+	parent  *AuditLog
+}
+
+// NewAuditLog creates an empty audit log.
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// Record appends an entry, stamping its time if unset.
+func (l *AuditLog) Record(e AuditEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for ; l != nil; l = l.parent {
+		l.mu.Lock()
+		l.entries = append(l.entries, e)
+		l.mu.Unlock()
+	}
+}
+
+// Entries returns a copy of the recorded entries.
+func (l *AuditLog) Entries() AuditTrail {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append(AuditTrail(nil), l.entries...)
+}
+
+type auditKey struct{}
+
+// WithAuditLog attaches l to ctx.
+func WithAuditLog(ctx context.Context, l *AuditLog) context.Context {
+	return context.WithValue(ctx, auditKey{}, l)
+}
+
+// AuditLogFromContext returns the audit log attached to ctx, or nil.
+func AuditLogFromContext(ctx context.Context) *AuditLog {
+	l, _ := ctx.Value(auditKey{}).(*AuditLog)
+	return l
+}
+
+// RecordAudit records e in the audit log attached to ctx, if any. Tools
+// and executors call it for side effects they perform.
+func RecordAudit(ctx context.Context, e AuditEntry) {
+	if l := AuditLogFromContext(ctx); l != nil {
+		l.Record(e)
+	}
+}
+
+// NewAuditTransport wraps base, or http.DefaultTransport if nil, so that
+// every request made with a context carrying an audit log is recorded
+// under source. Query parameters that look like credentials are redacted.
+func NewAuditTransport(base http.RoundTripper, source string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &auditTransport{base: base, source: source}
+}
+
+type auditTransport struct {
+	base   http.RoundTripper
+	source string
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	entry := AuditEntry{Kind: AuditHTTP, Source: t.source, Detail: req.Method + " " + redactURL(req.URL)}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Detail += " -> " + resp.Status
+	}
+	RecordAudit(req.Context(), entry)
+	return resp, err
+}
+
+// redactURL renders u with passwords and credential-like query values
+// replaced.
+func redactURL(u *url.URL) string {
+	c := *u
+	if c.RawQuery != "" {
+		q := c.Query()
+		for k := range q {
+			lk := strings.ToLower(k)
+			if strings.Contains(lk, "key") || strings.Contains(lk, "token") || strings.Contains(lk, "secret") || strings.Contains(lk, "password") {
+				q.Set(k, "REDACTED")
+			}
+		}
+		c.RawQuery = q.Encode()
+	}
+	return c.Redacted()
+}
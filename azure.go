@@ -0,0 +1,71 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/v3/option"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version requested unless
+// set with WithAzureAPIVersion.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// NewAzureOpenAIModel creates a model for a deployment of Azure OpenAI at
+// endpoint, e.g. "https://my-resource.openai.azure.com", authenticating
+// with apiKey or, if apiKey is empty, with WithAzureADToken. Requests are
+// routed to the deployment, whose name is the model ID; agents look up
+// its capabilities under that name, so use WithModelCapabilities if it
+// is not named after its model.
+func NewAzureOpenAIModel(deployment, endpoint, apiKey string, opts ...OpenAIOption) *OpenAIModel {
+	base := strings.TrimRight(endpoint, "/") + "/openai/deployments/" + deployment + "/"
+	clientOpts := []option.RequestOption{
+		option.WithBaseURL(base),
+		option.WithQuery("api-version", DefaultAzureAPIVersion),
+		// Azure takes keys in Api-Key; never send OPENAI_API_KEY.
+		option.WithHeaderDel("Authorization"),
+	}
+	if apiKey != "" {
+		clientOpts = append(clientOpts, option.WithHeader("Api-Key", apiKey))
+	}
+	m := newOpenAIModel(deployment, opts, clientOpts...)
+	m.provider = "az.ai.openai"
+	return m
+}
+
+// WithAzureAPIVersion sets the Azure OpenAI API version, e.g.
+// "2025-01-01-preview". See DefaultAzureAPIVersion.
+func WithAzureAPIVersion(version string) OpenAIOption {
+	return func(m *OpenAIModel) {
+		m.clientOpts = append(m.clientOpts, option.WithQuery("api-version", version))
+	}
+}
+
+// WithAzureADToken authenticates requests to Azure OpenAI with Microsoft
+// Entra ID (Azure AD) access tokens, which token is called for before
+// each request. With the Azure Identity library:
+//
+//	cred, err := azidentity.NewDefaultAzureCredential(nil)
+//	...
+//	neko.WithAzureADToken(func(ctx context.Context) (string, error) {
+//		t, err := cred.GetToken(ctx, policy.TokenRequestOptions{
+//			Scopes: []string{"https://cognitiveservices.azure.com/.default"},
+//		})
+//		return t.Token, err
+//	})
+//
+// The credential caches tokens until they expire.
+func WithAzureADToken(token func(ctx context.Context) (string, error)) OpenAIOption {
+	return func(m *OpenAIModel) {
+		m.clientOpts = append(m.clientOpts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			t, err := token(req.Context())
+			if err != nil {
+				return nil, fmt.Errorf("azure ad token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+t)
+			return next(req)
+		}))
+	}
+}
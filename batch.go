@@ -0,0 +1,130 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchResult is the outcome of one task of RunAll.
+type BatchResult struct {
+	Index    int // the task's index in the tasks given to RunAll
+	Task     string
+	Result   *RunResult // nil if the task did not run
+	Err      error
+	Duration time.Duration
+}
+
+// BatchOption configures RunAll.
+type BatchOption func(*batch)
+
+type batch struct {
+	concurrency int
+	runOpts     []RunOption
+	progress    func(BatchResult)
+	failFast    bool
+}
+
+// WithBatchConcurrency sets how many tasks run at once, each on its own
+// agent. Defaults to 1.
+func WithBatchConcurrency(n int) BatchOption {
+	return func(b *batch) { b.concurrency = n }
+}
+
+// WithBatchRunOptions adds options to every run, e.g. WithMaxSteps.
+func WithBatchRunOptions(opts ...RunOption) BatchOption {
+	return func(b *batch) { b.runOpts = opts }
+}
+
+// WithBatchProgress calls fn with each task's result as it finishes.
+// Calls are serialized.
+func WithBatchProgress(fn func(BatchResult)) BatchOption {
+	return func(b *batch) { b.progress = fn }
+}
+
+// WithFailFast stops starting tasks after the first one fails. Tasks
+// already running finish; those not started have a nil Result and
+// context.Canceled as their error.
+func WithFailFast() BatchOption {
+	return func(b *batch) { b.failFast = true }
+}
+
+// RunAll runs many independent tasks, e.g. the questions of a CSV file,
+// with bounded concurrency. Agents are not safe for concurrent runs, so
+// newAgent is called once per concurrent slot and each agent runs its
+// tasks one after another, with memory reset between them. The results
+// are in the order of tasks. The error joins the errors of the failed
+// tasks, and is also returned if an agent cannot be created or ctx ends
+// before every task has run.
+func RunAll(ctx context.Context, newAgent func() (Agent, error), tasks []string, opts ...BatchOption) ([]BatchResult, error) {
+	b := &batch{concurrency: 1}
+	for _, opt := range opts {
+		opt(b)
+	}
+	results := make([]BatchResult, len(tasks))
+	for i, task := range tasks {
+		results[i] = BatchResult{Index: i, Task: task, Err: context.Canceled}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		agentErr error
+	)
+	for range min(max(b.concurrency, 1), len(tasks)) {
+		agent, err := newAgent()
+		if err != nil {
+			agentErr = fmt.Errorf("create agent: %w", err)
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				start := time.Now()
+				result, err := agent.Run(ctx, tasks[i], b.runOpts...)
+				res := BatchResult{Index: i, Task: tasks[i], Result: result, Err: err, Duration: time.Since(start)}
+				mu.Lock()
+				results[i] = res
+				if b.progress != nil {
+					b.progress(res)
+				}
+				mu.Unlock()
+				if err != nil && b.failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+	if agentErr == nil {
+	feed:
+		for i := range tasks {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	if agentErr != nil {
+		errs = append(errs, agentErr)
+	}
+	for _, r := range results {
+		if r.Err != nil && (r.Result != nil || !errors.Is(r.Err, context.Canceled)) {
+			errs = append(errs, fmt.Errorf("task %d: %w", r.Index, r.Err))
+		}
+	}
+	if len(errs) == 0 && ctx.Err() != nil {
+		errs = append(errs, context.Cause(ctx))
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,345 @@
+// Package bedrock runs neko agents on models hosted in Amazon Bedrock,
+// such as Claude and Llama, through the Converse API. Requests are signed
+// with the IAM credentials of an aws.Config rather than an API key:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"))
+//	...
+//	model := bedrock.NewModel(cfg, "anthropic.claude-3-5-sonnet-20240620-v1:0")
+//	agent := neko.NewToolCallingAgent(neko.WithModel(model))
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gocnn/neko"
+)
+
+// Model implements neko.StreamingModel with the Bedrock Converse API.
+type Model struct {
+	client      *bedrockruntime.Client
+	modelID     string
+	temperature float64
+	maxTokens   int64
+	noTools     atomic.Bool // the model has no tool use
+	httpClient  *http.Client
+}
+
+// Option configures Model.
+type Option func(*Model)
+
+// WithTemperature sets the default temperature.
+func WithTemperature(t float64) Option {
+	return func(m *Model) { m.temperature = t }
+}
+
+// WithMaxTokens sets the default max output tokens.
+func WithMaxTokens(n int64) Option {
+	return func(m *Model) { m.maxTokens = n }
+}
+
+// WithToolCalling sets whether the model supports tool use. It defaults
+// to true; a model that rejects a request with tools is also marked as
+// unsupported, so agents prompt for tool calls instead.
+func WithToolCalling(enabled bool) Option {
+	return func(m *Model) { m.noTools.Store(!enabled) }
+}
+
+// WithHTTPClient sends requests with c instead of cfg's HTTP client, e.g.
+// to go through a proxy or add tracing.
+func WithHTTPClient(c *http.Client) Option {
+	return func(m *Model) { m.httpClient = c }
+}
+
+// NewModel creates a model for the Bedrock model or inference profile
+// modelID, calling Bedrock in cfg's region with cfg's credentials.
+func NewModel(cfg aws.Config, modelID string, opts ...Option) *Model {
+	m := &Model{
+		modelID:     modelID,
+		temperature: 0.7,
+		maxTokens:   4096,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.client = bedrockruntime.NewFromConfig(cfg, func(o *bedrockruntime.Options) {
+		if m.httpClient != nil {
+			o.HTTPClient = m.httpClient
+		}
+	})
+	return m
+}
+
+func (m *Model) ModelID() string { return m.modelID }
+
+func (m *Model) Provider() string { return "aws.bedrock" }
+
+// SupportsToolCalling reports whether the model supports tool use, as set
+// with WithToolCalling or learned from a rejected request.
+func (m *Model) SupportsToolCalling() bool { return !m.noTools.Load() }
+
+// request holds the parts of a Converse request.
+type request struct {
+	system    []types.SystemContentBlock
+	messages  []types.Message
+	inference *types.InferenceConfiguration
+	tools     *types.ToolConfiguration
+}
+
+func (m *Model) request(messages []neko.Message, opts []neko.GenerateOption) (*request, *neko.GenerateOptions) {
+	options := &neko.GenerateOptions{
+		Temperature: m.temperature,
+		MaxTokens:   m.maxTokens,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	req := &request{inference: &types.InferenceConfiguration{
+		Temperature:   aws.Float32(float32(options.Temperature)),
+		MaxTokens:     aws.Int32(int32(options.MaxTokens)),
+		StopSequences: options.StopSequences,
+	}}
+	// Converse takes the system prompt apart and requires the
+	// conversation to alternate, starting and ending with the user.
+	for _, msg := range neko.StrictAlternation(messages) {
+		if msg.Role == neko.RoleSystem {
+			req.system = append(req.system, &types.SystemContentBlockMemberText{Value: msg.Content})
+			continue
+		}
+		role := types.ConversationRoleUser
+		if msg.Role == neko.RoleAssistant {
+			role = types.ConversationRoleAssistant
+		}
+		req.messages = append(req.messages, types.Message{Role: role, Content: contentBlocks(msg)})
+	}
+	if len(options.Tools) > 0 && options.ToolChoice != neko.ToolChoiceNone {
+		req.tools = &types.ToolConfiguration{Tools: convertTools(options.Tools)}
+		switch options.ToolChoice {
+		case "", neko.ToolChoiceAuto:
+		case neko.ToolChoiceRequired:
+			req.tools.ToolChoice = &types.ToolChoiceMemberAny{}
+		default:
+			req.tools.ToolChoice = &types.ToolChoiceMemberTool{Value: types.SpecificToolChoice{Name: aws.String(options.ToolChoice)}}
+		}
+	}
+	return req, options
+}
+
+// contentBlocks converts a message's text and images. Images in formats
+// Bedrock does not accept are left out.
+func contentBlocks(msg neko.Message) []types.ContentBlock {
+	var blocks []types.ContentBlock
+	if strings.TrimSpace(msg.Content) != "" {
+		blocks = append(blocks, &types.ContentBlockMemberText{Value: msg.Content})
+	}
+	for _, img := range msg.Images {
+		format := imageFormat(img)
+		if format == "" {
+			continue
+		}
+		blocks = append(blocks, &types.ContentBlockMemberImage{Value: types.ImageBlock{
+			Format: format,
+			Source: &types.ImageSourceMemberBytes{Value: img},
+		}})
+	}
+	if len(blocks) == 0 {
+		// Converse rejects empty messages.
+		blocks = append(blocks, &types.ContentBlockMemberText{Value: "(empty)"})
+	}
+	return blocks
+}
+
+func imageFormat(img []byte) types.ImageFormat {
+	switch http.DetectContentType(img) {
+	case "image/png":
+		return types.ImageFormatPng
+	case "image/jpeg":
+		return types.ImageFormatJpeg
+	case "image/gif":
+		return types.ImageFormatGif
+	case "image/webp":
+		return types.ImageFormatWebp
+	}
+	return ""
+}
+
+func convertTools(tools []neko.Tool) []types.Tool {
+	result := make([]types.Tool, 0, len(tools))
+	for _, tool := range tools {
+		props := make(map[string]any)
+		required := []string{}
+		for name, input := range tool.Inputs() {
+			props[name] = map[string]any{
+				"type":        input.Type,
+				"description": input.Description,
+			}
+			if input.Required {
+				required = append(required, name)
+			}
+		}
+		result = append(result, &types.ToolMemberToolSpec{Value: types.ToolSpecification{
+			Name:        aws.String(tool.Name()),
+			Description: aws.String(tool.Description()),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(map[string]any{
+				"type":       "object",
+				"properties": props,
+				"required":   required,
+			})},
+		}})
+	}
+	return result
+}
+
+// requestError normalizes an error from Bedrock with
+// neko.NormalizeProviderError, marking the model as lacking tool use if
+// it rejected a request for its tools.
+func (m *Model) requestError(err error, options *neko.GenerateOptions) error {
+	if len(options.Tools) > 0 {
+		msg := strings.ToLower(err.Error())
+		for _, s := range []string{"doesn't support tool use", "does not support tool use", "tool use is not supported", "doesn't support the toolconfig"} {
+			if strings.Contains(msg, s) {
+				m.noTools.Store(true)
+				err = fmt.Errorf("%w: %w", neko.ErrToolCallingUnsupported, err)
+				break
+			}
+		}
+	}
+	status := 0
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		status = respErr.HTTPStatusCode()
+	}
+	return neko.NormalizeProviderError(status, err)
+}
+
+// Generate sends messages to Bedrock and returns the response.
+func (m *Model) Generate(ctx context.Context, messages []neko.Message, opts ...neko.GenerateOption) (*neko.Message, error) {
+	req, options := m.request(messages, opts)
+	resp, err := m.client.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId:         aws.String(m.modelID),
+		System:          req.system,
+		Messages:        req.messages,
+		InferenceConfig: req.inference,
+		ToolConfig:      req.tools,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock converse failed: %w", m.requestError(err, options))
+	}
+	out, ok := resp.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return nil, fmt.Errorf("no response message returned")
+	}
+	result := &neko.Message{Role: neko.RoleAssistant, TokenUsage: tokenUsage(resp.Usage)}
+	var content strings.Builder
+	for _, block := range out.Value.Content {
+		switch b := block.(type) {
+		case *types.ContentBlockMemberText:
+			content.WriteString(b.Value)
+		case *types.ContentBlockMemberToolUse:
+			var args map[string]any
+			if b.Value.Input != nil {
+				if err := b.Value.Input.UnmarshalSmithyDocument(&args); err != nil {
+					args = map[string]any{"raw": fmt.Sprint(b.Value.Input)}
+				}
+			}
+			result.ToolCalls = append(result.ToolCalls, neko.ToolCall{
+				ID:        aws.ToString(b.Value.ToolUseId),
+				Name:      aws.ToString(b.Value.Name),
+				Arguments: args,
+			})
+		}
+	}
+	result.Content = content.String()
+	if filtered(resp.StopReason) && result.Content == "" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("bedrock converse failed: %w: the response was blocked", neko.ErrContentFiltered)
+	}
+	return result, nil
+}
+
+func filtered(reason types.StopReason) bool {
+	return reason == types.StopReasonContentFiltered || reason == types.StopReasonGuardrailIntervened
+}
+
+func tokenUsage(u *types.TokenUsage) *neko.TokenUsage {
+	if u == nil {
+		return nil
+	}
+	return &neko.TokenUsage{
+		InputTokens:       int(aws.ToInt32(u.InputTokens)),
+		OutputTokens:      int(aws.ToInt32(u.OutputTokens)),
+		CachedInputTokens: int(aws.ToInt32(u.CacheReadInputTokens)),
+	}
+}
+
+// GenerateStream streams a response with the ConverseStream API. Tool
+// calls are delivered once their arguments are complete.
+func (m *Model) GenerateStream(ctx context.Context, messages []neko.Message, opts ...neko.GenerateOption) (<-chan neko.StreamDelta, error) {
+	req, options := m.request(messages, opts)
+	resp, err := m.client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+		ModelId:         aws.String(m.modelID),
+		System:          req.system,
+		Messages:        req.messages,
+		InferenceConfig: req.inference,
+		ToolConfig:      req.tools,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock converse failed: %w", m.requestError(err, options))
+	}
+	stream := resp.GetStream()
+
+	ch := make(chan neko.StreamDelta)
+	go func() {
+		defer close(ch)
+		defer stream.Close()
+
+		var (
+			call  *neko.ToolCall // the tool call being streamed
+			input strings.Builder
+			usage *neko.TokenUsage
+		)
+		for event := range stream.Events() {
+			switch e := event.(type) {
+			case *types.ConverseStreamOutputMemberContentBlockStart:
+				if start, ok := e.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+					call = &neko.ToolCall{ID: aws.ToString(start.Value.ToolUseId), Name: aws.ToString(start.Value.Name)}
+					input.Reset()
+				}
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				switch d := e.Value.Delta.(type) {
+				case *types.ContentBlockDeltaMemberText:
+					if d.Value != "" {
+						ch <- neko.StreamDelta{Content: d.Value}
+					}
+				case *types.ContentBlockDeltaMemberToolUse:
+					input.WriteString(aws.ToString(d.Value.Input))
+				}
+			case *types.ConverseStreamOutputMemberContentBlockStop:
+				if call != nil {
+					if input.Len() > 0 && json.Unmarshal([]byte(input.String()), &call.Arguments) != nil {
+						call.Arguments = map[string]any{"raw": input.String()}
+					}
+					ch <- neko.StreamDelta{ToolCalls: []neko.ToolCall{*call}}
+					call = nil
+				}
+			case *types.ConverseStreamOutputMemberMetadata:
+				usage = tokenUsage(e.Value.Usage)
+			}
+		}
+		if err := stream.Err(); err != nil {
+			ch <- neko.StreamDelta{Error: m.requestError(err, options), Done: true}
+			return
+		}
+		ch <- neko.StreamDelta{Done: true, TokenUsage: usage}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,97 @@
+package neko
+
+import "fmt"
+
+// Budget sets token and cost limits that trigger BudgetWarning events.
+// Unless Stop is set, the run is not stopped when a limit is reached;
+// subscribers decide how to react.
+type Budget struct {
+	MaxTokens  int       // total input and output tokens; zero disables
+	MaxCost    float64   // US dollars, computed with WithPricing; zero disables
+	Thresholds []float64 // fractions of the limits to warn at; defaults to 0.5, 0.8 and 1
+	// Stop ends the run after the step that reaches a limit, with the
+	// "budget_exceeded" state.
+	Stop bool
+}
+
+// BudgetWarning is published the first time a run's usage reaches a
+// threshold of its budget.
+type BudgetWarning struct {
+	Kind       string  `json:"kind"` // "tokens" or "cost"
+	Threshold  float64 `json:"threshold"`
+	Used       float64 `json:"used"`
+	Limit      float64 `json:"limit"`
+	StepNumber int     `json:"step_number"`
+}
+
+func (w *BudgetWarning) EventType() string { return EventBudgetWarning }
+
+func (w *BudgetWarning) String() string {
+	return fmt.Sprintf("%s budget at %.0f%% (%g of %g)", w.Kind, w.Threshold*100, w.Used, w.Limit)
+}
+
+// WithBudget emits BudgetWarning events as the run's token usage or cost
+// crosses the budget's thresholds.
+func WithBudget(b Budget) AgentOption {
+	return func(a *BaseAgent) { a.budget = &b }
+}
+
+// WithRunBudget replaces the agent's budget, if any, for one run.
+func WithRunBudget(b Budget) RunOption {
+	return func(o *RunOptions) { o.Budget = &b }
+}
+
+type budgetTracker struct {
+	budget   Budget
+	agent    *BaseAgent
+	fired    map[string]bool
+	base     TokenUsage // usage in memory before the run's first step
+	exceeded bool
+}
+
+// newBudgetTracker tracks b for a run of a. The run's usage is counted
+// from the memory it starts with; see startMemory.
+func newBudgetTracker(a *BaseAgent, b Budget) *budgetTracker {
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = []float64{0.5, 0.8, 1}
+	}
+	return &budgetTracker{budget: b, agent: a, fired: make(map[string]bool)}
+}
+
+// check warns about thresholds reached after step.
+func (t *budgetTracker) check(step *ActionStep) {
+	total := t.agent.memory.TotalTokens()
+	used := TokenUsage{
+		InputTokens:  total.InputTokens - t.base.InputTokens,
+		OutputTokens: total.OutputTokens - t.base.OutputTokens,
+	}
+	stepNumber := step.StepNumber
+	if t.budget.MaxTokens > 0 {
+		t.warn("tokens", float64(used.Total()), float64(t.budget.MaxTokens), stepNumber)
+	}
+	if t.budget.MaxCost > 0 {
+		t.warn("cost", used.Cost(t.agent.pricing), t.budget.MaxCost, stepNumber)
+	}
+}
+
+// stop reports whether the run must end because a limit was reached.
+func (t *budgetTracker) stop() bool {
+	return t != nil && t.budget.Stop && t.exceeded
+}
+
+func (t *budgetTracker) warn(kind string, used, limit float64, stepNumber int) {
+	if used >= limit && t.budget.Stop && !t.exceeded {
+		t.exceeded = true
+		t.agent.logger().Warn("budget exceeded, stopping run", "kind", kind, "used", used, "limit", limit)
+	}
+	for _, th := range t.budget.Thresholds {
+		key := fmt.Sprintf("%s:%g", kind, th)
+		if used < th*limit || t.fired[key] {
+			continue
+		}
+		t.fired[key] = true
+		w := &BudgetWarning{Kind: kind, Threshold: th, Used: used, Limit: limit, StepNumber: stepNumber}
+		t.agent.logger().Warn("budget threshold reached", "kind", kind, "threshold", th, "used", used, "limit", limit)
+		t.agent.events.Publish(w)
+	}
+}
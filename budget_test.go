@@ -0,0 +1,90 @@
+package neko_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+// restoredMemory is a finished conversation whose step used 1000 tokens.
+func restoredMemory() *neko.Memory {
+	m := neko.NewMemory("earlier")
+	m.AddStep(&neko.TaskStep{Task: "earlier task"})
+	m.AddStep(&neko.ActionStep{StepNumber: 1, ModelOutput: "earlier answer", TokenUsage: &neko.TokenUsage{InputTokens: 900, OutputTokens: 100}})
+	return m
+}
+
+func finalAnswer(answer string, usage *neko.TokenUsage) *neko.Message {
+	return &neko.Message{
+		Role:       neko.RoleAssistant,
+		ToolCalls:  []neko.ToolCall{{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": answer}}},
+		TokenUsage: usage,
+	}
+}
+
+func TestWithMemoryUsageNotCounted(t *testing.T) {
+	agent := neko.NewToolCallingAgent(
+		neko.WithModel(testutil.NewReplayModel(finalAnswer("done", &neko.TokenUsage{InputTokens: 40, OutputTokens: 10}))),
+		neko.WithBudget(neko.Budget{MaxTokens: 100, Stop: true}),
+	)
+	var warnings []*neko.BudgetWarning
+	agent.Events().Subscribe(neko.EventBudgetWarning, func(e neko.Event) {
+		warnings = append(warnings, e.(*neko.BudgetWarning))
+	})
+	result, err := agent.Run(context.Background(), "task", neko.WithMemory(restoredMemory()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.State != "success" {
+		t.Errorf("state = %q, want success", result.State)
+	}
+	if got := result.TokenUsage.Total(); got != 50 {
+		t.Errorf("result tokens = %d, want 50", got)
+	}
+	if len(warnings) != 1 || warnings[0].Used != 50 {
+		t.Errorf("warnings = %+v, want one at 50 tokens", warnings)
+	}
+}
+
+func TestWithMemoryUsageNotCountedOnNextTurn(t *testing.T) {
+	agent := neko.NewToolCallingAgent(neko.WithModel(testutil.NewReplayModel(
+		finalAnswer("one", &neko.TokenUsage{InputTokens: 40, OutputTokens: 10}),
+		finalAnswer("two", &neko.TokenUsage{InputTokens: 20, OutputTokens: 5}),
+	)))
+	if _, err := agent.Run(context.Background(), "one", neko.WithMemory(restoredMemory())); err != nil {
+		t.Fatal(err)
+	}
+	result, err := agent.Run(context.Background(), "two", neko.WithReset(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.TokenUsage.Total(); got != 75 {
+		t.Errorf("result tokens = %d, want 75", got)
+	}
+}
+
+func TestTraceRecordsWithMemorySteps(t *testing.T) {
+	var trace bytes.Buffer
+	agent := neko.NewToolCallingAgent(
+		neko.WithModel(testutil.NewReplayModel(finalAnswer("done", nil))),
+		neko.WithTraceWriter(&trace),
+	)
+	result, err := agent.Run(context.Background(), "task", neko.WithMemory(restoredMemory()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, memory, err := neko.LoadTrace(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := result.Steps
+	if len(memory.Steps) != len(want) {
+		t.Fatalf("trace has %d steps, want %d", len(memory.Steps), len(want))
+	}
+	if task := memory.Steps[0].(*neko.TaskStep).Task; task != "earlier task" {
+		t.Errorf("first step task = %q, want the restored task", task)
+	}
+}
@@ -0,0 +1,112 @@
+package neko
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// CallbackPool runs the callbacks of WithStepCallback, WithRunCallback,
+// WithModelStreamCallback and WithExecutionLogCallback off the run's
+// goroutine, with at most a fixed number running at once, so a slow
+// callback does not delay the run. An agent's callbacks run one at a time,
+// in the order of its events, so the callbacks of a run see its steps in
+// order. Agents without WithCallbackPool share a pool with one worker per
+// CPU.
+type CallbackPool struct {
+	sem    chan struct{}
+	mu     sync.Mutex
+	queues map[any]*callbackQueue
+	wg     sync.WaitGroup
+}
+
+// callbackQueue holds the pending callbacks of one agent.
+type callbackQueue struct {
+	fns      []func()
+	draining bool
+}
+
+// NewCallbackPool creates a pool running up to workers callbacks at once.
+func NewCallbackPool(workers int) *CallbackPool {
+	return &CallbackPool{sem: make(chan struct{}, max(workers, 1)), queues: map[any]*callbackQueue{}}
+}
+
+var defaultCallbackPool = NewCallbackPool(runtime.NumCPU())
+
+// submit queues fn after the pending callbacks of key.
+func (p *CallbackPool) submit(key any, fn func()) {
+	p.wg.Add(1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q := p.queues[key]
+	if q == nil {
+		q = &callbackQueue{}
+		p.queues[key] = q
+	}
+	q.fns = append(q.fns, fn)
+	if !q.draining {
+		q.draining = true
+		go p.drain(key, q)
+	}
+}
+
+// drain runs the callbacks of q in order while holding a worker slot.
+func (p *CallbackPool) drain(key any, q *callbackQueue) {
+	p.sem <- struct{}{}
+	defer func() { <-p.sem }()
+	for {
+		p.mu.Lock()
+		if len(q.fns) == 0 {
+			q.draining = false
+			delete(p.queues, key)
+			p.mu.Unlock()
+			return
+		}
+		fn := q.fns[0]
+		q.fns = q.fns[1:]
+		p.mu.Unlock()
+		fn()
+		p.wg.Done()
+	}
+}
+
+// Wait blocks until every callback queued so far has run.
+func (p *CallbackPool) Wait() { p.wg.Wait() }
+
+// WithCallbackPool runs the agent's callbacks on p instead of the shared
+// pool.
+func WithCallbackPool(p *CallbackPool) AgentOption {
+	return func(a *BaseAgent) { a.callbackPool = p }
+}
+
+// WithSyncCallbacks runs the agent's callbacks on the run's goroutine as
+// events happen, so they have all run when Run returns, e.g. for tests.
+func WithSyncCallbacks(enabled bool) AgentOption {
+	return func(a *BaseAgent) { a.syncCallbacks = enabled }
+}
+
+// WaitCallbacks blocks until the callbacks of the agent's finished runs
+// have run. It also waits for other agents sharing its pool.
+func (a *BaseAgent) WaitCallbacks() {
+	cmp.Or(a.callbackPool, defaultCallbackPool).Wait()
+}
+
+// runCallback runs fn, a callback of the agent's, as configured. A
+// panicking callback is logged and does not affect the run or later
+// callbacks.
+func (a *BaseAgent) runCallback(name string, fn func()) {
+	call := func() {
+		defer func() {
+			if r := recover(); r != nil {
+				a.logger().Error("callback panicked", "callback", name, "panic", fmt.Sprint(r))
+			}
+		}()
+		fn()
+	}
+	if a.syncCallbacks {
+		call()
+		return
+	}
+	cmp.Or(a.callbackPool, defaultCallbackPool).submit(a, call)
+}
@@ -0,0 +1,126 @@
+package neko
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Capabilities describes what a model supports. Agents consult them to
+// fall back instead of failing at runtime: a ToolCallingAgent prompts for
+// tool calls when Tools is false, and images are dropped from the
+// messages sent to models without Vision.
+type Capabilities struct {
+	Tools             bool // native function calling
+	Vision            bool // images in messages
+	JSONMode          bool // responses constrained to JSON
+	ParallelToolCalls bool // several tool calls in one response
+	MaxContext        int  // context window in tokens; zero if unknown
+}
+
+var (
+	capabilitiesMu sync.RWMutex
+	// capabilities holds the known models, keyed by model ID or ID prefix.
+	capabilities = map[string]Capabilities{
+		"gpt-4o":            {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 128000},
+		"gpt-4o-mini":       {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 128000},
+		"gpt-4.1":           {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 1047576},
+		"gpt-4-turbo":       {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 128000},
+		"gpt-4":             {Tools: true, MaxContext: 8192},
+		"gpt-3.5-turbo":     {Tools: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 16385},
+		"o1":                {Tools: true, Vision: true, JSONMode: true, MaxContext: 200000},
+		"o1-mini":           {MaxContext: 128000},
+		"o3":                {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 200000},
+		"o3-mini":           {Tools: true, JSONMode: true, MaxContext: 200000},
+		"o4-mini":           {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 200000},
+		"claude-3-5-sonnet": {Tools: true, Vision: true, ParallelToolCalls: true, MaxContext: 200000},
+		"claude-3-5-haiku":  {Tools: true, ParallelToolCalls: true, MaxContext: 200000},
+		"claude-3-7-sonnet": {Tools: true, Vision: true, ParallelToolCalls: true, MaxContext: 200000},
+		"claude-sonnet-4":   {Tools: true, Vision: true, ParallelToolCalls: true, MaxContext: 200000},
+		"claude-opus-4":     {Tools: true, Vision: true, ParallelToolCalls: true, MaxContext: 200000},
+		"gemini-1.5-pro":    {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 2097152},
+		"gemini-1.5-flash":  {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 1048576},
+		"gemini-2.0-flash":  {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 1048576},
+		"gemini-2.5-pro":    {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 1048576},
+		"gemini-2.5-flash":  {Tools: true, Vision: true, JSONMode: true, ParallelToolCalls: true, MaxContext: 1048576},
+		"deepseek-chat":     {Tools: true, JSONMode: true},
+		"deepseek-reasoner": {JSONMode: true},
+		"llama3":            {MaxContext: 8192},
+	}
+)
+
+// RegisterModelCapabilities sets the capabilities of models whose ID is
+// modelID or starts with it followed by "-", ":" or "@", such as dated
+// or tagged versions, replacing any known capabilities. The longest
+// registered match wins.
+func RegisterModelCapabilities(modelID string, c Capabilities) {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	capabilities[modelID] = c
+}
+
+// ModelCapabilities returns the capabilities of the model with the given
+// ID, ignoring any provider prefix such as "openai/", and false if the
+// model is unknown.
+func ModelCapabilities(modelID string) (Capabilities, bool) {
+	if i := strings.LastIndex(modelID, "/"); i >= 0 {
+		modelID = modelID[i+1:]
+	}
+	capabilitiesMu.RLock()
+	defer capabilitiesMu.RUnlock()
+	if c, ok := capabilities[modelID]; ok {
+		return c, true
+	}
+	var (
+		best  Capabilities
+		found string
+	)
+	for id, c := range capabilities {
+		if len(id) > len(found) && len(modelID) > len(id) && strings.HasPrefix(modelID, id) && strings.ContainsRune("-:@", rune(modelID[len(id)])) {
+			best, found = c, id
+		}
+	}
+	return best, found != ""
+}
+
+// WithModelCapabilities sets the capabilities of the agent's model,
+// overriding those known for its ID.
+func WithModelCapabilities(c Capabilities) AgentOption {
+	return func(a *BaseAgent) { a.capabilities = &c }
+}
+
+// modelCapabilities returns the capabilities of the agent's model, and
+// false if they are unknown, in which case everything is assumed to be
+// supported.
+func (a *BaseAgent) modelCapabilities() (Capabilities, bool) {
+	if a.capabilities != nil {
+		return *a.capabilities, true
+	}
+	if a.model == nil {
+		return Capabilities{}, false
+	}
+	return ModelCapabilities(a.model.ModelID())
+}
+
+// withoutImages returns msgs with images replaced by a note if the
+// agent's model cannot see them.
+func (a *BaseAgent) withoutImages(msgs []Message) []Message {
+	if c, ok := a.modelCapabilities(); !ok || c.Vision {
+		return msgs
+	}
+	var out []Message
+	for i, m := range msgs {
+		if len(m.Images) == 0 {
+			continue
+		}
+		if out == nil {
+			out = append([]Message{}, msgs...)
+		}
+		out[i].Images = nil
+		out[i].Content += fmt.Sprintf("\n[%d image(s) omitted: the model does not accept images]", len(m.Images))
+	}
+	if out == nil {
+		return msgs
+	}
+	return out
+}
@@ -0,0 +1,22 @@
+package neko_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+func TestTaskImagesOmittedWithoutVision(t *testing.T) {
+	model := testutil.NewReplayModel(finalAnswer("no idea", nil))
+	agent := neko.NewToolCallingAgent(neko.WithModel(model), neko.WithModelCapabilities(neko.Capabilities{Tools: true}))
+	if _, err := agent.Run(context.Background(), "what is this?", neko.WithImages(testImage)); err != nil {
+		t.Fatal(err)
+	}
+	images, content := taskImages(t, model)
+	if len(images) != 0 || !strings.Contains(content, "1 image(s) omitted") {
+		t.Errorf("task message = %q with %d images, want the image replaced by a note", content, len(images))
+	}
+}
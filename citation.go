@@ -0,0 +1,59 @@
+package neko
+
+import (
+	"context"
+	"sync"
+)
+
+// Citation is a source consulted during a run: a web page visited, a
+// search result or a retrieved document.
+type Citation struct {
+	URL     string `json:"url,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+	Source  string `json:"source,omitempty"` // tool that consulted it
+}
+
+// key identifies the cited source, for removing duplicates.
+func (c Citation) key() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return c.Title
+}
+
+type stepKey struct{}
+
+// stepRecorder collects what tools record for a step. Tools bridged into
+// executed code may record from other goroutines.
+type stepRecorder struct {
+	mu   sync.Mutex
+	step *ActionStep
+}
+
+// withStepRecorder attaches step to ctx for RecordCitation and
+// RecordArtifact.
+func withStepRecorder(ctx context.Context, step *ActionStep) context.Context {
+	return context.WithValue(ctx, stepKey{}, &stepRecorder{step: step})
+}
+
+// RecordCitation adds c to the citations of the action step ctx belongs
+// to, if any. Tools call it for the sources they read, so a run's
+// RunResult.Citations lists them without parsing observations.
+func RecordCitation(ctx context.Context, c Citation) {
+	if r, ok := ctx.Value(stepKey{}).(*stepRecorder); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.step.Citations = append(r.step.Citations, c)
+	}
+}
+
+// RecordArtifact adds a to the artifacts of the action step ctx belongs
+// to, if any. Tools call it for the files they produce.
+func RecordArtifact(ctx context.Context, a Artifact) {
+	if r, ok := ctx.Value(stepKey{}).(*stepRecorder); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.step.Artifacts = append(r.step.Artifacts, a)
+	}
+}
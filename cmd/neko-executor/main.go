@@ -0,0 +1,90 @@
+// Command neko-executor serves a code executor over HTTP so agents can run
+// generated code on a separate, hardened host via exec.NewRemoteExecutor.
+//
+// Requests must carry the bearer token from NEKO_EXECUTOR_TOKEN. Without a
+// token the server only listens on a loopback address.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/exec"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	python := flag.String("python", "python3", "Python interpreter path")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-execution timeout")
+	lang := flag.String("lang", "python", "execution language: python or js")
+	flag.Parse()
+
+	// The token is read from the environment to keep it out of process listings.
+	token := os.Getenv("NEKO_EXECUTOR_TOKEN")
+	if token == "" && !isLoopback(*addr) {
+		log.Fatalf("NEKO_EXECUTOR_TOKEN must be set to listen on %s; use a loopback address for unauthenticated local use", *addr)
+	}
+
+	var newExecutor func() neko.CodeExecutor
+	switch *lang {
+	case "python":
+		newExecutor = func() neko.CodeExecutor {
+			return exec.NewPythonExecutor(exec.WithPythonPath(*python), exec.WithTimeout(*timeout))
+		}
+	case "js":
+		newExecutor = func() neko.CodeExecutor { return exec.NewJSExecutor(exec.WithJSTimeout(*timeout)) }
+	default:
+		log.Fatalf("unknown language: %s", *lang)
+	}
+
+	log.Printf("neko-executor listening on %s (%s)", *addr, *lang)
+	if err := serve(*addr, token, *timeout, newExecutor); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve runs the HTTP server until it fails or the process is signalled.
+// Each client session gets its own executor, and with it its own
+// interpreter state and working directory.
+func serve(addr, token string, timeout time.Duration, newExecutor func() neko.CodeExecutor) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler := exec.NewRemoteSessionHandler(newExecutor, token)
+	defer handler.Close()
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only accepts local connections.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
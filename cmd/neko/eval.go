@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/config"
+	"github.com/gocnn/neko/eval"
+)
+
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	suitePath := fs.String("suite", "", "suite file: a JSON suite or JSON Lines cases")
+	concurrency := fs.Int("concurrency", 1, "cases to run at once")
+	timeout := fs.Duration("timeout", 0, "cancel cases that take longer than this")
+	maxSteps := fs.Int("max-steps", 0, "maximum steps per case; defaults to the agent's")
+	grader := fs.String("grader", "exact", "how answers are scored: exact, contains or model (the agent's model as judge)")
+	format := fs.String("format", "json", "report format: json or csv")
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	baseline := fs.String("baseline", "", "JSON report of an earlier run; fail if a case it passed now fails")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *suitePath == "" {
+		return fmt.Errorf("usage: neko eval -suite suite.jsonl [flags] agent.yaml")
+	}
+	suite, err := eval.LoadSuite(*suitePath)
+	if err != nil {
+		return err
+	}
+	newAgent := func() (neko.Agent, error) { return config.LoadAgentFromConfig(fs.Arg(0)) }
+
+	switch *grader {
+	case "exact":
+	case "contains":
+		suite.Grader = eval.Contains()
+	case "model":
+		agent, err := newAgent()
+		if err != nil {
+			return err
+		}
+		m, ok := agent.(interface{ Model() neko.Model })
+		if !ok || m.Model() == nil {
+			return fmt.Errorf("agent has no model to grade with")
+		}
+		suite.Grader = eval.ModelGrader(m.Model())
+	default:
+		return fmt.Errorf("unknown grader %q", *grader)
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts := []eval.Option{
+		eval.WithConcurrency(*concurrency),
+		eval.WithTimeout(*timeout),
+		eval.WithProgress(func(r eval.Result) {
+			mark := "✗"
+			if r.Correct() {
+				mark = "✓"
+			}
+			fmt.Fprintf(os.Stderr, "%s %s (%s)\n", mark, r.CaseID, r.Duration.Round(time.Millisecond))
+		}),
+	}
+	if *maxSteps > 0 {
+		opts = append(opts, eval.WithRunOptions(neko.WithMaxSteps(*maxSteps)))
+	}
+	report, err := eval.Run(ctx, suite, newAgent, opts...)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = report.WriteCSV(w)
+	} else {
+		err = report.WriteJSON(w)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, report)
+	fmt.Fprintln(os.Stderr, report.Trajectory)
+
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err != nil {
+			return err
+		}
+		var base eval.Report
+		if err := json.Unmarshal(data, &base); err != nil {
+			return fmt.Errorf("%s: %w", *baseline, err)
+		}
+		if ids := report.Regressions(&base); len(ids) > 0 {
+			return fmt.Errorf("%d regressions since baseline: %s", len(ids), strings.Join(ids, ", "))
+		}
+	}
+	return nil
+}
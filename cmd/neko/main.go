@@ -0,0 +1,309 @@
+// Command neko works with recorded agent runs.
+//
+//	neko replay [flags] trace.jsonl
+//	neko redact [flags] trace.jsonl
+//	neko serve [flags] agent.yaml
+//	neko worker [flags] agent.yaml
+//	neko graph [flags] agent.yaml
+//	neko eval -suite suite.jsonl [flags] agent.yaml
+//	neko validate agent.yaml
+//
+// replay prints a run recorded with neko.WithTraceWriter. With -from it
+// re-executes the run from that action step using the OpenAI-compatible
+// model configured by OPENAI_API_KEY, OPENAI_MODEL and OPENAI_BASE_URL,
+// optionally after editing the task, system prompt or earlier tool results.
+//
+// redact writes the trace to stdout with emails, API keys, card and phone
+// numbers, IP addresses and any names given with -names redacted.
+//
+// serve builds the agent defined in a config file (see package config) and
+// serves it over HTTP, with a web chat UI at / unless -ui=false. On
+// SIGINT or SIGTERM it drains: in-flight runs get -grace to finish, and
+// runs canceled after that are saved to the -checkpoints file if set.
+//
+// worker runs the agent defined in a config file on tasks consumed from a
+// Redis list or NATS JetStream stream (see package worker), publishing
+// each task's result.
+//
+// graph prints the agent hierarchy defined in a config file as Graphviz
+// DOT or, with -format mermaid, a Mermaid flowchart. With -trace it adds
+// the step flow of a recorded run.
+//
+// eval runs the agent on every case of a suite (see package eval) and
+// writes a JSON or CSV report. With -baseline it fails if a case that
+// passed in an earlier report now fails.
+//
+// validate checks the agent defined in a config file before it is used:
+// that its model answers, its tool schemas are valid and its executor runs
+// code (see neko.Validator).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/config"
+	"github.com/gocnn/neko/exec"
+	"github.com/gocnn/neko/server"
+	"github.com/gocnn/neko/tool"
+)
+
+const usage = "usage: neko replay|redact [flags] trace.jsonl | neko serve|worker|graph|eval|validate [flags] agent.yaml"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "replay":
+		err = replay(os.Args[2:])
+	case "redact":
+		err = redact(os.Args[2:])
+	case "serve":
+		err = serve(os.Args[2:])
+	case "worker":
+		err = runWorker(os.Args[2:])
+	case "graph":
+		err = graph(os.Args[2:])
+	case "eval":
+		err = runEval(os.Args[2:])
+	case "validate":
+		err = validate(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "neko:", err)
+		os.Exit(1)
+	}
+}
+
+func redact(args []string) error {
+	fs := flag.NewFlagSet("redact", flag.ExitOnError)
+	names := fs.String("names", "", "comma-separated names to redact as well")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: neko redact [flags] trace.jsonl")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	detectors := neko.DefaultDetectors()
+	if *names != "" {
+		detectors = append(detectors, neko.NameDetector(strings.Split(*names, ",")...))
+	}
+	return neko.NewRedactor(detectors...).RedactTrace(os.Stdout, f)
+}
+
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	timeout := fs.Duration("timeout", time.Minute, "give up on checks that take longer than this")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: neko validate [flags] agent.yaml")
+	}
+	agent, err := config.LoadAgentFromConfig(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	v, ok := agent.(neko.Validator)
+	if !ok {
+		return fmt.Errorf("%s cannot be validated", agent.Name())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := v.Validate(ctx); err != nil {
+		return fmt.Errorf("%s is not ready:\n%w", agent.Name(), err)
+	}
+	fmt.Printf("%s is ready\n", agent.Name())
+	return nil
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	ui := fs.Bool("ui", true, "serve the web chat UI at /")
+	grace := fs.Duration("grace", 30*time.Second, "on shutdown, wait this long for in-flight runs before canceling them")
+	checkpoints := fs.String("checkpoints", "", "append runs canceled on shutdown to this JSONL file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: neko serve [flags] agent.yaml")
+	}
+	agent, err := config.LoadAgentFromConfig(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := []server.Option{server.WithShutdownTimeout(*grace)}
+	if *ui {
+		opts = append(opts, server.WithUI())
+	}
+	if *checkpoints != "" {
+		opts = append(opts, server.WithCheckpointStore(server.NewFileCheckpoints(*checkpoints)))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Serving %s on http://%s\n", agent.Name(), *addr)
+	return server.New(agent, opts...).ListenAndServe(ctx, *addr)
+}
+
+func graph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.String("format", neko.GraphDOT, "output format: dot or mermaid")
+	trace := fs.String("trace", "", "add the step flow of the run recorded in this trace file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: neko graph [flags] agent.yaml")
+	}
+	agent, err := config.LoadAgentFromConfig(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var opts []neko.GraphOption
+	if *trace != "" {
+		f, err := os.Open(*trace)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		result, _, err := neko.LoadTrace(f)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, neko.WithGraphRun(result))
+	}
+	out, err := neko.ExportGraph(agent, *format, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// observationFlags collects repeated -observation N=text flags.
+type observationFlags map[int]string
+
+func (o observationFlags) String() string { return fmt.Sprint(map[int]string(o)) }
+
+func (o observationFlags) Set(v string) error {
+	n, text, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("want N=text, got %q", v)
+	}
+	step, err := strconv.Atoi(n)
+	if err != nil {
+		return fmt.Errorf("invalid step number %q", n)
+	}
+	o[step] = text
+	return nil
+}
+
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	step := fs.Int("step", 0, "print only this action step")
+	from := fs.Int("from", 0, "re-execute the run from this action step")
+	task := fs.String("task", "", "replace the task when re-executing")
+	system := fs.String("system", "", "replace the system prompt when re-executing")
+	maxSteps := fs.Int("max-steps", 20, "maximum new steps when re-executing")
+	agentType := fs.String("agent", "", "agent type when re-executing: tool or code (default: detected from the trace)")
+	python := fs.String("python", "python3", "Python interpreter path for code agents")
+	out := fs.String("o", "", "write the re-executed run's trace to this file")
+	observations := observationFlags{}
+	fs.Var(observations, "observation", "replace the observations of an earlier step, as N=text (repeatable)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: neko replay [flags] trace.jsonl")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	r, err := neko.LoadReplay(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *from > 0:
+	case *step > 0:
+		return r.PrintStep(os.Stdout, *step)
+	default:
+		r.Print(os.Stdout)
+		if r.Err != nil {
+			fmt.Printf("Run failed: %v\n", r.Err)
+		}
+		return nil
+	}
+
+	var opts []neko.ReplayOption
+	if *task != "" {
+		opts = append(opts, neko.WithReplayTask(*task))
+	}
+	if *system != "" {
+		opts = append(opts, neko.WithReplaySystemPrompt(*system))
+	}
+	for n, text := range observations {
+		opts = append(opts, neko.WithReplayObservation(n, text))
+	}
+	memory, err := r.Fork(*from, opts...)
+	if err != nil {
+		return err
+	}
+
+	agentOpts := []neko.AgentOption{
+		neko.WithModel(neko.NewOpenAIModelWithBaseURL(os.Getenv("OPENAI_MODEL"), os.Getenv("OPENAI_API_KEY"), os.Getenv("OPENAI_BASE_URL"))),
+		neko.WithToolList(tool.NewCalculatorTool(), tool.NewWebSearchTool(5), tool.NewVisitWebpageTool(10000)),
+		neko.WithConsoleOutput(os.Stdout),
+	}
+	if *out != "" {
+		w, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		agentOpts = append(agentOpts, neko.WithTraceWriter(w))
+	}
+
+	if *agentType == "" {
+		*agentType = "tool"
+		for _, s := range r.Memory.ActionSteps() {
+			if s.CodeAction != "" {
+				*agentType = "code"
+				break
+			}
+		}
+	}
+	var agent neko.Agent
+	switch *agentType {
+	case "tool":
+		agent = neko.NewToolCallingAgent(agentOpts...)
+	case "code":
+		agent = neko.NewCodeAgent(exec.NewPythonExecutor(exec.WithPythonPath(*python)), agentOpts...)
+	default:
+		return fmt.Errorf("unknown agent type: %s", *agentType)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Printf("Re-executing from step %d\n\n", *from)
+	runTask := r.Task()
+	if *task != "" {
+		runTask = *task
+	}
+	_, err = agent.Run(ctx, runTask, neko.WithResume(memory), neko.WithMaxSteps(*maxSteps))
+	return err
+}
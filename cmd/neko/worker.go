@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/config"
+	"github.com/gocnn/neko/worker"
+	"github.com/gocnn/neko/worker/natsqueue"
+	"github.com/gocnn/neko/worker/redisqueue"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/redis/go-redis/v9"
+)
+
+func runWorker(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	redisURL := fs.String("redis", "", "consume tasks from Redis at this URL, e.g. redis://localhost:6379/0")
+	natsURL := fs.String("nats", "", "consume tasks from NATS JetStream at this URL, e.g. nats://localhost:4222")
+	queue := fs.String("queue", "neko-tasks", "Redis list, or JetStream stream, holding the tasks")
+	results := fs.String("results", "neko-results", "NATS subject results are published on")
+	concurrency := fs.Int("concurrency", 1, "tasks to run at once")
+	attempts := fs.Int("attempts", 3, "attempts per task before it fails")
+	timeout := fs.Duration("timeout", 0, "cancel attempts that take longer than this")
+	fs.Parse(args)
+	if fs.NArg() != 1 || (*redisURL == "") == (*natsURL == "") {
+		return fmt.Errorf("usage: neko worker -redis URL|-nats URL [flags] agent.yaml")
+	}
+	// Load once up front so config errors are reported before consuming.
+	if _, err := config.ReadFile(fs.Arg(0)); err != nil {
+		return err
+	}
+	newAgent := func() (neko.Agent, error) { return config.LoadAgentFromConfig(fs.Arg(0)) }
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var q interface {
+		worker.Queue
+		worker.Publisher
+	}
+	if *redisURL != "" {
+		opts, err := redis.ParseURL(*redisURL)
+		if err != nil {
+			return err
+		}
+		client := redis.NewClient(opts)
+		defer client.Close()
+		q = redisqueue.New(client, *queue)
+	} else {
+		nc, err := nats.Connect(*natsURL)
+		if err != nil {
+			return err
+		}
+		defer nc.Close()
+		js, err := jetstream.New(nc)
+		if err != nil {
+			return err
+		}
+		ackWait := time.Hour
+		if *timeout > 0 {
+			ackWait = *timeout*time.Duration(*attempts) + time.Minute
+		}
+		consumer, err := js.CreateOrUpdateConsumer(ctx, *queue, jetstream.ConsumerConfig{
+			Durable:   "neko-worker",
+			AckPolicy: jetstream.AckExplicitPolicy,
+			AckWait:   ackWait,
+		})
+		if err != nil {
+			return err
+		}
+		q = natsqueue.New(js, consumer, *results)
+	}
+
+	fmt.Printf("Consuming tasks from %s\n", *queue)
+	return worker.New(newAgent, q,
+		worker.WithPublisher(q),
+		worker.WithConcurrency(*concurrency),
+		worker.WithMaxAttempts(*attempts),
+		worker.WithTaskTimeout(*timeout),
+	).Run(ctx)
+}
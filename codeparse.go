@@ -0,0 +1,247 @@
+package neko
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CodeBlock is a block of code found in model output.
+type CodeBlock struct {
+	Code string
+	// Language is the block's language, normalized to "python",
+	// "javascript", "bash" or another lower-case name. It comes from the
+	// fence or tag when Tagged, and is otherwise guessed from the code;
+	// it is "" when no guess can be made.
+	Language string
+	Tagged   bool
+}
+
+// CodeDelimiter is a pair of tags enclosing code, such as <code> and
+// </code>. An Open tag ending in ">" also matches the tag with attributes,
+// e.g. <code lang="python">, whose lang or language attribute gives the
+// block's language.
+type CodeDelimiter struct {
+	Open, Close string
+}
+
+// CodeParser extracts code blocks from model output.
+type CodeParser struct {
+	// Delimiters are the tag pairs that enclose code. A tagged block may
+	// itself hold a Markdown fence, which is removed. A block left open
+	// at the end of the text, as when the model stops at the closing tag,
+	// runs to the end.
+	Delimiters []CodeDelimiter
+	// Fences also extracts Markdown code blocks fenced with three or more
+	// backticks or tildes. A fence is closed only by a line holding a
+	// longer or equal run of the same character, so a block fenced with
+	// four backticks can contain lines of three.
+	Fences bool
+}
+
+// DefaultCodeParser returns the parser CodeAgent uses by default: <code>
+// tags and Markdown fences.
+func DefaultCodeParser() *CodeParser {
+	return &CodeParser{Delimiters: []CodeDelimiter{{Open: "<code>", Close: "</code>"}}, Fences: true}
+}
+
+// WithCodeParser sets how a CodeAgent finds code in model output. Custom
+// delimiters usually need a matching system prompt; the closing tags are
+// used as stop sequences.
+func WithCodeParser(p *CodeParser) AgentOption {
+	return func(a *BaseAgent) { a.codeParser = p }
+}
+
+// Parse returns the code blocks in text, in order. Empty blocks are
+// skipped.
+func (p *CodeParser) Parse(text string) []CodeBlock {
+	var blocks []CodeBlock
+	for text != "" {
+		tagAt, d := p.nextTag(text)
+		fenceAt := -1
+		if p.Fences {
+			fenceAt = nextFence(text)
+		}
+		var block CodeBlock
+		switch {
+		case tagAt >= 0 && (fenceAt < 0 || tagAt <= fenceAt):
+			block, text = parseTagged(text[tagAt:], d)
+		case fenceAt >= 0:
+			block, text = parseFenced(text[fenceAt:])
+		default:
+			return blocks
+		}
+		if strings.TrimSpace(block.Code) == "" {
+			continue
+		}
+		block.Code = strings.TrimSpace(block.Code)
+		if block.Language == "" {
+			block.Language, block.Tagged = detectLanguage(block.Code), false
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// closeTags returns the closing tags, for use as stop sequences.
+func (p *CodeParser) closeTags() []string {
+	tags := make([]string, 0, len(p.Delimiters))
+	for _, d := range p.Delimiters {
+		tags = append(tags, d.Close)
+	}
+	return tags
+}
+
+// nextTag returns the position of the first opening tag in text.
+func (p *CodeParser) nextTag(text string) (int, CodeDelimiter) {
+	at, found := -1, CodeDelimiter{}
+	for _, d := range p.Delimiters {
+		i := indexOpenTag(text, d.Open)
+		if i >= 0 && (at < 0 || i < at) {
+			at, found = i, d
+		}
+	}
+	return at, found
+}
+
+// indexOpenTag finds open, or open with attributes if it ends in ">".
+func indexOpenTag(text, open string) int {
+	if open == "" {
+		return -1
+	}
+	name, isTag := strings.CutSuffix(open, ">")
+	for from := 0; ; {
+		i := strings.Index(text[from:], name)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		rest := text[i+len(name):]
+		if !isTag || strings.HasPrefix(rest, ">") || (rest != "" && (rest[0] == ' ' || rest[0] == '\t') && strings.Contains(rest, ">")) {
+			return i
+		}
+		from = i + len(name)
+	}
+}
+
+var tagLanguage = regexp.MustCompile(`\blang(?:uage)?\s*=\s*["']?([\w+#-]+)`)
+
+// parseTagged reads the tagged block at the start of text and returns it
+// with the text after it.
+func parseTagged(text string, d CodeDelimiter) (CodeBlock, string) {
+	var block CodeBlock
+	start := len(d.Open)
+	if name, ok := strings.CutSuffix(d.Open, ">"); ok && !strings.HasPrefix(text, d.Open) {
+		end := strings.Index(text, ">")
+		if m := tagLanguage.FindStringSubmatch(text[len(name):end]); m != nil {
+			block.Language, block.Tagged = normalizeLanguage(m[1]), true
+		}
+		start = end + 1
+	}
+	body, rest := text[start:], ""
+	if i := strings.Index(body, d.Close); i >= 0 {
+		body, rest = body[:i], body[i+len(d.Close):]
+	}
+	// Models often fence the code inside the tags as well.
+	if trimmed := strings.TrimSpace(body); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		inner, _ := parseFenced(trimmed)
+		body = inner.Code
+		if inner.Language != "" && !block.Tagged {
+			block.Language, block.Tagged = inner.Language, true
+		}
+	}
+	block.Code = body
+	return block, rest
+}
+
+// nextFence returns the position of the first line opening a fence.
+func nextFence(text string) int {
+	for at := 0; at < len(text); {
+		line := text[at:]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		if _, _, ok := fenceLine(line); ok {
+			return at
+		}
+		at += len(line) + 1
+	}
+	return -1
+}
+
+// fenceLine reports whether line is a fence, with its character, length
+// and the info string after it.
+func fenceLine(line string) (fence string, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", "", false
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return "", "", false
+	}
+	info = strings.TrimSpace(trimmed[n:])
+	if trimmed[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false // inline code, not a fence
+	}
+	return trimmed[:n], info, true
+}
+
+// parseFenced reads the fenced block at the start of text and returns it
+// with the text after it.
+func parseFenced(text string) (CodeBlock, string) {
+	first, body, _ := strings.Cut(text, "\n")
+	fence, info, _ := fenceLine(first)
+	var block CodeBlock
+	if lang, _, _ := strings.Cut(info, " "); lang != "" {
+		block.Language, block.Tagged = normalizeLanguage(lang), true
+	}
+	var code strings.Builder
+	for body != "" {
+		line, rest, _ := strings.Cut(body, "\n")
+		if f, info, ok := fenceLine(line); ok && info == "" && f[0] == fence[0] && len(f) >= len(fence) {
+			block.Code = code.String()
+			return block, rest
+		}
+		code.WriteString(line)
+		code.WriteByte('\n')
+		body = rest
+	}
+	// An unclosed fence, e.g. cut off by a stop sequence, runs to the end.
+	block.Code = code.String()
+	return block, ""
+}
+
+func normalizeLanguage(lang string) string {
+	switch lang = strings.ToLower(lang); lang {
+	case "py", "python3":
+		return "python"
+	case "js", "node", "mjs":
+		return "javascript"
+	case "sh", "shell", "zsh", "console":
+		return "bash"
+	}
+	return lang
+}
+
+var (
+	pythonHints     = regexp.MustCompile(`(?m)^\s*(def |import |from \S+ import |print\(|elif |for \w+ in |with .+:$)`)
+	javascriptHints = regexp.MustCompile(`(?m)(^\s*(const|let|var) \w+\s*=|console\.log\(|=>|^\s*function\s+\w+\s*\()`)
+	bashHints       = regexp.MustCompile(`(?m)(^#!/bin/(ba)?sh|^\s*(echo|cd|ls|cat|grep|export|curl|mkdir|rm)\s|\$\(|^\s*fi\s*$|\|\s*(grep|wc|head|tail|sort))`)
+)
+
+// detectLanguage guesses the language of untagged code.
+func detectLanguage(code string) string {
+	switch {
+	case strings.HasPrefix(code, "#!") && strings.Contains(strings.SplitN(code, "\n", 2)[0], "python"):
+		return "python"
+	case strings.HasPrefix(code, "#!") && strings.Contains(strings.SplitN(code, "\n", 2)[0], "node"):
+		return "javascript"
+	case bashHints.MatchString(code) && !pythonHints.MatchString(code) && !javascriptHints.MatchString(code):
+		return "bash"
+	case javascriptHints.MatchString(code) && !pythonHints.MatchString(code):
+		return "javascript"
+	case pythonHints.MatchString(code):
+		return "python"
+	}
+	return ""
+}
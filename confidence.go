@@ -0,0 +1,127 @@
+package neko
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Abstention is the answer of a run that abstained: the model said it
+// cannot determine the answer, or was less confident in its answer than
+// the agent's confidence threshold.
+const Abstention = "cannot determine"
+
+// WithConfidence makes the model report its confidence, from 0 to 1,
+// with every final answer, and lets it abstain by answering Abstention.
+// Answers given with a confidence below threshold are replaced by
+// Abstention. The confidence is reported in RunResult.Confidence, and
+// abstentions in RunResult.Abstained; final answer checks are skipped
+// for them. A ToolCallingAgent's final_answer tool gains a confidence
+// argument; a CodeAgent passes final_answer a dict with "answer" and
+// "confidence" keys.
+func WithConfidence(threshold float64) AgentOption {
+	return func(a *BaseAgent) { a.confidence = &threshold }
+}
+
+// newConfidentFinalAnswerTool creates the final_answer tool of agents
+// with WithConfidence.
+func newConfidentFinalAnswerTool() *FinalAnswerTool {
+	t := NewFinalAnswerTool()
+	t.inputs = map[string]ToolInput{
+		"answer":     t.inputs["answer"],
+		"confidence": {Type: "number", Description: "Your confidence that the answer is correct, from 0 to 1", Required: true},
+	}
+	return t
+}
+
+// confidencePrompt asks for confidence scores in the system prompt.
+func (a *BaseAgent) confidencePrompt(code bool) string {
+	if a.confidence == nil {
+		return ""
+	}
+	how := "Pass final_answer your confidence that the answer is correct, from 0 to 1, as its confidence argument."
+	if code {
+		how = `Call final_answer with a dict holding the answer and your confidence that it is correct, from 0 to 1: final_answer({"answer": answer, "confidence": 0.8}).`
+	}
+	return fmt.Sprintf("\n\n## Confidence\n%s Be calibrated: report how likely the answer is to be right, not how hard you tried. If you cannot determine the answer, or your confidence is below %g, answer %q instead of guessing.",
+		how, *a.confidence, Abstention)
+}
+
+// answerConfidence is the confidence reported with a final answer.
+type answerConfidence struct {
+	score     *float64
+	abstained bool
+}
+
+// takeConfidence separates the confidence reported with a final answer
+// from the answer, given the answer and the arguments of the
+// final_answer call that gave it, nil for a CodeAgent. The answer is
+// replaced by Abstention if the model abstained. An error rejects an
+// answer without a valid confidence.
+func (a *BaseAgent) takeConfidence(answer any, args map[string]any) (any, answerConfidence, error) {
+	if a.confidence == nil {
+		return answer, answerConfidence{}, nil
+	}
+	var raw any
+	if args != nil {
+		raw = args["confidence"]
+	} else if !isAbstention(answer) {
+		m, ok := answer.(map[string]any)
+		if s, isString := answer.(string); isString {
+			ok = json.Unmarshal([]byte(strings.TrimSpace(s)), &m) == nil
+		}
+		_, hasAnswer := m["answer"]
+		if !ok || !hasAnswer {
+			return nil, answerConfidence{}, fmt.Errorf(`final answer rejected: pass final_answer a dict like {"answer": answer, "confidence": 0.8}`)
+		}
+		answer, raw = m["answer"], m["confidence"]
+	}
+	if isAbstention(answer) {
+		score, _ := parseConfidence(raw)
+		return Abstention, answerConfidence{score: score, abstained: true}, nil
+	}
+	score, err := parseConfidence(raw)
+	if err != nil {
+		return nil, answerConfidence{}, fmt.Errorf("final answer rejected: %w", err)
+	}
+	if *score < *a.confidence {
+		a.logger().Info("abstaining from low-confidence answer", "confidence", *score, "threshold", *a.confidence)
+		return Abstention, answerConfidence{score: score, abstained: true}, nil
+	}
+	return answer, answerConfidence{score: score}, nil
+}
+
+// isAbstention reports whether answer is Abstention.
+func isAbstention(answer any) bool {
+	s, ok := answer.(string)
+	return ok && strings.EqualFold(strings.Trim(strings.TrimSpace(s), `."`), Abstention)
+}
+
+// parseConfidence reads a confidence score from 0 to 1.
+func parseConfidence(raw any) (*float64, error) {
+	var v float64
+	switch c := raw.(type) {
+	case nil:
+		return nil, fmt.Errorf("report your confidence that the answer is correct, from 0 to 1")
+	case float64:
+		v = c
+	case int:
+		v = float64(c)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(c), "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("confidence %q is not a number from 0 to 1", c)
+		}
+		if strings.HasSuffix(strings.TrimSpace(c), "%") {
+			f /= 100
+		}
+		v = f
+	default:
+		return nil, fmt.Errorf("confidence %v is not a number from 0 to 1", raw)
+	}
+	if v < 0 || v > 1 {
+		return nil, fmt.Errorf("confidence %g is not between 0 and 1", v)
+	}
+	return &v, nil
+}
@@ -0,0 +1,308 @@
+// Package config builds agents from YAML or JSON files, so agents can be
+// defined and changed without recompiling:
+//
+//	name: orchestrator
+//	model:
+//	  id: gpt-4o
+//	  api_key: ${OPENAI_API_KEY}
+//	max_steps: 15
+//	managed_agents:
+//	  - name: web_researcher
+//	    description: Expert at finding information on the web
+//	    tools:
+//	      - name: web_search
+//	        options: {max_results: 10}
+//	  - name: analyst
+//	    description: Runs calculations in Python
+//	    type: code
+//	    executor:
+//	      name: python
+//	      options: {timeout: 1m, imports: [math, statistics]}
+//	    system_prompt_file: prompts/analyst.md
+//
+// Tools, executors and model providers are looked up by name in a
+// Registry, which knows the ones in this module and can be extended.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gocnn/neko"
+	"gopkg.in/yaml.v3"
+)
+
+// AgentConfig describes an agent.
+type AgentConfig struct {
+	// File loads the agent from another config file, relative to this one.
+	// Other fields must then be empty.
+	File string `json:"file,omitempty"`
+
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is "tool_calling" (the default) or "code".
+	Type  string       `json:"type,omitempty"`
+	Model *ModelConfig `json:"model,omitempty"`
+	Tools []ToolConfig `json:"tools,omitempty"`
+	// Executor runs the actions of code agents. Defaults to python.
+	Executor *ExecutorConfig `json:"executor,omitempty"`
+	// SystemPrompt replaces the default system prompt. SystemPromptFile
+	// reads it from a file relative to the config file instead.
+	SystemPrompt     string `json:"system_prompt,omitempty"`
+	SystemPromptFile string `json:"system_prompt_file,omitempty"`
+	MaxSteps         int    `json:"max_steps,omitempty"`
+	// ManagedAgents are sub-agents the agent can call as tools. Each needs
+	// a name and description, and uses its parent's model unless it has
+	// its own.
+	ManagedAgents []AgentConfig `json:"managed_agents,omitempty"`
+
+	dir string // directory relative paths are resolved against
+}
+
+// ModelConfig describes a model. ID, APIKey and BaseURL may refer to
+// environment variables as ${NAME}.
+type ModelConfig struct {
+	// Provider selects the model constructor. Defaults to "openai", which
+	// works with any OpenAI-compatible API and uses OPENAI_API_KEY and
+	// OPENAI_BASE_URL when APIKey and BaseURL are empty.
+	// "azure" is for Azure OpenAI, with the deployment name as ID and the
+	// resource endpoint as BaseURL, defaulting to AZURE_OPENAI_API_KEY
+	// and AZURE_OPENAI_ENDPOINT. "huggingface" is for Hugging Face Hub
+	// models, served serverless or by the Inference Endpoint or TGI
+	// server at BaseURL, with HF_TOKEN as the default APIKey. "groq",
+	// "deepseek" and "xai" use GROQ_API_KEY, DEEPSEEK_API_KEY and
+	// XAI_API_KEY by default.
+	Provider    string        `json:"provider,omitempty"`
+	ID          string        `json:"id"`
+	APIKey      string        `json:"api_key,omitempty"`
+	BaseURL     string        `json:"base_url,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	MaxTokens   int64         `json:"max_tokens,omitempty"`
+	Pricing     *neko.Pricing `json:"pricing,omitempty"`
+	Options     Options       `json:"options,omitempty"`
+}
+
+// ToolConfig names a registered tool and its options.
+type ToolConfig struct {
+	Name    string  `json:"name"`
+	Options Options `json:"options,omitempty"`
+}
+
+// ExecutorConfig names a registered code executor and its options.
+type ExecutorConfig struct {
+	Name    string  `json:"name"`
+	Options Options `json:"options,omitempty"`
+}
+
+// ReadFile parses an agent config from a YAML or JSON file.
+func ReadFile(path string) (*AgentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.dir = filepath.Dir(path)
+	return cfg, nil
+}
+
+// Parse parses an agent config from YAML or JSON. Relative paths in it
+// are resolved against the working directory. Unknown fields are errors.
+func Parse(data []byte) (*AgentConfig, error) {
+	// JSON is valid YAML, so both go through the YAML parser and are then
+	// decoded strictly through JSON.
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(normalized))
+	dec.DisallowUnknownFields()
+	var cfg AgentConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Option configures how agents are built.
+type Option func(*builder)
+
+type builder struct {
+	registry *Registry
+	opts     []neko.AgentOption
+}
+
+// WithRegistry builds tools, executors and models from r instead of
+// NewRegistry().
+func WithRegistry(r *Registry) Option {
+	return func(b *builder) { b.registry = r }
+}
+
+// WithAgentOptions applies opts to the top-level agent after its
+// configured settings, e.g. to add console output or a trace writer.
+func WithAgentOptions(opts ...neko.AgentOption) Option {
+	return func(b *builder) { b.opts = append(b.opts, opts...) }
+}
+
+// LoadAgentFromConfig reads the config file at path and builds its agent.
+func LoadAgentFromConfig(path string, opts ...Option) (neko.Agent, error) {
+	cfg, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Build(cfg, opts...)
+}
+
+// Build builds the agent described by cfg.
+func Build(cfg *AgentConfig, opts ...Option) (neko.Agent, error) {
+	b := &builder{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.registry == nil {
+		b.registry = NewRegistry()
+	}
+	return b.build(cfg, nil, b.opts)
+}
+
+// build builds an agent, using model unless cfg configures its own.
+func (b *builder) build(cfg *AgentConfig, model *builtModel, extra []neko.AgentOption) (neko.Agent, error) {
+	if cfg.File != "" {
+		path := resolve(cfg.dir, cfg.File)
+		sub, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return b.build(sub, model, extra)
+	}
+
+	if cfg.Model != nil {
+		var err error
+		if model, err = b.model(cfg.Model); err != nil {
+			return nil, agentError(cfg, err)
+		}
+	}
+	if model == nil {
+		return nil, agentError(cfg, fmt.Errorf("no model configured"))
+	}
+
+	opts := []neko.AgentOption{neko.WithModel(model.model)}
+	if model.pricing != nil {
+		opts = append(opts, neko.WithPricing(*model.pricing))
+	}
+	if cfg.Name != "" {
+		opts = append(opts, neko.WithName(cfg.Name))
+	}
+	if cfg.Description != "" {
+		opts = append(opts, neko.WithDescription(cfg.Description))
+	}
+	if cfg.MaxSteps > 0 {
+		opts = append(opts, neko.WithAgentMaxSteps(cfg.MaxSteps))
+	}
+
+	prompt := cfg.SystemPrompt
+	if cfg.SystemPromptFile != "" {
+		if prompt != "" {
+			return nil, agentError(cfg, fmt.Errorf("system_prompt and system_prompt_file are mutually exclusive"))
+		}
+		data, err := os.ReadFile(resolve(cfg.dir, cfg.SystemPromptFile))
+		if err != nil {
+			return nil, agentError(cfg, err)
+		}
+		prompt = string(data)
+	}
+	if prompt != "" {
+		opts = append(opts, neko.WithSystemPrompt(prompt))
+	}
+
+	var tools []neko.Tool
+	for _, tc := range cfg.Tools {
+		t, err := b.registry.tool(tc)
+		if err != nil {
+			return nil, agentError(cfg, err)
+		}
+		tools = append(tools, t)
+	}
+	if len(tools) > 0 {
+		opts = append(opts, neko.WithToolList(tools...))
+	}
+
+	for i := range cfg.ManagedAgents {
+		sub := &cfg.ManagedAgents[i]
+		if sub.dir == "" {
+			sub.dir = cfg.dir
+		}
+		agent, err := b.build(sub, model, nil)
+		if err != nil {
+			return nil, err
+		}
+		if agent.Name() == "" || agent.Description() == "" {
+			return nil, agentError(cfg, fmt.Errorf("managed agent %d needs a name and description", i))
+		}
+		opts = append(opts, neko.WithManagedAgents(agent))
+	}
+	opts = append(opts, extra...)
+
+	switch cfg.Type {
+	case "", "tool_calling":
+		if cfg.Executor != nil {
+			return nil, agentError(cfg, fmt.Errorf("executor is only used by code agents"))
+		}
+		return neko.NewToolCallingAgent(opts...), nil
+	case "code":
+		ec := cfg.Executor
+		if ec == nil {
+			ec = &ExecutorConfig{Name: "python"}
+		}
+		executor, err := b.registry.executor(*ec, tools)
+		if err != nil {
+			return nil, agentError(cfg, err)
+		}
+		return neko.NewCodeAgent(executor, opts...), nil
+	default:
+		return nil, agentError(cfg, fmt.Errorf("unknown agent type %q", cfg.Type))
+	}
+}
+
+type builtModel struct {
+	model   neko.Model
+	pricing *neko.Pricing
+}
+
+func (b *builder) model(mc *ModelConfig) (*builtModel, error) {
+	resolved := *mc
+	resolved.ID = os.ExpandEnv(mc.ID)
+	resolved.APIKey = os.ExpandEnv(mc.APIKey)
+	resolved.BaseURL = os.ExpandEnv(mc.BaseURL)
+	if resolved.Provider == "" {
+		resolved.Provider = "openai"
+	}
+	m, err := b.registry.newModel(resolved)
+	if err != nil {
+		return nil, err
+	}
+	return &builtModel{model: m, pricing: mc.Pricing}, nil
+}
+
+func agentError(cfg *AgentConfig, err error) error {
+	if cfg.Name == "" {
+		return err
+	}
+	return fmt.Errorf("agent %s: %w", cfg.Name, err)
+}
+
+func resolve(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,663 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/exec"
+	"github.com/gocnn/neko/tool"
+)
+
+// Options are the settings of a tool, executor or model in a config file.
+// String values may refer to environment variables as ${NAME}.
+type Options map[string]any
+
+// Decode stores the options in the struct v points to, matching its json
+// tags. Options v has no field for are errors.
+func (o Options) Decode(v any) error {
+	expanded := make(map[string]any, len(o))
+	for k, val := range o {
+		if s, ok := val.(string); ok {
+			val = os.ExpandEnv(s)
+		}
+		expanded[k] = val
+	}
+	data, err := json.Marshal(expanded)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Duration is a time.Duration given in options as a string such as "30s"
+// or a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// ToolConstructor creates a tool from its options.
+type ToolConstructor func(opts Options) (neko.Tool, error)
+
+// ExecutorConstructor creates a code executor from its options. tools are
+// the agent's configured tools, for executors that bind them directly.
+type ExecutorConstructor func(opts Options, tools []neko.Tool) (neko.CodeExecutor, error)
+
+// ModelConstructor creates a model from its config, with environment
+// variables in ID, APIKey and BaseURL already expanded.
+type ModelConstructor func(cfg ModelConfig) (neko.Model, error)
+
+// Registry maps the names used in config files to constructors.
+type Registry struct {
+	tools     map[string]ToolConstructor
+	executors map[string]ExecutorConstructor
+	models    map[string]ModelConstructor
+}
+
+// NewRegistry creates a registry with the tools, executors and model
+// providers of this module:
+//
+//	tools      calculator, web_search, serpapi_search, visit_webpage
+//	executors  python, javascript, bash, docker, e2b, remote
+//	models     openai
+func NewRegistry() *Registry {
+	r := &Registry{
+		tools:     make(map[string]ToolConstructor),
+		executors: make(map[string]ExecutorConstructor),
+		models:    make(map[string]ModelConstructor),
+	}
+	r.RegisterTool("calculator", newCalculator)
+	r.RegisterTool("web_search", newWebSearch)
+	r.RegisterTool("serpapi_search", newSerpAPISearch)
+	r.RegisterTool("visit_webpage", newVisitWebpage)
+	r.RegisterExecutor("python", newPythonExecutor)
+	r.RegisterExecutor("javascript", newJSExecutor)
+	r.RegisterExecutor("bash", newBashExecutor)
+	r.RegisterExecutor("docker", newDockerExecutor)
+	r.RegisterExecutor("e2b", newE2BExecutor)
+	r.RegisterExecutor("remote", newRemoteExecutor)
+	r.RegisterModel("openai", newOpenAIModel)
+	r.RegisterModel("azure", newAzureOpenAIModel)
+	r.RegisterModel("huggingface", newHFInferenceModel)
+	r.RegisterModel("groq", newGroqModel)
+	r.RegisterModel("deepseek", newDeepSeekModel)
+	r.RegisterModel("xai", newGrokModel)
+	return r
+}
+
+// RegisterTool makes a tool available under name, replacing any tool
+// already registered with it.
+func (r *Registry) RegisterTool(name string, fn ToolConstructor) {
+	r.tools[name] = fn
+}
+
+// RegisterExecutor makes a code executor available under name.
+func (r *Registry) RegisterExecutor(name string, fn ExecutorConstructor) {
+	r.executors[name] = fn
+}
+
+// RegisterModel makes a model provider available under name.
+func (r *Registry) RegisterModel(name string, fn ModelConstructor) {
+	r.models[name] = fn
+}
+
+func (r *Registry) tool(tc ToolConfig) (neko.Tool, error) {
+	fn, ok := r.tools[tc.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q (known: %s)", tc.Name, names(r.tools))
+	}
+	t, err := fn(tc.Options)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", tc.Name, err)
+	}
+	return t, nil
+}
+
+func (r *Registry) executor(ec ExecutorConfig, tools []neko.Tool) (neko.CodeExecutor, error) {
+	fn, ok := r.executors[ec.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown executor %q (known: %s)", ec.Name, names(r.executors))
+	}
+	e, err := fn(ec.Options, tools)
+	if err != nil {
+		return nil, fmt.Errorf("executor %s: %w", ec.Name, err)
+	}
+	return e, nil
+}
+
+func (r *Registry) newModel(mc ModelConfig) (neko.Model, error) {
+	fn, ok := r.models[mc.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown model provider %q (known: %s)", mc.Provider, names(r.models))
+	}
+	m, err := fn(mc)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", mc.ID, err)
+	}
+	return m, nil
+}
+
+func names[T any](m map[string]T) string {
+	out := make([]string, 0, len(m))
+	for name := range m {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+func newCalculator(opts Options) (neko.Tool, error) {
+	if err := opts.Decode(&struct{}{}); err != nil {
+		return nil, err
+	}
+	return tool.NewCalculatorTool(), nil
+}
+
+// proxyClient returns an HTTP client sending requests through the proxy
+// at proxy, or nil if proxy is empty. Without one, clients use the
+// HTTP_PROXY and HTTPS_PROXY environment variables.
+func proxyClient(proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q", proxy)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	return &http.Client{Transport: transport}, nil
+}
+
+// toolOptions returns the options of a web tool with the given proxy.
+func toolOptions(proxy string) ([]tool.Option, error) {
+	c, err := proxyClient(proxy)
+	if c == nil || err != nil {
+		return nil, err
+	}
+	c.Timeout = 30 * time.Second
+	return []tool.Option{tool.WithHTTPClient(c)}, nil
+}
+
+func newWebSearch(opts Options) (neko.Tool, error) {
+	var o struct {
+		MaxResults int    `json:"max_results"`
+		Proxy      string `json:"proxy"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	to, err := toolOptions(o.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return tool.NewWebSearchTool(o.MaxResults, to...), nil
+}
+
+func newSerpAPISearch(opts Options) (neko.Tool, error) {
+	o := struct {
+		APIKey     string `json:"api_key"`
+		MaxResults int    `json:"max_results"`
+		Proxy      string `json:"proxy"`
+	}{APIKey: os.Getenv("SERPAPI_API_KEY"), MaxResults: 10}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if o.APIKey == "" {
+		return nil, fmt.Errorf("api_key or SERPAPI_API_KEY is required")
+	}
+	to, err := toolOptions(o.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return tool.NewSerpAPISearchTool(o.APIKey, o.MaxResults, to...), nil
+}
+
+func newVisitWebpage(opts Options) (neko.Tool, error) {
+	var o struct {
+		MaxLength int    `json:"max_length"`
+		Proxy     string `json:"proxy"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	to, err := toolOptions(o.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return tool.NewVisitWebpageTool(o.MaxLength, to...), nil
+}
+
+func newPythonExecutor(opts Options, _ []neko.Tool) (neko.CodeExecutor, error) {
+	var o struct {
+		PythonPath    string   `json:"python_path"`
+		Timeout       Duration `json:"timeout"`
+		Imports       []string `json:"imports"`
+		WorkDir       string   `json:"work_dir"`
+		MaxOutputSize int      `json:"max_output_size"`
+		Preload       []string `json:"preload"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	var po []exec.PythonOption
+	if o.PythonPath != "" {
+		po = append(po, exec.WithPythonPath(o.PythonPath))
+	}
+	if o.Timeout > 0 {
+		po = append(po, exec.WithTimeout(time.Duration(o.Timeout)))
+	}
+	if o.Imports != nil {
+		po = append(po, exec.WithImports(o.Imports))
+	}
+	if o.WorkDir != "" {
+		po = append(po, exec.WithWorkDir(o.WorkDir))
+	}
+	if o.MaxOutputSize > 0 {
+		po = append(po, exec.WithMaxOutputSize(o.MaxOutputSize))
+	}
+	if len(o.Preload) > 0 {
+		po = append(po, exec.WithPreload(o.Preload...))
+	}
+	return exec.NewPythonExecutor(po...), nil
+}
+
+func newJSExecutor(opts Options, tools []neko.Tool) (neko.CodeExecutor, error) {
+	var o struct {
+		Timeout      Duration `json:"timeout"`
+		HeapGuard    uint64   `json:"heap_guard"`
+		MaxCallStack int      `json:"max_call_stack"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	jo := []exec.JSOption{exec.WithJSTools(tools...)}
+	if o.Timeout > 0 {
+		jo = append(jo, exec.WithJSTimeout(time.Duration(o.Timeout)))
+	}
+	if o.HeapGuard > 0 {
+		jo = append(jo, exec.WithJSHeapGuard(o.HeapGuard))
+	}
+	if o.MaxCallStack > 0 {
+		jo = append(jo, exec.WithJSMaxCallStack(o.MaxCallStack))
+	}
+	return exec.NewJSExecutor(jo...), nil
+}
+
+func newBashExecutor(opts Options, _ []neko.Tool) (neko.CodeExecutor, error) {
+	var o struct {
+		Path            string   `json:"path"`
+		Timeout         Duration `json:"timeout"`
+		AllowedCommands []string `json:"allowed_commands"`
+		WorkDir         string   `json:"work_dir"`
+		MaxOutputSize   int      `json:"max_output_size"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	var bo []exec.BashOption
+	if o.Path != "" {
+		bo = append(bo, exec.WithBashPath(o.Path))
+	}
+	if o.Timeout > 0 {
+		bo = append(bo, exec.WithBashTimeout(time.Duration(o.Timeout)))
+	}
+	if o.AllowedCommands != nil {
+		bo = append(bo, exec.WithAllowedCommands(o.AllowedCommands...))
+	}
+	if o.WorkDir != "" {
+		bo = append(bo, exec.WithBashWorkDir(o.WorkDir))
+	}
+	if o.MaxOutputSize > 0 {
+		bo = append(bo, exec.WithBashMaxOutputSize(o.MaxOutputSize))
+	}
+	return exec.NewBashExecutor(bo...), nil
+}
+
+func newDockerExecutor(opts Options, _ []neko.Tool) (neko.CodeExecutor, error) {
+	var o struct {
+		Image    string   `json:"image"`
+		Timeout  Duration `json:"timeout"`
+		Packages []string `json:"packages"`
+		WorkDir  string   `json:"work_dir"`
+		Network  string   `json:"network"`
+		Memory   string   `json:"memory"`
+		CPUs     float64  `json:"cpus"`
+		User     string   `json:"user"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	var do []exec.DockerOption
+	if len(o.Packages) > 0 {
+		do = append(do, exec.WithDockerPackages(o.Packages...))
+	}
+	if o.WorkDir != "" {
+		do = append(do, exec.WithDockerWorkDir(o.WorkDir))
+	}
+	if o.Network != "" {
+		do = append(do, exec.WithDockerNetwork(o.Network))
+	}
+	if o.Memory != "" {
+		do = append(do, exec.WithDockerMemory(o.Memory))
+	}
+	if o.CPUs > 0 {
+		do = append(do, exec.WithDockerCPUs(o.CPUs))
+	}
+	if o.User != "" {
+		do = append(do, exec.WithDockerUser(o.User))
+	}
+	return exec.NewDockerExecutor(o.Image, time.Duration(o.Timeout), do...), nil
+}
+
+func newE2BExecutor(opts Options, _ []neko.Tool) (neko.CodeExecutor, error) {
+	o := struct {
+		APIKey   string   `json:"api_key"`
+		Template string   `json:"template"`
+		Timeout  Duration `json:"timeout"`
+		Packages []string `json:"packages"`
+	}{APIKey: os.Getenv("E2B_API_KEY")}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if o.APIKey == "" {
+		return nil, fmt.Errorf("api_key or E2B_API_KEY is required")
+	}
+	var eo []exec.E2BOption
+	if o.Template != "" {
+		eo = append(eo, exec.WithE2BTemplate(o.Template))
+	}
+	if o.Timeout > 0 {
+		eo = append(eo, exec.WithE2BTimeout(time.Duration(o.Timeout)))
+	}
+	if len(o.Packages) > 0 {
+		eo = append(eo, exec.WithE2BPackages(o.Packages...))
+	}
+	return exec.NewE2BExecutor(o.APIKey, eo...), nil
+}
+
+func newRemoteExecutor(opts Options, _ []neko.Tool) (neko.CodeExecutor, error) {
+	var o struct {
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	if o.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	var ro []exec.RemoteOption
+	if o.Token != "" {
+		ro = append(ro, exec.WithRemoteToken(o.Token))
+	}
+	return exec.NewRemoteExecutor(o.URL, ro...), nil
+}
+
+// openAIOptions are the options of models served with an
+// OpenAI-compatible API.
+type openAIOptions struct {
+	// ToolCalling false makes tool-calling agents prompt for tool
+	// calls, for backends without function calling.
+	ToolCalling *bool `json:"tool_calling"`
+	// Messages is "merge" to merge adjacent messages with the same
+	// role, or "strict" for strict user/assistant alternation.
+	Messages string `json:"messages"`
+	// Proxy is the URL of an HTTP proxy to send requests through.
+	Proxy string `json:"proxy"`
+}
+
+// modelOptions returns the options for a model configured by mc and o.
+func (o *openAIOptions) modelOptions(mc ModelConfig) ([]neko.OpenAIOption, error) {
+	var opts []neko.OpenAIOption
+	if mc.Temperature != nil {
+		opts = append(opts, neko.WithOpenAITemperature(*mc.Temperature))
+	}
+	if mc.MaxTokens > 0 {
+		opts = append(opts, neko.WithOpenAIMaxTokens(mc.MaxTokens))
+	}
+	if o.ToolCalling != nil {
+		opts = append(opts, neko.WithOpenAIToolCalling(*o.ToolCalling))
+	}
+	proxy, err := proxyClient(o.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		opts = append(opts, neko.WithOpenAIHTTPClient(proxy))
+	}
+	switch o.Messages {
+	case "":
+	case "merge":
+		opts = append(opts, neko.WithOpenAIMessageNormalizer(neko.MergeAdjacentMessages))
+	case "strict":
+		opts = append(opts, neko.WithOpenAIMessageNormalizer(neko.StrictAlternation))
+	default:
+		return nil, fmt.Errorf("unknown messages mode %q: want merge or strict", o.Messages)
+	}
+	return opts, nil
+}
+
+func newOpenAIModel(mc ModelConfig) (neko.Model, error) {
+	var o openAIOptions
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	apiKey, baseURL := mc.APIKey, mc.BaseURL
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	opts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		return neko.NewOpenAIModel(mc.ID, apiKey, opts...), nil
+	}
+	return neko.NewOpenAIModelWithBaseURL(mc.ID, apiKey, baseURL, opts...), nil
+}
+
+// newAzureOpenAIModel creates a model for the Azure OpenAI deployment
+// named by ID, at the endpoint in BaseURL.
+func newAzureOpenAIModel(mc ModelConfig) (neko.Model, error) {
+	var o struct {
+		openAIOptions
+		APIVersion string `json:"api_version"`
+	}
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required: the deployment name")
+	}
+	apiKey, endpoint := mc.APIKey, mc.BaseURL
+	if apiKey == "" {
+		apiKey = os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	if endpoint == "" {
+		endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	if apiKey == "" || endpoint == "" {
+		return nil, fmt.Errorf("api_key and base_url, or AZURE_OPENAI_API_KEY and AZURE_OPENAI_ENDPOINT, are required")
+	}
+	opts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	if o.APIVersion != "" {
+		opts = append(opts, neko.WithAzureAPIVersion(o.APIVersion))
+	}
+	return neko.NewAzureOpenAIModel(mc.ID, endpoint, apiKey, opts...), nil
+}
+
+// newHFInferenceModel creates a model for the Hugging Face Hub model ID,
+// served serverless or, with BaseURL, by a dedicated endpoint.
+func newHFInferenceModel(mc ModelConfig) (neko.Model, error) {
+	var o struct {
+		openAIOptions
+		// PromptTemplate is "chatml" or "llama3" to render prompts
+		// locally, for models served without a chat template.
+		PromptTemplate string `json:"prompt_template"`
+	}
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	token := mc.APIKey
+	if token == "" {
+		token = os.Getenv("HF_TOKEN")
+	}
+	// The proxy also serves text generation requests.
+	proxy, err := proxyClient(o.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	o.Proxy = ""
+	openAIOpts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	opts := []neko.HFInferenceOption{neko.WithHFOpenAIOptions(openAIOpts...)}
+	if proxy != nil {
+		opts = append(opts, neko.WithHFHTTPClient(proxy))
+	}
+	if mc.BaseURL != "" {
+		opts = append(opts, neko.WithHFEndpoint(mc.BaseURL))
+	}
+	switch o.PromptTemplate {
+	case "":
+	case "chatml":
+		opts = append(opts, neko.WithHFPromptTemplate(neko.ChatMLTemplate))
+	case "llama3":
+		opts = append(opts, neko.WithHFPromptTemplate(neko.Llama3Template))
+	default:
+		return nil, fmt.Errorf("unknown prompt_template %q: want chatml or llama3", o.PromptTemplate)
+	}
+	return neko.NewHFInferenceModel(mc.ID, token, opts...), nil
+}
+
+// newGroqModel creates a Groq model, using GROQ_API_KEY when APIKey is
+// empty.
+func newGroqModel(mc ModelConfig) (neko.Model, error) {
+	var o openAIOptions
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	apiKey := mc.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GROQ_API_KEY")
+	}
+	openAIOpts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	opts := []neko.GroqOption{neko.WithGroqOpenAIOptions(openAIOpts...)}
+	if mc.BaseURL != "" {
+		opts = append(opts, neko.WithGroqBaseURL(mc.BaseURL))
+	}
+	return neko.NewGroqModel(mc.ID, apiKey, opts...), nil
+}
+
+// newDeepSeekModel creates a DeepSeek model, using DEEPSEEK_API_KEY when
+// APIKey is empty.
+func newDeepSeekModel(mc ModelConfig) (neko.Model, error) {
+	var o openAIOptions
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	apiKey := mc.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("DEEPSEEK_API_KEY")
+	}
+	openAIOpts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	opts := []neko.DeepSeekOption{neko.WithDeepSeekOpenAIOptions(openAIOpts...)}
+	if mc.BaseURL != "" {
+		opts = append(opts, neko.WithDeepSeekBaseURL(mc.BaseURL))
+	}
+	return neko.NewDeepSeekModel(mc.ID, apiKey, opts...), nil
+}
+
+// newGrokModel creates an xAI Grok model, using XAI_API_KEY when APIKey
+// is empty.
+func newGrokModel(mc ModelConfig) (neko.Model, error) {
+	var o struct {
+		openAIOptions
+		// LiveSearch enables live search for every call.
+		LiveSearch *struct {
+			Mode       string                  `json:"mode"`
+			Sources    []neko.LiveSearchSource `json:"sources"`
+			MaxResults int                     `json:"max_results"`
+		} `json:"live_search"`
+	}
+	if err := mc.Options.Decode(&o); err != nil {
+		return nil, err
+	}
+	if mc.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	apiKey := mc.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("XAI_API_KEY")
+	}
+	openAIOpts, err := o.modelOptions(mc)
+	if err != nil {
+		return nil, err
+	}
+	opts := []neko.GrokOption{neko.WithGrokOpenAIOptions(openAIOpts...)}
+	if mc.BaseURL != "" {
+		opts = append(opts, neko.WithGrokBaseURL(mc.BaseURL))
+	}
+	if s := o.LiveSearch; s != nil {
+		switch s.Mode {
+		case "", neko.LiveSearchAuto, neko.LiveSearchOn, neko.LiveSearchOff:
+		default:
+			return nil, fmt.Errorf("unknown live_search mode %q: want auto, on or off", s.Mode)
+		}
+		opts = append(opts, neko.WithGrokLiveSearch(neko.LiveSearch{Mode: s.Mode, Sources: s.Sources, MaxResults: s.MaxResults}))
+	}
+	return neko.NewGrokModel(mc.ID, apiKey, opts...), nil
+}
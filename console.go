@@ -0,0 +1,165 @@
+package neko
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ANSI styles used by the console reporter.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+const consoleWidth = 80
+
+// WithConsoleOutput pretty-prints the task, each step and the final
+// answer to w as the run progresses: the model's thought, the code or tool
+// calls it produced, observations, errors, durations and token counts.
+// Output is colored when w is a terminal and NO_COLOR is unset. A nil w
+// writes to os.Stdout.
+func WithConsoleOutput(w io.Writer) AgentOption {
+	return func(a *BaseAgent) {
+		if w == nil {
+			w = os.Stdout
+		}
+		c := newConsoleReporter(w)
+		a.events.Subscribe(EventStep, func(e Event) { c.report(e.(StepEvent).Step) })
+		a.events.Subscribe(EventBudgetWarning, func(e Event) { c.budgetWarning(e.(*BudgetWarning)) })
+		a.events.Subscribe(EventAdaptation, func(e Event) { c.adaptation(e.(*Adaptation)) })
+	}
+}
+
+// consoleReporter renders steps as text panels.
+type consoleReporter struct {
+	w     io.Writer
+	color bool
+}
+
+func newConsoleReporter(w io.Writer) *consoleReporter {
+	return &consoleReporter{w: w, color: isTerminal(w) && os.Getenv("NO_COLOR") == ""}
+}
+
+func (c *consoleReporter) report(step Step) {
+	switch s := step.(type) {
+	case *TaskStep:
+		c.panel("New run", ansiBold+ansiBlue, s.Task)
+	case *PlanningStep:
+		c.panel("Plan", ansiCyan, s.Plan)
+		c.footer(s.Timing.Duration, s.TokenUsage)
+	case *ActionStep:
+		c.action(s)
+	case *FinalAnswerStep:
+		fmt.Fprintf(c.w, "%s\n\n", c.style(ansiBold+ansiYellow, fmt.Sprintf("Final answer: %v", s.Output)))
+	}
+}
+
+func (c *consoleReporter) budgetWarning(w *BudgetWarning) {
+	fmt.Fprintf(c.w, "%s\n\n", c.style(ansiBold+ansiRed, "Budget warning: "+w.String()))
+}
+
+func (c *consoleReporter) adaptation(e *Adaptation) {
+	fmt.Fprintf(c.w, "%s\n\n", c.style(ansiBold+ansiYellow, "Adapting run: "+e.String()))
+}
+
+func (c *consoleReporter) action(s *ActionStep) {
+	c.rule(fmt.Sprintf(" Step %d ", s.StepNumber))
+	if thought := consoleThought(s.ModelOutput); thought != "" {
+		fmt.Fprintf(c.w, "%s\n", c.style(ansiDim, thought))
+	}
+	if s.CodeAction != "" {
+		c.panel("Code", ansiCyan, s.CodeAction)
+	}
+	for _, tc := range s.ToolCalls {
+		c.panel("Tool call: "+tc.Name, ansiCyan, formatArguments(tc.Arguments))
+	}
+	if s.Observations != "" {
+		c.panel("Observations", ansiGreen, s.Observations)
+	}
+	for _, art := range s.Artifacts {
+		fmt.Fprintf(c.w, "%s\n", c.style(ansiDim, "Artifact: "+art.Name))
+	}
+	if s.Error != nil {
+		c.panel("Error", ansiRed, s.Error.Error())
+	}
+	c.footer(s.Timing.Duration, s.TokenUsage)
+}
+
+func (c *consoleReporter) rule(title string) {
+	side := (consoleWidth - len(title)) / 2
+	if side < 2 {
+		side = 2
+	}
+	line := strings.Repeat("━", side) + title + strings.Repeat("━", side)
+	fmt.Fprintf(c.w, "%s\n", c.style(ansiBold+ansiYellow, line))
+}
+
+func (c *consoleReporter) panel(title, color, body string) {
+	top := "╭─ " + title + " "
+	if n := consoleWidth - len([]rune(top)); n > 0 {
+		top += strings.Repeat("─", n)
+	}
+	fmt.Fprintf(c.w, "%s\n", c.style(color, top))
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		fmt.Fprintf(c.w, "%s %s\n", c.style(color, "│"), line)
+	}
+	fmt.Fprintf(c.w, "%s\n", c.style(color, "╰"+strings.Repeat("─", consoleWidth-1)))
+}
+
+func (c *consoleReporter) footer(d time.Duration, tokens *TokenUsage) {
+	text := fmt.Sprintf("[Duration %.2fs", d.Seconds())
+	if tokens != nil {
+		text += fmt.Sprintf(" | Input tokens: %d | Output tokens: %d", tokens.InputTokens, tokens.OutputTokens)
+	}
+	fmt.Fprintf(c.w, "%s\n\n", c.style(ansiDim, text+"]"))
+}
+
+func (c *consoleReporter) style(code, text string) string {
+	if !c.color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// consoleThought returns the part of a model response before its code.
+func consoleThought(output string) string {
+	for _, marker := range []string{"<code>", "```"} {
+		if i := strings.Index(output, marker); i >= 0 {
+			output = output[:i]
+		}
+	}
+	return strings.TrimSpace(output)
+}
+
+func formatArguments(args map[string]any) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("%s: %v", k, args[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,75 @@
+package neko
+
+import (
+	"context"
+	"strings"
+
+	"github.com/openai/openai-go/v3/option"
+)
+
+// DefaultDeepSeekBaseURL is DeepSeek's OpenAI-compatible API.
+const DefaultDeepSeekBaseURL = "https://api.deepseek.com"
+
+// DeepSeekModel is a model served by DeepSeek, e.g. "deepseek-chat" or
+// the reasoning model "deepseek-reasoner". The reasoner's chain of
+// thought is returned in Message.Reasoning, apart from its answer, and
+// agents record it in ActionStep.Reasoning; as DeepSeek requires, it is
+// not sent back with later requests. Since the reasoner rejects
+// consecutive messages with the same role, messages are sent in strict
+// alternation.
+type DeepSeekModel struct {
+	chat    *OpenAIModel
+	baseURL string
+	openAI  []OpenAIOption
+}
+
+// DeepSeekOption configures a DeepSeekModel.
+type DeepSeekOption func(*DeepSeekModel)
+
+// WithDeepSeekOpenAIOptions configures the underlying OpenAI-compatible
+// client, e.g. with WithOpenAIMaxTokens or WithOpenAIHTTPClient.
+func WithDeepSeekOpenAIOptions(opts ...OpenAIOption) DeepSeekOption {
+	return func(m *DeepSeekModel) { m.openAI = append(m.openAI, opts...) }
+}
+
+// WithDeepSeekBaseURL sends requests to url instead of
+// DefaultDeepSeekBaseURL.
+func WithDeepSeekBaseURL(url string) DeepSeekOption {
+	return func(m *DeepSeekModel) { m.baseURL = url }
+}
+
+// NewDeepSeekModel creates a DeepSeek model. Reasoner models get room for
+// their chain of thought, which counts towards the maximum tokens: 32768
+// unless set with WithOpenAIMaxTokens.
+func NewDeepSeekModel(modelID, apiKey string, opts ...DeepSeekOption) *DeepSeekModel {
+	m := &DeepSeekModel{baseURL: DefaultDeepSeekBaseURL}
+	for _, opt := range opts {
+		opt(m)
+	}
+	chatOpts := []OpenAIOption{WithOpenAIMessageNormalizer(StrictAlternation)}
+	if strings.Contains(modelID, "reasoner") {
+		chatOpts = append(chatOpts, WithOpenAIMaxTokens(32768))
+	}
+	m.chat = newOpenAIModel(modelID, append(chatOpts, m.openAI...), option.WithAPIKey(apiKey), option.WithBaseURL(m.baseURL))
+	return m
+}
+
+func (m *DeepSeekModel) ModelID() string { return m.chat.ModelID() }
+
+func (m *DeepSeekModel) Provider() string { return "deepseek" }
+
+// SupportsToolCalling reports whether DeepSeek has not rejected tools for
+// the model.
+func (m *DeepSeekModel) SupportsToolCalling() bool { return m.chat.SupportsToolCalling() }
+
+// Generate sends messages to DeepSeek and returns its response, with the
+// reasoner's chain of thought in Reasoning.
+func (m *DeepSeekModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
+	return m.chat.Generate(ctx, messages, opts...)
+}
+
+// GenerateStream streams DeepSeek's response. The reasoner streams its
+// chain of thought in the Reasoning of deltas before the answer.
+func (m *DeepSeekModel) GenerateStream(ctx context.Context, messages []Message, opts ...GenerateOption) (<-chan StreamDelta, error) {
+	return m.chat.GenerateStream(ctx, messages, opts...)
+}
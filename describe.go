@@ -0,0 +1,121 @@
+package neko
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Agent types reported in a Manifest.
+const (
+	AgentTypeToolCalling = "tool_calling"
+	AgentTypeCode        = "code"
+)
+
+// Manifest is a machine-readable description of what an agent can do, for
+// discovery endpoints and for orchestrators choosing sub-agents.
+type Manifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`     // AgentTypeToolCalling or AgentTypeCode
+	Language    string `json:"language,omitempty"` // the language a code agent writes
+	Model       string `json:"model,omitempty"`
+	// Tools lists the tools the agent can call, in name order, excluding
+	// final_answer and managed agents.
+	Tools         []ToolSchema   `json:"tools,omitempty"`
+	ManagedAgents []*Manifest    `json:"managed_agents,omitempty"`
+	Skills        []string       `json:"skills,omitempty"`
+	Limits        ManifestLimits `json:"limits"`
+}
+
+// ManifestLimits are the limits an agent applies to its runs. Zero values
+// are unlimited.
+type ManifestLimits struct {
+	MaxSteps           int     `json:"max_steps"`
+	StepTimeoutSeconds float64 `json:"step_timeout_seconds,omitempty"`
+	MaxTokens          int     `json:"max_tokens,omitempty"`
+	MaxCost            float64 `json:"max_cost,omitempty"`
+	WorkspaceQuota     int64   `json:"workspace_quota,omitempty"` // bytes
+}
+
+// Describer is implemented by agents that can describe themselves.
+// Agents built by this package implement it.
+type Describer interface {
+	Describe() *Manifest
+}
+
+// Describe returns the agent's manifest. Managed agents that do not
+// implement Describer are listed with their name and description only.
+func (a *BaseAgent) Describe() *Manifest {
+	m := &Manifest{
+		Name:        a.name,
+		Description: a.description,
+		Type:        AgentTypeToolCalling,
+		Limits:      ManifestLimits{MaxSteps: a.maxSteps, StepTimeoutSeconds: a.stepTimeout.Seconds()},
+	}
+	if a.model != nil {
+		m.Model = a.model.ModelID()
+	}
+	for _, t := range a.tools.All() {
+		if t.Name() != "final_answer" {
+			m.Tools = append(m.Tools, ToolSchema{Name: t.Name(), Description: t.Description(), Inputs: t.Inputs(), OutputType: t.OutputType()})
+		}
+	}
+	sort.Slice(m.Tools, func(i, j int) bool { return m.Tools[i].Name < m.Tools[j].Name })
+	for name, agent := range a.managedAgents {
+		d := &Manifest{Name: name, Description: agent.Description()}
+		if da, ok := agent.(Describer); ok {
+			d = da.Describe()
+			d.Name = name
+		}
+		m.ManagedAgents = append(m.ManagedAgents, d)
+	}
+	sort.Slice(m.ManagedAgents, func(i, j int) bool { return m.ManagedAgents[i].Name < m.ManagedAgents[j].Name })
+	for _, s := range a.skills {
+		m.Skills = append(m.Skills, s.Name)
+	}
+	if b := a.budget; b != nil {
+		m.Limits.MaxTokens, m.Limits.MaxCost = b.MaxTokens, b.MaxCost
+	}
+	if w := a.workspace; w != nil {
+		m.Limits.WorkspaceQuota = w.quota
+	}
+	return m
+}
+
+// Describe returns the agent's manifest.
+func (a *CodeAgent) Describe() *Manifest {
+	m := a.BaseAgent.Describe()
+	m.Type = AgentTypeCode
+	m.Language = executorLanguage(a.executor)
+	return m
+}
+
+// Restrict returns a copy of m listing only the named tools and managed
+// agents, as seen by runs limited with WithAllowedTools.
+func (m *Manifest) Restrict(names ...string) *Manifest {
+	c := *m
+	c.Tools = slices.DeleteFunc(slices.Clone(m.Tools), func(t ToolSchema) bool { return !slices.Contains(names, t.Name) })
+	c.ManagedAgents = slices.DeleteFunc(slices.Clone(m.ManagedAgents), func(d *Manifest) bool { return !slices.Contains(names, d.Name) })
+	return &c
+}
+
+// String renders m as a short summary, e.g. for an orchestrator's prompt.
+func (m *Manifest) String() string {
+	var sb strings.Builder
+	sb.WriteString(m.Name)
+	if m.Description != "" {
+		sb.WriteString(": " + m.Description)
+	}
+	var names []string
+	for _, t := range m.Tools {
+		names = append(names, t.Name)
+	}
+	for _, d := range m.ManagedAgents {
+		names = append(names, d.Name)
+	}
+	if len(names) > 0 {
+		sb.WriteString(" (tools: " + strings.Join(names, ", ") + ")")
+	}
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// Embedder turns texts into embedding vectors, one per text, for
+// similarity search.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// OpenAIEmbedder embeds texts with an OpenAI-compatible embeddings API.
+type OpenAIEmbedder struct {
+	client  openai.Client
+	modelID string
+}
+
+// EmbedderOption configures OpenAIEmbedder.
+type EmbedderOption func(*[]option.RequestOption)
+
+// WithEmbedderHTTPClient sends requests with c, e.g. to go through a
+// proxy, present a client certificate or add tracing.
+func WithEmbedderHTTPClient(c *http.Client) EmbedderOption {
+	return func(o *[]option.RequestOption) { *o = append(*o, option.WithHTTPClient(c)) }
+}
+
+// NewOpenAIEmbedder creates an embedder using an OpenAI embedding model,
+// e.g. "text-embedding-3-small".
+func NewOpenAIEmbedder(modelID, apiKey string, opts ...EmbedderOption) *OpenAIEmbedder {
+	return newOpenAIEmbedder(modelID, opts, option.WithAPIKey(apiKey))
+}
+
+// NewOpenAIEmbedderWithBaseURL creates an embedder for an
+// OpenAI-compatible API with a custom base URL.
+func NewOpenAIEmbedderWithBaseURL(modelID, apiKey, baseURL string, opts ...EmbedderOption) *OpenAIEmbedder {
+	return newOpenAIEmbedder(modelID, opts, option.WithAPIKey(apiKey), option.WithBaseURL(baseURL))
+}
+
+func newOpenAIEmbedder(modelID string, opts []EmbedderOption, clientOpts ...option.RequestOption) *OpenAIEmbedder {
+	for _, opt := range opts {
+		opt(&clientOpts)
+	}
+	return &OpenAIEmbedder{client: openai.NewClient(clientOpts...), modelID: modelID}
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModel(e.modelID),
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	})
+	if err != nil {
+		return nil, NormalizeProviderError(openAIStatus(err), err)
+	}
+	out := make([][]float64, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || int(d.Index) >= len(out) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// if either is zero or their lengths differ.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
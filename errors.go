@@ -1,6 +1,11 @@
 package neko
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 // AgentError is the base error type for agent errors.
 type AgentError struct {
@@ -17,6 +22,93 @@ func (e *AgentError) Error() string {
 
 func (e *AgentError) Unwrap() error { return e.Cause }
 
+// ErrOutputLimit is matched by errors from executors that stopped code
+// because it wrote more output than allowed, truncating its logs.
+var ErrOutputLimit = errors.New("output limit exceeded")
+
+// ErrToolCallRejected is matched by errors from tool calls refused by the
+// agent's tool approval function.
+var ErrToolCallRejected = errors.New("tool call rejected")
+
+// ErrToolCallingUnsupported is matched by errors from models whose backend
+// rejected a request because it does not support function calling.
+var ErrToolCallingUnsupported = errors.New("tool calling not supported")
+
+// ErrToolCallCanceled is matched by errors from long tool calls canceled
+// by the agent's tool progress policy.
+var ErrToolCallCanceled = errors.New("tool call canceled")
+
+// ErrStepTimeout is matched by the error of an action step cancelled by
+// the agent's step timeout.
+var ErrStepTimeout = errors.New("step timeout")
+
+// ErrModelNotAllowed is matched by the error of a run, or model call,
+// using a model excluded by WithAllowedModels.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// Errors from model backends, normalized by NormalizeProviderError so
+// that retries and recovery policies can tell them apart whichever
+// backend produced them.
+var (
+	// ErrRateLimited is matched by errors from requests refused for the
+	// backend's rate limit or quota; they may succeed if retried later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrContextLength is matched by errors from requests whose messages
+	// do not fit the model's context window; retrying them as they are
+	// fails again.
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrContentFiltered is matched by errors from requests, or responses,
+	// blocked by the backend's content filter.
+	ErrContentFiltered = errors.New("content filtered")
+	// ErrInvalidToolSchema is matched by errors from requests rejected for
+	// the schema of one of their tools.
+	ErrInvalidToolSchema = errors.New("invalid tool schema")
+)
+
+// providerErrors maps fragments of backends' error messages and codes,
+// in lower case, to the errors they normalize to.
+var providerErrors = []struct {
+	err       error
+	fragments []string
+}{
+	{ErrContextLength, []string{"context_length_exceeded", "context length", "context window", "maximum context", "prompt is too long", "too many tokens", "input is too long", "exceeds the maximum number of tokens"}},
+	{ErrContentFiltered, []string{"content_filter", "content filter", "content management policy", "responsible ai", "safety settings", "blocked due to safety"}},
+	{ErrInvalidToolSchema, []string{"invalid schema for function", "invalid_function_parameters", "invalid function parameters", "tools.function.parameters", "invalid tool schema"}},
+	{ErrRateLimited, []string{"rate_limit", "rate limit", "too many requests", "resource_exhausted", "resource exhausted", "insufficient_quota", "overloaded"}},
+}
+
+// NormalizeProviderError wraps err, returned by a model backend, with the
+// neko error matching it: ErrRateLimited, ErrContextLength,
+// ErrContentFiltered or ErrInvalidToolSchema. status is the HTTP status
+// of the failed request, or 0 if unknown. Errors that match none, or
+// already match one, are returned as they are. Model implementations
+// call it on the errors of their backends.
+func NormalizeProviderError(status int, err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, e := range providerErrors {
+		if errors.Is(err, e.err) {
+			return err
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	for _, e := range providerErrors {
+		for _, f := range e.fragments {
+			if strings.Contains(msg, f) {
+				return fmt.Errorf("%w: %w", e.err, err)
+			}
+		}
+	}
+	if status == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+	if status == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("%w: %w", ErrContextLength, err)
+	}
+	return err
+}
+
 // Specific error types
 
 // ErrMaxSteps indicates the agent exceeded maximum steps.
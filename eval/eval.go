@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Result is the outcome of one case.
+type Result struct {
+	CaseID   string  `json:"case_id"`
+	Question string  `json:"question"`
+	Expected string  `json:"expected,omitempty"`
+	Answer   string  `json:"answer"`
+	Score    float64 `json:"score"`
+	State    string  `json:"state,omitempty"` // the run's state
+	Error    string  `json:"error,omitempty"` // a run or grading error
+	Steps    int     `json:"steps"`
+	// ToolCalls, RedundantToolCalls and FailedSteps describe the run's
+	// trajectory; see Trajectory.
+	ToolCalls          int               `json:"tool_calls"`
+	RedundantToolCalls int               `json:"redundant_tool_calls"`
+	FailedSteps        int               `json:"failed_steps"`
+	Tokens             neko.TokenUsage   `json:"tokens"`
+	Cost               float64           `json:"cost"`
+	Duration           time.Duration     `json:"duration"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// Correct reports whether the answer got a full score.
+func (r *Result) Correct() bool { return r.Score >= 1 }
+
+// Option configures Run.
+type Option func(*runner)
+
+type runner struct {
+	concurrency int
+	timeout     time.Duration
+	runOpts     []neko.RunOption
+	progress    func(Result)
+}
+
+// WithConcurrency sets how many cases run at once, each with its own
+// agent. Defaults to 1.
+func WithConcurrency(n int) Option {
+	return func(r *runner) { r.concurrency = n }
+}
+
+// WithTimeout cancels cases that take longer than d. Zero, the default,
+// means no limit.
+func WithTimeout(d time.Duration) Option {
+	return func(r *runner) { r.timeout = d }
+}
+
+// WithRunOptions adds options to every run, e.g. neko.WithMaxSteps.
+func WithRunOptions(opts ...neko.RunOption) Option {
+	return func(r *runner) { r.runOpts = opts }
+}
+
+// WithProgress calls fn with each case's result as it finishes. Calls
+// are serialized.
+func WithProgress(fn func(Result)) Option {
+	return func(r *runner) { r.progress = fn }
+}
+
+// Run runs agents made by newAgent on every case of suite and grades their
+// answers. newAgent is called once per concurrent slot, and memory is
+// reset for every case. Failed runs count as wrong answers; Run returns an
+// error only if an agent cannot be created or ctx ends.
+func Run(ctx context.Context, suite *Suite, newAgent func() (neko.Agent, error), opts ...Option) (*Report, error) {
+	r := &runner{concurrency: 1}
+	for _, opt := range opts {
+		opt(r)
+	}
+	grader := suite.Grader
+	if grader == nil {
+		grader = ExactMatch()
+	}
+
+	report := &Report{Suite: suite.Name, StartedAt: time.Now().UTC(), Results: make([]Result, len(suite.Cases))}
+	cases := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		agentErr error
+	)
+	for range min(r.concurrency, len(suite.Cases)) {
+		agent, err := newAgent()
+		if err != nil {
+			agentErr = fmt.Errorf("create agent: %w", err)
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range cases {
+				c := suite.Cases[i]
+				g := grader
+				if c.Grader != nil {
+					g = c.Grader
+				}
+				res := r.run(ctx, agent, c, g)
+				mu.Lock()
+				report.Results[i] = res
+				if r.progress != nil {
+					r.progress(res)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	if agentErr == nil {
+	feed:
+		for i := range suite.Cases {
+			select {
+			case cases <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(cases)
+	wg.Wait()
+	if agentErr != nil {
+		return nil, agentErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report.summarize()
+	return report, nil
+}
+
+func (r *runner) run(ctx context.Context, agent neko.Agent, c Case, grader Grader) Result {
+	res := Result{CaseID: c.ID, Question: c.Question, Expected: c.Expected, Metadata: c.Metadata}
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	opts := append([]neko.RunOption{neko.WithReset(true)}, r.runOpts...)
+	if len(c.Images) > 0 {
+		opts = append(opts, neko.WithImages(c.Images...))
+	}
+
+	start := time.Now()
+	out, err := agent.Run(ctx, c.Question, opts...)
+	res.Duration = time.Since(start)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.State, res.Cost = out.State, out.Cost
+	if out.TokenUsage != nil {
+		res.Tokens = *out.TokenUsage
+	}
+	t := TrajectoryOf(out)
+	res.Steps, res.ToolCalls, res.RedundantToolCalls, res.FailedSteps = t.Steps, t.ToolCalls, t.RedundantToolCalls, t.FailedSteps
+	if out.Output != nil {
+		res.Answer = fmt.Sprint(out.Output)
+	}
+	if res.Score, err = grader.Grade(ctx, c, res.Answer); err != nil {
+		res.Score, res.Error = 0, "grade: "+err.Error()
+	}
+	return res
+}
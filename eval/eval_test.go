@@ -0,0 +1,35 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+func TestCaseImagesReachModel(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	model := testutil.NewReplayModel(&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{
+		{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": "cat"}},
+	}})
+	newAgent := func() (neko.Agent, error) {
+		return neko.NewToolCallingAgent(neko.WithModel(model), neko.WithModelCapabilities(neko.Capabilities{Tools: true, Vision: true})), nil
+	}
+	suite := &Suite{Name: "vision", Cases: []Case{{ID: "1", Question: "what is this?", Expected: "cat", Images: [][]byte{image}}}}
+	report, err := Run(context.Background(), suite, newAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Results[0].Correct() {
+		t.Errorf("result = %+v, want the expected answer", report.Results[0])
+	}
+	var got [][]byte
+	for _, msg := range model.Requests()[0] {
+		got = append(got, msg.Images...)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], image) {
+		t.Errorf("model saw images %q, want the case's image", got)
+	}
+}
@@ -0,0 +1,242 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Arm is one of the two agent configurations compared by Compare, e.g.
+// a system prompt or model under test.
+type Arm struct {
+	Name string
+	// NewAgent creates the arm's agent. It must apply opts, which record
+	// the run's trace, after its own options.
+	NewAgent func(opts ...neko.AgentOption) (neko.Agent, error)
+}
+
+// ArmResult is how one arm did on the task.
+type ArmResult struct {
+	Arm        string          `json:"arm"`
+	Answer     string          `json:"answer"`
+	State      string          `json:"state,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Tokens     neko.TokenUsage `json:"tokens"`
+	Cost       float64         `json:"cost"`
+	Duration   time.Duration   `json:"duration"`
+	Trajectory Trajectory      `json:"trajectory"`
+	Result     *neko.RunResult `json:"-"`
+	Trace      []byte          `json:"-"` // the run's JSONL trace; see neko.LoadTrace
+}
+
+// Judge verdicts.
+const (
+	VerdictA   = "A"
+	VerdictB   = "B"
+	VerdictTie = "tie"
+)
+
+// Comparison is the report of Compare.
+type Comparison struct {
+	Task string    `json:"task"`
+	A    ArmResult `json:"a"`
+	B    ArmResult `json:"b"`
+	// Verdict is the judge's preference: VerdictA, VerdictB or
+	// VerdictTie, or empty without a judge or if it failed.
+	Verdict string `json:"verdict,omitempty"`
+	Winner  string `json:"winner,omitempty"` // the preferred arm's name
+	Reason  string `json:"reason,omitempty"`
+	// JudgeError is why there is no verdict, if the judge failed.
+	JudgeError string `json:"judge_error,omitempty"`
+}
+
+// CompareOption configures Compare.
+type CompareOption func(*comparer)
+
+type comparer struct {
+	judge   neko.Model
+	fork    *neko.Memory
+	runOpts []neko.RunOption
+}
+
+// WithJudge has model say which arm's answer is better. The answers are
+// judged twice, in both orders, and a preference that changes with the
+// order is reported as a tie.
+func WithJudge(model neko.Model) CompareOption {
+	return func(c *comparer) { c.judge = model }
+}
+
+// WithFork has both arms continue the conversation in m, e.g. a
+// session's memory, instead of starting fresh, so that the same
+// conversation can be taken two ways.
+func WithFork(m *neko.Memory) CompareOption {
+	return func(c *comparer) { c.fork = m }
+}
+
+// WithCompareRunOptions adds options to both runs, e.g. WithMaxSteps.
+func WithCompareRunOptions(opts ...neko.RunOption) CompareOption {
+	return func(c *comparer) { c.runOpts = opts }
+}
+
+// Compare runs task with the agents of arms a and b, concurrently, and
+// reports how each did, with a verdict if a judge is set. An arm whose
+// run fails is reported with its error; the returned error is only for
+// agents that cannot be created.
+func Compare(ctx context.Context, task string, a, b Arm, opts ...CompareOption) (*Comparison, error) {
+	c := &comparer{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	cmp := &Comparison{Task: task}
+	arms := []*ArmResult{&cmp.A, &cmp.B}
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, arm := range []Arm{a, b} {
+		wg.Go(func() {
+			*arms[i], errs[i] = c.run(ctx, task, arm)
+		})
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("arm %s: %w", arms[i].Arm, err)
+		}
+	}
+	if c.judge != nil && cmp.A.Error == "" && cmp.B.Error == "" {
+		if err := c.decide(ctx, cmp); err != nil {
+			cmp.JudgeError = err.Error()
+		}
+	}
+	return cmp, nil
+}
+
+func (c *comparer) run(ctx context.Context, task string, arm Arm) (ArmResult, error) {
+	res := ArmResult{Arm: arm.Name}
+	var trace bytes.Buffer
+	agent, err := arm.NewAgent(neko.WithTraceWriter(&trace))
+	if err != nil {
+		return res, err
+	}
+	runOpts := []neko.RunOption{neko.WithReset(true)}
+	if c.fork != nil {
+		runOpts = []neko.RunOption{neko.WithMemory(c.fork)}
+	}
+	runOpts = append(runOpts, c.runOpts...)
+
+	start := time.Now()
+	out, err := agent.Run(ctx, task, runOpts...)
+	res.Duration = time.Since(start)
+	res.Trace = trace.Bytes()
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if out == nil {
+		return res, nil
+	}
+	res.Result, res.State, res.Cost = out, out.State, out.Cost
+	if out.TokenUsage != nil {
+		res.Tokens = *out.TokenUsage
+	}
+	res.Trajectory = TrajectoryOf(out)
+	if out.Output != nil {
+		res.Answer = fmt.Sprint(out.Output)
+	}
+	return res, nil
+}
+
+// decide asks the judge for its preference with each answer first, and
+// keeps it if both agree.
+func (c *comparer) decide(ctx context.Context, cmp *Comparison) error {
+	first, reason, err := c.prefer(ctx, cmp.Task, cmp.A.Answer, cmp.B.Answer)
+	if err != nil {
+		return err
+	}
+	second, _, err := c.prefer(ctx, cmp.Task, cmp.B.Answer, cmp.A.Answer)
+	if err != nil {
+		return err
+	}
+	switch {
+	case first == "1" && second == "2":
+		cmp.Verdict, cmp.Winner = VerdictA, cmp.A.Arm
+	case first == "2" && second == "1":
+		cmp.Verdict, cmp.Winner = VerdictB, cmp.B.Arm
+	default:
+		cmp.Verdict = VerdictTie
+	}
+	cmp.Reason = reason
+	return nil
+}
+
+var preference = regexp.MustCompile(`(?i)\b(1|2|tie)\b`)
+
+// prefer asks the judge which of two answers is better, returning "1",
+// "2" or "tie" and its reason.
+func (c *comparer) prefer(ctx context.Context, task, first, second string) (string, string, error) {
+	prompt := fmt.Sprintf(`Compare two answers to the same task and say which is better: more correct, complete and helpful. Ignore length unless it hurts the answer.
+
+Task: %s
+
+Answer 1:
+%s
+
+Answer 2:
+%s
+
+Reply with 1, 2 or TIE on the first line, then one sentence explaining why.`, task, first, second)
+	resp, err := c.judge.Generate(ctx, []neko.Message{{Role: neko.RoleUser, Content: prompt}})
+	if err != nil {
+		return "", "", err
+	}
+	line, reason, _ := strings.Cut(strings.TrimSpace(resp.Content), "\n")
+	m := preference.FindString(line)
+	if m == "" {
+		return "", "", fmt.Errorf("judge gave no preference: %q", line)
+	}
+	return strings.ToLower(m), strings.TrimSpace(reason), nil
+}
+
+// String returns a one-line summary.
+func (c *Comparison) String() string {
+	arm := func(r ArmResult) string {
+		if r.Error != "" {
+			return fmt.Sprintf("%s failed (%s)", r.Arm, r.Error)
+		}
+		return fmt.Sprintf("%s %d steps, %d tokens, $%.4f, %s", r.Arm, r.Trajectory.Steps, r.Tokens.Total(), r.Cost, r.Duration.Round(time.Millisecond))
+	}
+	s := arm(c.A) + " vs " + arm(c.B)
+	switch c.Verdict {
+	case VerdictA, VerdictB:
+		s += "; judge prefers " + c.Winner
+	case VerdictTie:
+		s += "; judge: tie"
+	}
+	return s
+}
+
+// WriteJSON writes the comparison, without traces, as indented JSON.
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// WriteTraces writes each arm's trace to dir, as a.jsonl and b.jsonl.
+func (c *Comparison) WriteTraces(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.jsonl"), c.A.Trace, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "b.jsonl"), c.B.Trace, 0o644)
+}
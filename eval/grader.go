@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gocnn/neko"
+)
+
+// Grader scores an answer to a case from 0 (wrong) to 1 (right).
+type Grader interface {
+	Grade(ctx context.Context, c Case, answer string) (float64, error)
+}
+
+// GraderFunc adapts a function to the Grader interface.
+type GraderFunc func(ctx context.Context, c Case, answer string) (float64, error)
+
+// Grade calls f(ctx, c, answer).
+func (f GraderFunc) Grade(ctx context.Context, c Case, answer string) (float64, error) {
+	return f(ctx, c, answer)
+}
+
+// ExactMatch compares the answer with the expected one the way the GAIA
+// benchmark does: numbers are compared as numbers, ignoring units and
+// thousands separators; comma- or semicolon-separated lists element by
+// element; and other strings ignoring case, whitespace and punctuation.
+func ExactMatch() Grader {
+	return GraderFunc(func(ctx context.Context, c Case, answer string) (float64, error) {
+		if matchAnswer(c.Expected, answer) {
+			return 1, nil
+		}
+		return 0, nil
+	})
+}
+
+// Contains scores answers that contain the expected answer, ignoring case.
+func Contains() Grader {
+	return GraderFunc(func(ctx context.Context, c Case, answer string) (float64, error) {
+		if strings.Contains(strings.ToLower(answer), strings.ToLower(c.Expected)) {
+			return 1, nil
+		}
+		return 0, nil
+	})
+}
+
+// ModelGrader asks model to judge whether the answer agrees with the
+// expected one, for free-form answers that exact matching would reject.
+func ModelGrader(model neko.Model) Grader {
+	return GraderFunc(func(ctx context.Context, c Case, answer string) (float64, error) {
+		prompt := fmt.Sprintf(`Judge whether a candidate answer to a question is correct, given the reference answer.
+The candidate is correct if it means the same as the reference; ignore formatting and extra explanation.
+
+Question: %s
+Reference answer: %s
+Candidate answer: %s
+
+Reply with exactly one word: CORRECT or INCORRECT.`, c.Question, c.Expected, answer)
+		resp, err := model.Generate(ctx, []neko.Message{{Role: neko.RoleUser, Content: prompt}})
+		if err != nil {
+			return 0, err
+		}
+		verdict := strings.ToUpper(resp.Content)
+		if strings.Contains(verdict, "CORRECT") && !strings.Contains(verdict, "INCORRECT") {
+			return 1, nil
+		}
+		return 0, nil
+	})
+}
+
+func matchAnswer(expected, answer string) bool {
+	if n, ok := parseNumber(expected); ok {
+		got, ok := parseNumber(answer)
+		return ok && got == n
+	}
+	if strings.ContainsAny(expected, ",;") {
+		want, got := splitList(expected), splitList(answer)
+		if len(want) != len(got) {
+			return false
+		}
+		for i := range want {
+			if !matchAnswer(want[i], got[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return normalize(expected) == normalize(answer)
+}
+
+// parseNumber reads a number, ignoring currency and percent signs and
+// thousands separators.
+func parseNumber(s string) (float64, bool) {
+	s = strings.NewReplacer("$", "", "%", "", ",", "").Replace(strings.TrimSpace(s))
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' })
+}
+
+func normalize(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+package eval
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Report summarizes a suite run.
+type Report struct {
+	Suite      string            `json:"suite"`
+	StartedAt  time.Time         `json:"started_at"`
+	Duration   time.Duration     `json:"duration"`
+	Cases      int               `json:"cases"`
+	Correct    int               `json:"correct"`
+	Accuracy   float64           `json:"accuracy"` // mean score
+	Errors     int               `json:"errors"`
+	Tokens     neko.TokenUsage   `json:"tokens"`
+	Cost       float64           `json:"cost"`
+	LatencyP50 time.Duration     `json:"latency_p50"`
+	LatencyP95 time.Duration     `json:"latency_p95"`
+	Trajectory TrajectoryMetrics `json:"trajectory"`
+	Results    []Result          `json:"results"`
+}
+
+func (r *Report) summarize() {
+	r.Duration = time.Since(r.StartedAt)
+	r.Cases = len(r.Results)
+	var score float64
+	latencies := make([]time.Duration, 0, len(r.Results))
+	trajectories := make([]Trajectory, 0, len(r.Results))
+	for _, res := range r.Results {
+		score += res.Score
+		if res.Correct() {
+			r.Correct++
+		}
+		if res.Error != "" {
+			r.Errors++
+		}
+		r.Tokens.InputTokens += res.Tokens.InputTokens
+		r.Tokens.OutputTokens += res.Tokens.OutputTokens
+		r.Cost += res.Cost
+		latencies = append(latencies, res.Duration)
+		trajectories = append(trajectories, Trajectory{
+			Steps: res.Steps, ToolCalls: res.ToolCalls, RedundantToolCalls: res.RedundantToolCalls, FailedSteps: res.FailedSteps,
+			Tokens: res.Tokens.Total(), Cost: res.Cost, Correct: res.Correct(),
+		})
+	}
+	r.Trajectory = Summarize(trajectories)
+	if r.Cases == 0 {
+		return
+	}
+	r.Accuracy = score / float64(r.Cases)
+	slices.Sort(latencies)
+	r.LatencyP50 = percentile(latencies, 0.5)
+	r.LatencyP95 = percentile(latencies, 0.95)
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// String returns a one-line summary.
+func (r *Report) String() string {
+	return fmt.Sprintf("%s: %d/%d correct (%.1f%%), %d errors, %d tokens, $%.4f, p50 %s, p95 %s",
+		r.Suite, r.Correct, r.Cases, r.Accuracy*100, r.Errors, r.Tokens.Total(), r.Cost,
+		r.LatencyP50.Round(time.Millisecond), r.LatencyP95.Round(time.Millisecond))
+}
+
+// WriteJSON writes the report, with every result, as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per result. Durations are in seconds.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"case_id", "question", "expected", "answer", "score", "correct", "state", "error",
+		"steps", "input_tokens", "output_tokens", "cost", "duration",
+		"tool_calls", "redundant_tool_calls", "failed_steps"})
+	for _, res := range r.Results {
+		cw.Write([]string{
+			res.CaseID, res.Question, res.Expected, res.Answer,
+			strconv.FormatFloat(res.Score, 'g', -1, 64),
+			strconv.FormatBool(res.Correct()),
+			res.State, res.Error,
+			strconv.Itoa(res.Steps),
+			strconv.Itoa(res.Tokens.InputTokens),
+			strconv.Itoa(res.Tokens.OutputTokens),
+			strconv.FormatFloat(res.Cost, 'f', 6, 64),
+			strconv.FormatFloat(res.Duration.Seconds(), 'f', 3, 64),
+			strconv.Itoa(res.ToolCalls),
+			strconv.Itoa(res.RedundantToolCalls),
+			strconv.Itoa(res.FailedSteps),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Regressions returns the IDs of cases answered correctly in baseline, an
+// earlier report on the same suite, but not in r.
+func (r *Report) Regressions(baseline *Report) []string {
+	passed := make(map[string]bool)
+	for _, res := range baseline.Results {
+		passed[res.CaseID] = res.Correct()
+	}
+	var ids []string
+	for _, res := range r.Results {
+		if passed[res.CaseID] && !res.Correct() {
+			ids = append(ids, res.CaseID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,91 @@
+// Package eval runs an agent across a suite of tasks and reports its
+// accuracy, cost and latency, to track regressions when prompts or models
+// change:
+//
+//	suite, err := eval.LoadSuite("gaia-dev.jsonl")
+//	report, err := eval.Run(ctx, suite, newAgent, eval.WithConcurrency(4))
+//	report.WriteCSV(os.Stdout)
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Case is one task of a suite.
+type Case struct {
+	ID       string            `json:"id"`
+	Question string            `json:"question"`
+	Expected string            `json:"expected,omitempty"`
+	Images   [][]byte          `json:"images,omitempty"`   // base64 in JSON
+	Metadata map[string]string `json:"metadata,omitempty"` // e.g. a difficulty level, copied to the Result
+	// Grader overrides the suite's grader for this case.
+	Grader Grader `json:"-"`
+}
+
+// Suite is a named set of cases.
+type Suite struct {
+	Name  string `json:"name"`
+	Cases []Case `json:"cases"`
+	// Grader scores answers. Defaults to ExactMatch.
+	Grader Grader `json:"-"`
+}
+
+// LoadSuite reads a suite from a JSON file holding a Suite, or a JSON Lines
+// file (.jsonl) holding one Case per line. A JSON Lines suite is named
+// after its file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) != ".jsonl" {
+		var s Suite
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &s, s.check()
+	}
+
+	s := &Suite{Name: strings.TrimSuffix(filepath.Base(path), ".jsonl")}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 64<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, s.check()
+}
+
+// check validates cases and numbers those without an ID.
+func (s *Suite) check() error {
+	seen := make(map[string]bool)
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.ID == "" {
+			c.ID = fmt.Sprint(i + 1)
+		}
+		if c.Question == "" {
+			return fmt.Errorf("case %s: question is required", c.ID)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("duplicate case ID %q", c.ID)
+		}
+		seen[c.ID] = true
+	}
+	return nil
+}
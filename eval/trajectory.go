@@ -0,0 +1,135 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gocnn/neko"
+)
+
+// Trajectory is how one run got to its answer.
+type Trajectory struct {
+	Steps int `json:"steps"` // action steps
+	// ToolCalls counts the tool calls made, excluding final_answer. For a
+	// CodeAgent each code action counts as one call.
+	ToolCalls int `json:"tool_calls"`
+	// RedundantToolCalls counts calls repeating an earlier call of the
+	// run with the same tool and arguments, or the same code.
+	RedundantToolCalls int     `json:"redundant_tool_calls"`
+	FailedSteps        int     `json:"failed_steps"` // action steps with an error
+	Tokens             int     `json:"tokens"`
+	Cost               float64 `json:"cost"`
+	Correct            bool    `json:"correct"`
+}
+
+// TrajectoryOf returns the trajectory of result. Whether it is correct is
+// left to the caller.
+func TrajectoryOf(result *neko.RunResult) Trajectory {
+	t := Trajectory{Cost: result.Cost}
+	if result.TokenUsage != nil {
+		t.Tokens = result.TokenUsage.Total()
+	}
+	seen := make(map[string]bool)
+	call := func(key string) {
+		t.ToolCalls++
+		if seen[key] {
+			t.RedundantToolCalls++
+		}
+		seen[key] = true
+	}
+	for _, step := range result.Steps {
+		s, ok := step.(*neko.ActionStep)
+		if !ok {
+			continue
+		}
+		t.Steps++
+		if s.Error != nil {
+			t.FailedSteps++
+		}
+		if s.CodeAction != "" {
+			call("code\x00" + s.CodeAction)
+		}
+		for _, tc := range s.ToolCalls {
+			if tc.Name == "final_answer" {
+				continue
+			}
+			args, _ := json.Marshal(tc.Arguments)
+			call(tc.Name + "\x00" + string(args))
+		}
+	}
+	return t
+}
+
+// TrajectoryMetrics summarizes the trajectories of a set of runs, for
+// comparing agents beyond their accuracy. Ratios are 0 when undefined.
+type TrajectoryMetrics struct {
+	Runs               int     `json:"runs"`
+	Correct            int     `json:"correct"`
+	MeanStepsToSuccess float64 `json:"mean_steps_to_success"` // over correct runs
+	ToolCalls          int     `json:"tool_calls"`
+	RedundantToolCalls int     `json:"redundant_tool_calls"`
+	RedundancyRate     float64 `json:"redundancy_rate"` // redundant share of tool calls
+	Steps              int     `json:"steps"`
+	FailedSteps        int     `json:"failed_steps"`
+	ErrorRate          float64 `json:"error_rate"` // failed share of action steps
+	// TokensPerCorrect and CostPerCorrect divide the usage of all runs,
+	// including wrong ones, by the number of correct answers.
+	TokensPerCorrect float64 `json:"tokens_per_correct"`
+	CostPerCorrect   float64 `json:"cost_per_correct"`
+}
+
+// Summarize computes metrics over trajectories.
+func Summarize(trajectories []Trajectory) TrajectoryMetrics {
+	m := TrajectoryMetrics{Runs: len(trajectories)}
+	var tokens, successSteps int
+	var cost float64
+	for _, t := range trajectories {
+		if t.Correct {
+			m.Correct++
+			successSteps += t.Steps
+		}
+		m.ToolCalls += t.ToolCalls
+		m.RedundantToolCalls += t.RedundantToolCalls
+		m.Steps += t.Steps
+		m.FailedSteps += t.FailedSteps
+		tokens += t.Tokens
+		cost += t.Cost
+	}
+	if m.Correct > 0 {
+		m.MeanStepsToSuccess = float64(successSteps) / float64(m.Correct)
+		m.TokensPerCorrect = float64(tokens) / float64(m.Correct)
+		m.CostPerCorrect = cost / float64(m.Correct)
+	}
+	if m.ToolCalls > 0 {
+		m.RedundancyRate = float64(m.RedundantToolCalls) / float64(m.ToolCalls)
+	}
+	if m.Steps > 0 {
+		m.ErrorRate = float64(m.FailedSteps) / float64(m.Steps)
+	}
+	return m
+}
+
+// ScoreRuns computes metrics over results, using correct to decide which
+// runs answered correctly. A nil correct counts runs ending in the
+// "success" state as correct.
+func ScoreRuns(results []*neko.RunResult, correct func(*neko.RunResult) bool) TrajectoryMetrics {
+	if correct == nil {
+		correct = func(r *neko.RunResult) bool { return r.State == "success" }
+	}
+	trajectories := make([]Trajectory, 0, len(results))
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		t := TrajectoryOf(r)
+		t.Correct = correct(r)
+		trajectories = append(trajectories, t)
+	}
+	return Summarize(trajectories)
+}
+
+// String returns a one-line summary.
+func (m TrajectoryMetrics) String() string {
+	return fmt.Sprintf("%d/%d correct, %.1f steps to success, %.1f%% redundant tool calls, %.1f%% failed steps, %.0f tokens and $%.4f per correct answer",
+		m.Correct, m.Runs, m.MeanStepsToSuccess, m.RedundancyRate*100, m.ErrorRate*100, m.TokensPerCorrect, m.CostPerCorrect)
+}
@@ -0,0 +1,191 @@
+package neko
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Event types published on an agent's EventBus.
+const (
+	EventRunStarted    = "run_started"
+	EventStep          = "step"
+	EventRunCompleted  = "run_completed"
+	EventModelDelta    = "model_delta"
+	EventAnswerDelta   = "answer_delta"
+	EventExecutionLog  = "execution_log"
+	EventBudgetWarning = "budget_warning"
+	EventAdaptation    = "adaptation"
+	EventToolProgress  = "tool_progress"
+	EventAll           = "*" // subscribes to every event type
+)
+
+// Event is a typed notification published during a run.
+type Event interface {
+	EventType() string
+}
+
+// RunStartedEvent is published when a run begins.
+type RunStartedEvent struct {
+	Agent    string
+	Task     string
+	Reset    bool
+	MaxSteps int
+	Resume   *Memory // the recorded run being continued, if any
+	Memory   *Memory // the conversation the run starts from, set by WithMemory
+	// SystemPrompt is the run's system prompt.
+	SystemPrompt string
+}
+
+// StepEvent is published when a step is recorded in memory.
+type StepEvent struct {
+	Agent string
+	Step  Step
+}
+
+// RunCompletedEvent is published when a run ends, with its result or error.
+type RunCompletedEvent struct {
+	Agent  string
+	Result *RunResult
+	Err    error
+}
+
+// ModelDeltaEvent carries a chunk of model output as it streams.
+type ModelDeltaEvent struct {
+	Agent      string
+	StepNumber int
+	Delta      string
+}
+
+// ExecutionLogEvent carries a line of executor output as code runs.
+type ExecutionLogEvent struct {
+	Agent      string
+	StepNumber int
+	Line       string
+}
+
+func (RunStartedEvent) EventType() string   { return EventRunStarted }
+func (StepEvent) EventType() string         { return EventStep }
+func (RunCompletedEvent) EventType() string { return EventRunCompleted }
+func (ModelDeltaEvent) EventType() string   { return EventModelDelta }
+func (ExecutionLogEvent) EventType() string { return EventExecutionLog }
+
+// EventBus delivers events to subscribers. Handlers run synchronously on
+// the publishing goroutine, in subscription order, so a handler may block
+// the run, e.g. to pause it. A handler that panics is unsubscribed and
+// does not affect the run or other subscribers; the panic is logged with
+// slog.Default. Subscribing and unsubscribing are safe while events are
+// published.
+type EventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []*subscription
+}
+
+type subscription struct {
+	id        int
+	eventType string
+	handler   func(Event)
+}
+
+// NewEventBus creates an event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe calls handler for each event of eventType, or for every event
+// if eventType is EventAll. The returned function unsubscribes.
+func (b *EventBus) Subscribe(eventType string, handler func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, &subscription{id: id, eventType: eventType, handler: handler})
+	return func() { b.unsubscribe(id) }
+}
+
+// SubscribeAsync is like Subscribe but delivers events in order on a
+// separate goroutine, so a slow handler never delays the run. Up to
+// buffer events are queued; further events are dropped until the handler
+// catches up. Unsubscribing stops the goroutine after the queued events.
+func (b *EventBus) SubscribeAsync(eventType string, handler func(Event), buffer int) (unsubscribe func()) {
+	ch := make(chan Event, buffer)
+	go func() {
+		for e := range ch {
+			handler(e)
+		}
+	}()
+	var once sync.Once
+	var mu sync.Mutex
+	closed := false
+	unsub := b.Subscribe(eventType, func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	return func() {
+		once.Do(func() {
+			unsub()
+			mu.Lock()
+			closed = true
+			close(ch)
+			mu.Unlock()
+		})
+	}
+}
+
+// HasSubscribers reports whether any handler subscribed to eventType
+// specifically, ignoring EventAll subscribers. Agents use it to skip
+// producing costly events, such as streamed model output, nobody asked for.
+func (b *EventBus) HasSubscribers(eventType string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.eventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish delivers e to its subscribers.
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	subs := make([]*subscription, 0, len(b.subs))
+	for _, s := range b.subs {
+		if s.eventType == e.EventType() || s.eventType == EventAll {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+	for _, s := range subs {
+		b.deliver(s, e)
+	}
+}
+
+func (b *EventBus) deliver(s *subscription, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.unsubscribe(s.id)
+			slog.Error("event handler panicked and was unsubscribed", "event", e.EventType(), "panic", fmt.Sprint(r))
+		}
+	}()
+	s.handler(e)
+}
+
+func (b *EventBus) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subs {
+		if s.id == id {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
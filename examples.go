@@ -0,0 +1,108 @@
+package neko
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Example is a worked example for the system prompt: a task and an
+// excerpt of an ideal trajectory solving it, written in the format the
+// agent uses (code blocks for a CodeAgent, tool calls for a
+// ToolCallingAgent).
+type Example struct {
+	Task       string `json:"task"`
+	Trajectory string `json:"trajectory"`
+}
+
+// WithExamples adds worked examples to the agent. Each run includes the
+// examples most relevant to its task, within the budget set with
+// WithExampleBudget, in an "Examples" section at the end of the system
+// prompt. Examples of skills added with WithSkills are selected the same
+// way.
+func WithExamples(examples ...Example) AgentOption {
+	return func(a *BaseAgent) { a.examples = append(a.examples, examples...) }
+}
+
+// WithExampleBudget limits the examples included in a run to about tokens
+// tokens, estimated at four characters a token. Zero, the default,
+// includes them all.
+func WithExampleBudget(tokens int) AgentOption {
+	return func(a *BaseAgent) { a.exampleBudget = tokens }
+}
+
+// examplesPrompt renders the examples selected for task.
+func (a *BaseAgent) examplesPrompt(task string) string {
+	selected := selectExamples(a.examples, task, a.exampleBudget)
+	if len(selected) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n## Examples\n")
+	for _, ex := range selected {
+		sb.WriteString("\n" + ex.render())
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// render formats the example for the system prompt.
+func (ex Example) render() string {
+	return fmt.Sprintf("Example task: %s\n%s\n", ex.Task, strings.TrimSpace(ex.Trajectory))
+}
+
+// selectExamples returns the examples to include for task: with a budget,
+// the ones sharing the most words with task that fit, most relevant
+// first; without, all of them in order.
+func selectExamples(examples []Example, task string, budget int) []Example {
+	if budget <= 0 {
+		return examples
+	}
+	taskWords := map[string]bool{}
+	for _, w := range words(task) {
+		taskWords[w] = true
+	}
+	scores := make([]int, len(examples))
+	order := make([]int, len(examples))
+	for i, ex := range examples {
+		order[i] = i
+		seen := map[string]bool{}
+		for _, w := range words(ex.Task) {
+			if taskWords[w] && !seen[w] {
+				seen[w] = true
+				scores[i]++
+			}
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	var selected []Example
+	for _, i := range order {
+		cost := estimateTokens(examples[i].render())
+		if cost <= budget {
+			selected = append(selected, examples[i])
+			budget -= cost
+		}
+	}
+	return selected
+}
+
+// words returns the lower-case words of s that are long enough to say
+// something about its topic.
+func words(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) >= 4 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// estimateTokens estimates the tokens in s at four characters a token.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
@@ -0,0 +1,108 @@
+package exec
+
+import (
+	"context"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// maxArtifactSize caps how much of a single file is read into an artifact.
+const maxArtifactSize = 10 << 20
+
+// fileStamp identifies a version of a file for change detection.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// dirSnapshot maps relative file paths to their stamps.
+type dirSnapshot map[string]fileStamp
+
+// snapshotDir records every regular file under dir. It returns nil if dir
+// is empty.
+func snapshotDir(dir string) dirSnapshot {
+	if dir == "" {
+		return nil
+	}
+	snap := dirSnapshot{}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		snap[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return snap
+}
+
+// workspaceDir returns dir, or if it is empty the directory of the
+// workspace attached to ctx, if any.
+func workspaceDir(ctx context.Context, dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if w := neko.WorkspaceFromContext(ctx); w != nil {
+		return w.Dir()
+	}
+	return ""
+}
+
+// checkWorkspaceQuota returns err, or if it is nil and code run in dir
+// took the workspace attached to ctx over its quota, the quota error.
+func checkWorkspaceQuota(ctx context.Context, dir string, err error) error {
+	if w := neko.WorkspaceFromContext(ctx); err == nil && w != nil && w.Dir() == dir {
+		return w.CheckQuota()
+	}
+	return err
+}
+
+// collectArtifacts returns the files under dir that are new or changed
+// since before, in path order.
+func collectArtifacts(dir string, before dirSnapshot) []neko.Artifact {
+	if dir == "" {
+		return nil
+	}
+	after := snapshotDir(dir)
+	paths := make([]string, 0, len(after))
+	for rel, stamp := range after {
+		if old, ok := before[rel]; !ok || old != stamp {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	artifacts := make([]neko.Artifact, 0, len(paths))
+	for _, rel := range paths {
+		artifact := neko.Artifact{Name: filepath.Base(rel), Path: filepath.ToSlash(rel)}
+		if after[rel].size <= maxArtifactSize {
+			if data, err := os.ReadFile(filepath.Join(dir, rel)); err == nil {
+				artifact.Data = data
+			}
+		}
+		artifact.MIMEType = detectMIMEType(rel, artifact.Data)
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
+
+func detectMIMEType(name string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	if len(data) > 0 {
+		return http.DetectContentType(data)
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,445 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// bashPrelude defines final_answer for scripts. The answer is written to
+// a file named by NEKO_FINAL_ANSWER_FILE so it never mixes with output.
+const bashPrelude = `final_answer() { printf '%s' "$*" > "$NEKO_FINAL_ANSWER_FILE"; echo "Final Answer: $*"; }
+`
+
+// DefaultBashBuiltins are always allowed when an allowlist is set.
+var DefaultBashBuiltins = []string{
+	"echo", "printf", "cd", "pwd", "test", "[", "true", "false",
+	"export", "read", "set", "shift", "local", "return", "exit", "final_answer",
+}
+
+// BashExecutor runs shell scripts with bash. Commands are checked against
+// an allowlist by parsing the script before it runs, and the shell gets a
+// clean environment, its own working directory and a timeout.
+type BashExecutor struct {
+	shell     string
+	timeout   time.Duration
+	allowed   map[string]bool
+	workDir   string
+	envAllow  []string
+	env       []string
+	maxOutput int
+
+	mu      sync.Mutex
+	runDir  string
+	tempDir bool
+}
+
+// BashOption configures BashExecutor.
+type BashOption func(*BashExecutor)
+
+// WithBashPath sets the shell binary.
+func WithBashPath(path string) BashOption {
+	return func(e *BashExecutor) { e.shell = path }
+}
+
+// WithBashTimeout sets execution timeout.
+func WithBashTimeout(d time.Duration) BashOption {
+	return func(e *BashExecutor) { e.timeout = d }
+}
+
+// WithAllowedCommands restricts scripts to the given commands plus
+// DefaultBashBuiltins and functions the script defines. Redirections and
+// cd must then name literal paths inside the working directory, so that
+// builtins cannot write to host files or open /dev/tcp and /dev/udp
+// sockets. Without it any command may run.
+func WithAllowedCommands(cmds ...string) BashOption {
+	return func(e *BashExecutor) {
+		if e.allowed == nil {
+			e.allowed = make(map[string]bool)
+			for _, b := range DefaultBashBuiltins {
+				e.allowed[b] = true
+			}
+		}
+		for _, c := range cmds {
+			e.allowed[c] = true
+		}
+	}
+}
+
+// WithBashWorkDir sets the directory scripts run in. Files created or
+// changed there are collected as artifacts. Without it, scripts run in
+// the run's workspace if the agent has one, or else in a temporary
+// directory.
+func WithBashWorkDir(dir string) BashOption {
+	return func(e *BashExecutor) { e.workDir = dir }
+}
+
+// WithBashEnv adds an environment variable. Scripts otherwise only see
+// the host variables allowed by WithBashEnvAllowlist and variables
+// derived from agent state.
+func WithBashEnv(key, value string) BashOption {
+	return func(e *BashExecutor) { e.env = append(e.env, key+"="+value) }
+}
+
+// WithBashEnvAllowlist sets the host environment variables scripts see,
+// replacing DefaultEnvAllowlist. A name ending in "*" allows every
+// variable with that prefix. With no names, scripts see none.
+func WithBashEnvAllowlist(names ...string) BashOption {
+	return func(e *BashExecutor) { e.envAllow = append([]string{}, names...) }
+}
+
+// WithBashMaxOutputSize kills the shell once it has written more than n
+// bytes to stdout and stderr combined.
+func WithBashMaxOutputSize(n int) BashOption {
+	return func(e *BashExecutor) { e.maxOutput = n }
+}
+
+// NewBashExecutor creates a shell script executor.
+func NewBashExecutor(opts ...BashOption) *BashExecutor {
+	e := &BashExecutor{
+		shell:     "bash",
+		timeout:   30 * time.Second,
+		maxOutput: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Language reports the language scripts are written in.
+func (e *BashExecutor) Language() string { return "bash" }
+
+// Start prepares the working directory for an agent run.
+func (e *BashExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runDir != "" {
+		return nil
+	}
+	if dir := workspaceDir(ctx, e.workDir); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		e.runDir = dir
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "neko-run-")
+	if err != nil {
+		return err
+	}
+	e.runDir, e.tempDir = dir, true
+	return nil
+}
+
+// Close removes the run's temporary working directory, if any.
+func (e *BashExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	dir, temp := e.runDir, e.tempDir
+	e.runDir, e.tempDir = "", false
+	if temp {
+		return os.RemoveAll(dir)
+	}
+	return nil
+}
+
+// dir returns the directory a script runs in: the run's directory if a
+// run is active, otherwise the configured or workspace directory, or else
+// a temporary directory that remove deletes.
+func (e *BashExecutor) dir(ctx context.Context) (dir string, remove func(), err error) {
+	e.mu.Lock()
+	dir = e.runDir
+	e.mu.Unlock()
+	if dir == "" {
+		dir = workspaceDir(ctx, e.workDir)
+	}
+	if dir != "" {
+		return dir, func() {}, nil
+	}
+	dir, err = os.MkdirTemp("", "neko-run-")
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// Execute runs a bash script. String, number and boolean state values are
+// exported as environment variables; scripts cannot update state.
+func (e *BashExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	return e.execute(ctx, code, state, nil)
+}
+
+// ExecuteStream is like Execute but delivers stdout and stderr lines while
+// the script runs.
+func (e *BashExecutor) ExecuteStream(ctx context.Context, code string, state map[string]any) (<-chan neko.ExecutionDelta, error) {
+	return streamExecution(func(onLine func(string)) (*neko.ExecutionResult, error) {
+		return e.execute(ctx, code, state, onLine)
+	}), nil
+}
+
+func (e *BashExecutor) execute(ctx context.Context, code string, state map[string]any, onLine func(string)) (*neko.ExecutionResult, error) {
+	dir, remove, err := e.dir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+	if err := e.check(code, dir); err != nil {
+		return &neko.ExecutionResult{State: state}, err
+	}
+	auditCommands(ctx, code)
+
+	answerFile, err := os.CreateTemp("", "neko-answer-")
+	if err != nil {
+		return nil, err
+	}
+	answerFile.Close()
+	defer os.Remove(answerFile.Name())
+
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, e.shell, "-c", bashPrelude+code)
+	cmd.Dir = dir
+	cmd.Env = e.environ(state, answerFile.Name())
+	killProcessGroupOnCancel(cmd)
+
+	before := snapshotDir(dir)
+	stdout, stderr, err := runCommand(cmd, nil, onLine, e.maxOutput)
+	res := &neko.ExecutionResult{
+		Logs:      strings.TrimRight(stdout, "\n"),
+		State:     state,
+		Artifacts: collectArtifacts(dir, before),
+	}
+	if err != nil {
+		if cerr := contextError(ctx, runCtx, e.timeout); cerr != nil {
+			return res, cerr
+		}
+		if limitErr := (*errOutputLimit)(nil); errors.As(err, &limitErr) {
+			return res, err
+		}
+		return res, fmt.Errorf("%v: %s", err, stderr)
+	}
+	if stderr != "" {
+		res.Logs += "\n" + strings.TrimRight(stderr, "\n")
+	}
+	if err := checkWorkspaceQuota(ctx, dir, nil); err != nil {
+		return res, err
+	}
+
+	if info, err := os.Stat(answerFile.Name()); err == nil && info.Size() > 0 {
+		answer, _ := os.ReadFile(answerFile.Name())
+		res.Output = string(answer)
+		res.IsFinal = true
+	}
+	return res, nil
+}
+
+func (e *BashExecutor) environ(state map[string]any, answerFile string) []string {
+	env := append(hostEnv(e.envAllow), "NEKO_FINAL_ANSWER_FILE="+answerFile)
+	for k, v := range state {
+		if !syntax.ValidName(k) {
+			continue
+		}
+		switch v.(type) {
+		case string, float64, int, int64, bool:
+			env = append(env, fmt.Sprintf("%s=%v", k, v))
+		}
+	}
+	return append(env, e.env...)
+}
+
+// auditCommands records each command the script invokes in the run's
+// audit log.
+func auditCommands(ctx context.Context, code string) {
+	if neko.AuditLogFromContext(ctx) == nil {
+		return
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(code), "")
+	if err != nil {
+		return
+	}
+	printer := syntax.NewPrinter()
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			var sb strings.Builder
+			printer.Print(&sb, call)
+			neko.RecordAudit(ctx, neko.AuditEntry{Kind: neko.AuditCommand, Source: "bash", Detail: sb.String()})
+		}
+		return true
+	})
+}
+
+// check parses the script and rejects commands outside the allowlist,
+// including commands whose name is computed at runtime, and paths that
+// leave dir.
+func (e *BashExecutor) check(code, dir string) error {
+	file, err := syntax.NewParser().Parse(strings.NewReader(code), "")
+	if err != nil {
+		return fmt.Errorf("invalid script: %w", err)
+	}
+	if e.allowed == nil {
+		return nil
+	}
+
+	defined := map[string]bool{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if fn, ok := node.(*syntax.FuncDecl); ok {
+			defined[fn.Name.Value] = true
+		}
+		return true
+	})
+
+	denied := map[string]bool{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		name := call.Args[0].Lit()
+		switch {
+		case name == "":
+			denied["<dynamic command>"] = true
+		case !e.allowed[name] && !defined[name]:
+			denied[name] = true
+		}
+		return true
+	})
+	if len(denied) == 0 {
+		return checkPaths(file, dir)
+	}
+
+	names := make([]string, 0, len(denied))
+	for name := range denied {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	allowed := make([]string, 0, len(e.allowed))
+	for name := range e.allowed {
+		allowed = append(allowed, name)
+	}
+	sort.Strings(allowed)
+	return fmt.Errorf("commands not allowed: %s (allowed: %s)", strings.Join(names, ", "), strings.Join(allowed, ", "))
+}
+
+// devFiles are the paths outside the working directory scripts may
+// redirect to.
+var devFiles = map[string]bool{"/dev/null": true, "/dev/stdin": true, "/dev/stdout": true, "/dev/stderr": true}
+
+// checkPaths rejects redirections and cd targets that are computed at
+// runtime or name a path outside dir, such as a host file or a
+// /dev/tcp socket. A script starting in dir then stays in it.
+func checkPaths(file *syntax.File, dir string) error {
+	var denied []string
+	printer := syntax.NewPrinter()
+	deny := func(node syntax.Node) {
+		var sb strings.Builder
+		printer.Print(&sb, node)
+		denied = append(denied, sb.String())
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.Redirect:
+			switch n.Op {
+			case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+				return true
+			case syntax.DplIn, syntax.DplOut:
+				if fd, ok := literalWord(n.Word); ok && isFD(fd) {
+					return true
+				}
+			}
+			if path, ok := literalWord(n.Word); !ok || !devFiles[path] && !insideDir(path, dir) {
+				deny(n)
+			}
+		case *syntax.CallExpr:
+			if len(n.Args) == 0 || n.Args[0].Lit() != "cd" {
+				return true
+			}
+			if len(n.Args) != 2 {
+				deny(n)
+				return true
+			}
+			if path, ok := literalWord(n.Args[1]); !ok || !insideDir(path, dir) {
+				deny(n)
+			}
+		}
+		return true
+	})
+	if len(denied) == 0 {
+		return nil
+	}
+	return fmt.Errorf("paths outside the working directory not allowed: %s", strings.Join(denied, "; "))
+}
+
+// literalWord returns w's value if it is made only of literal and quoted
+// text, with no expansions.
+func literalWord(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, qp := range p.Parts {
+				lit, ok := qp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// isFD reports whether s is a file descriptor number or "-", the targets
+// of <& and >& that duplicate or close descriptors.
+func isFD(s string) bool {
+	if s == "-" {
+		return true
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// insideDir reports whether path, relative to a working directory inside
+// dir, stays inside dir. Relative paths must not climb with "..", and
+// absolute ones must be under dir.
+func insideDir(path, dir string) bool {
+	if !filepath.IsAbs(path) {
+		return filepath.IsLocal(path) && !strings.HasPrefix(path, "~")
+	}
+	if dir == "" {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(abs, path)
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -0,0 +1,97 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+func TestBashRejectsPathsOutsideWorkDir(t *testing.T) {
+	e := NewBashExecutor(WithAllowedCommands())
+	dir := t.TempDir()
+	for _, code := range []string{
+		"echo x > /dev/tcp/127.0.0.1/9",
+		"echo x >> /dev/udp/127.0.0.1/53",
+		"printf x > /tmp/neko-escape",
+		"echo x > ../escape",
+		"echo x &> /etc/escape",
+		"echo x >& /tmp/escape",
+		`echo x > "$HOME/escape"`,
+		"echo x > ~/escape",
+		"read line < /etc/passwd",
+		"cd /; echo x > tmp/escape",
+		"cd ..",
+		"cd",
+		"cd $OLDPWD",
+	} {
+		err := e.check(code, dir)
+		if err == nil || !strings.Contains(err.Error(), "outside the working directory") {
+			t.Errorf("%q: err = %v, want it rejected", code, err)
+		}
+	}
+	for _, code := range []string{
+		"echo x > out.txt 2>&1",
+		`echo x >> "logs/out.txt"`,
+		"echo x > " + filepath.Join(dir, "abs.txt"),
+		"echo x > /dev/null 2>/dev/stderr",
+		`read x <<< "hi"; echo "$x" >&2`,
+		"cd sub",
+	} {
+		if err := e.check(code, dir); err != nil {
+			t.Errorf("%q: %v", code, err)
+		}
+	}
+}
+
+func TestBashWriteOutsideWorkDirNotRun(t *testing.T) {
+	e := NewBashExecutor(WithAllowedCommands())
+	outside := filepath.Join(t.TempDir(), "escape")
+	if _, err := e.Execute(context.Background(), "echo x > "+outside, nil); err == nil {
+		t.Fatal("script writing outside its directory ran")
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Errorf("%s was written", outside)
+	}
+}
+
+func TestBashRunsInOwnDirectory(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewBashExecutor()
+	res, err := e.Execute(context.Background(), "pwd", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Logs == cwd {
+		t.Errorf("script ran in the process's directory %s", cwd)
+	}
+
+	if err := e.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	res, err = e.Execute(context.Background(), "pwd; echo hi > note.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runDir := res.Logs
+	if runDir == cwd {
+		t.Errorf("script ran in the process's directory %s", cwd)
+	}
+	if len(res.Artifacts) != 1 || res.Artifacts[0].Name != "note.txt" {
+		t.Errorf("artifacts = %+v, want note.txt", res.Artifacts)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(runDir); !os.IsNotExist(err) {
+		t.Errorf("run directory %s not removed", runDir)
+	}
+}
+
+var _ neko.SessionExecutor = (*BashExecutor)(nil)
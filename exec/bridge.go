@@ -0,0 +1,162 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/gocnn/neko"
+)
+
+// bridgeTool describes a tool stub generated inside the interpreter.
+// Params lists input names in the order they bind positional arguments.
+type bridgeTool struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params"`
+}
+
+// bridgeEndpoint tells the interpreter where to send tool calls.
+type bridgeEndpoint struct {
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+	Token string `json:"token"`
+}
+
+// toolBridge serves tool calls from executed code over a loopback TCP
+// socket for the duration of one execution. Each request is one JSON
+// line carrying a random per-execution token, so other local processes
+// cannot invoke tools.
+type toolBridge struct {
+	ctx      context.Context
+	listener net.Listener
+	tools    map[string]neko.Tool
+	token    string
+	wg       sync.WaitGroup
+}
+
+// startToolBridge starts a bridge for the tools attached to ctx with
+// neko.WithExecutionTools. It returns nil if there are none.
+func startToolBridge(ctx context.Context) (*toolBridge, error) {
+	tools := neko.ExecutionTools(ctx)
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start tool bridge: %w", err)
+	}
+	secret := make([]byte, 16)
+	rand.Read(secret)
+
+	b := &toolBridge{ctx: ctx, listener: ln, tools: make(map[string]neko.Tool), token: hex.EncodeToString(secret)}
+	for _, t := range tools {
+		b.tools[t.Name()] = t
+	}
+	b.wg.Add(1)
+	go b.serve()
+	return b, nil
+}
+
+// apply adds the bridge's stubs and endpoint to p. It is a no-op on a nil
+// bridge.
+func (b *toolBridge) apply(p *runPayload) {
+	if b == nil {
+		return
+	}
+	addr := b.listener.Addr().(*net.TCPAddr)
+	p.Bridge = &bridgeEndpoint{Host: addr.IP.String(), Port: addr.Port, Token: b.token}
+	for name, t := range b.tools {
+		// final_answer is provided by the runner itself.
+		if name == "final_answer" {
+			continue
+		}
+		p.Tools = append(p.Tools, bridgeTool{Name: name, Params: neko.ToolParamNames(t)})
+	}
+	sort.Slice(p.Tools, func(i, j int) bool { return p.Tools[i].Name < p.Tools[j].Name })
+}
+
+// Close stops the bridge and waits for in-flight calls. It is a no-op on a
+// nil bridge.
+func (b *toolBridge) Close() {
+	if b == nil {
+		return
+	}
+	b.listener.Close()
+	b.wg.Wait()
+}
+
+func (b *toolBridge) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			defer conn.Close()
+			b.handle(conn)
+		}()
+	}
+}
+
+func (b *toolBridge) handle(conn net.Conn) {
+	var req struct {
+		Token     string         `json:"token"`
+		Tool      string         `json:"tool"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	var resp struct {
+		Result any    `json:"result,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+	defer func() { json.NewEncoder(conn).Encode(resp) }()
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		resp.Error = "malformed tool request"
+		return
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = "malformed tool request"
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(b.token)) != 1 {
+		resp.Error = "unauthorized"
+		return
+	}
+	tool, ok := b.tools[req.Tool]
+	if !ok {
+		resp.Error = "unknown tool: " + req.Tool
+		return
+	}
+	if err := neko.ValidateToolArgs(tool, req.Arguments); err != nil {
+		resp.Error = err.Error()
+		return
+	}
+	result, err := callTool(b.ctx, tool, req.Arguments)
+	if err != nil {
+		resp.Error = neko.NewErrToolExecution(req.Tool, err).Error()
+		return
+	}
+	if _, err := json.Marshal(result); err != nil {
+		result = fmt.Sprintf("%v", result)
+	}
+	resp.Result = result
+}
+
+// callTool runs tool with args, passing ctx to tools that accept one.
+func callTool(ctx context.Context, tool neko.Tool, args map[string]any) (any, error) {
+	if ct, ok := tool.(neko.ContextTool); ok {
+		return ct.ExecuteContext(ctx, args)
+	}
+	return tool.Execute(args)
+}
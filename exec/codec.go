@@ -0,0 +1,23 @@
+package exec
+
+// StateCodec selects how Python executors carry variables that are not
+// JSON-native, such as numpy arrays and pandas DataFrames, from one step
+// to the next. Encoded values travel in the agent's state as objects with
+// a "__neko_codec__" key and are decoded before the next step's code runs.
+type StateCodec string
+
+// State codecs.
+const (
+	// StateCodecJSON keeps only JSON-serializable variables; others are
+	// dropped after each step. This is the default.
+	StateCodecJSON StateCodec = "json"
+	// StateCodecPickle pickles other variables. It handles nearly any
+	// Python value, but the state can only be restored by a compatible
+	// interpreter, and restoring it runs code, so state must not come from
+	// an untrusted source.
+	StateCodecPickle StateCodec = "pickle"
+	// StateCodecArrow encodes pandas DataFrames and numpy arrays in the
+	// Arrow IPC format, which needs pyarrow; other variables that are not
+	// JSON-native are dropped.
+	StateCodecArrow StateCodec = "arrow"
+)
@@ -0,0 +1,416 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// containerWorkDir is where the host work directory is mounted.
+const containerWorkDir = "/workspace"
+
+// DockerExecutor executes code in a Docker container. One container is
+// started per agent run and each step runs in it with docker exec, so
+// installed packages and files written by earlier steps stay available
+// and steps skip the container cold start.
+type DockerExecutor struct {
+	binary   string
+	image    string
+	timeout  time.Duration
+	workDir  string
+	packages []string
+	volumes  []string
+	env      []string
+	network  string
+	memory   string
+	cpus     string
+	user     string
+	platform string
+	policy   *SafetyPolicy
+	codec    StateCodec
+
+	mu        sync.Mutex
+	container string
+	hostDir   string
+	tempDir   bool
+	// installed lists the packages added with Install during the run, to
+	// install again if the container is replaced.
+	installed []string
+	// detached is set when the container was started on the default
+	// bridge network for package installs and disconnected from it
+	// afterwards, so later installs must reconnect it.
+	detached bool
+}
+
+// installNetwork is the network a container without a configured network
+// joins while packages are installed.
+const installNetwork = "bridge"
+
+// DockerOption configures DockerExecutor.
+type DockerOption func(*DockerExecutor)
+
+// WithDockerWorkDir mounts a host directory at /workspace in the container
+// and makes it the working directory for executed code. Without it, each
+// agent run mounts a fresh temporary directory that is removed when the
+// run ends. Files created or changed there are collected as artifacts.
+func WithDockerWorkDir(dir string) DockerOption {
+	return func(e *DockerExecutor) { e.workDir = dir }
+}
+
+// WithDockerPackages sets pip packages installed when the container starts.
+func WithDockerPackages(pkgs ...string) DockerOption {
+	return func(e *DockerExecutor) { e.packages = pkgs }
+}
+
+// WithDockerBinary sets the container CLI, e.g. "podman". Any binary
+// accepting docker-compatible run/exec/rm arguments works.
+func WithDockerBinary(path string) DockerOption {
+	return func(e *DockerExecutor) { e.binary = path }
+}
+
+// WithDockerImage sets the container image.
+func WithDockerImage(image string) DockerOption {
+	return func(e *DockerExecutor) { e.image = image }
+}
+
+// WithDockerVolume mounts hostPath at containerPath, read-only if
+// readOnly is set.
+func WithDockerVolume(hostPath, containerPath string, readOnly bool) DockerOption {
+	return func(e *DockerExecutor) {
+		v := hostPath + ":" + containerPath
+		if readOnly {
+			v += ":ro"
+		}
+		e.volumes = append(e.volumes, v)
+	}
+}
+
+// WithDockerEnv sets an environment variable in the container.
+func WithDockerEnv(key, value string) DockerOption {
+	return func(e *DockerExecutor) { e.env = append(e.env, key+"="+value) }
+}
+
+// WithDockerNetwork sets the network mode, e.g. "none", "bridge" or a
+// named network. By default executed code has no network: a container
+// with packages to install is attached to the default bridge network
+// only while pip runs.
+func WithDockerNetwork(mode string) DockerOption {
+	return func(e *DockerExecutor) { e.network = mode }
+}
+
+// WithDockerMemory sets the memory limit, e.g. "512m". Empty disables it.
+func WithDockerMemory(limit string) DockerOption {
+	return func(e *DockerExecutor) { e.memory = limit }
+}
+
+// WithDockerCPUs sets the CPU limit. Zero disables it.
+func WithDockerCPUs(cpus float64) DockerOption {
+	return func(e *DockerExecutor) {
+		e.cpus = ""
+		if cpus > 0 {
+			e.cpus = strconv.FormatFloat(cpus, 'f', -1, 64)
+		}
+	}
+}
+
+// WithDockerUser sets the user code runs as, e.g. "1000:1000".
+func WithDockerUser(user string) DockerOption {
+	return func(e *DockerExecutor) { e.user = user }
+}
+
+// WithDockerPlatform sets the image platform, e.g. "linux/amd64".
+func WithDockerPlatform(platform string) DockerOption {
+	return func(e *DockerExecutor) { e.platform = platform }
+}
+
+// WithDockerSafetyPolicy checks code against p before it runs. By default
+// no check is made, since the container is the isolation boundary.
+func WithDockerSafetyPolicy(p *SafetyPolicy) DockerOption {
+	return func(e *DockerExecutor) { e.policy = p }
+}
+
+// WithDockerStateCodec sets how variables that are not JSON-native are
+// kept between steps. Defaults to StateCodecJSON.
+func WithDockerStateCodec(c StateCodec) DockerOption {
+	return func(e *DockerExecutor) { e.codec = c }
+}
+
+// NewDockerExecutor creates a Docker-based executor.
+func NewDockerExecutor(image string, timeout time.Duration, opts ...DockerOption) *DockerExecutor {
+	if image == "" {
+		image = "python:3.11-slim"
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	e := &DockerExecutor{
+		binary:  "docker",
+		image:   image,
+		timeout: timeout,
+		memory:  "256m",
+		cpus:    "0.5",
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Start creates the container for a run and installs configured packages,
+// and those added with Install if it replaces a container removed after a
+// timeout. It is a no-op if a container is already running.
+func (e *DockerExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.container != "" {
+		return nil
+	}
+	packages := append(slices.Clone(e.packages), e.installed...)
+
+	name := containerName()
+	args := []string{"run", "-d", "--rm", "--name", name}
+	// Package installs need network access, so a container with packages
+	// starts on the default network and is disconnected once they are
+	// installed; without packages it never gets a network by default.
+	network := e.network
+	detach := network == "" && len(packages) > 0
+	if network == "" && !detach {
+		network = "none"
+	}
+	if network != "" {
+		args = append(args, "--network="+network)
+	}
+	if e.memory != "" {
+		args = append(args, "--memory="+e.memory)
+	}
+	if e.cpus != "" {
+		args = append(args, "--cpus="+e.cpus)
+	}
+	if e.user != "" {
+		args = append(args, "--user="+e.user)
+	}
+	if e.platform != "" {
+		args = append(args, "--platform="+e.platform)
+	}
+	for _, v := range e.volumes {
+		args = append(args, "-v", v)
+	}
+	for _, kv := range e.env {
+		args = append(args, "-e", kv)
+	}
+	if err := e.prepareHostDirLocked(ctx); err != nil {
+		return err
+	}
+	args = append(args, "-v", e.hostDir+":"+containerWorkDir, "-w", containerWorkDir)
+	args = append(args, e.image, "sleep", "infinity")
+
+	out, err := exec.CommandContext(ctx, e.binary, args...).CombinedOutput()
+	entry := neko.AuditEntry{Kind: neko.AuditCommand, Source: "docker", Detail: e.binary + " " + strings.Join(redactEnvArgs(args), " ")}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	neko.RecordAudit(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to start container: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	e.container = name
+
+	if len(packages) > 0 {
+		err := e.pipInstallLocked(ctx, packages)
+		if err == nil && detach {
+			err = e.networkLocked(ctx, "disconnect")
+			e.detached = err == nil
+		}
+		if err != nil {
+			e.removeLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+// Install runs pip install for pkgs inside the running container. A
+// container whose network was disconnected after start-up is attached to
+// the default network for the install only.
+func (e *DockerExecutor) Install(ctx context.Context, pkgs ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.container == "" {
+		return fmt.Errorf("package install failed: no running container")
+	}
+	if !e.detached {
+		err := e.pipInstallLocked(ctx, pkgs)
+		if err == nil {
+			e.installed = append(e.installed, pkgs...)
+		}
+		return err
+	}
+	if err := e.networkLocked(ctx, "connect"); err != nil {
+		return err
+	}
+	installErr := e.pipInstallLocked(ctx, pkgs)
+	if installErr == nil {
+		e.installed = append(e.installed, pkgs...)
+	}
+	// Code must not run with the network attached, so a container that
+	// cannot be disconnected again is removed.
+	if err := e.networkLocked(context.WithoutCancel(ctx), "disconnect"); err != nil {
+		e.removeLocked()
+		return err
+	}
+	return installErr
+}
+
+func (e *DockerExecutor) pipInstallLocked(ctx context.Context, pkgs []string) error {
+	args := append([]string{"exec", e.container, "python3", "-m", "pip", "install", "--quiet"}, pkgs...)
+	if out, err := exec.CommandContext(ctx, e.binary, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("package install failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// networkLocked connects the container to, or disconnects it from, the
+// install network. action is "connect" or "disconnect".
+func (e *DockerExecutor) networkLocked(ctx context.Context, action string) error {
+	out, err := exec.CommandContext(ctx, e.binary, "network", action, installNetwork, e.container).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to %s container network: %v: %s", action, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Close removes the run's container and temporary work directory.
+func (e *DockerExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.removeLocked()
+	if e.tempDir {
+		os.RemoveAll(e.hostDir)
+	}
+	e.hostDir, e.tempDir, e.installed = "", false, nil
+	return err
+}
+
+// prepareHostDirLocked picks the host directory mounted as the work
+// directory, keeping it across container restarts within a run. Without
+// a work directory, the run's workspace is used if there is one.
+func (e *DockerExecutor) prepareHostDirLocked(ctx context.Context) error {
+	if e.hostDir != "" {
+		return nil
+	}
+	if dir := workspaceDir(ctx, e.workDir); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(abs, 0o755); err != nil {
+			return err
+		}
+		e.hostDir = abs
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "neko-run-")
+	if err != nil {
+		return err
+	}
+	// The container user may differ from the host user.
+	if err := os.Chmod(dir, 0o777); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	e.hostDir, e.tempDir = dir, true
+	return nil
+}
+
+func (e *DockerExecutor) removeLocked() error {
+	if e.container == "" {
+		return nil
+	}
+	name := e.container
+	e.container, e.detached = "", false
+	if out, err := exec.Command(e.binary, "rm", "-f", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove container: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Execute runs code in the run's container, starting one if needed. State
+// is exchanged the same way as with PythonExecutor. Killing the docker
+// client does not stop the process inside the container, so on
+// cancellation or timeout the container is removed and the next step
+// starts a fresh one, with the run's packages installed again; files in
+// the work directory are kept, but other changes to the container are
+// lost.
+func (e *DockerExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	return e.execute(ctx, code, state, nil)
+}
+
+// ExecuteStream is like Execute but delivers stdout and stderr lines while
+// the code runs.
+func (e *DockerExecutor) ExecuteStream(ctx context.Context, code string, state map[string]any) (<-chan neko.ExecutionDelta, error) {
+	return streamExecution(func(onLine func(string)) (*neko.ExecutionResult, error) {
+		return e.execute(ctx, code, state, onLine)
+	}), nil
+}
+
+func (e *DockerExecutor) execute(ctx context.Context, code string, state map[string]any, onLine func(string)) (*neko.ExecutionResult, error) {
+	if err := e.Start(ctx); err != nil {
+		return nil, err
+	}
+	payload, err := encodePayload(runPayload{Code: code, State: state, Codec: e.codec, Policy: e.policy})
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	e.mu.Lock()
+	name, dir := e.container, e.hostDir
+	e.mu.Unlock()
+
+	cmd := exec.CommandContext(runCtx, e.binary, "exec", "-i", name, "python3", "-u", "-c", pythonRunner)
+	cmd.WaitDelay = waitDelay
+
+	before := snapshotDir(dir)
+	stdout, stderr, err := runCommand(cmd, payload, onLine, 0)
+	if err != nil {
+		res := &neko.ExecutionResult{Logs: stdout, State: state, Artifacts: collectArtifacts(dir, before)}
+		if cerr := contextError(ctx, runCtx, e.timeout); cerr != nil {
+			e.mu.Lock()
+			e.removeLocked()
+			e.mu.Unlock()
+			return res, cerr
+		}
+		return res, fmt.Errorf("%v: %s", err, stderr)
+	}
+	res, err := parseRunOutput(stdout, state)
+	res.Artifacts = append(res.Artifacts, collectArtifacts(dir, before)...)
+	return res, checkWorkspaceQuota(ctx, dir, err)
+}
+
+// redactEnvArgs hides the values of -e arguments.
+func redactEnvArgs(args []string) []string {
+	out := append([]string(nil), args...)
+	for i := 1; i < len(out); i++ {
+		if out[i-1] == "-e" {
+			if k, _, ok := strings.Cut(out[i], "="); ok {
+				out[i] = k + "=REDACTED"
+			}
+		}
+	}
+	return out
+}
+
+func containerName() string {
+	return fmt.Sprintf("neko-exec-%d", time.Now().UnixNano())
+}
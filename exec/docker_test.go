@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDocker writes a docker CLI stand-in that logs its arguments, one
+// call per line, and returns the binary and log paths.
+func fakeDocker(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls.log")
+	bin := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin, log
+}
+
+func dockerCalls(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestDockerPackagesInstallWithoutLeavingNetwork(t *testing.T) {
+	bin, log := fakeDocker(t)
+	e := NewDockerExecutor("", time.Second, WithDockerBinary(bin), WithDockerPackages("numpy"))
+	ctx := context.Background()
+	if err := e.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.Install(ctx, "pandas"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := dockerCalls(t, log)
+	want := []string{"run ", "exec ", "network disconnect bridge", "network connect bridge", "exec ", "network disconnect bridge"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %q", calls)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(calls[i], prefix) {
+			t.Errorf("call %d = %q, want prefix %q", i, calls[i], prefix)
+		}
+	}
+	if strings.Contains(calls[0], "--network") {
+		t.Errorf("run = %q, want the default network for the install", calls[0])
+	}
+}
+
+func TestDockerNoNetworkWithoutPackages(t *testing.T) {
+	bin, log := fakeDocker(t)
+	e := NewDockerExecutor("", time.Second, WithDockerBinary(bin))
+	if err := e.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if calls := dockerCalls(t, log); !strings.Contains(calls[0], "--network=none") {
+		t.Errorf("run = %q, want --network=none", calls[0])
+	}
+}
+
+func TestDockerReinstallsPackagesInReplacedContainer(t *testing.T) {
+	bin, log := fakeDocker(t)
+	e := NewDockerExecutor("", time.Second, WithDockerBinary(bin), WithDockerPackages("numpy"))
+	ctx := context.Background()
+	if err := e.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.Install(ctx, "pandas"); err != nil {
+		t.Fatal(err)
+	}
+	// As after a timeout.
+	e.mu.Lock()
+	e.removeLocked()
+	e.mu.Unlock()
+	if err := e.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := dockerCalls(t, log)
+	var installs []string
+	for _, c := range calls {
+		if strings.HasPrefix(c, "exec ") {
+			installs = append(installs, c)
+		}
+	}
+	if len(installs) != 3 || !strings.HasSuffix(installs[2], "install --quiet numpy pandas") {
+		t.Errorf("installs = %q, want numpy and pandas installed in the new container", installs)
+	}
+	if last := calls[len(calls)-1]; !strings.HasPrefix(last, "network disconnect bridge") {
+		t.Errorf("last call = %q, want the new container disconnected", last)
+	}
+}
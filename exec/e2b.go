@@ -0,0 +1,370 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// E2BExecutor runs Python in a disposable E2B cloud sandbox. One sandbox
+// is created per agent run and killed when the run ends, so each run gets
+// a fresh filesystem and can install packages without affecting others.
+//
+// Other hosted sandboxes (Modal, Daytona, etc.) can be used by running
+// cmd/neko-executor inside them and connecting with NewRemoteExecutor.
+type E2BExecutor struct {
+	apiKey   string
+	template string
+	apiURL   string
+	domain   string
+	timeout  time.Duration
+	ttl      time.Duration
+	packages []string
+	policy   *SafetyPolicy
+	codec    StateCodec
+	client   *http.Client
+
+	mu      sync.Mutex
+	sandbox *e2bSandbox
+	key     string // the API key the sandbox was created with
+}
+
+type e2bSandbox struct {
+	ID          string `json:"sandboxID"`
+	AccessToken string `json:"envdAccessToken"`
+}
+
+// E2BOption configures E2BExecutor.
+type E2BOption func(*E2BExecutor)
+
+// WithE2BTemplate sets the sandbox template. The default is the code
+// interpreter template.
+func WithE2BTemplate(template string) E2BOption {
+	return func(e *E2BExecutor) { e.template = template }
+}
+
+// WithE2BDomain sets the sandbox domain, for self-hosted deployments.
+func WithE2BDomain(domain string) E2BOption {
+	return func(e *E2BExecutor) {
+		e.domain = domain
+		e.apiURL = "https://api." + domain
+	}
+}
+
+// WithE2BTimeout sets the per-execution timeout.
+func WithE2BTimeout(d time.Duration) E2BOption {
+	return func(e *E2BExecutor) { e.timeout = d }
+}
+
+// WithE2BSandboxTTL sets how long an idle sandbox lives before E2B kills
+// it, as a safety net if Close is never called.
+func WithE2BSandboxTTL(d time.Duration) E2BOption {
+	return func(e *E2BExecutor) { e.ttl = d }
+}
+
+// WithE2BPackages sets pip packages installed when the sandbox starts.
+func WithE2BPackages(pkgs ...string) E2BOption {
+	return func(e *E2BExecutor) { e.packages = pkgs }
+}
+
+// WithE2BSafetyPolicy checks code against p before it runs. By default no
+// check is made, since the sandbox is the isolation boundary.
+func WithE2BSafetyPolicy(p *SafetyPolicy) E2BOption {
+	return func(e *E2BExecutor) { e.policy = p }
+}
+
+// WithE2BStateCodec sets how variables that are not JSON-native are kept
+// between steps. Defaults to StateCodecJSON.
+func WithE2BStateCodec(c StateCodec) E2BOption {
+	return func(e *E2BExecutor) { e.codec = c }
+}
+
+// WithE2BHTTPClient sets the HTTP client used for requests to E2B and
+// the sandbox.
+func WithE2BHTTPClient(c *http.Client) E2BOption {
+	return func(e *E2BExecutor) { e.client = c }
+}
+
+// NewE2BExecutor creates an E2B sandbox executor. If apiKey is empty,
+// the key is looked up as the secret E2B_API_KEY of the agent starting
+// the sandbox; see neko.WithSecrets.
+func NewE2BExecutor(apiKey string, opts ...E2BOption) *E2BExecutor {
+	e := &E2BExecutor{
+		apiKey:   apiKey,
+		template: "code-interpreter-v1",
+		apiURL:   "https://api.e2b.app",
+		domain:   "e2b.app",
+		timeout:  60 * time.Second,
+		ttl:      10 * time.Minute,
+		client:   &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Start creates the sandbox and installs configured packages.
+func (e *E2BExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sandbox != nil {
+		return nil
+	}
+	key := e.apiKey
+	if key == "" {
+		var err error
+		if key, err = neko.LookupSecret(ctx, "E2B_API_KEY"); err != nil {
+			return fmt.Errorf("e2b api key: %w", err)
+		}
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"templateID": e.template,
+		"timeout":    int(e.ttl.Seconds()),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.apiURL+"/sandboxes", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("e2b sandbox creation failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("e2b sandbox creation HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var sb e2bSandbox
+	if err := json.NewDecoder(resp.Body).Decode(&sb); err != nil {
+		return fmt.Errorf("failed to decode e2b sandbox: %w", err)
+	}
+	e.sandbox, e.key = &sb, key
+
+	if len(e.packages) > 0 {
+		if err := e.installLocked(ctx, e.packages); err != nil {
+			// The run ends without calling Close, so the sandbox would
+			// otherwise live until its TTL.
+			e.sandbox, e.key = nil, ""
+			e.kill(sb.ID, key)
+			return err
+		}
+	}
+	return nil
+}
+
+// Close kills the sandbox.
+func (e *E2BExecutor) Close() error {
+	e.mu.Lock()
+	sb, key := e.sandbox, e.key
+	e.sandbox, e.key = nil, ""
+	e.mu.Unlock()
+	if sb == nil {
+		return nil
+	}
+	return e.kill(sb.ID, key)
+}
+
+// kill deletes the sandbox with the given ID.
+func (e *E2BExecutor) kill(id, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, e.apiURL+"/sandboxes/"+id, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", key)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("e2b sandbox kill failed: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Execute runs Python code in the sandbox, starting one if needed.
+func (e *E2BExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	if err := e.Start(ctx); err != nil {
+		return nil, err
+	}
+	payload, err := encodePayload(runPayload{Code: code, State: state, Codec: e.codec, Policy: e.policy})
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	stdout, err := e.run(runCtx, inlineRunner(payload))
+	if err != nil {
+		res := &neko.ExecutionResult{Logs: stdout, State: state}
+		if cerr := contextError(ctx, runCtx, e.timeout); cerr != nil {
+			return res, cerr
+		}
+		return res, err
+	}
+	return parseRunOutput(stdout, state)
+}
+
+// WriteFile uploads data to path inside the sandbox.
+func (e *E2BExecutor) WriteFile(ctx context.Context, path string, data []byte) error {
+	sb, err := e.current()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", path)
+	if err != nil {
+		return err
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.filesURL(sb, path), &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Access-Token", sb.AccessToken)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("e2b file upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("e2b file upload HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ReadFile downloads the file at path inside the sandbox.
+func (e *E2BExecutor) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	sb, err := e.current()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.filesURL(sb, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Access-Token", sb.AccessToken)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("e2b file download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("e2b file download HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (e *E2BExecutor) current() (*e2bSandbox, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sandbox == nil {
+		return nil, fmt.Errorf("e2b sandbox not started")
+	}
+	return e.sandbox, nil
+}
+
+// Install runs pip install for pkgs inside the running sandbox.
+func (e *E2BExecutor) Install(ctx context.Context, pkgs ...string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sandbox == nil {
+		return fmt.Errorf("e2b sandbox not started")
+	}
+	return e.installLocked(ctx, pkgs)
+}
+
+func (e *E2BExecutor) installLocked(ctx context.Context, pkgs []string) error {
+	args, _ := json.Marshal(append([]string{"-m", "pip", "install", "--quiet"}, pkgs...))
+	script := fmt.Sprintf("import subprocess, sys, json\nsubprocess.run([sys.executable] + json.loads(%q), check=True)", args)
+	if _, err := e.runLocked(ctx, e.sandbox, script); err != nil {
+		return fmt.Errorf("package install failed: %w", err)
+	}
+	return nil
+}
+
+func (e *E2BExecutor) run(ctx context.Context, code string) (string, error) {
+	sb, err := e.current()
+	if err != nil {
+		return "", err
+	}
+	return e.runLocked(ctx, sb, code)
+}
+
+// runLocked sends code to the sandbox's code interpreter and collects its
+// streamed stdout. Errors raised by the code are returned with their
+// traceback.
+func (e *E2BExecutor) runLocked(ctx context.Context, sb *e2bSandbox, code string) (string, error) {
+	body, _ := json.Marshal(map[string]any{"code": code, "language": "python"})
+	endpoint := fmt.Sprintf("https://49999-%s.%s/execute", sb.ID, e.domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Access-Token", sb.AccessToken)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("e2b execution request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("e2b execution HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	// The response is a stream of JSON events, one per line.
+	var stdout strings.Builder
+	var execErr error
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev struct {
+			Type      string `json:"type"`
+			Text      string `json:"text"`
+			Name      string `json:"name"`
+			Value     string `json:"value"`
+			Traceback string `json:"traceback"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		switch ev.Type {
+		case "stdout":
+			stdout.WriteString(ev.Text)
+		case "error":
+			execErr = fmt.Errorf("%s: %s\n%s", ev.Name, ev.Value, ev.Traceback)
+		}
+	}
+	if err := scanner.Err(); err != nil && execErr == nil {
+		execErr = err
+	}
+	return stdout.String(), execErr
+}
+
+func (e *E2BExecutor) filesURL(sb *e2bSandbox, path string) string {
+	return fmt.Sprintf("https://49983-%s.%s/files?path=%s", sb.ID, e.domain, url.QueryEscape(path))
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// handlerTransport serves every request with a handler, whatever its host.
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func TestE2BStartKillsSandboxWhenInstallFails(t *testing.T) {
+	var mu sync.Mutex
+	var killed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sandboxes", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sandboxID":"sb1","envdAccessToken":"t"}`))
+	})
+	mux.HandleFunc("POST /execute", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"error","name":"CalledProcessError","value":"pip failed"}` + "\n"))
+	})
+	mux.HandleFunc("DELETE /sandboxes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		killed = append(killed, r.PathValue("id"))
+		mu.Unlock()
+	})
+
+	e := NewE2BExecutor("key", WithE2BPackages("nope"), WithE2BHTTPClient(&http.Client{Transport: handlerTransport{mux}}))
+	if err := e.Start(context.Background()); err == nil {
+		t.Fatal("Start succeeded, want the install error")
+	}
+	if len(killed) != 1 || killed[0] != "sb1" {
+		t.Errorf("killed = %v, want [sb1]", killed)
+	}
+	if _, err := e.current(); err == nil {
+		t.Error("sandbox still set after failed start")
+	}
+}
@@ -0,0 +1,32 @@
+package exec
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultEnvAllowlist lists the host environment variables the Python and
+// bash executors pass to the processes they start, unless given an
+// allowlist of their own. Everything else in the host environment, such
+// as API keys and cloud credentials, is withheld.
+var DefaultEnvAllowlist = []string{"PATH", "HOME", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// hostEnv returns the host environment variables named in allow, or in
+// DefaultEnvAllowlist if allow is nil, as KEY=value pairs. A name ending
+// in "*" matches every variable with that prefix.
+func hostEnv(allow []string) []string {
+	if allow == nil {
+		allow = DefaultEnvAllowlist
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, name := range allow {
+			if key == name || strings.HasSuffix(name, "*") && strings.HasPrefix(key, strings.TrimSuffix(name, "*")) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
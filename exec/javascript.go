@@ -0,0 +1,235 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/gocnn/neko"
+)
+
+// JSExecutor executes JavaScript in an embedded goja runtime, so no
+// external interpreter is needed.
+type JSExecutor struct {
+	timeout   time.Duration
+	heapGuard uint64
+	maxStack  int
+	tools     []neko.Tool
+}
+
+// JSOption configures JSExecutor.
+type JSOption func(*JSExecutor)
+
+// WithJSTimeout sets execution timeout.
+func WithJSTimeout(d time.Duration) JSOption {
+	return func(e *JSExecutor) { e.timeout = d }
+}
+
+// WithJSHeapGuard aborts execution once the whole process's Go heap has
+// grown by more than n bytes since it started. This is not a memory limit:
+// scripts share the heap with the rest of the program, so another
+// goroutine's allocations can trip the guard and a script's own
+// allocations are not measured on their own. It is off by default; for a
+// real limit, run scripts in a separate process or container.
+func WithJSHeapGuard(n uint64) JSOption {
+	return func(e *JSExecutor) { e.heapGuard = n }
+}
+
+// WithJSMaxCallStack limits the JavaScript call stack depth.
+func WithJSMaxCallStack(n int) JSOption {
+	return func(e *JSExecutor) { e.maxStack = n }
+}
+
+// WithJSTools exposes tools to scripts as global functions. A tool is
+// called either with a single object of named arguments or, if it has
+// exactly one input, with that input as a positional argument. Tools
+// attached to the execution context with neko.WithExecutionTools are
+// exposed the same way and take precedence over these.
+func WithJSTools(tools ...neko.Tool) JSOption {
+	return func(e *JSExecutor) { e.tools = append(e.tools, tools...) }
+}
+
+// NewJSExecutor creates a JavaScript code executor.
+func NewJSExecutor(opts ...JSOption) *JSExecutor {
+	e := &JSExecutor{
+		timeout:  30 * time.Second,
+		maxStack: 1024,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Language reports the language scripts are written in.
+func (e *JSExecutor) Language() string { return "javascript" }
+
+// Execute runs JavaScript code and returns its result. State values are
+// defined as globals before the code runs; enumerable globals assigned by
+// the code (var declarations and bare assignments, not let/const) are
+// returned as the updated state.
+func (e *JSExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	rt := goja.New()
+	rt.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+	rt.SetMaxCallStackSize(e.maxStack)
+
+	var logs strings.Builder
+	var final struct {
+		set   bool
+		value any
+	}
+	reserved := map[string]bool{}
+	define := func(name string, v any) {
+		rt.Set(name, v)
+		reserved[name] = true
+	}
+
+	for k, v := range state {
+		rt.Set(k, v)
+	}
+	print := func(call goja.FunctionCall) goja.Value {
+		parts := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			parts[i] = arg.String()
+		}
+		logs.WriteString(strings.Join(parts, " ") + "\n")
+		return goja.Undefined()
+	}
+	console := rt.NewObject()
+	console.Set("log", print)
+	console.Set("error", print)
+	define("console", console)
+	define("print", print)
+	define("final_answer", func(answer goja.Value) goja.Value {
+		final.set = true
+		final.value = answer.Export()
+		fmt.Fprintf(&logs, "Final Answer: %v\n", final.value)
+		return answer
+	})
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	// Context tools come from the agent and route calls through its
+	// approval, filtering and accounting, so they replace static ones of
+	// the same name.
+	tools := append(append([]neko.Tool(nil), e.tools...), neko.ExecutionTools(ctx)...)
+	for _, tool := range tools {
+		if tool.Name() == "final_answer" {
+			continue
+		}
+		define(tool.Name(), e.bindTool(runCtx, rt, tool))
+	}
+
+	stop := e.watch(runCtx, rt)
+	defer stop()
+
+	res := &neko.ExecutionResult{State: copyState(state)}
+	_, err := rt.RunString(code)
+	res.Logs = strings.TrimRight(logs.String(), "\n")
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			if cerr := contextError(ctx, runCtx, e.timeout); cerr != nil {
+				return res, cerr
+			}
+			return res, fmt.Errorf("%v", interrupted.Value())
+		}
+		return res, err
+	}
+
+	global := rt.GlobalObject()
+	for _, k := range global.Keys() {
+		if reserved[k] {
+			continue
+		}
+		v := global.Get(k)
+		if _, ok := goja.AssertFunction(v); ok {
+			continue
+		}
+		exported := v.Export()
+		if _, err := json.Marshal(exported); err == nil {
+			res.State[k] = exported
+		}
+	}
+	if final.set {
+		res.Output = final.value
+		res.IsFinal = true
+	}
+	return res, nil
+}
+
+// watch interrupts rt when ctx is done or the heap guard trips.
+// The returned function stops the watcher.
+func (e *JSExecutor) watch(ctx context.Context, rt *goja.Runtime) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var ticker *time.Ticker
+		var tick <-chan time.Time
+		var base uint64
+		if e.heapGuard > 0 {
+			base = heapBytes()
+			ticker = time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				rt.Interrupt(ctx.Err())
+				return
+			case <-tick:
+				if heap := heapBytes(); heap > base && heap-base > e.heapGuard {
+					rt.Interrupt(fmt.Sprintf("heap grew by more than %d bytes", e.heapGuard))
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (e *JSExecutor) bindTool(ctx context.Context, rt *goja.Runtime, tool neko.Tool) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		args := map[string]any{}
+		if len(call.Arguments) == 1 {
+			if obj, ok := call.Arguments[0].Export().(map[string]any); ok {
+				args = obj
+			} else if inputs := tool.Inputs(); len(inputs) == 1 {
+				for name := range inputs {
+					args[name] = call.Arguments[0].Export()
+				}
+			}
+		}
+		if err := neko.ValidateToolArgs(tool, args); err != nil {
+			panic(rt.NewGoError(err))
+		}
+		result, err := callTool(ctx, tool, args)
+		if err != nil {
+			panic(rt.NewGoError(neko.NewErrToolExecution(tool.Name(), err)))
+		}
+		return rt.ToValue(result)
+	}
+}
+
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
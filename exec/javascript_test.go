@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+type ctxKey struct{}
+
+// ctxTool records the value of ctxKey in the context it is called with.
+type ctxTool struct {
+	*neko.FuncTool
+	seen any
+}
+
+func (t *ctxTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	t.seen = ctx.Value(ctxKey{})
+	return t.FuncTool.Execute(args)
+}
+
+func TestJSExecutorUsesExecutionTools(t *testing.T) {
+	inputs := map[string]neko.ToolInput{"x": {Type: "number", Required: true}}
+	static := neko.NewFuncTool("double", "", inputs, "number", func(args map[string]any) (any, error) {
+		return 0, nil
+	})
+	bound := &ctxTool{FuncTool: neko.NewFuncTool("double", "", inputs, "string", func(args map[string]any) (any, error) {
+		return fmt.Sprint(args["x"]) + "!", nil
+	})}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "step")
+	ctx = neko.WithExecutionTools(ctx, bound)
+	res, err := NewJSExecutor(WithJSTools(static)).Execute(ctx, "final_answer(double(21))", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Output != "21!" {
+		t.Errorf("output = %v (%T), want 21!", res.Output, res.Output)
+	}
+	if bound.seen != "step" {
+		t.Errorf("tool saw context value %v, want the execution context", bound.seen)
+	}
+}
@@ -0,0 +1,211 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gocnn/neko"
+)
+
+// ExecutorPool runs Python code on pre-started interpreters. It keeps
+// size workers started and blocked waiting for code, so a step only pays
+// for running the code, not for interpreter startup and preloaded
+// imports. Each worker runs one execution and is replaced in the
+// background, so no state leaks between steps. Code runs in the directory
+// set with WithWorkDir, or else in the run's workspace if the agent has
+// one, or else in a temporary directory of the worker's own that is
+// removed with it. Files the code creates there are collected as
+// artifacts, and the workspace quota is checked as for PythonExecutor.
+type ExecutorPool struct {
+	config  *PythonExecutor
+	workers chan *poolWorker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type poolWorker struct {
+	proc   *process
+	cancel context.CancelFunc
+	dir    string // the worker's temporary directory
+}
+
+// stop kills the worker if it is still running and removes its directory.
+func (w *poolWorker) stop() {
+	w.cancel()
+	w.proc.cmd.Wait()
+	os.RemoveAll(w.dir)
+}
+
+// NewExecutorPool starts a pool of size Python workers configured with
+// opts. Use WithPreload to import heavy modules ahead of time. Call Close
+// to stop the idle workers.
+func NewExecutorPool(size int, opts ...PythonOption) *ExecutorPool {
+	if size <= 0 {
+		size = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ExecutorPool{
+		config:  NewPythonExecutor(opts...),
+		workers: make(chan *poolWorker, size),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for range size {
+		p.refill()
+	}
+	return p
+}
+
+// refill starts one worker in the background and adds it to the pool,
+// unless the pool is closed.
+func (p *ExecutorPool) refill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		w, err := p.spawn()
+		if err != nil {
+			return
+		}
+		select {
+		case p.workers <- w:
+		case <-p.ctx.Done():
+			w.stop()
+		}
+	}()
+}
+
+func (p *ExecutorPool) spawn() (*poolWorker, error) {
+	if p.ctx.Err() != nil {
+		return nil, p.ctx.Err()
+	}
+	dir, err := os.MkdirTemp("", "neko-run-")
+	if err != nil {
+		return nil, err
+	}
+	// Workers do not inherit p.ctx, so Close leaves checked-out workers
+	// to finish their execution.
+	ctx, cancel := context.WithCancel(context.Background())
+	proc, err := startProcess(p.config.command(ctx, dir))
+	if err != nil {
+		cancel()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &poolWorker{proc: proc, cancel: cancel, dir: dir}, nil
+}
+
+// checkout takes an idle worker, or starts one if none is ready.
+func (p *ExecutorPool) checkout() (*poolWorker, error) {
+	select {
+	case w := <-p.workers:
+		p.refill()
+		return w, nil
+	default:
+		w, err := p.spawn()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start worker: %w", err)
+		}
+		return w, nil
+	}
+}
+
+// Execute runs code on a warm worker with the same semantics as
+// PythonExecutor.Execute.
+func (p *ExecutorPool) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	return p.execute(ctx, code, state, nil)
+}
+
+// ExecuteStream is like Execute but delivers stdout and stderr lines while
+// the code runs.
+func (p *ExecutorPool) ExecuteStream(ctx context.Context, code string, state map[string]any) (<-chan neko.ExecutionDelta, error) {
+	return streamExecution(func(onLine func(string)) (*neko.ExecutionResult, error) {
+		return p.execute(ctx, code, state, onLine)
+	}), nil
+}
+
+func (p *ExecutorPool) execute(ctx context.Context, code string, state map[string]any, onLine func(string)) (*neko.ExecutionResult, error) {
+	cfg := p.config
+	bridge, err := startToolBridge(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer bridge.Close()
+
+	w, err := p.checkout()
+	if err != nil {
+		return nil, err
+	}
+	defer w.stop()
+	// The worker started in its own directory; the runner changes to the
+	// run's directory before the code runs.
+	dir := workspaceDir(ctx, cfg.workDir)
+	if dir == "" {
+		dir = w.dir
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := runPayload{Code: code, State: state, Codec: cfg.codec, Policy: cfg.policy, Limits: &cfg.limits, Dir: absDir}
+	bridge.apply(&rp)
+	payload, err := encodePayload(rp)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+	stop := context.AfterFunc(runCtx, w.cancel)
+	defer stop()
+
+	before := snapshotDir(dir)
+	stdout, stderr, err := w.proc.run(payload, onLine, cfg.maxOutput)
+	if err != nil {
+		res := &neko.ExecutionResult{Logs: stdout, State: state, Artifacts: collectArtifacts(dir, before)}
+		if cerr := contextError(ctx, runCtx, cfg.timeout); cerr != nil {
+			return res, cerr
+		}
+		if limitErr := (*errOutputLimit)(nil); errors.As(err, &limitErr) {
+			return res, err
+		}
+		return res, fmt.Errorf("%v: %s", err, stderr)
+	}
+	res, err := parseRunOutput(stdout, state)
+	res.Artifacts = append(res.Artifacts, collectArtifacts(dir, before)...)
+	return res, checkWorkspaceQuota(ctx, dir, err)
+}
+
+// Close stops all idle workers and the pool's background refills.
+// Executions in progress are not affected; their workers exit when they
+// finish.
+func (p *ExecutorPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.cancel()
+	p.wg.Wait()
+	for {
+		select {
+		case w := <-p.workers:
+			w.stop()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolCloseLeavesRunningExecution(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	p := NewExecutorPool(1, WithImports([]string{"time"}))
+	done := make(chan error, 1)
+	var logs string
+	go func() {
+		res, err := p.Execute(context.Background(), "import time\ntime.sleep(1)\nprint('finished')", nil)
+		if res != nil {
+			logs = res.Logs
+		}
+		done <- err
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("execution failed after Close: %v", err)
+	}
+	if !strings.Contains(logs, "finished") {
+		t.Errorf("logs = %q, want the execution's output", logs)
+	}
+}
+
+func TestPoolRunsInWorkDir(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := "import os\nprint(os.getcwd())\nopen('out.txt', 'w').write('hi')"
+
+	p := NewExecutorPool(1, WithSafetyPolicy(&SafetyPolicy{AuthorizedImports: []string{"os"}, AllowFileWrite: true}))
+	defer p.Close()
+	res, err := p.Execute(context.Background(), code, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := strings.TrimSpace(res.Logs); dir == cwd {
+		t.Errorf("code ran in the process's directory %s", cwd)
+	} else if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("worker directory %s not removed", dir)
+	}
+	if len(res.Artifacts) != 1 || string(res.Artifacts[0].Data) != "hi" {
+		t.Errorf("artifacts = %+v, want out.txt", res.Artifacts)
+	}
+
+	workDir := t.TempDir()
+	p = NewExecutorPool(1, WithSafetyPolicy(&SafetyPolicy{AuthorizedImports: []string{"os"}, AllowFileWrite: true}), WithWorkDir(workDir))
+	defer p.Close()
+	res, err = p.Execute(context.Background(), code, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := strings.TrimSpace(res.Logs); dir != workDir {
+		t.Errorf("code ran in %s, want %s", dir, workDir)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "out.txt")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPoolCloseDuringCheckouts(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	p := NewExecutorPool(2)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Execute(context.Background(), "x = 1", nil)
+		}()
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if _, err := p.checkout(); err == nil {
+		t.Error("checkout succeeded on a closed pool")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package exec
+
+import (
+	"os/exec"
+	"time"
+)
+
+// waitDelay bounds how long Wait blocks on output pipes after the process
+// has been killed, in case an orphaned grandchild still holds them open.
+const waitDelay = 2 * time.Second
+
+// killProcessGroupOnCancel falls back to killing only the direct child on
+// platforms without POSIX process groups.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	cmd.WaitDelay = waitDelay
+}
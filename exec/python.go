@@ -1,19 +1,36 @@
 package exec
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
-	"strings"
+	"sync"
 	"time"
+
+	"github.com/gocnn/neko"
 )
 
-// PythonExecutor executes Python code via subprocess.
+// PythonExecutor executes Python code via subprocess. The interpreter
+// only sees the host environment variables in DefaultEnvAllowlist, or
+// those set with WithEnvAllowlist and WithEnv.
 type PythonExecutor struct {
 	pythonPath string
 	timeout    time.Duration
-	imports    []string
+	policy     *SafetyPolicy
+	workDir    string
+	envAllow   []string
+	env        []string
+	limits     ResourceLimits
+	maxOutput  int
+	preload    []string
+	codec      StateCodec
+
+	mu      sync.Mutex
+	runDir  string
+	tempDir bool
 }
 
 // PythonOption configures PythonExecutor.
@@ -31,7 +48,77 @@ func WithTimeout(d time.Duration) PythonOption {
 
 // WithImports sets allowed imports.
 func WithImports(imports []string) PythonOption {
-	return func(e *PythonExecutor) { e.imports = imports }
+	return func(e *PythonExecutor) {
+		if e.policy == nil {
+			e.policy = DefaultSafetyPolicy()
+		}
+		e.policy.AuthorizedImports = imports
+	}
+}
+
+// WithSafetyPolicy replaces the safety policy checked before code runs.
+// A nil policy disables checking.
+func WithSafetyPolicy(p *SafetyPolicy) PythonOption {
+	return func(e *PythonExecutor) { e.policy = p }
+}
+
+// WithWorkDir runs code in dir and collects files it creates or changes
+// as artifacts. Without it, each agent run gets a fresh temporary
+// directory that is removed when the run ends, or runs in the run's
+// workspace if the agent has one.
+func WithWorkDir(dir string) PythonOption {
+	return func(e *PythonExecutor) { e.workDir = dir }
+}
+
+// WithEnvAllowlist sets the host environment variables the interpreter
+// sees, replacing DefaultEnvAllowlist. A name ending in "*" allows every
+// variable with that prefix, e.g. "PYTHON*". With no names, the
+// interpreter sees none.
+func WithEnvAllowlist(names ...string) PythonOption {
+	return func(e *PythonExecutor) { e.envAllow = append([]string{}, names...) }
+}
+
+// WithEnv sets an environment variable for the interpreter, in addition
+// to the allowed host variables.
+func WithEnv(key, value string) PythonOption {
+	return func(e *PythonExecutor) { e.env = append(e.env, key+"="+value) }
+}
+
+// WithCPUTimeLimit caps the CPU time the interpreter may use.
+func WithCPUTimeLimit(d time.Duration) PythonOption {
+	return func(e *PythonExecutor) { e.limits.CPUSeconds = int(d.Seconds()) }
+}
+
+// WithMemoryLimit caps the interpreter's address space in bytes.
+func WithMemoryLimit(n uint64) PythonOption {
+	return func(e *PythonExecutor) { e.limits.MemoryBytes = n }
+}
+
+// WithMaxProcesses caps how many processes the interpreter may create.
+// The limit is enforced per user by the OS, so it counts every process
+// the user already runs and must be set well above that number.
+func WithMaxProcesses(n int) PythonOption {
+	return func(e *PythonExecutor) { e.limits.MaxProcesses = n }
+}
+
+// WithMaxOutputSize kills the interpreter once it has written more than n
+// bytes to stdout and stderr combined.
+func WithMaxOutputSize(n int) PythonOption {
+	return func(e *PythonExecutor) { e.maxOutput = n }
+}
+
+// WithPreload imports modules when the interpreter starts, before code is
+// received. This mostly matters for ExecutorPool, where heavy imports
+// like numpy or pandas then happen while the worker is idle. Code must
+// still import the modules itself, subject to the safety policy.
+func WithPreload(modules ...string) PythonOption {
+	return func(e *PythonExecutor) { e.preload = modules }
+}
+
+// WithStateCodec sets how variables that are not JSON-native are kept
+// between steps. Defaults to StateCodecJSON.
+func WithStateCodec(c StateCodec) PythonOption {
+	return func(e *PythonExecutor) { e.codec = c }
 }
 
 // NewPythonExecutor creates a Python code executor.
@@ -39,7 +126,8 @@ func NewPythonExecutor(opts ...PythonOption) *PythonExecutor {
 	e := &PythonExecutor{
 		pythonPath: "python3",
 		timeout:    30 * time.Second,
-		imports:    []string{"math", "json", "datetime", "re", "collections"},
+		policy:     DefaultSafetyPolicy("math", "json", "datetime", "re", "collections"),
+		maxOutput:  1 << 20,
 	}
 	for _, opt := range opts {
 		opt(e)
@@ -47,144 +135,132 @@ func NewPythonExecutor(opts ...PythonOption) *PythonExecutor {
 	return e
 }
 
-// Execute runs Python code and returns output.
-func (e *PythonExecutor) Execute(code string, state map[string]any) (any, string, error) {
-	// Wrap code with state injection and output capture
-	wrappedCode := e.wrapCode(code, state)
-
-	cmd := exec.Command(e.pythonPath, "-c", wrappedCode)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	done := make(chan error)
-	go func() { done <- cmd.Run() }()
-
-	select {
-	case err := <-done:
-		logs := stdout.String()
-		if err != nil {
-			return nil, logs, fmt.Errorf("%v: %s", err, stderr.String())
-		}
-		// Parse result from stdout (last line is JSON result)
-		lines := strings.Split(strings.TrimSpace(logs), "\n")
-		if len(lines) == 0 {
-			return nil, logs, nil
+// Start prepares the working directory for an agent run.
+func (e *PythonExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runDir != "" {
+		return nil
+	}
+	if e.workDir != "" {
+		if err := os.MkdirAll(e.workDir, 0o755); err != nil {
+			return err
 		}
+		e.runDir = e.workDir
+		return nil
+	}
+	if w := neko.WorkspaceFromContext(ctx); w != nil {
+		e.runDir = w.Dir()
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "neko-run-")
+	if err != nil {
+		return err
+	}
+	e.runDir, e.tempDir = dir, true
+	return nil
+}
 
-		// Check for final answer marker
-		lastLine := lines[len(lines)-1]
-		if strings.HasPrefix(lastLine, "__RESULT__:") {
-			resultJSON := strings.TrimPrefix(lastLine, "__RESULT__:")
-			var result any
-			json.Unmarshal([]byte(resultJSON), &result)
-			return result, strings.Join(lines[:len(lines)-1], "\n"), nil
-		}
-		return nil, logs, nil
+// Close removes the run's temporary working directory, if any.
+func (e *PythonExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	dir, temp := e.runDir, e.tempDir
+	e.runDir, e.tempDir = "", false
+	if temp {
+		return os.RemoveAll(dir)
+	}
+	return nil
+}
 
-	case <-time.After(e.timeout):
-		cmd.Process.Kill()
-		return nil, "", fmt.Errorf("execution timeout after %v", e.timeout)
+// dir returns the directory code runs in: the run's directory if a run is
+// active, otherwise the configured work directory.
+func (e *PythonExecutor) dir() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runDir != "" {
+		return e.runDir
 	}
+	return e.workDir
+}
+
+// Execute runs Python code and returns its result. State is passed to the
+// interpreter on stdin and the result carries state updated with the
+// variables the code assigned. The interpreter and any children it spawns
+// are killed when ctx is cancelled or the timeout expires.
+func (e *PythonExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	return e.execute(ctx, code, state, nil)
 }
 
-func (e *PythonExecutor) wrapCode(code string, state map[string]any) string {
-	stateJSON, _ := json.Marshal(state)
+// ExecuteStream is like Execute but delivers stdout and stderr lines while
+// the code runs.
+func (e *PythonExecutor) ExecuteStream(ctx context.Context, code string, state map[string]any) (<-chan neko.ExecutionDelta, error) {
+	return streamExecution(func(onLine func(string)) (*neko.ExecutionResult, error) {
+		return e.execute(ctx, code, state, onLine)
+	}), nil
+}
 
-	return fmt.Sprintf(`
-import json
-import sys
-import math
+func (e *PythonExecutor) execute(ctx context.Context, code string, state map[string]any, onLine func(string)) (*neko.ExecutionResult, error) {
+	bridge, err := startToolBridge(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer bridge.Close()
 
-# Inject state
-__state__ = json.loads('%s')
-locals().update(__state__)
+	p := runPayload{Code: code, State: state, Codec: e.codec, Policy: e.policy, Limits: &e.limits}
+	bridge.apply(&p)
+	payload, err := encodePayload(p)
+	if err != nil {
+		return nil, err
+	}
 
-# Capture final answer
-__final_answer__ = None
-def final_answer(answer):
-    global __final_answer__
-    __final_answer__ = answer
-    print(f"Final Answer: {answer}")
-    return answer
+	runCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
 
-# Execute user code
-%s
+	dir := e.dir()
+	cmd := e.command(runCtx, dir)
 
-# Output result
-if __final_answer__ is not None:
-    try:
-        print("__RESULT__:" + json.dumps(__final_answer__))
-    except (TypeError, ValueError):
-        print("__RESULT__:" + json.dumps(str(__final_answer__)))
-`, string(stateJSON), code)
+	before := snapshotDir(dir)
+	stdout, stderr, err := runCommand(cmd, payload, onLine, e.maxOutput)
+	if err != nil {
+		res := &neko.ExecutionResult{Logs: stdout, State: state, Artifacts: collectArtifacts(dir, before)}
+		if cerr := contextError(ctx, runCtx, e.timeout); cerr != nil {
+			return res, cerr
+		}
+		if limitErr := (*errOutputLimit)(nil); errors.As(err, &limitErr) {
+			return res, err
+		}
+		return res, fmt.Errorf("%v: %s", err, stderr)
+	}
+	res, err := parseRunOutput(stdout, state)
+	res.Artifacts = append(res.Artifacts, collectArtifacts(dir, before)...)
+	return res, checkWorkspaceQuota(ctx, dir, err)
 }
 
-// DockerExecutor executes code in a Docker container.
-type DockerExecutor struct {
-	image   string
-	timeout time.Duration
+// command builds the interpreter command running the runner script.
+func (e *PythonExecutor) command(ctx context.Context, dir string) *exec.Cmd {
+	script := pythonRunner
+	if len(e.preload) > 0 {
+		modules, _ := json.Marshal(e.preload)
+		script = "import importlib\nfor __m in " + string(modules) + ":\n" +
+			"    try:\n        importlib.import_module(__m)\n    except ImportError:\n        pass\n" + script
+	}
+	cmd := exec.CommandContext(ctx, e.pythonPath, "-u", "-c", script)
+	cmd.Dir = dir
+	cmd.Env = append(hostEnv(e.envAllow), e.env...)
+	killProcessGroupOnCancel(cmd)
+	return cmd
 }
 
-// NewDockerExecutor creates a Docker-based executor.
-func NewDockerExecutor(image string, timeout time.Duration) *DockerExecutor {
-	if image == "" {
-		image = "python:3.11-slim"
+// contextError reports why a command was stopped: the caller's context
+// error if it was cancelled, or a timeout error if only the executor's
+// own deadline expired. It returns nil if neither context is done.
+func contextError(parent, run context.Context, timeout time.Duration) error {
+	if err := parent.Err(); err != nil {
+		return err
 	}
-	return &DockerExecutor{image: image, timeout: timeout}
-}
-
-// Execute runs code in Docker container.
-func (e *DockerExecutor) Execute(code string, state map[string]any) (any, string, error) {
-	stateJSON, _ := json.Marshal(state)
-
-	wrappedCode := fmt.Sprintf(`
-import json
-__state__ = json.loads('%s')
-locals().update(__state__)
-__final_answer__ = None
-def final_answer(answer):
-    global __final_answer__
-    __final_answer__ = answer
-    return answer
-%s
-if __final_answer__ is not None:
-    print("__RESULT__:" + json.dumps(__final_answer__))
-`, string(stateJSON), code)
-
-	cmd := exec.Command("docker", "run", "--rm", "-i",
-		"--network=none",
-		"--memory=256m",
-		"--cpus=0.5",
-		e.image,
-		"python3", "-c", wrappedCode)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	done := make(chan error)
-	go func() { done <- cmd.Run() }()
-
-	select {
-	case err := <-done:
-		logs := stdout.String()
-		if err != nil {
-			return nil, logs, fmt.Errorf("%v: %s", err, stderr.String())
-		}
-		lines := strings.Split(strings.TrimSpace(logs), "\n")
-		if len(lines) > 0 {
-			lastLine := lines[len(lines)-1]
-			if after, ok := strings.CutPrefix(lastLine, "__RESULT__:"); ok {
-				resultJSON := after
-				var result any
-				json.Unmarshal([]byte(resultJSON), &result)
-				return result, strings.Join(lines[:len(lines)-1], "\n"), nil
-			}
-		}
-		return nil, logs, nil
-	case <-time.After(e.timeout):
-		return nil, "", fmt.Errorf("docker execution timeout")
+	if errors.Is(run.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("execution timeout after %v", timeout)
 	}
+	return nil
 }
@@ -0,0 +1,453 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// RemoteRequest is the body of a POST to a remote executor's /execute
+// endpoint. Session is the ID returned by POST /sessions, if the client
+// started one.
+type RemoteRequest struct {
+	Code    string         `json:"code"`
+	State   map[string]any `json:"state"`
+	Session string         `json:"session,omitempty"`
+}
+
+// RemoteResponse is the reply from a remote executor. Error is set when
+// the code failed; Result may still carry partial logs in that case.
+type RemoteResponse struct {
+	Result *neko.ExecutionResult `json:"result,omitempty"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// RemoteInfo is the reply to GET /info on a remote executor.
+type RemoteInfo struct {
+	Language string `json:"language"`
+}
+
+// RemoteSession is the reply to POST /sessions on a remote executor.
+type RemoteSession struct {
+	ID string `json:"id"`
+}
+
+// RemoteExecutor runs code on a remote executor server over HTTP. Each
+// agent run is a session on the server, which servers from
+// NewRemoteSessionHandler back with an executor of its own.
+type RemoteExecutor struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	mu       sync.Mutex
+	session  string
+	language string
+}
+
+// RemoteOption configures RemoteExecutor.
+type RemoteOption func(*RemoteExecutor)
+
+// WithRemoteToken sets the bearer token sent with each request.
+func WithRemoteToken(token string) RemoteOption {
+	return func(e *RemoteExecutor) { e.token = token }
+}
+
+// WithRemoteHTTPClient sets the HTTP client used for requests.
+func WithRemoteHTTPClient(c *http.Client) RemoteOption {
+	return func(e *RemoteExecutor) { e.client = c }
+}
+
+// NewRemoteExecutor creates an executor that sends code to the server at
+// baseURL, e.g. "http://sandbox:8080".
+func NewRemoteExecutor(baseURL string, opts ...RemoteOption) *RemoteExecutor {
+	e := &RemoteExecutor{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 5 * time.Minute},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Language returns the language the server runs, as reported by its
+// GET /info endpoint. It is "python" if the server cannot be reached.
+func (e *RemoteExecutor) Language() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.language != "" {
+		return e.language
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var info RemoteInfo
+	if err := e.do(ctx, http.MethodGet, "/info", nil, &info); err != nil || info.Language == "" {
+		return "python"
+	}
+	e.language = info.Language
+	return e.language
+}
+
+// Start opens a session on the server, so the run's code gets an
+// executor of its own. Servers from NewRemoteHandler have no sessions;
+// their clients share its executor.
+func (e *RemoteExecutor) Start(ctx context.Context) error {
+	var s RemoteSession
+	err := e.do(ctx, http.MethodPost, "/sessions", nil, &s)
+	if isRemoteNotFound(err) {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start remote session: %w", err)
+	}
+	e.mu.Lock()
+	e.session = s.ID
+	e.mu.Unlock()
+	return nil
+}
+
+// Close ends the session opened by Start, releasing its executor on the
+// server.
+func (e *RemoteExecutor) Close() error {
+	e.mu.Lock()
+	id := e.session
+	e.session = ""
+	e.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := e.do(ctx, http.MethodDelete, "/sessions/"+id, nil, nil); err != nil && !isRemoteNotFound(err) {
+		return fmt.Errorf("failed to close remote session: %w", err)
+	}
+	return nil
+}
+
+// Execute sends code and state to the remote server and returns its
+// result. Cancelling ctx aborts the request; the server in turn cancels
+// the execution when the connection is closed.
+func (e *RemoteExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	e.mu.Lock()
+	session := e.session
+	e.mu.Unlock()
+	var out RemoteResponse
+	if err := e.do(ctx, http.MethodPost, "/execute", RemoteRequest{Code: code, State: state, Session: session}, &out); err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return out.Result, fmt.Errorf("%s", out.Error)
+	}
+	return out.Result, nil
+}
+
+// remoteHTTPError is an error reply from a remote executor.
+type remoteHTTPError struct {
+	status int
+	msg    string
+}
+
+func (e *remoteHTTPError) Error() string {
+	return fmt.Sprintf("remote executor HTTP %d: %s", e.status, e.msg)
+}
+
+func isRemoteNotFound(err error) bool {
+	var he *remoteHTTPError
+	return errors.As(err, &he) && he.status == http.StatusNotFound
+}
+
+// do sends a request with body, if any, encoded as JSON to path on the
+// server and decodes the reply into out, if any.
+func (e *RemoteExecutor) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode state: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote execution request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &remoteHTTPError{status: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode remote response: %w", err)
+	}
+	return nil
+}
+
+// maxRemoteRequestBytes caps the size of a request body accepted by
+// NewRemoteHandler, code and state included.
+const maxRemoteRequestBytes = 32 << 20
+
+// NewRemoteHandler exposes executor over HTTP at POST /execute, speaking
+// the protocol used by RemoteExecutor. Every client shares executor; use
+// NewRemoteSessionHandler to give each its own. If token is non-empty,
+// requests must carry it as a bearer token. Request bodies larger than
+// 32 MiB are rejected.
+func NewRemoteHandler(executor neko.CodeExecutor, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /execute", func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := decodeRemoteRequest(w, r, token); ok {
+			executeRemote(w, r, executor, req)
+		}
+	})
+	handleRemoteInfo(mux, token, remoteLanguage(executor))
+	return mux
+}
+
+// remoteSessionIdle is how long a session may go unused before
+// NewRemoteSessionHandler closes it, for clients that never called Close.
+const remoteSessionIdle = time.Hour
+
+// maxRemoteSessions caps the sessions a RemoteSessionHandler holds open.
+const maxRemoteSessions = 64
+
+// RemoteSessionHandler serves the RemoteExecutor protocol, giving each
+// client session an executor of its own, so clients never see each
+// other's variables or files. Requests outside a session get a fresh
+// executor each.
+type RemoteSessionHandler struct {
+	mux         *http.ServeMux
+	newExecutor func() neko.CodeExecutor
+
+	mu       sync.Mutex
+	sessions map[string]*remoteSession
+}
+
+type remoteSession struct {
+	mu       sync.Mutex // serializes executions
+	executor neko.CodeExecutor
+	lastUsed time.Time
+}
+
+// NewRemoteSessionHandler creates a handler whose sessions run code with
+// executors from newExecutor. Executors that are SessionExecutors are
+// started when their session opens and closed when it ends, when it has
+// been idle for an hour, or when the handler is closed. If token is
+// non-empty, requests must carry it as a bearer token.
+func NewRemoteSessionHandler(newExecutor func() neko.CodeExecutor, token string) *RemoteSessionHandler {
+	h := &RemoteSessionHandler{mux: http.NewServeMux(), newExecutor: newExecutor, sessions: map[string]*remoteSession{}}
+	h.mux.HandleFunc("POST /sessions", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r, token) {
+			return
+		}
+		id, err := h.open(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RemoteSession{ID: id})
+	})
+	h.mux.HandleFunc("DELETE /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r, token) {
+			return
+		}
+		if !h.close(r.PathValue("id")) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+		}
+	})
+	h.mux.HandleFunc("POST /execute", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRemoteRequest(w, r, token)
+		if !ok {
+			return
+		}
+		if req.Session == "" {
+			executor, err := startExecutor(r.Context(), newExecutor)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			defer closeExecutor(executor)
+			executeRemote(w, r, executor, req)
+			return
+		}
+		h.mu.Lock()
+		s := h.sessions[req.Session]
+		h.mu.Unlock()
+		if s == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lastUsed = time.Now()
+		executeRemote(w, r, s.executor, req)
+	})
+	handleRemoteInfo(h.mux, token, remoteLanguage(newExecutor()))
+	return h
+}
+
+func (h *RemoteSessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Close closes every open session.
+func (h *RemoteSessionHandler) Close() error {
+	h.mu.Lock()
+	sessions := h.sessions
+	h.sessions = map[string]*remoteSession{}
+	h.mu.Unlock()
+	var errs []error
+	for _, s := range sessions {
+		s.mu.Lock()
+		errs = append(errs, closeExecutor(s.executor))
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// open starts a session, first closing those left idle.
+func (h *RemoteSessionHandler) open(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	for id, s := range h.sessions {
+		if s.mu.TryLock() {
+			if time.Since(s.lastUsed) > remoteSessionIdle {
+				delete(h.sessions, id)
+				closeExecutor(s.executor)
+			}
+			s.mu.Unlock()
+		}
+	}
+	full := len(h.sessions) >= maxRemoteSessions
+	h.mu.Unlock()
+	if full {
+		return "", fmt.Errorf("too many sessions")
+	}
+
+	executor, err := startExecutor(ctx, h.newExecutor)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	h.mu.Lock()
+	h.sessions[id] = &remoteSession{executor: executor, lastUsed: time.Now()}
+	h.mu.Unlock()
+	return id, nil
+}
+
+// close ends the session id, reporting whether it was open.
+func (h *RemoteSessionHandler) close(id string) bool {
+	h.mu.Lock()
+	s := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closeExecutor(s.executor)
+	return true
+}
+
+func startExecutor(ctx context.Context, newExecutor func() neko.CodeExecutor) (neko.CodeExecutor, error) {
+	executor := newExecutor()
+	if se, ok := executor.(neko.SessionExecutor); ok {
+		// The session outlives the request that opens it.
+		if err := se.Start(context.WithoutCancel(ctx)); err != nil {
+			return nil, fmt.Errorf("failed to start executor: %w", err)
+		}
+	}
+	return executor, nil
+}
+
+func closeExecutor(executor neko.CodeExecutor) error {
+	if se, ok := executor.(neko.SessionExecutor); ok {
+		return se.Close()
+	}
+	return nil
+}
+
+// authorized checks the request's bearer token, writing an error if it
+// is wrong.
+func authorized(w http.ResponseWriter, r *http.Request, token string) bool {
+	auth := []byte(r.Header.Get("Authorization"))
+	if token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func decodeRemoteRequest(w http.ResponseWriter, r *http.Request, token string) (RemoteRequest, bool) {
+	var req RemoteRequest
+	if !authorized(w, r, token) {
+		return req, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRemoteRequestBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func executeRemote(w http.ResponseWriter, r *http.Request, executor neko.CodeExecutor, req RemoteRequest) {
+	res, err := executor.Execute(r.Context(), req.Code, req.State)
+	out := RemoteResponse{Result: res}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// handleRemoteInfo serves GET /info and GET /healthz.
+func handleRemoteInfo(mux *http.ServeMux, token, language string) {
+	mux.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r, token) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RemoteInfo{Language: language})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func remoteLanguage(executor neko.CodeExecutor) string {
+	if le, ok := executor.(neko.LanguageExecutor); ok {
+		return le.Language()
+	}
+	return "python"
+}
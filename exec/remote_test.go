@@ -0,0 +1,127 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+type echoExecutor struct{}
+
+func (echoExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	return &neko.ExecutionResult{Output: code, State: state}, nil
+}
+
+func TestRemoteRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(NewRemoteHandler(echoExecutor{}, "secret"))
+	defer srv.Close()
+
+	e := NewRemoteExecutor(srv.URL, WithRemoteToken("secret"))
+	res, err := e.Execute(context.Background(), "1 + 1", map[string]any{"x": 1.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Output != "1 + 1" || res.State["x"] != 1.0 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestRemoteHandlerRejectsBadToken(t *testing.T) {
+	srv := httptest.NewServer(NewRemoteHandler(echoExecutor{}, "secret"))
+	defer srv.Close()
+
+	_, err := NewRemoteExecutor(srv.URL, WithRemoteToken("wrong")).Execute(context.Background(), "x", nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want HTTP 401", err)
+	}
+}
+
+func TestRemoteHandlerLimitsBody(t *testing.T) {
+	h := NewRemoteHandler(echoExecutor{}, "")
+	body := `{"code":"` + strings.Repeat("x", maxRemoteRequestBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRemoteLanguage(t *testing.T) {
+	srv := httptest.NewServer(NewRemoteHandler(NewJSExecutor(), "secret"))
+	defer srv.Close()
+
+	if lang := NewRemoteExecutor(srv.URL, WithRemoteToken("secret")).Language(); lang != "javascript" {
+		t.Errorf("Language() = %q, want the server's javascript", lang)
+	}
+}
+
+// sessionExecutor reports which executor ran the code and records
+// whether it was closed.
+type sessionExecutor struct {
+	id      int
+	started bool
+	closed  bool
+}
+
+func (e *sessionExecutor) Start(ctx context.Context) error { e.started = true; return nil }
+func (e *sessionExecutor) Close() error                    { e.closed = true; return nil }
+
+func (e *sessionExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	if !e.started || e.closed {
+		return nil, errors.New("not started")
+	}
+	return &neko.ExecutionResult{Output: e.id}, nil
+}
+
+func TestRemoteSessionsAreSeparate(t *testing.T) {
+	var executors []*sessionExecutor
+	h := NewRemoteSessionHandler(func() neko.CodeExecutor {
+		e := &sessionExecutor{id: len(executors)}
+		executors = append(executors, e)
+		return e
+	}, "")
+	defer h.Close()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	a, b := NewRemoteExecutor(srv.URL), NewRemoteExecutor(srv.URL)
+	for _, e := range []*RemoteExecutor{a, b} {
+		if err := e.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ra, err := a.Execute(context.Background(), "x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, err := b.Execute(context.Background(), "x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.Output == rb.Output {
+		t.Errorf("both clients ran on executor %v", ra.Output)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	closed := 0
+	for _, e := range executors {
+		if e.closed {
+			closed++
+		}
+	}
+	if closed != 1 {
+		t.Errorf("%d executors closed after one session ended, want 1", closed)
+	}
+	if _, err := b.Execute(context.Background(), "x", nil); err != nil {
+		t.Errorf("other session broken by Close: %v", err)
+	}
+}
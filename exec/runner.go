@@ -0,0 +1,286 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gocnn/neko"
+)
+
+// resultMarker prefixes the line the runner script prints with its
+// JSON-encoded result envelope.
+const resultMarker = "__NEKO_RESULT__:"
+
+// pythonRunner is the driver script passed to the interpreter with -c.
+// Code and state are read from stdin as a single JSON document, so no
+// user-controlled text is ever spliced into Python source.
+const pythonRunner = `
+import json
+import sys
+__neko_payload__ = json.loads(sys.stdin.read())
+` + pythonRunnerBody
+
+// pythonRunnerBody executes the code in __neko_payload__, which the
+// caller must define first. The code runs in its own namespace so the
+// runner's imports are not reachable from it. Matplotlib is forced onto a
+// non-interactive backend and any open figures are captured as PNGs.
+// After the code runs, the final answer, the figures and every
+// JSON-serializable variable, plus those the payload's state codec can
+// encode, are written back as one result envelope on the last line of
+// stdout.
+const pythonRunnerBody = `
+import json
+import sys
+import types
+` + pythonSafetyCheck + `
+def __neko_tool_stub__(bridge, name, params):
+    import socket
+
+    def call(*args, **kwargs):
+        if len(args) > len(params):
+            raise TypeError(f"{name}() takes {len(params)} positional arguments but {len(args)} were given")
+        kwargs.update(zip(params, args))
+        request = {"token": bridge["token"], "tool": name, "arguments": kwargs}
+        with socket.create_connection((bridge["host"], bridge["port"])) as conn:
+            conn.sendall((json.dumps(request) + "\n").encode())
+            response = json.loads(conn.makefile("r", encoding="utf-8").readline())
+        if response.get("error"):
+            raise RuntimeError(response["error"])
+        return response.get("result")
+
+    call.__name__ = name
+    return call
+
+def __neko_decode_state__(value):
+    if not (isinstance(value, dict) and "__neko_codec__" in value):
+        return value
+    import base64
+    data = base64.b64decode(value["data"])
+    if value["__neko_codec__"] == "pickle":
+        import pickle
+        return pickle.loads(data)
+    if value["__neko_codec__"] == "arrow":
+        import pyarrow as pa
+        if value.get("kind") == "tensor":
+            return pa.ipc.read_tensor(pa.BufferReader(data)).to_numpy()
+        return pa.ipc.open_stream(data).read_pandas()
+    return value
+
+def __neko_encode_state__(value, codec):
+    import base64
+    kind = None
+    try:
+        if codec == "pickle":
+            import pickle
+            data = pickle.dumps(value)
+        elif codec == "arrow":
+            cls = type(value)
+            if cls.__module__.startswith("pandas") and cls.__name__ == "DataFrame":
+                import pyarrow as pa
+                table = pa.Table.from_pandas(value)
+                sink = pa.BufferOutputStream()
+                with pa.ipc.new_stream(sink, table.schema) as writer:
+                    writer.write_table(table)
+                kind = "dataframe"
+            elif cls.__module__ == "numpy" and cls.__name__ == "ndarray":
+                import pyarrow as pa
+                sink = pa.BufferOutputStream()
+                pa.ipc.write_tensor(pa.Tensor.from_numpy(value), sink)
+                kind = "tensor"
+            else:
+                return None, False
+            data = sink.getvalue().to_pybytes()
+        else:
+            return None, False
+    except Exception:
+        return None, False
+    return {"__neko_codec__": codec, "kind": kind, "data": base64.b64encode(data).decode()}, True
+
+def __neko_run__(payload):
+    import math
+    limits = payload.get("limits")
+    if limits:
+        try:
+            import resource
+        except ImportError:
+            resource = None
+        if resource is not None:
+            for name, key in (("RLIMIT_CPU", "cpu_seconds"), ("RLIMIT_AS", "memory_bytes"), ("RLIMIT_NPROC", "max_processes")):
+                if limits.get(key) and hasattr(resource, name):
+                    # A hard CPU limit above the soft one delivers SIGXCPU
+                    # rather than an anonymous SIGKILL.
+                    hard = limits[key] + 1 if key == "cpu_seconds" else limits[key]
+                    resource.setrlimit(getattr(resource, name), (limits[key], hard))
+    if payload.get("dir"):
+        __import__("os").chdir(payload["dir"])
+    __import__("os").environ.setdefault("MPLBACKEND", "Agg")
+
+    final = {"set": False, "value": None}
+    def final_answer(answer):
+        final["set"] = True
+        final["value"] = answer
+        print(f"Final Answer: {answer}")
+        return answer
+
+    ns = {"__name__": "__main__", "math": math, "final_answer": final_answer}
+    bridge = payload.get("bridge")
+    for tool in payload.get("tools") or []:
+        ns[tool["name"]] = __neko_tool_stub__(bridge, tool["name"], tool["params"])
+    ns.update({k: __neko_decode_state__(v) for k, v in payload["state"].items()})
+    # Run the code like a REPL cell: if it ends in an expression, its
+    # value is captured as the step's result.
+    import ast
+    tree = ast.parse(payload["code"], "<code>")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, "<code>", "exec"), ns)
+    last_value = eval(compile(last, "<code>", "eval"), ns) if last is not None else None
+
+    def encode(value):
+        try:
+            json.dumps(value)
+            return value, True
+        except (TypeError, ValueError):
+            return None, False
+
+    codec = payload.get("codec") or "json"
+    state = {}
+    for k, v in ns.items():
+        if k.startswith("_") or isinstance(v, (types.ModuleType, types.FunctionType, type)):
+            continue
+        encoded, ok = encode(v)
+        if not ok:
+            encoded, ok = __neko_encode_state__(v, codec)
+        if ok:
+            state[k] = encoded
+
+    figures = []
+    plt = sys.modules.get("matplotlib.pyplot")
+    if plt is not None:
+        import base64
+        import io
+        for num in plt.get_fignums():
+            buf = io.BytesIO()
+            plt.figure(num).savefig(buf, format="png")
+            figures.append(base64.b64encode(buf.getvalue()).decode())
+        plt.close("all")
+
+    output, ok = encode(final["value"])
+    if not ok:
+        output = str(final["value"])
+    encoded, ok = encode(last_value)
+    last_value = encoded if ok else repr(last_value)
+    return {"has_output": final["set"], "output": output, "last_value": last_value, "state": state, "figures": figures}
+
+__neko_result__ = __neko_run__(__neko_payload__)
+sys.stdout.flush()
+print("` + resultMarker + `" + json.dumps(__neko_result__))
+`
+
+// runPayload is the stdin document consumed by pythonRunner.
+type runPayload struct {
+	Code   string          `json:"code"`
+	State  map[string]any  `json:"state"`
+	Codec  StateCodec      `json:"codec,omitempty"`
+	Policy *SafetyPolicy   `json:"policy,omitempty"`
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	Tools  []bridgeTool    `json:"tools,omitempty"`
+	Bridge *bridgeEndpoint `json:"bridge,omitempty"`
+	Dir    string          `json:"dir,omitempty"` // changed to before the code runs, for pre-started interpreters
+}
+
+// ResourceLimits are OS resource limits applied by the runner before the
+// code starts. Zero fields are unlimited. They are set with setrlimit and
+// silently ignored on platforms without it.
+type ResourceLimits struct {
+	CPUSeconds   int    `json:"cpu_seconds,omitempty"`
+	MemoryBytes  uint64 `json:"memory_bytes,omitempty"`
+	MaxProcesses int    `json:"max_processes,omitempty"`
+}
+
+// runResult is the envelope pythonRunner prints after execution.
+type runResult struct {
+	HasOutput  bool           `json:"has_output"`
+	LastValue  any            `json:"last_value"`
+	Output     any            `json:"output"`
+	State      map[string]any `json:"state"`
+	Figures    [][]byte       `json:"figures"`
+	Violations []string       `json:"violations"`
+}
+
+// encodePayload serializes a payload for the runner's stdin.
+func encodePayload(p runPayload) ([]byte, error) {
+	if p.State == nil {
+		p.State = map[string]any{}
+	}
+	if p.Limits != nil && *p.Limits == (ResourceLimits{}) {
+		p.Limits = nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %w", err)
+	}
+	return data, nil
+}
+
+// inlineRunner returns a self-contained script that embeds the payload as
+// a string literal, for interpreters that cannot be fed stdin. A JSON
+// string is also a valid Python string literal.
+func inlineRunner(payload []byte) string {
+	literal, _ := json.Marshal(string(payload))
+	return "import json\n__neko_payload__ = json.loads(" + string(literal) + ")\n" + pythonRunnerBody
+}
+
+// parseRunOutput separates user logs from the result envelope in stdout.
+// The returned state is a copy of state updated with the variables the
+// code assigned.
+func parseRunOutput(stdout string, state map[string]any) (*neko.ExecutionResult, error) {
+	updated := copyState(state)
+	idx := strings.LastIndex(stdout, resultMarker)
+	if idx < 0 {
+		return &neko.ExecutionResult{Logs: stdout, State: updated}, nil
+	}
+	res := &neko.ExecutionResult{
+		Logs:  strings.TrimRight(stdout[:idx], "\n"),
+		State: updated,
+	}
+	line := stdout[idx+len(resultMarker):]
+	if nl := strings.IndexByte(line, '\n'); nl >= 0 {
+		line = line[:nl]
+	}
+
+	var env runResult
+	if err := json.Unmarshal([]byte(line), &env); err != nil {
+		return res, fmt.Errorf("failed to decode execution result: %w", err)
+	}
+	if err := violationsError(env.Violations); err != nil {
+		return res, err
+	}
+	for k, v := range env.State {
+		updated[k] = v
+	}
+	for i, png := range env.Figures {
+		res.Artifacts = append(res.Artifacts, neko.Artifact{
+			Name:     fmt.Sprintf("figure_%d.png", i+1),
+			MIMEType: "image/png",
+			Data:     png,
+		})
+	}
+	if env.HasOutput {
+		res.Output = env.Output
+		res.IsFinal = true
+	} else {
+		res.Output = env.LastValue
+	}
+	return res, nil
+}
+
+func copyState(state map[string]any) map[string]any {
+	out := make(map[string]any, len(state))
+	for k, v := range state {
+		out[k] = v
+	}
+	return out
+}
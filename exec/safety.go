@@ -0,0 +1,104 @@
+package exec
+
+import "strings"
+
+// SafetyPolicy restricts what executed Python code may do. It is enforced
+// by walking the code's AST inside the interpreter before anything runs;
+// code that violates the policy is rejected with an error listing every
+// violation, which the agent sees as the step's observation.
+type SafetyPolicy struct {
+	// AuthorizedImports lists importable modules. An entry also authorizes
+	// its submodules, and "*" authorizes everything.
+	AuthorizedImports []string `json:"authorized_imports"`
+	// BannedBuiltins lists names that may not be referenced.
+	BannedBuiltins []string `json:"banned_builtins"`
+	// AllowFileWrite permits open() in write/append mode and
+	// Path.write_text/write_bytes.
+	AllowFileWrite bool `json:"allow_file_write"`
+}
+
+// DefaultBannedBuiltins are builtins that allow escaping static checks.
+var DefaultBannedBuiltins = []string{
+	"eval", "exec", "compile", "__import__", "globals", "locals", "vars",
+	"breakpoint", "input", "getattr", "setattr", "delattr",
+}
+
+// DefaultSafetyPolicy returns a policy authorizing the given imports with
+// the default banned builtins and no file writes.
+func DefaultSafetyPolicy(imports ...string) *SafetyPolicy {
+	return &SafetyPolicy{
+		AuthorizedImports: imports,
+		BannedBuiltins:    DefaultBannedBuiltins,
+	}
+}
+
+// ErrUnsafeCode reports code rejected by a SafetyPolicy.
+type ErrUnsafeCode struct {
+	Violations []string
+}
+
+func (e *ErrUnsafeCode) Error() string {
+	return "code rejected by safety policy:\n- " + strings.Join(e.Violations, "\n- ")
+}
+
+// pythonSafetyCheck validates __neko_payload__["code"] against
+// __neko_payload__["policy"] and prints a result envelope listing the
+// violations, then exits, if any are found.
+const pythonSafetyCheck = `
+__neko_policy__ = __neko_payload__.get("policy")
+if __neko_policy__ is not None:
+    import ast
+
+    def __neko_check__(code, policy):
+        allowed = policy.get("authorized_imports") or []
+        banned = set(policy.get("banned_builtins") or [])
+        write_ok = policy.get("allow_file_write", False)
+        problems = []
+
+        def import_ok(name):
+            return "*" in allowed or any(name == a or name.startswith(a + ".") for a in allowed)
+
+        try:
+            tree = ast.parse(code)
+        except SyntaxError:
+            return problems
+        for node in ast.walk(tree):
+            line = getattr(node, "lineno", "?")
+            if isinstance(node, ast.Import):
+                for alias in node.names:
+                    if not import_ok(alias.name):
+                        problems.append(f"line {line}: import of '{alias.name}' is not allowed (authorized: {', '.join(allowed) or 'none'})")
+            elif isinstance(node, ast.ImportFrom):
+                if node.level:
+                    problems.append(f"line {line}: relative imports are not allowed")
+                elif not import_ok(node.module or ""):
+                    problems.append(f"line {line}: import from '{node.module}' is not allowed (authorized: {', '.join(allowed) or 'none'})")
+            elif isinstance(node, ast.Name) and node.id in banned:
+                problems.append(f"line {line}: use of '{node.id}' is not allowed")
+            elif isinstance(node, ast.Attribute):
+                if node.attr.startswith("__") and node.attr.endswith("__"):
+                    problems.append(f"line {line}: access to dunder attribute '{node.attr}' is not allowed")
+                elif not write_ok and node.attr in ("write_text", "write_bytes"):
+                    problems.append(f"line {line}: writing files is not allowed")
+            if not write_ok and isinstance(node, ast.Call):
+                fn = node.func
+                name = fn.id if isinstance(fn, ast.Name) else fn.attr if isinstance(fn, ast.Attribute) else None
+                if name == "open":
+                    mode = node.args[1] if len(node.args) > 1 else next((k.value for k in node.keywords if k.arg == "mode"), None)
+                    if mode is not None and not (isinstance(mode, ast.Constant) and isinstance(mode.value, str) and not set(mode.value) & set("wax+")):
+                        problems.append(f"line {line}: opening files for writing is not allowed")
+        return problems
+
+    __neko_violations__ = __neko_check__(__neko_payload__["code"], __neko_policy__)
+    if __neko_violations__:
+        print("` + resultMarker + `" + json.dumps({"violations": __neko_violations__}))
+        sys.exit(0)
+`
+
+// violationsError converts violations reported by the runner to an error.
+func violationsError(violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ErrUnsafeCode{Violations: violations}
+}
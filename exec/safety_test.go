@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafetyPolicyRejectsCode(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	for _, tc := range []struct {
+		name, code, want string
+	}{
+		{"import", "import os", "import of 'os' is not allowed"},
+		{"import from", "from subprocess import run", "import from 'subprocess' is not allowed"},
+		{"relative import", "from . import x", "relative imports are not allowed"},
+		{"banned builtin", "eval('1')", "use of 'eval' is not allowed"},
+		{"dunder", "().__class__", "dunder attribute '__class__'"},
+		{"open for writing", "open('out.txt', 'w')", "opening files for writing is not allowed"},
+		{"write_text", "p.write_text('x')", "writing files is not allowed"},
+	} {
+		dir := t.TempDir()
+		e := NewPythonExecutor(WithWorkDir(dir))
+		_, err := e.Execute(context.Background(), tc.code, nil)
+		var unsafe *ErrUnsafeCode
+		if !errors.As(err, &unsafe) {
+			t.Errorf("%s: err = %v, want ErrUnsafeCode", tc.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %q, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestSafetyPolicyRejectsBeforeRunning(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	dir := t.TempDir()
+	e := NewPythonExecutor(WithWorkDir(dir))
+	code := "open('out.txt', 'w').write('x')\nimport os"
+	_, err := e.Execute(context.Background(), code, nil)
+	var unsafe *ErrUnsafeCode
+	if !errors.As(err, &unsafe) || len(unsafe.Violations) != 2 {
+		t.Fatalf("err = %v, want both violations", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.txt")); !os.IsNotExist(err) {
+		t.Errorf("rejected code ran: stat out.txt: %v", err)
+	}
+}
+
+func TestSafetyPolicyAllowsAuthorizedCode(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	e := NewPythonExecutor(WithWorkDir(t.TempDir()), WithSafetyPolicy(&SafetyPolicy{AuthorizedImports: []string{"os"}, AllowFileWrite: true}))
+	res, err := e.Execute(context.Background(), "import os.path\nopen('out.txt', 'w').write('x')\nprint(os.path.exists('out.txt'))", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Logs, "True") {
+		t.Errorf("logs = %q, want the code to run", res.Logs)
+	}
+}
@@ -0,0 +1,162 @@
+package exec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/gocnn/neko"
+)
+
+// errOutputLimit is returned when a process writes more than its allowed
+// output and is killed.
+type errOutputLimit struct{ limit int }
+
+func (e *errOutputLimit) Error() string {
+	return fmt.Sprintf("output limit exceeded (%d bytes)", e.limit)
+}
+
+func (e *errOutputLimit) Unwrap() error { return neko.ErrOutputLimit }
+
+// process is a started command with its standard streams attached.
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+}
+
+// startProcess starts cmd with pipes for all standard streams.
+func startProcess(cmd *exec.Cmd) (*process, error) {
+	p := &process{cmd: cmd}
+	var err error
+	if p.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if p.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if p.stderr, err = cmd.StderrPipe(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// runCommand runs cmd with payload on stdin and returns its stdout and
+// stderr. See process.run for onLine and maxOutput.
+func runCommand(cmd *exec.Cmd, payload []byte, onLine func(string), maxOutput int) (string, string, error) {
+	p, err := startProcess(cmd)
+	if err != nil {
+		return "", "", err
+	}
+	return p.run(payload, onLine, maxOutput)
+}
+
+// run writes payload to the process's stdin, waits for it to exit and
+// returns its stdout and stderr. If onLine is non-nil it is called with
+// each output line as it is produced, except the runner's result
+// envelope. If maxOutput is positive and the combined output, not
+// counting the envelope, exceeds it, the process is killed.
+func (p *process) run(payload []byte, onLine func(string), maxOutput int) (string, string, error) {
+	cmd := p.cmd
+	go func() {
+		p.stdin.Write(payload)
+		p.stdin.Close()
+	}()
+
+	var mu sync.Mutex
+	total, exceeded := 0, false
+	emit := func(line string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if exceeded {
+			return false
+		}
+		// The envelope carries state and figures rather than printed
+		// output, so it does not count towards the limit.
+		if strings.HasPrefix(line, resultMarker) {
+			return true
+		}
+		total += len(line) + 1
+		if maxOutput > 0 && total > maxOutput {
+			exceeded = true
+			if cmd.Cancel != nil {
+				cmd.Cancel()
+			} else {
+				cmd.Process.Kill()
+			}
+			return false
+		}
+		if onLine != nil {
+			onLine(line)
+		}
+		return true
+	}
+	var stdout, stderr strings.Builder
+	var outErr, errErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		outErr = scanLines(p.stdout, &stdout, emit)
+	}()
+	go func() {
+		defer wg.Done()
+		errErr = scanLines(p.stderr, &stderr, emit)
+	}()
+	wg.Wait()
+	err := cmd.Wait()
+	if exceeded {
+		return stdout.String(), stderr.String(), &errOutputLimit{limit: maxOutput}
+	}
+	if err == nil {
+		err = errors.Join(outErr, errErr)
+	}
+	return stdout.String(), stderr.String(), err
+}
+
+// maxLineBytes is the longest output line scanLines accepts. The result
+// envelope is one line, so this also bounds the size of returned state.
+const maxLineBytes = 64 << 20
+
+// scanLines copies lines from r into buf while emit accepts them. It
+// returns an error if a line is longer than maxLineBytes, since the line
+// and everything after it are lost.
+func scanLines(r io.Reader, buf *strings.Builder, emit func(string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !emit(line) {
+			break
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	err := scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = fmt.Errorf("output line longer than %d bytes", maxLineBytes)
+	}
+	// Drain so the process never blocks on a full pipe.
+	io.Copy(io.Discard, r)
+	return err
+}
+
+// streamExecution adapts a blocking execute function taking a line
+// callback to the StreamingCodeExecutor channel protocol.
+func streamExecution(execute func(onLine func(string)) (*neko.ExecutionResult, error)) <-chan neko.ExecutionDelta {
+	ch := make(chan neko.ExecutionDelta)
+	go func() {
+		defer close(ch)
+		res, err := execute(func(line string) { ch <- neko.ExecutionDelta{Log: line} })
+		ch <- neko.ExecutionDelta{Result: res, Done: true, Error: err}
+	}()
+	return ch
+}
@@ -0,0 +1,41 @@
+package exec
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+func TestRunCommandIgnoresEnvelopeInLimit(t *testing.T) {
+	script := `printf 'hi\n'; printf '` + resultMarker + `%s\n' "$(head -c 4096 /dev/zero | tr '\0' a)"`
+	stdout, _, err := runCommand(exec.Command("sh", "-c", script), nil, nil, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, resultMarker) {
+		t.Errorf("stdout lost the envelope: %.40q", stdout)
+	}
+}
+
+func TestRunCommandOutputLimit(t *testing.T) {
+	var lines []string
+	script := `head -c 4096 /dev/zero | tr '\0' a; echo`
+	_, _, err := runCommand(exec.Command("sh", "-c", script), nil, func(l string) { lines = append(lines, l) }, 1024)
+	if !errors.Is(err, neko.ErrOutputLimit) {
+		t.Fatalf("err = %v, want ErrOutputLimit", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("got %d lines past the limit", len(lines))
+	}
+}
+
+func TestRunCommandLineTooLong(t *testing.T) {
+	script := `head -c 70000000 /dev/zero | tr '\0' a; echo`
+	_, _, err := runCommand(exec.Command("sh", "-c", script), nil, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Fatalf("err = %v, want a line length error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package neko
+
+import "context"
+
+// CodeExecutor runs code and returns results. Implementations must stop
+// execution and release any processes when ctx is cancelled.
+type CodeExecutor interface {
+	Execute(ctx context.Context, code string, state map[string]any) (*ExecutionResult, error)
+}
+
+// ExecutionResult holds the outcome of running one code action. Output is
+// the final answer if IsFinal is set; otherwise it is the value of the
+// code's trailing expression, if the executor captures one.
+type ExecutionResult struct {
+	Output    any            `json:"output,omitempty"`
+	IsFinal   bool           `json:"is_final_answer"`
+	Logs      string         `json:"logs,omitempty"`
+	State     map[string]any `json:"state,omitempty"`
+	Artifacts []Artifact     `json:"artifacts,omitempty"`
+}
+
+type executionToolsKey struct{}
+
+// WithExecutionTools attaches tools to ctx so executors that support it
+// can expose them to executed code as callable functions. CodeAgent does
+// this for every execution with the agent's tools and managed agents.
+func WithExecutionTools(ctx context.Context, tools ...Tool) context.Context {
+	return context.WithValue(ctx, executionToolsKey{}, tools)
+}
+
+// ExecutionTools returns the tools attached to ctx by WithExecutionTools.
+func ExecutionTools(ctx context.Context) []Tool {
+	tools, _ := ctx.Value(executionToolsKey{}).([]Tool)
+	return tools
+}
+
+// LanguageExecutor is implemented by executors that run a language other
+// than Python. CodeAgent uses it to pick a matching default prompt.
+type LanguageExecutor interface {
+	Language() string
+}
+
+// SessionExecutor is implemented by executors that hold resources, such
+// as a sandbox or container, for the duration of an agent run. CodeAgent
+// calls Start before the first step and Close when the run ends.
+type SessionExecutor interface {
+	CodeExecutor
+	Start(ctx context.Context) error
+	Close() error
+}
+
+// StreamingCodeExecutor extends CodeExecutor with incremental log output.
+type StreamingCodeExecutor interface {
+	CodeExecutor
+	ExecuteStream(ctx context.Context, code string, state map[string]any) (<-chan ExecutionDelta, error)
+}
+
+// ExecutionDelta represents a streaming chunk of execution output. The
+// last delta has Done set and carries either Result or Error.
+type ExecutionDelta struct {
+	Log    string           `json:"log,omitempty"`
+	Result *ExecutionResult `json:"result,omitempty"`
+	Done   bool             `json:"done"`
+	Error  error            `json:"error,omitempty"`
+}
+
+// LegacyCodeExecutor is the original executor interface returning output
+// and logs directly.
+type LegacyCodeExecutor interface {
+	Execute(ctx context.Context, code string, state map[string]any) (output any, logs string, err error)
+}
+
+// AdaptLegacyExecutor wraps a LegacyCodeExecutor as a CodeExecutor. A
+// final answer is detected by a final_answer( call in the code, and state
+// is whatever the legacy executor left in the map it was given.
+func AdaptLegacyExecutor(e LegacyCodeExecutor) CodeExecutor {
+	return &legacyExecutor{inner: e}
+}
+
+type legacyExecutor struct {
+	inner LegacyCodeExecutor
+}
+
+func (e *legacyExecutor) Execute(ctx context.Context, code string, state map[string]any) (*ExecutionResult, error) {
+	output, logs, err := e.inner.Execute(ctx, code, state)
+	res := &ExecutionResult{Output: output, Logs: logs, State: state}
+	if err != nil {
+		return res, err
+	}
+	res.IsFinal = isFinalAnswer(code)
+	return res, nil
+}
+
+// ExecuteStream runs code on e, streaming logs if e supports it. For other
+// executors the full log is delivered as one delta when execution ends.
+func ExecuteStream(ctx context.Context, e CodeExecutor, code string, state map[string]any) (<-chan ExecutionDelta, error) {
+	if se, ok := e.(StreamingCodeExecutor); ok {
+		return se.ExecuteStream(ctx, code, state)
+	}
+
+	ch := make(chan ExecutionDelta, 2)
+	go func() {
+		defer close(ch)
+		res, err := e.Execute(ctx, code, state)
+		if res != nil && res.Logs != "" {
+			ch <- ExecutionDelta{Log: res.Logs}
+		}
+		ch <- ExecutionDelta{Result: res, Done: true, Error: err}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,146 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FinalAnswerCheck inspects a final answer before the run accepts it.
+// steps are the run's steps so far, ending with the step that gave the
+// answer. An error rejects the answer: it is recorded on the step, shown
+// to the model, and the run goes on so the model can try again.
+type FinalAnswerCheck func(ctx context.Context, answer any, steps []Step) error
+
+// WithFinalAnswerChecks adds checks every final answer must pass.
+func WithFinalAnswerChecks(checks ...FinalAnswerCheck) AgentOption {
+	return func(a *BaseAgent) { a.finalChecks = append(a.finalChecks, checks...) }
+}
+
+// checkFinalAnswer runs the final answer checks, with step as the last
+// step.
+func (a *BaseAgent) checkFinalAnswer(ctx context.Context, answer any, step *ActionStep) error {
+	if len(a.finalChecks) == 0 {
+		return nil
+	}
+	steps := append(slices.Clip(a.memory.Steps), step)
+	for _, check := range a.finalChecks {
+		if err := check(ctx, answer, steps); err != nil {
+			return fmt.Errorf("final answer rejected: %w", err)
+		}
+	}
+	return nil
+}
+
+var answerURL = regexp.MustCompile(`https?://[^\s<>"'\x60\])]+`)
+
+// RequireCitations returns a check that the final answer cites at least
+// minSources sources consulted during the run, and no URL that was not.
+// Consulted sources are the URLs fetched over HTTP, as recorded in the
+// run's audit log, and the sources tools recorded with RecordCitation. A
+// source is cited by its URL or, for documents without one, its title.
+func RequireCitations(minSources int) FinalAnswerCheck {
+	minSources = max(minSources, 1)
+	return func(ctx context.Context, answer any, steps []Step) error {
+		consulted := map[string]bool{}
+		var titles, list []string
+		var audit AuditTrail
+		if l := AuditLogFromContext(ctx); l != nil {
+			audit = l.Entries()
+		}
+		for _, e := range audit.Filter(AuditHTTP) {
+			if e.Error != "" {
+				continue
+			}
+			// Details read "GET https://... -> 200 OK".
+			if _, rest, ok := strings.Cut(e.Detail, " "); ok {
+				u, _, _ := strings.Cut(rest, " -> ")
+				if !consulted[normalizeURL(u)] {
+					consulted[normalizeURL(u)] = true
+					list = append(list, u)
+				}
+			}
+		}
+		for _, s := range steps {
+			as, ok := s.(*ActionStep)
+			if !ok {
+				continue
+			}
+			for _, c := range as.Citations {
+				switch {
+				case c.URL != "" && !consulted[normalizeURL(c.URL)]:
+					consulted[normalizeURL(c.URL)] = true
+					list = append(list, c.URL)
+				case c.URL == "" && c.Title != "":
+					titles = append(titles, c.Title)
+					list = append(list, c.Title)
+				}
+			}
+		}
+
+		text := fmt.Sprint(answer)
+		cited := map[string]bool{}
+		var unknown []string
+		for _, u := range answerURL.FindAllString(text, -1) {
+			u = strings.TrimRight(u, ".,;:!?")
+			if consulted[normalizeURL(u)] {
+				cited[normalizeURL(u)] = true
+			} else {
+				unknown = append(unknown, u)
+			}
+		}
+		lower := strings.ToLower(text)
+		for _, t := range titles {
+			if strings.Contains(lower, strings.ToLower(t)) {
+				cited[t] = true
+			}
+		}
+
+		if len(unknown) > 0 {
+			return fmt.Errorf("it cites %s, which %s not consulted in this run; cite only sources you visited: %s",
+				strings.Join(unknown, ", "), pluralVerb(len(unknown)), sourceList(list))
+		}
+		if len(cited) < minSources {
+			if len(list) == 0 {
+				return fmt.Errorf("it must cite %d source(s), but no sources have been consulted yet; find and read sources first", minSources)
+			}
+			return fmt.Errorf("it cites %d of the %d source(s) required; include the URLs or titles of sources you visited: %s",
+				len(cited), minSources, sourceList(list))
+		}
+		return nil
+	}
+}
+
+// normalizeURL makes URLs that differ only in ways that don't matter for
+// citing them compare equal.
+func normalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	u.Fragment = ""
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+func pluralVerb(n int) string {
+	if n == 1 {
+		return "was"
+	}
+	return "were"
+}
+
+// sourceList formats the consulted sources for the model, up to ten.
+func sourceList(sources []string) string {
+	if len(sources) > 10 {
+		return strings.Join(sources[:10], ", ") + fmt.Sprintf(" and %d more", len(sources)-10)
+	}
+	return strings.Join(sources, ", ")
+}
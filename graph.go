@@ -0,0 +1,279 @@
+package neko
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Graph formats for ExportGraph.
+const (
+	GraphDOT     = "dot"     // Graphviz
+	GraphMermaid = "mermaid" // Mermaid flowchart
+)
+
+// GraphOption configures ExportGraph.
+type GraphOption func(*graphOptions)
+
+type graphOptions struct {
+	run *RunResult
+}
+
+// WithGraphRun adds the step flow of a completed run of the agent: one
+// node per step, linked in order, with dashed edges to the tools and
+// managed agents each step called.
+func WithGraphRun(result *RunResult) GraphOption {
+	return func(o *graphOptions) { o.run = result }
+}
+
+// ExportGraph renders the agent hierarchy, with each agent's managed
+// agents, tools and code executor, as a Graphviz DOT or Mermaid graph.
+func ExportGraph(agent Agent, format string, opts ...GraphOption) (string, error) {
+	var o graphOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	g := &graph{}
+	root := g.addAgent(agent, map[Agent]string{})
+	if o.run != nil {
+		g.addRun(root, o.run)
+	}
+	switch format {
+	case GraphDOT:
+		return g.dot(), nil
+	case GraphMermaid:
+		return g.mermaid(), nil
+	default:
+		return "", fmt.Errorf("unknown graph format %q", format)
+	}
+}
+
+// Graph node kinds.
+const (
+	nodeAgent    = "agent"
+	nodeTool     = "tool"
+	nodeExecutor = "executor"
+	nodeStep     = "step"
+)
+
+type graphNode struct {
+	id, label, kind string
+	inRun           bool
+}
+
+type graphEdge struct {
+	from, to, label string
+	dashed          bool
+}
+
+type graph struct {
+	nodes    []graphNode
+	edges    []graphEdge
+	tools    map[string]map[string]string // agent node → tool name → node
+	runLabel string
+}
+
+func (g *graph) node(kind, label string) string {
+	id := fmt.Sprintf("n%d", len(g.nodes))
+	g.nodes = append(g.nodes, graphNode{id: id, label: label, kind: kind})
+	return id
+}
+
+func (g *graph) edge(from, to, label string) {
+	g.edges = append(g.edges, graphEdge{from: from, to: to, label: label})
+}
+
+// addAgent adds agent and everything below it, returning its node. An
+// agent managed by several others appears once.
+func (g *graph) addAgent(agent Agent, seen map[Agent]string) string {
+	comparable := reflect.TypeOf(agent).Comparable()
+	if comparable {
+		if id, ok := seen[agent]; ok {
+			return id
+		}
+	}
+
+	var base *BaseAgent
+	var executor CodeExecutor
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", agent), "*")
+	switch a := agent.(type) {
+	case *ToolCallingAgent:
+		base, kind = &a.BaseAgent, "ToolCallingAgent"
+	case *CodeAgent:
+		base, kind, executor = &a.BaseAgent, "CodeAgent", a.executor
+	}
+	label := kind
+	if name := agent.Name(); name != "" {
+		label = name + "\n" + kind
+	}
+	if base != nil && base.model != nil {
+		label += " · " + base.model.ModelID()
+	}
+	id := g.node(nodeAgent, label)
+	if comparable {
+		seen[agent] = id
+	}
+	if base == nil {
+		return id
+	}
+
+	if executor != nil {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", executor), "*")
+		g.edge(id, g.node(nodeExecutor, name+"\n"+executorLanguage(executor)), "")
+	}
+	if g.tools == nil {
+		g.tools = make(map[string]map[string]string)
+	}
+	g.tools[id] = make(map[string]string)
+	names := base.tools.Names()
+	slices.Sort(names)
+	for _, name := range names {
+		if name == "final_answer" {
+			continue
+		}
+		tool := g.node(nodeTool, name)
+		g.tools[id][name] = tool
+		g.edge(id, tool, "")
+	}
+	managed := make([]string, 0, len(base.managedAgents))
+	for name := range base.managedAgents {
+		managed = append(managed, name)
+	}
+	slices.Sort(managed)
+	for _, name := range managed {
+		child := g.addAgent(base.managedAgents[name], seen)
+		g.tools[id][name] = child
+		label := name
+		if name == base.managedAgents[name].Name() {
+			label = ""
+		}
+		g.edge(id, child, label)
+	}
+	return id
+}
+
+// addRun adds the steps of a run of the agent at node root.
+func (g *graph) addRun(root string, result *RunResult) {
+	g.runLabel = "Run: " + result.State
+	prev := root
+	link := func(kind, label string) string {
+		id := g.node(kind, label)
+		g.nodes[len(g.nodes)-1].inRun = true
+		g.edge(prev, id, "")
+		prev = id
+		return id
+	}
+	for _, step := range result.Steps {
+		switch s := step.(type) {
+		case *TaskStep:
+			link(nodeStep, "Task\n"+truncate(s.Task, 60))
+		case *PlanningStep:
+			link(nodeStep, "Plan")
+		case *ActionStep:
+			label := fmt.Sprintf("Step %d", s.StepNumber)
+			switch {
+			case s.Error != nil:
+				label += "\nerror: " + truncate(s.Error.Error(), 60)
+			case s.CodeAction != "":
+				label += "\ncode"
+			}
+			id := link(nodeStep, label)
+			for _, tc := range s.ToolCalls {
+				if target, ok := g.tools[root][tc.Name]; ok {
+					g.edges = append(g.edges, graphEdge{from: id, to: target, label: tc.Name, dashed: true})
+				}
+			}
+		case *FinalAnswerStep:
+			link(nodeStep, "Final answer\n"+truncate(fmt.Sprint(s.Output), 60))
+		}
+	}
+}
+
+func (g *graph) dot() string {
+	shapes := map[string]string{
+		nodeAgent:    `shape=box, style="filled", fillcolor="#dbe9ff"`,
+		nodeTool:     `shape=ellipse`,
+		nodeExecutor: `shape=cylinder`,
+		nodeStep:     `shape=box, style="rounded"`,
+	}
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph agent {\n\tnode [fontname=\"Helvetica\"];\n")
+	for _, n := range g.nodes {
+		if !n.inRun {
+			fmt.Fprintf(&sb, "\t%s [label=%s, %s];\n", n.id, quote(n.label), shapes[n.kind])
+		}
+	}
+	if g.runLabel != "" {
+		fmt.Fprintf(&sb, "\tsubgraph cluster_run {\n\t\tlabel=%s;\n", quote(g.runLabel))
+		for _, n := range g.nodes {
+			if n.inRun {
+				fmt.Fprintf(&sb, "\t\t%s [label=%s, %s];\n", n.id, quote(n.label), shapes[n.kind])
+			}
+		}
+		sb.WriteString("\t}\n")
+	}
+	for _, e := range g.edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+quote(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s", e.from, e.to)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (g *graph) mermaid() string {
+	shapes := map[string][2]string{
+		nodeAgent:    {"[", "]"},
+		nodeTool:     {"([", "])"},
+		nodeExecutor: {"[(", ")]"},
+		nodeStep:     {"(", ")"},
+	}
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(s) + `"`
+	}
+	node := func(n graphNode) string {
+		s := shapes[n.kind]
+		return n.id + s[0] + quote(n.label) + s[1]
+	}
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	for _, n := range g.nodes {
+		if !n.inRun {
+			fmt.Fprintf(&sb, "\t%s\n", node(n))
+		}
+	}
+	if g.runLabel != "" {
+		fmt.Fprintf(&sb, "\tsubgraph run[%s]\n", quote(g.runLabel))
+		for _, n := range g.nodes {
+			if n.inRun {
+				fmt.Fprintf(&sb, "\t\t%s\n", node(n))
+			}
+		}
+		sb.WriteString("\tend\n")
+	}
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			fmt.Fprintf(&sb, "\t%s %s|%s| %s\n", e.from, arrow, quote(e.label), e.to)
+		} else {
+			fmt.Fprintf(&sb, "\t%s %s %s\n", e.from, arrow, e.to)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,171 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/respjson"
+)
+
+// DefaultGrokBaseURL is xAI's OpenAI-compatible API.
+const DefaultGrokBaseURL = "https://api.x.ai/v1"
+
+// Live search modes for LiveSearch.
+const (
+	LiveSearchAuto = "auto" // the model decides whether to search
+	LiveSearchOn   = "on"   // the model always searches
+	LiveSearchOff  = "off"  // the model never searches
+)
+
+// Live search source types for LiveSearchSource.
+const (
+	SearchSourceWeb  = "web"
+	SearchSourceX    = "x"
+	SearchSourceNews = "news"
+	SearchSourceRSS  = "rss"
+)
+
+// LiveSearch configures Grok's live search, in which the model searches
+// the web, X, news and RSS feeds while it answers. The sources it cites
+// are recorded on the calling step, as RecordCitation records them.
+type LiveSearch struct {
+	Mode string // LiveSearchAuto by default
+	// Sources are searched instead of xAI's default of the web, X and
+	// news.
+	Sources []LiveSearchSource
+	// From and To restrict the search to data from that date range; zero
+	// leaves it open.
+	From, To time.Time
+	// MaxResults caps the number of sources searched, and billed; zero
+	// leaves xAI's default.
+	MaxResults int
+}
+
+// LiveSearchSource is a source for live search. Fields that do not apply
+// to its type are ignored by xAI.
+type LiveSearchSource struct {
+	Type    string `json:"type"`              // SearchSourceWeb, SearchSourceX, SearchSourceNews or SearchSourceRSS
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, for web and news
+	// AllowedWebsites restricts web search to these sites, and
+	// ExcludedWebsites leaves these sites out of web and news search.
+	AllowedWebsites  []string `json:"allowed_websites,omitempty"`
+	ExcludedWebsites []string `json:"excluded_websites,omitempty"`
+	SafeSearch       *bool    `json:"safe_search,omitempty"` // on by default, for web and news
+	// IncludedXHandles restricts X search to posts by these handles, and
+	// ExcludedXHandles leaves posts by these handles out.
+	IncludedXHandles []string `json:"included_x_handles,omitempty"`
+	ExcludedXHandles []string `json:"excluded_x_handles,omitempty"`
+	Links            []string `json:"links,omitempty"` // feed URLs, for RSS
+}
+
+// WithLiveSearch has a GrokModel search live sources while generating,
+// as s says, for one call. Other models ignore it.
+func WithLiveSearch(s LiveSearch) GenerateOption {
+	return func(o *GenerateOptions) { o.LiveSearch = &s }
+}
+
+// GrokModel is a model served by xAI, e.g. "grok-4". With live search,
+// set for every call with WithGrokLiveSearch or for one with
+// WithLiveSearch, Grok searches for current information while it answers
+// and the sources it cites are recorded as the step's citations.
+type GrokModel struct {
+	chat    *OpenAIModel
+	baseURL string
+	openAI  []OpenAIOption
+	search  *LiveSearch
+}
+
+// GrokOption configures a GrokModel.
+type GrokOption func(*GrokModel)
+
+// WithGrokOpenAIOptions configures the underlying OpenAI-compatible
+// client, e.g. with WithOpenAITemperature or WithOpenAIHTTPClient.
+func WithGrokOpenAIOptions(opts ...OpenAIOption) GrokOption {
+	return func(m *GrokModel) { m.openAI = append(m.openAI, opts...) }
+}
+
+// WithGrokBaseURL sends requests to url instead of DefaultGrokBaseURL.
+func WithGrokBaseURL(url string) GrokOption {
+	return func(m *GrokModel) { m.baseURL = url }
+}
+
+// WithGrokLiveSearch enables live search for every call, unless a call
+// sets its own with WithLiveSearch.
+func WithGrokLiveSearch(s LiveSearch) GrokOption {
+	return func(m *GrokModel) { m.search = &s }
+}
+
+// NewGrokModel creates an xAI Grok model.
+func NewGrokModel(modelID, apiKey string, opts ...GrokOption) *GrokModel {
+	m := &GrokModel{baseURL: DefaultGrokBaseURL}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.chat = newOpenAIModel(modelID, m.openAI, option.WithAPIKey(apiKey), option.WithBaseURL(m.baseURL))
+	m.chat.callOpts = m.searchParameters
+	m.chat.extra = recordSearchCitations
+	return m
+}
+
+func (m *GrokModel) ModelID() string { return m.chat.ModelID() }
+
+func (m *GrokModel) Provider() string { return "xai" }
+
+// SupportsToolCalling reports whether xAI has not rejected tools for the
+// model.
+func (m *GrokModel) SupportsToolCalling() bool { return m.chat.SupportsToolCalling() }
+
+// Generate sends messages to Grok and returns its response.
+func (m *GrokModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
+	return m.chat.Generate(ctx, messages, opts...)
+}
+
+// GenerateStream streams Grok's response.
+func (m *GrokModel) GenerateStream(ctx context.Context, messages []Message, opts ...GenerateOption) (<-chan StreamDelta, error) {
+	return m.chat.GenerateStream(ctx, messages, opts...)
+}
+
+// searchParameters sets the request's search_parameters from the call's
+// live search, or the model's.
+func (m *GrokModel) searchParameters(o *GenerateOptions) []option.RequestOption {
+	s := o.LiveSearch
+	if s == nil {
+		s = m.search
+	}
+	if s == nil {
+		return nil
+	}
+	params := map[string]any{"mode": LiveSearchAuto, "return_citations": true}
+	if s.Mode != "" {
+		params["mode"] = s.Mode
+	}
+	if len(s.Sources) > 0 {
+		params["sources"] = s.Sources
+	}
+	if !s.From.IsZero() {
+		params["from_date"] = s.From.Format(time.DateOnly)
+	}
+	if !s.To.IsZero() {
+		params["to_date"] = s.To.Format(time.DateOnly)
+	}
+	if s.MaxResults > 0 {
+		params["max_search_results"] = s.MaxResults
+	}
+	return []option.RequestOption{option.WithJSONSet("search_parameters", params)}
+}
+
+// recordSearchCitations records the sources a response cites as
+// citations of the step ctx belongs to.
+func recordSearchCitations(ctx context.Context, fields map[string]respjson.Field) {
+	f, ok := fields["citations"]
+	if !ok {
+		return
+	}
+	var urls []string
+	json.Unmarshal([]byte(f.Raw()), &urls)
+	for _, u := range urls {
+		RecordCitation(ctx, Citation{URL: u, Source: "live_search"})
+	}
+}
@@ -0,0 +1,243 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// DefaultGroqBaseURL is Groq's OpenAI-compatible API.
+const DefaultGroqBaseURL = "https://api.groq.com/openai/v1"
+
+// GroqModel is a model served by Groq. It is tuned for fast agent loops:
+// responses are capped at 2048 tokens and requests time out after 30
+// seconds. Requests are paced by the rate limits Groq reports, rate-limited
+// and overloaded requests are retried when the wait is short, and tool
+// calls Groq fails to parse from the model's output (tool_use_failed) are
+// regenerated. Waits longer than the maximum wait fail at once with
+// ErrRateLimited, so a latency-sensitive caller can fall back instead.
+type GroqModel struct {
+	chat       *OpenAIModel
+	baseURL    string
+	openAI     []OpenAIOption
+	maxRetries int
+	maxWait    time.Duration
+
+	mu     sync.Mutex
+	limits GroqRateLimits
+}
+
+// GroqRateLimits is the quota Groq reported in the headers of its last
+// response. Limits are zero until a response reports them.
+type GroqRateLimits struct {
+	LimitRequests     int       `json:"limit_requests"` // per day
+	RemainingRequests int       `json:"remaining_requests"`
+	ResetRequests     time.Time `json:"reset_requests"`
+	LimitTokens       int       `json:"limit_tokens"` // per minute
+	RemainingTokens   int       `json:"remaining_tokens"`
+	ResetTokens       time.Time `json:"reset_tokens"`
+}
+
+// GroqOption configures a GroqModel.
+type GroqOption func(*GroqModel)
+
+// WithGroqOpenAIOptions configures the underlying OpenAI-compatible
+// client, e.g. with WithOpenAITemperature, WithOpenAIMaxTokens or
+// WithOpenAIHTTPClient.
+func WithGroqOpenAIOptions(opts ...OpenAIOption) GroqOption {
+	return func(m *GroqModel) { m.openAI = append(m.openAI, opts...) }
+}
+
+// WithGroqBaseURL sends requests to url instead of DefaultGroqBaseURL,
+// e.g. through a gateway.
+func WithGroqBaseURL(url string) GroqOption {
+	return func(m *GroqModel) { m.baseURL = url }
+}
+
+// WithGroqRetries sets how many times a failed request is retried, 2 by
+// default, and the longest wait for a retry or for the rate limit to
+// reset, 5 seconds by default.
+func WithGroqRetries(n int, maxWait time.Duration) GroqOption {
+	return func(m *GroqModel) { m.maxRetries, m.maxWait = n, maxWait }
+}
+
+// NewGroqModel creates a Groq model, e.g. "llama-3.3-70b-versatile".
+func NewGroqModel(modelID, apiKey string, opts ...GroqOption) *GroqModel {
+	m := &GroqModel{baseURL: DefaultGroqBaseURL, maxRetries: 2, maxWait: 5 * time.Second}
+	for _, opt := range opts {
+		opt(m)
+	}
+	chatOpts := append([]OpenAIOption{WithOpenAIMaxTokens(2048)}, m.openAI...)
+	m.chat = newOpenAIModel(modelID, chatOpts,
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(m.baseURL),
+		option.WithRequestTimeout(30*time.Second),
+		option.WithMaxRetries(0), // retried by Generate, which knows Groq's limits
+		option.WithMiddleware(m.observe),
+	)
+	return m
+}
+
+func (m *GroqModel) ModelID() string { return m.chat.ModelID() }
+
+func (m *GroqModel) Provider() string { return "groq" }
+
+// SupportsToolCalling reports whether Groq has not rejected tools for the
+// model.
+func (m *GroqModel) SupportsToolCalling() bool { return m.chat.SupportsToolCalling() }
+
+// RateLimits returns the quota Groq last reported.
+func (m *GroqModel) RateLimits() GroqRateLimits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limits
+}
+
+// Generate sends messages to Groq and returns its response.
+func (m *GroqModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
+	for attempt := 0; ; attempt++ {
+		if err := m.pace(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := m.chat.Generate(ctx, messages, opts...)
+		if err == nil {
+			return resp, nil
+		}
+		if err := m.backoff(ctx, err, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GenerateStream streams Groq's response. Requests that fail before the
+// first chunk are retried as Generate retries them.
+func (m *GroqModel) GenerateStream(ctx context.Context, messages []Message, opts ...GenerateOption) (<-chan StreamDelta, error) {
+	for attempt := 0; ; attempt++ {
+		if err := m.pace(ctx); err != nil {
+			return nil, err
+		}
+		ch, err := m.chat.GenerateStream(ctx, messages, opts...)
+		if err != nil {
+			return nil, err
+		}
+		first, ok := <-ch
+		if ok && first.Error != nil {
+			if err := m.backoff(ctx, first.Error, attempt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		out := make(chan StreamDelta)
+		go func() {
+			defer close(out)
+			if !ok {
+				return
+			}
+			out <- first
+			for d := range ch {
+				out <- d
+			}
+		}()
+		return out, nil
+	}
+}
+
+// backoff waits before retrying a request that failed with err, or
+// returns the error to fail with.
+func (m *GroqModel) backoff(ctx context.Context, err error, attempt int) error {
+	var apiErr *openai.Error
+	if attempt >= m.maxRetries || !errors.As(err, &apiErr) {
+		return err
+	}
+	var wait time.Duration
+	switch {
+	case apiErr.StatusCode == http.StatusBadRequest && apiErr.Code == "tool_use_failed":
+		// The model wrote a malformed tool call; sample it again.
+	case apiErr.StatusCode == http.StatusTooManyRequests,
+		apiErr.StatusCode == 498, // flex tier over capacity
+		apiErr.StatusCode >= http.StatusInternalServerError:
+		wait = time.Duration(250<<attempt) * time.Millisecond
+		if apiErr.Response != nil {
+			if s, err := strconv.ParseFloat(apiErr.Response.Header.Get("Retry-After"), 64); err == nil {
+				wait = time.Duration(s * float64(time.Second))
+			}
+		}
+		if wait > m.maxWait {
+			return err
+		}
+	default:
+		return err
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pace waits for an exhausted quota to reset, or fails with
+// ErrRateLimited if that takes longer than the maximum wait.
+func (m *GroqModel) pace(ctx context.Context) error {
+	l := m.RateLimits()
+	var until time.Time
+	if l.LimitRequests > 0 && l.RemainingRequests == 0 {
+		until = l.ResetRequests
+	}
+	if l.LimitTokens > 0 && l.RemainingTokens == 0 && l.ResetTokens.After(until) {
+		until = l.ResetTokens
+	}
+	wait := time.Until(until)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > m.maxWait {
+		return fmt.Errorf("groq: %w: quota resets in %s", ErrRateLimited, wait.Round(time.Second))
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe records the rate limits reported with each response.
+func (m *GroqModel) observe(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	resp, err := next(req)
+	if resp == nil || resp.Header.Get("X-Ratelimit-Limit-Requests") == "" {
+		return resp, err
+	}
+	now := time.Now()
+	h := resp.Header
+	num := func(name string) int {
+		n, _ := strconv.Atoi(h.Get(name))
+		return n
+	}
+	reset := func(name string) time.Time {
+		d, _ := time.ParseDuration(h.Get(name))
+		return now.Add(d)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = GroqRateLimits{
+		LimitRequests:     num("X-Ratelimit-Limit-Requests"),
+		RemainingRequests: num("X-Ratelimit-Remaining-Requests"),
+		ResetRequests:     reset("X-Ratelimit-Reset-Requests"),
+		LimitTokens:       num("X-Ratelimit-Limit-Tokens"),
+		RemainingTokens:   num("X-Ratelimit-Remaining-Tokens"),
+		ResetTokens:       reset("X-Ratelimit-Reset-Tokens"),
+	}
+	return resp, err
+}
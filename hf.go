@@ -0,0 +1,255 @@
+package neko
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/v3/option"
+)
+
+// DefaultHFBaseURL is the OpenAI-compatible router of Hugging Face's
+// serverless Inference Providers.
+const DefaultHFBaseURL = "https://router.huggingface.co/v1"
+
+// hfMaxStopSequences is the number of stop sequences Text Generation
+// Inference (TGI) accepts by default.
+const hfMaxStopSequences = 4
+
+// HFInferenceModel is an open-weight model served by Hugging Face: either
+// serverless, through Inference Providers, or by a dedicated Inference
+// Endpoint or other Text Generation Inference (TGI) server.
+//
+// By default it uses the chat completions (Messages) API, for which the
+// server renders messages with the model's own chat template. Since most
+// chat templates reject consecutive messages with the same role, messages
+// are sent in strict alternation. For models served without a chat
+// template, such as base models, WithHFPromptTemplate renders the prompt
+// locally and sends it to TGI's text generation API instead; the model
+// then has no function calling, so agents prompt for tool calls.
+type HFInferenceModel struct {
+	chat     *OpenAIModel
+	modelID  string
+	endpoint string
+	token    string
+	template PromptTemplate
+	client   *http.Client
+	openAI   []OpenAIOption
+}
+
+// HFInferenceOption configures an HFInferenceModel.
+type HFInferenceOption func(*HFInferenceModel)
+
+// WithHFEndpoint sends requests to a dedicated Inference Endpoint or TGI
+// server at url, e.g. "https://xyz.us-east-1.aws.endpoints.huggingface.cloud",
+// instead of the serverless API.
+func WithHFEndpoint(url string) HFInferenceOption {
+	return func(m *HFInferenceModel) { m.endpoint = strings.TrimRight(url, "/") }
+}
+
+// WithHFPromptTemplate renders messages into a prompt with t and sends it
+// to the text generation API, for models served without a chat template.
+func WithHFPromptTemplate(t PromptTemplate) HFInferenceOption {
+	return func(m *HFInferenceModel) { m.template = t }
+}
+
+// WithHFOpenAIOptions configures the chat completions client, e.g. with
+// WithOpenAITemperature or WithOpenAIMaxTokens, which also apply with a
+// prompt template.
+func WithHFOpenAIOptions(opts ...OpenAIOption) HFInferenceOption {
+	return func(m *HFInferenceModel) { m.openAI = append(m.openAI, opts...) }
+}
+
+// WithHFHTTPClient sends requests with c, e.g. to go through a proxy.
+func WithHFHTTPClient(c *http.Client) HFInferenceOption {
+	return func(m *HFInferenceModel) {
+		m.client = c
+		m.openAI = append(m.openAI, WithOpenAIHTTPClient(c))
+	}
+}
+
+// NewHFInferenceModel creates a model for the Hub model modelID, e.g.
+// "Qwen/Qwen2.5-Coder-32B-Instruct", authenticating with the Hugging Face
+// access token token. Serverless model IDs may name the provider to route
+// to, as in "meta-llama/Llama-3.3-70B-Instruct:together".
+func NewHFInferenceModel(modelID, token string, opts ...HFInferenceOption) *HFInferenceModel {
+	m := &HFInferenceModel{modelID: modelID, token: token, client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(m)
+	}
+	base := DefaultHFBaseURL
+	if m.endpoint != "" {
+		base = m.endpoint + "/v1"
+	}
+	chatOpts := append([]OpenAIOption{WithOpenAIMessageNormalizer(StrictAlternation)}, m.openAI...)
+	m.chat = newOpenAIModel(modelID, chatOpts, option.WithAPIKey(token), option.WithBaseURL(base))
+	return m
+}
+
+func (m *HFInferenceModel) ModelID() string { return m.modelID }
+
+func (m *HFInferenceModel) Provider() string { return "huggingface" }
+
+// SupportsToolCalling reports whether the model is called through the
+// chat completions API and its server has not rejected tools.
+func (m *HFInferenceModel) SupportsToolCalling() bool {
+	return m.template == nil && m.chat.SupportsToolCalling()
+}
+
+// Generate sends messages to the model and returns its response.
+func (m *HFInferenceModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
+	opts = hfLimitStops(opts)
+	if m.template == nil {
+		return m.chat.Generate(ctx, messages, opts...)
+	}
+	return m.generateText(ctx, messages, opts)
+}
+
+// GenerateStream streams the model's response. With a prompt template
+// the response arrives as one chunk.
+func (m *HFInferenceModel) GenerateStream(ctx context.Context, messages []Message, opts ...GenerateOption) (<-chan StreamDelta, error) {
+	opts = hfLimitStops(opts)
+	if m.template == nil {
+		return m.chat.GenerateStream(ctx, messages, opts...)
+	}
+	resp, err := m.generateText(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamDelta, 1)
+	ch <- StreamDelta{Content: resp.Content, TokenUsage: resp.TokenUsage}
+	close(ch)
+	return ch, nil
+}
+
+// hfLimitStops keeps the first stop sequences TGI accepts.
+func hfLimitStops(opts []GenerateOption) []GenerateOption {
+	var o GenerateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.StopSequences) <= hfMaxStopSequences {
+		return opts
+	}
+	return append(opts, WithStopSequences(o.StopSequences[:hfMaxStopSequences]...))
+}
+
+// generateText calls TGI's text generation API with the prompt rendered
+// by the model's template.
+func (m *HFInferenceModel) generateText(ctx context.Context, messages []Message, opts []GenerateOption) (*Message, error) {
+	options := &GenerateOptions{Temperature: m.chat.temperature, MaxTokens: m.chat.maxTokens}
+	for _, opt := range opts {
+		opt(options)
+	}
+	params := map[string]any{
+		"max_new_tokens":   options.MaxTokens,
+		"return_full_text": false,
+		"details":          true,
+	}
+	if options.Temperature > 0 {
+		params["temperature"] = options.Temperature
+		params["do_sample"] = true
+	}
+	if len(options.StopSequences) > 0 {
+		params["stop"] = options.StopSequences
+	}
+	body, err := json.Marshal(map[string]any{"inputs": m.template(messages), "parameters": params})
+	if err != nil {
+		return nil, err
+	}
+	url := "https://router.huggingface.co/hf-inference/models/" + m.modelID
+	if m.endpoint != "" {
+		url = m.endpoint + "/generate"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.token)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("hf generation failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("hf generation failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+		return nil, fmt.Errorf("hf generation failed: %w", NormalizeProviderError(resp.StatusCode, err))
+	}
+	type generation struct {
+		GeneratedText string `json:"generated_text"`
+		Details       struct {
+			GeneratedTokens int `json:"generated_tokens"`
+		} `json:"details"`
+	}
+	// TGI returns an object, the serverless API a one-element array.
+	var gen generation
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var gens []generation
+		if err := json.Unmarshal(data, &gens); err != nil || len(gens) == 0 {
+			return nil, fmt.Errorf("hf generation failed: unexpected response: %s", data)
+		}
+		gen = gens[0]
+	} else if err := json.Unmarshal(data, &gen); err != nil {
+		return nil, fmt.Errorf("hf generation failed: unexpected response: %s", data)
+	}
+	// Unlike chat APIs, TGI keeps the stop sequence that ended the text.
+	text := gen.GeneratedText
+	for _, stop := range options.StopSequences {
+		if t, ok := strings.CutSuffix(text, stop); ok {
+			text = t
+			break
+		}
+	}
+	return &Message{
+		Role:       RoleAssistant,
+		Content:    text,
+		TokenUsage: &TokenUsage{OutputTokens: gen.Details.GeneratedTokens},
+	}, nil
+}
+
+// PromptTemplate renders chat messages into a prompt for a text
+// generation model, ending where the assistant's reply begins.
+type PromptTemplate func(messages []Message) string
+
+// ChatMLTemplate renders messages in the ChatML format used by Qwen and
+// many other open-weight models.
+func ChatMLTemplate(messages []Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&sb, "<|im_start|>%s\n%s<|im_end|>\n", templateRole(msg.Role), msg.Content)
+	}
+	sb.WriteString("<|im_start|>assistant\n")
+	return sb.String()
+}
+
+// Llama3Template renders messages in the format of Llama 3 instruct
+// models.
+func Llama3Template(messages []Message) string {
+	var sb strings.Builder
+	sb.WriteString("<|begin_of_text|>")
+	for _, msg := range messages {
+		fmt.Fprintf(&sb, "<|start_header_id|>%s<|end_header_id|>\n\n%s<|eot_id|>", templateRole(msg.Role), msg.Content)
+	}
+	sb.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+	return sb.String()
+}
+
+// templateRole returns the template role of a message; tool messages are
+// sent as user messages, as they are to chat APIs.
+func templateRole(role MessageRole) MessageRole {
+	if role == RoleTool {
+		return RoleUser
+	}
+	return role
+}
@@ -0,0 +1,148 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// HFStore keeps files in a Hugging Face Hub repository.
+type HFStore struct {
+	repo     string
+	token    string
+	repoType string
+	revision string
+	endpoint string
+	client   *http.Client
+}
+
+// HFOption configures an HFStore.
+type HFOption func(*HFStore)
+
+// WithHFRepoType sets the repository type: "model" (the default),
+// "dataset" or "space".
+func WithHFRepoType(t string) HFOption {
+	return func(s *HFStore) { s.repoType = t }
+}
+
+// WithHFRevision sets the branch files are read from and committed to.
+// Defaults to "main".
+func WithHFRevision(rev string) HFOption {
+	return func(s *HFStore) { s.revision = rev }
+}
+
+// WithHFEndpoint sets the Hub URL, for self-hosted or mirrored Hubs.
+func WithHFEndpoint(endpoint string) HFOption {
+	return func(s *HFStore) { s.endpoint = strings.TrimRight(endpoint, "/") }
+}
+
+// WithHFHTTPClient sets the HTTP client used for requests.
+func WithHFHTTPClient(c *http.Client) HFOption {
+	return func(s *HFStore) { s.client = c }
+}
+
+// NewHFStore creates a store for the existing Hub repository repo, e.g.
+// "acme/agent-tools". token is a Hub access token; it may be empty for
+// reading public repositories.
+func NewHFStore(repo, token string, opts ...HFOption) *HFStore {
+	s := &HFStore{
+		repo:     repo,
+		token:    token,
+		repoType: "model",
+		revision: "main",
+		endpoint: "https://huggingface.co",
+		client:   &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *HFStore) Read(ctx context.Context, path string) ([]byte, error) {
+	prefix := ""
+	if s.repoType != "model" {
+		prefix = s.repoType + "s/"
+	}
+	u := fmt.Sprintf("%s/%s%s/resolve/%s/%s", s.endpoint, prefix, s.repo, url.PathEscape(s.revision), path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, hfError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Write commits files with the Hub's commit API.
+func (s *HFStore) Write(ctx context.Context, files map[string][]byte, message string) error {
+	type line struct {
+		Key   string `json:"key"`
+		Value any    `json:"value"`
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.Encode(line{Key: "header", Value: map[string]string{"summary": message}})
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		enc.Encode(line{Key: "file", Value: map[string]string{
+			"path":     path,
+			"content":  base64.StdEncoding.EncodeToString(files[path]),
+			"encoding": "base64",
+		}})
+	}
+
+	u := fmt.Sprintf("%s/api/%ss/%s/commit/%s", s.endpoint, s.repoType, s.repo, url.PathEscape(s.revision))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return hfError(resp)
+	}
+	return nil
+}
+
+func (s *HFStore) do(req *http.Request) (*http.Response, error) {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return s.client.Do(req)
+}
+
+func hfError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &e) == nil && e.Error != "" {
+		return fmt.Errorf("hub: %s: %s", resp.Status, e.Error)
+	}
+	return fmt.Errorf("hub: %s", resp.Status)
+}
@@ -0,0 +1,189 @@
+// Package hub shares tool definitions and prompt templates through a
+// registry, so agents can load them by name and version at runtime:
+//
+//	h := hub.New(hub.NewHFStore("acme/agent-tools", os.Getenv("HF_TOKEN")))
+//	err := h.PushTool(ctx, spec, "1.2.0")
+//	...
+//	t, err := h.LoadTool(ctx, "word_count@1.2.0", exec.NewPythonExecutor())
+//
+// Every push also updates the "latest" version, which is loaded when a
+// reference has no version. Definitions are stored as JSON files at
+// tools/<name>/<version>.json and prompts/<name>/<version>.json, in a
+// Hugging Face Hub repository, a Git repository or a local directory.
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/gocnn/neko"
+)
+
+// Latest is the version every push also writes, and the one loaded when
+// a reference has no version.
+const Latest = "latest"
+
+// ErrNotFound is returned when a name or version is not in the registry.
+var ErrNotFound = errors.New("not found in hub")
+
+// Hub publishes and loads definitions in a Store.
+type Hub struct {
+	store Store
+}
+
+// New creates a hub backed by store.
+func New(store Store) *Hub {
+	return &Hub{store: store}
+}
+
+// ToolSpec is a shareable tool: its schema plus source code defining a
+// function with the tool's name, which is called with the tool's
+// arguments as keyword arguments (Python) or a single object
+// (JavaScript) and whose return value is the tool's output.
+type ToolSpec struct {
+	neko.ToolSchema
+	Language string `json:"language"` // "python" (default) or "javascript"
+	Code     string `json:"code"`
+}
+
+// Prompt is a shareable prompt template in text/template syntax.
+type Prompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Template    string `json:"template"`
+}
+
+// Render executes the template with data. Templates can wrap untrusted
+// values, such as the task or tool output, with the untrusted function,
+// e.g. {{untrusted "task" .Task}}; see neko.WrapUntrusted. Data values are
+// never parsed as template syntax, so they need no escaping.
+func (p *Prompt) Render(data any) (string, error) {
+	tmpl, err := p.parse()
+	if err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return sb.String(), nil
+}
+
+var promptFuncs = template.FuncMap{"untrusted": neko.WrapUntrusted}
+
+func (p *Prompt) parse() (*template.Template, error) {
+	return template.New(p.Name).Option("missingkey=error").Funcs(promptFuncs).Parse(p.Template)
+}
+
+// PushTool publishes spec as version of the tool.
+func (h *Hub) PushTool(ctx context.Context, spec *ToolSpec, version string) error {
+	if spec.Code == "" {
+		return fmt.Errorf("tool %s has no code", spec.Name)
+	}
+	switch spec.Language {
+	case "", "python", "javascript":
+	default:
+		return fmt.Errorf("tool %s: unsupported language %q", spec.Name, spec.Language)
+	}
+	return h.push(ctx, "tools", spec.Name, version, spec)
+}
+
+// PullTool fetches a tool definition by "name@version" or "name" for the
+// latest version.
+func (h *Hub) PullTool(ctx context.Context, ref string) (*ToolSpec, error) {
+	var spec ToolSpec
+	if err := h.pull(ctx, "tools", ref, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// LoadTool fetches a tool definition and returns a tool that runs its
+// code with executor, which must run the tool's language.
+func (h *Hub) LoadTool(ctx context.Context, ref string, executor neko.CodeExecutor) (neko.Tool, error) {
+	spec, err := h.PullTool(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return NewCodeTool(spec, executor), nil
+}
+
+// PushPrompt publishes p as version of the prompt.
+func (h *Hub) PushPrompt(ctx context.Context, p *Prompt, version string) error {
+	if _, err := p.parse(); err != nil {
+		return fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return h.push(ctx, "prompts", p.Name, version, p)
+}
+
+// PullPrompt fetches a prompt template by "name@version" or "name" for
+// the latest version.
+func (h *Hub) PullPrompt(ctx context.Context, ref string) (*Prompt, error) {
+	var p Prompt
+	if err := h.pull(ctx, "prompts", ref, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (h *Hub) push(ctx context.Context, kind, name, version string, v any) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkName(version); err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+	if version == Latest {
+		return fmt.Errorf("version %q is reserved", Latest)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		path.Join(kind, name, version+".json"): data,
+		path.Join(kind, name, Latest+".json"):  data,
+	}
+	return h.store.Write(ctx, files, fmt.Sprintf("Publish %s %s@%s", strings.TrimSuffix(kind, "s"), name, version))
+}
+
+func (h *Hub) pull(ctx context.Context, kind, ref string, v any) error {
+	name, version := ParseRef(ref)
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkName(version); err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+	data, err := h.store.Read(ctx, path.Join(kind, name, version+".json"))
+	if err != nil {
+		return fmt.Errorf("%s %s@%s: %w", strings.TrimSuffix(kind, "s"), name, version, err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ParseRef splits "name@version" into its parts. The version is Latest
+// when ref has none.
+func ParseRef(ref string) (name, version string) {
+	name, version, ok := strings.Cut(ref, "@")
+	if !ok || version == "" {
+		version = Latest
+	}
+	return name, version
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// checkName rejects names that are empty or would escape their directory.
+func checkName(s string) error {
+	if !validName.MatchString(s) || strings.Contains(s, "..") {
+		return fmt.Errorf("invalid name %q", s)
+	}
+	return nil
+}
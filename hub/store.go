@@ -0,0 +1,206 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is where a Hub keeps its files. Paths are slash-separated and
+// relative to the store's root.
+type Store interface {
+	// Read returns a file's contents, or an error wrapping ErrNotFound.
+	Read(ctx context.Context, path string) ([]byte, error)
+	// Write adds or replaces files in one change described by message.
+	Write(ctx context.Context, files map[string][]byte, message string) error
+}
+
+// DirStore keeps files in a local directory, such as a shared mount.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a store rooted at dir.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (s *DirStore) Read(ctx context.Context, path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *DirStore) Write(ctx context.Context, files map[string][]byte, message string) error {
+	for path, data := range files {
+		full := filepath.Join(s.dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GitStore keeps files in a branch of a Git repository, using the git
+// command and a local clone. Each Write is one commit pushed to the
+// remote, so the repository's history records every publication.
+type GitStore struct {
+	remote string
+	branch string
+	dir    string
+	name   string
+	email  string
+
+	mu sync.Mutex
+}
+
+// GitOption configures a GitStore.
+type GitOption func(*GitStore)
+
+// WithGitBranch sets the branch files are kept in. Defaults to "main".
+func WithGitBranch(branch string) GitOption {
+	return func(s *GitStore) { s.branch = branch }
+}
+
+// WithGitCacheDir sets where the local clone is kept. Defaults to a
+// directory under the user cache directory.
+func WithGitCacheDir(dir string) GitOption {
+	return func(s *GitStore) { s.dir = dir }
+}
+
+// WithGitAuthor sets the author and committer of commits. Defaults to
+// the git configuration.
+func WithGitAuthor(name, email string) GitOption {
+	return func(s *GitStore) { s.name, s.email = name, email }
+}
+
+// NewGitStore creates a store for the repository at remote, which can be
+// any URL or path git accepts. Credentials come from git's configuration.
+func NewGitStore(remote string, opts ...GitOption) *GitStore {
+	s := &GitStore{remote: remote, branch: "main"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			cache = os.TempDir()
+		}
+		sum := sha256.Sum256([]byte(remote))
+		s.dir = filepath.Join(cache, "neko", "hub", hex.EncodeToString(sum[:8]))
+	}
+	return s
+}
+
+func (s *GitStore) Read(ctx context.Context, path string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+	data, err := s.git(ctx, nil, "show", "FETCH_HEAD:"+path)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "exists on disk") {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *GitStore) Write(ctx context.Context, files map[string][]byte, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Build the commit in the index with plumbing commands, leaving the
+	// clone's working tree alone.
+	var parent []string
+	err := s.fetch(ctx)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// A new branch, or an empty repository.
+		_, err = s.git(ctx, nil, "read-tree", "--empty")
+	case err == nil:
+		parent = []string{"-p", "FETCH_HEAD"}
+		_, err = s.git(ctx, nil, "read-tree", "FETCH_HEAD")
+	}
+	if err != nil {
+		return err
+	}
+
+	for path, data := range files {
+		blob, err := s.git(ctx, data, "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		info := "100644," + strings.TrimSpace(string(blob)) + "," + path
+		if _, err := s.git(ctx, nil, "update-index", "--add", "--cacheinfo", info); err != nil {
+			return err
+		}
+	}
+	tree, err := s.git(ctx, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+	args := append([]string{"commit-tree", strings.TrimSpace(string(tree)), "-m", message}, parent...)
+	commit, err := s.git(ctx, nil, args...)
+	if err != nil {
+		return err
+	}
+	_, err = s.git(ctx, nil, "push", "-q", "origin", strings.TrimSpace(string(commit))+":refs/heads/"+s.branch)
+	return err
+}
+
+// fetch updates FETCH_HEAD to the remote branch, creating the local
+// repository first if needed. It returns ErrNotFound if the branch does
+// not exist yet.
+func (s *GitStore) fetch(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return err
+		}
+		if _, err := s.git(ctx, nil, "init", "-q"); err != nil {
+			return err
+		}
+		if _, err := s.git(ctx, nil, "remote", "add", "origin", s.remote); err != nil {
+			return err
+		}
+	}
+	_, err := s.git(ctx, nil, "fetch", "-q", "origin", s.branch)
+	if err != nil && strings.Contains(err.Error(), "couldn't find remote ref") {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *GitStore) git(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = s.dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if s.name != "" {
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME="+s.name, "GIT_AUTHOR_EMAIL="+s.email,
+			"GIT_COMMITTER_NAME="+s.name, "GIT_COMMITTER_EMAIL="+s.email)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gocnn/neko"
+)
+
+// codeTool runs a ToolSpec's code with a code executor.
+type codeTool struct {
+	spec     *ToolSpec
+	executor neko.CodeExecutor
+}
+
+// NewCodeTool returns a tool that runs spec's code with executor on each
+// call, passing the arguments in the tool_args variable.
+func NewCodeTool(spec *ToolSpec, executor neko.CodeExecutor) neko.Tool {
+	return &codeTool{spec: spec, executor: executor}
+}
+
+func (t *codeTool) Name() string                      { return t.spec.Name }
+func (t *codeTool) Description() string               { return t.spec.Description }
+func (t *codeTool) Inputs() map[string]neko.ToolInput { return t.spec.Inputs }
+func (t *codeTool) OutputType() string                { return t.spec.OutputType }
+
+func (t *codeTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *codeTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	call := fmt.Sprintf("\nfinal_answer(%s(**tool_args))\n", t.spec.Name)
+	if t.spec.Language == "javascript" {
+		call = fmt.Sprintf("\nfinal_answer(%s(tool_args));\n", t.spec.Name)
+	}
+	res, err := t.executor.Execute(ctx, t.spec.Code+call, map[string]any{"tool_args": args})
+	if err != nil {
+		return nil, err
+	}
+	if !res.IsFinal {
+		return nil, fmt.Errorf("tool %s returned no result", t.spec.Name)
+	}
+	return res.Output, nil
+}
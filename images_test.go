@@ -0,0 +1,55 @@
+package neko_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+var testImage = []byte("\x89PNG\r\n\x1a\nimage")
+
+// taskImages returns the images of the task message in the model's first
+// request.
+func taskImages(t *testing.T, model *testutil.ReplayModel) (images [][]byte, content string) {
+	t.Helper()
+	requests := model.Requests()
+	if len(requests) == 0 {
+		t.Fatal("model was not called")
+	}
+	for _, msg := range requests[0] {
+		if msg.Role == neko.RoleUser && strings.HasPrefix(msg.Content, "Task:") {
+			return msg.Images, msg.Content
+		}
+	}
+	t.Fatal("no task message in the model's input")
+	return nil, ""
+}
+
+func TestTaskImagesReachModel(t *testing.T) {
+	vision := neko.WithModelCapabilities(neko.Capabilities{Tools: true, Vision: true})
+	tests := []struct {
+		name  string
+		model *testutil.ReplayModel
+		agent func(neko.Model) neko.Agent
+	}{
+		{"tool calling", testutil.NewReplayModel(finalAnswer("a cat", nil)), func(m neko.Model) neko.Agent {
+			return neko.NewToolCallingAgent(neko.WithModel(m), vision)
+		}},
+		{"code", testutil.NewReplayModel(&neko.Message{Role: neko.RoleAssistant, Content: `<code>final_answer("a cat")</code>`}), func(m neko.Model) neko.Agent {
+			return neko.NewCodeAgent(leakyExecutor{}, neko.WithModel(m), vision)
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.agent(tt.model).Run(context.Background(), "what is this?", neko.WithImages(testImage)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		images, _ := taskImages(t, tt.model)
+		if len(images) != 1 || !bytes.Equal(images[0], testImage) {
+			t.Errorf("%s: task message images = %q, want the attached image", tt.name, images)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package neko
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// StepLatency breaks down where an action step spent its time. Execution
+// includes any tool calls the executed code made, which are also counted
+// in Tools.
+type StepLatency struct {
+	Model     time.Duration            `json:"model"`
+	Tools     time.Duration            `json:"tools"`
+	Execution time.Duration            `json:"execution"`
+	ByTool    map[string]time.Duration `json:"by_tool,omitempty"`
+}
+
+func (l *StepLatency) addTool(name string, d time.Duration) {
+	l.Tools += d
+	if l.ByTool == nil {
+		l.ByTool = make(map[string]time.Duration)
+	}
+	l.ByTool[name] += d
+}
+
+func (l *StepLatency) add(o StepLatency) {
+	l.Model += o.Model
+	l.Tools += o.Tools
+	l.Execution += o.Execution
+	for name, d := range o.ByTool {
+		if l.ByTool == nil {
+			l.ByTool = make(map[string]time.Duration)
+		}
+		l.ByTool[name] += d
+	}
+}
+
+// Latency aggregates step latencies over a run.
+type Latency struct {
+	Total StepLatency   `json:"total"`
+	Steps []StepLatency `json:"steps"` // one per action step, in order
+}
+
+// Latency returns the latency breakdown of the action steps in memory.
+func (m *Memory) Latency() *Latency {
+	l := &Latency{}
+	for _, step := range m.Steps {
+		if s, ok := step.(*ActionStep); ok {
+			sl := s.Latency
+			sl.ByTool = maps.Clone(sl.ByTool)
+			l.Steps = append(l.Steps, sl)
+			l.Total.add(sl)
+		}
+	}
+	return l
+}
+
+type latencyKey struct{}
+
+// latencyRecorder accumulates into a step's latency. Tools bridged into
+// executed code may record from other goroutines.
+type latencyRecorder struct {
+	mu sync.Mutex
+	l  *StepLatency
+}
+
+func withLatencyRecorder(ctx context.Context, l *StepLatency) context.Context {
+	return context.WithValue(ctx, latencyKey{}, &latencyRecorder{l: l})
+}
+
+// recordLatency applies fn to the latency of the step ctx belongs to, if
+// any.
+func recordLatency(ctx context.Context, fn func(*StepLatency)) {
+	r, ok := ctx.Value(latencyKey{}).(*latencyRecorder)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.l)
+}
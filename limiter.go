@@ -0,0 +1,148 @@
+package neko
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter paces model requests so that every agent sharing it together
+// stays within one provider quota: at most rate requests per second on
+// average, with bursts of up to burst requests. When requests are
+// waiting, agents are served in turn, one request each, so a busy
+// sub-agent cannot starve its siblings or its manager.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	queues map[string][]chan struct{} // waiting requests by agent
+	order  []string                   // agents with waiting requests, in turn order
+	timer  *time.Timer
+}
+
+// NewLimiter creates a limiter allowing rate requests per second with
+// bursts of up to burst requests. A burst below 1 is treated as 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	b := float64(max(burst, 1))
+	return &Limiter{rate: rate, burst: b, tokens: b, last: time.Now(), queues: map[string][]chan struct{}{}}
+}
+
+// Wait blocks until agent may make a request or ctx ends. A limiter with
+// a rate of zero or less does not limit.
+func (l *Limiter) Wait(ctx context.Context, agent string) error {
+	if l.rate <= 0 {
+		return ctx.Err()
+	}
+	ready := make(chan struct{})
+	l.mu.Lock()
+	if len(l.queues[agent]) == 0 {
+		l.order = append(l.order, agent)
+	}
+	l.queues[agent] = append(l.queues[agent], ready)
+	l.dispatchLocked()
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// Granted while giving up: return the token.
+		l.tokens = min(l.burst, l.tokens+1)
+		l.dispatchLocked()
+	default:
+		l.removeLocked(agent, ready)
+	}
+	return ctx.Err()
+}
+
+// dispatchLocked grants tokens to waiting requests, one agent at a time,
+// and schedules itself for when the next token is due. l.mu must be held.
+func (l *Limiter) dispatchLocked() {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	for len(l.order) > 0 && l.tokens >= 1 {
+		agent := l.order[0]
+		l.order = l.order[1:]
+		q := l.queues[agent]
+		close(q[0])
+		l.tokens--
+		if len(q) > 1 {
+			l.queues[agent] = q[1:]
+			l.order = append(l.order, agent)
+		} else {
+			delete(l.queues, agent)
+		}
+	}
+	if len(l.order) == 0 || l.timer != nil {
+		return
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.timer = time.AfterFunc(wait, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		l.dispatchLocked()
+	})
+}
+
+// removeLocked drops a waiting request. l.mu must be held.
+func (l *Limiter) removeLocked(agent string, ready chan struct{}) {
+	q := l.queues[agent]
+	for i, c := range q {
+		if c == ready {
+			q = append(q[:i:i], q[i+1:]...)
+			break
+		}
+	}
+	if len(q) > 0 {
+		l.queues[agent] = q
+		return
+	}
+	delete(l.queues, agent)
+	for i, name := range l.order {
+		if name == agent {
+			l.order = append(l.order[:i:i], l.order[i+1:]...)
+			break
+		}
+	}
+}
+
+type limiterKey struct{}
+
+// WithLimiter paces the agent's model requests with l. Managed and
+// spawned agents without a limiter of their own share their manager's,
+// so one limiter covers a whole hierarchy.
+func WithLimiter(l *Limiter) AgentOption {
+	return func(a *BaseAgent) { a.limiter = l }
+}
+
+// startLimiter attaches the run's limiter to ctx for managed agents.
+func (a *BaseAgent) startLimiter(ctx context.Context) context.Context {
+	if a.limiter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, limiterKey{}, a.limiter)
+}
+
+// pace waits for the run's limiter, if any, before a model request.
+func (a *BaseAgent) pace(ctx context.Context) error {
+	l, ok := ctx.Value(limiterKey{}).(*Limiter)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := l.Wait(ctx, a.name)
+	if d := time.Since(start); d > time.Second {
+		a.logger().Debug("model request paced by limiter", "waited", d)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package neko
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queued waits until agent has n requests waiting on l.
+func queued(t *testing.T, l *Limiter, agent string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		got := len(l.queues[agent])
+		l.mu.Unlock()
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("%s never had %d waiting requests", agent, n)
+}
+
+func TestLimiterServesAgentsInTurn(t *testing.T) {
+	l := NewLimiter(50, 1)
+	ctx := context.Background()
+	if err := l.Wait(ctx, "busy"); err != nil { // takes the burst
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	wait := func(agent string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(ctx, agent); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			order = append(order, agent)
+			mu.Unlock()
+		}()
+	}
+	for i := range 3 {
+		wait("busy")
+		queued(t, l, "busy", i+1)
+	}
+	wait("other")
+	queued(t, l, "other", 1)
+	wg.Wait()
+	if want := []string{"busy", "other", "busy", "busy"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestLimiterCanceledWaitLeavesQueue(t *testing.T) {
+	l := NewLimiter(1, 1)
+	if err := l.Wait(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "a"); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queues) != 0 || len(l.order) != 0 {
+		t.Errorf("canceled request still queued: %v %v", l.queues, l.order)
+	}
+}
@@ -0,0 +1,66 @@
+package neko
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LocaleContext is the time and place an agent runs in, stated in its
+// system prompt by WithLocaleContext.
+type LocaleContext struct {
+	// Location is the time zone of the stated time, time.Local by
+	// default.
+	Location *time.Location
+	// Locale is the user's BCP 47 language tag, e.g. "en-GB", and is left
+	// out when empty.
+	Locale string
+	// Now returns the current time, time.Now by default; set it to replay
+	// a run as of another date.
+	Now func() time.Time
+}
+
+// WithLocaleContext states the current date and time, time zone and
+// locale in the system prompt, refreshed at the start of every run, so
+// the model does not take its training cutoff for today.
+func WithLocaleContext(c LocaleContext) AgentOption {
+	return func(a *BaseAgent) { a.locale = &c }
+}
+
+// WithRunLocale replaces the agent's locale context, if any, for one run,
+// e.g. with the time zone of the user the run serves.
+func WithRunLocale(c LocaleContext) RunOption {
+	return func(o *RunOptions) { o.Locale = &c }
+}
+
+// localePrompt states the run's time and locale for the system prompt.
+func (a *BaseAgent) localePrompt(options *RunOptions) string {
+	c := a.locale
+	if options.Locale != nil {
+		c = options.Locale
+	}
+	if c == nil {
+		return ""
+	}
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	t := now().In(loc)
+	zone := loc.String()
+	if zone == "Local" || zone == "" {
+		zone, _ = t.Zone()
+	}
+	lines := []string{
+		fmt.Sprintf("Current date and time: %s (%s, UTC%s).", t.Format("Monday, 2 January 2006, 15:04"), zone, t.Format("-07:00")),
+	}
+	if c.Locale != "" {
+		lines = append(lines, fmt.Sprintf("User locale: %s. Use its conventions for dates, numbers and currency.", c.Locale))
+	}
+	lines = append(lines, `Take this as today, not the date of your training data, for anything time-sensitive such as "today", "latest" or "this year".`)
+	return "\n\n## Current context\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,82 @@
+package neko
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// WithLogger sets the logger for run, step, model, tool and execution
+// events. Routine events are logged at debug level, run completion at
+// info and failures at warn. Defaults to slog.Default().
+func WithLogger(l *slog.Logger) AgentOption {
+	return func(a *BaseAgent) { a.log = l }
+}
+
+func (a *BaseAgent) logger() *slog.Logger {
+	l := a.log
+	if l == nil {
+		l = slog.Default()
+	}
+	if a.secrets != nil {
+		l = slog.New(NewRedactingHandler(l.Handler(), a.secrets.redactor))
+	}
+	return l.With("agent", a.name)
+}
+
+func (a *BaseAgent) logRun(result *RunResult, err error) {
+	if err != nil {
+		a.logger().Warn("run failed", "error", err)
+		return
+	}
+	attrs := []any{"state", result.State, "steps", len(result.Steps), "duration", result.Timing.Duration}
+	if result.TokenUsage != nil {
+		attrs = append(attrs, "input_tokens", result.TokenUsage.InputTokens, "output_tokens", result.TokenUsage.OutputTokens)
+	}
+	a.logger().Info("run finished", attrs...)
+}
+
+func (a *BaseAgent) logStep(step *ActionStep) {
+	attrs := []any{"step", step.StepNumber, "duration", step.Timing.Duration, "final", step.IsFinal}
+	if step.TokenUsage != nil {
+		attrs = append(attrs, "input_tokens", step.TokenUsage.InputTokens, "output_tokens", step.TokenUsage.OutputTokens)
+	}
+	if step.Error != nil {
+		a.logger().Warn("step failed", append(attrs, "error", step.Error)...)
+		return
+	}
+	a.logger().Debug("step finished", attrs...)
+}
+
+func (a *BaseAgent) logGenerate(resp *Message, d time.Duration, err error) {
+	attrs := []any{"model", a.model.ModelID(), "duration", d}
+	if err != nil {
+		a.logger().Warn("model call failed", append(attrs, "error", err)...)
+		return
+	}
+	if resp.TokenUsage != nil {
+		attrs = append(attrs, "input_tokens", resp.TokenUsage.InputTokens, "output_tokens", resp.TokenUsage.OutputTokens)
+	}
+	a.logger().Debug("model call", attrs...)
+}
+
+func (a *BaseAgent) logToolCall(tc ToolCall, d time.Duration, err error) {
+	attrs := []any{"tool", tc.Name, "duration", d}
+	if err != nil {
+		a.logger().Warn("tool call failed", append(attrs, "error", err)...)
+		return
+	}
+	a.logger().Debug("tool call", attrs...)
+}
+
+func (a *BaseAgent) logExecution(step int, language string, d time.Duration, err error) {
+	attrs := []any{"step", step, "language", language, "duration", d}
+	switch {
+	case errors.Is(err, ErrOutputLimit):
+		a.logger().Warn("execution output truncated", append(attrs, "error", err)...)
+	case err != nil:
+		a.logger().Warn("code execution failed", append(attrs, "error", err)...)
+	default:
+		a.logger().Debug("code executed", attrs...)
+	}
+}
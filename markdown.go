@@ -0,0 +1,101 @@
+package neko
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ToMarkdown renders the run as a Markdown transcript for pasting into
+// issues and docs: the task, any plans, each step's thought, code, tool
+// calls, observations and errors, and the final answer, followed by a
+// state, timing, token and cost summary. Images are left out.
+func (r *RunResult) ToMarkdown() string {
+	var sb strings.Builder
+	if err := markdownTemplate.Execute(&sb, r); err != nil {
+		return fmt.Sprintf("<!-- failed to render transcript: %s -->\n", err)
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"thought":   consoleThought,
+	"arguments": formatArguments,
+	"fence":     fence,
+	"quote":     quote,
+	"seconds":   func(t Timing) string { return fmt.Sprintf("%.2fs", t.Duration.Seconds()) },
+	"cost":      func(c float64) string { return fmt.Sprintf("$%.4f", c) },
+	"value":     func(v any) string { return fmt.Sprintf("%v", v) },
+}).Parse(markdownText))
+
+// fence wraps s in a code block, with a fence longer than any run of
+// backticks in s.
+func fence(s string) string {
+	ticks, run := 3, 0
+	for _, r := range s {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		if run++; run >= ticks {
+			ticks = run + 1
+		}
+	}
+	f := strings.Repeat("`", ticks)
+	return f + "\n" + strings.TrimRight(s, "\n") + "\n" + f
+}
+
+// quote renders s as a Markdown blockquote.
+func quote(s string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ")
+}
+
+const markdownText = `# Agent run
+{{range .Steps}}
+{{- if eq .StepType "task"}}
+## Task
+
+{{.Task}}
+{{else if eq .StepType "planning"}}
+## Plan
+
+{{.Plan}}
+{{else if eq .StepType "action"}}
+## Step {{.StepNumber}}
+{{with thought .ModelOutput}}
+{{quote .}}
+{{end}}
+{{- with .CodeAction}}
+{{fence .}}
+{{end}}
+{{- range .ToolCalls}}
+**Tool call:** ` + "`{{.Name}}`" + `
+
+{{fence (arguments .Arguments)}}
+{{end}}
+{{- with .Observations}}
+**Observations:**
+
+{{fence .}}
+{{end}}
+{{- range .Artifacts}}
+**Artifact:** {{.Name}}{{with .Path}} (` + "`{{.}}`" + `){{end}}
+{{end}}
+{{- with .Error}}
+**Error:**
+
+{{fence .Error}}
+{{end}}
+_{{seconds .Timing}}{{with .TokenUsage}} · {{.InputTokens}} input / {{.OutputTokens}} output tokens{{end}}_
+{{else if eq .StepType "final_answer"}}
+## Final answer
+
+{{fence (value .Output)}}
+{{end}}
+{{- end}}
+---
+
+| State | Steps | Duration |{{with .TokenUsage}} Tokens |{{end}}{{if .Cost}} Cost |{{end}}
+|---|---|---|{{with .TokenUsage}}---|{{end}}{{if .Cost}}---|{{end}}
+| {{.State}} | {{len .Steps}} | {{seconds .Timing}} |{{with .TokenUsage}} {{.Total}} (input {{.InputTokens}}, output {{.OutputTokens}}) |{{end}}{{if .Cost}} {{cost .Cost}} |{{end}}
+`
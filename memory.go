@@ -2,6 +2,8 @@ package neko
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 )
 
@@ -29,6 +31,64 @@ func (m *Memory) AddStep(step Step) {
 	m.Steps = append(m.Steps, step)
 }
 
+// clone returns a copy of m that can be appended to independently.
+func (m *Memory) clone() *Memory {
+	return &Memory{SystemPrompt: m.SystemPrompt, Steps: append([]Step(nil), m.Steps...)}
+}
+
+// CopySteps returns a copy of the steps that shares no mutable state with
+// memory: the step structs, their slices, maps and token usage are
+// copied. Image and artifact bytes, which are never modified, are shared.
+// Steps of types defined outside this package are not copied.
+func (m *Memory) CopySteps() []Step {
+	steps := make([]Step, len(m.Steps))
+	for i, s := range m.Steps {
+		steps[i] = copyStep(s)
+	}
+	return steps
+}
+
+func copyStep(step Step) Step {
+	switch s := step.(type) {
+	case *TaskStep:
+		c := *s
+		c.Images = slices.Clone(s.Images)
+		c.Files = slices.Clone(s.Files)
+		return &c
+	case *PlanningStep:
+		c := *s
+		c.TokenUsage = copyUsage(s.TokenUsage)
+		return &c
+	case *ActionStep:
+		c := *s
+		c.ToolCalls = slices.Clone(s.ToolCalls)
+		for i := range c.ToolCalls {
+			c.ToolCalls[i].Arguments = maps.Clone(c.ToolCalls[i].Arguments)
+		}
+		c.Artifacts = slices.Clone(s.Artifacts)
+		c.Citations = slices.Clone(s.Citations)
+		c.Candidates = slices.Clone(s.Candidates)
+		c.AgentUsage = maps.Clone(s.AgentUsage)
+		c.ToolUsage = maps.Clone(s.ToolUsage)
+		c.ObservationImages = slices.Clone(s.ObservationImages)
+		c.TokenUsage = copyUsage(s.TokenUsage)
+		c.Latency.ByTool = maps.Clone(s.Latency.ByTool)
+		return &c
+	case *FinalAnswerStep:
+		c := *s
+		return &c
+	}
+	return step
+}
+
+func copyUsage(u *TokenUsage) *TokenUsage {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	return &c
+}
+
 // LastStep returns the most recent step, or nil.
 func (m *Memory) LastStep() Step {
 	if len(m.Steps) == 0 {
@@ -55,11 +115,13 @@ func (m *Memory) TotalTokens() TokenUsage {
 			if s.TokenUsage != nil {
 				total.InputTokens += s.TokenUsage.InputTokens
 				total.OutputTokens += s.TokenUsage.OutputTokens
+				total.CachedInputTokens += s.TokenUsage.CachedInputTokens
 			}
 		case *PlanningStep:
 			if s.TokenUsage != nil {
 				total.InputTokens += s.TokenUsage.InputTokens
 				total.OutputTokens += s.TokenUsage.OutputTokens
+				total.CachedInputTokens += s.TokenUsage.CachedInputTokens
 			}
 		}
 	}
@@ -77,6 +139,31 @@ func (m *Memory) ActionSteps() []*ActionStep {
 	return steps
 }
 
+// Artifacts returns artifacts produced by all action steps, in order.
+func (m *Memory) Artifacts() []Artifact {
+	var artifacts []Artifact
+	for _, s := range m.ActionSteps() {
+		artifacts = append(artifacts, s.Artifacts...)
+	}
+	return artifacts
+}
+
+// Citations returns the sources cited by all action steps, in the order
+// first consulted, each source once.
+func (m *Memory) Citations() []Citation {
+	var citations []Citation
+	seen := map[string]bool{}
+	for _, s := range m.ActionSteps() {
+		for _, c := range s.Citations {
+			if !seen[c.key()] {
+				seen[c.key()] = true
+				citations = append(citations, c)
+			}
+		}
+	}
+	return citations
+}
+
 // Summary returns a brief summary of the memory state.
 func (m *Memory) Summary() string {
 	var sb strings.Builder
@@ -117,29 +204,3 @@ func truncate(s string, maxLen int) string {
 	}
 	return s[:maxLen] + "..."
 }
-
-// CallbackRegistry manages step callbacks.
-type CallbackRegistry struct {
-	callbacks map[string][]func(Step)
-}
-
-// NewCallbackRegistry creates a callback registry.
-func NewCallbackRegistry() *CallbackRegistry {
-	return &CallbackRegistry{callbacks: make(map[string][]func(Step))}
-}
-
-// Register adds a callback for a step type.
-func (r *CallbackRegistry) Register(stepType string, fn func(Step)) {
-	r.callbacks[stepType] = append(r.callbacks[stepType], fn)
-}
-
-// Trigger fires callbacks for a step.
-func (r *CallbackRegistry) Trigger(step Step) {
-	for _, fn := range r.callbacks[step.StepType()] {
-		fn(step)
-	}
-	// Also trigger "all" callbacks
-	for _, fn := range r.callbacks["all"] {
-		fn(step)
-	}
-}
@@ -2,11 +2,17 @@ package neko
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/respjson"
 )
 
 // Model is the interface for LLM backends.
@@ -15,12 +21,30 @@ type Model interface {
 	ModelID() string
 }
 
+// ToolCallingSupport is implemented by models that can report whether
+// their backend supports native function calling. A ToolCallingAgent
+// whose model reports no support describes its tools in the prompt and
+// parses tool calls from the model's text instead; see
+// WithPromptedToolCalls.
+type ToolCallingSupport interface {
+	SupportsToolCalling() bool
+}
+
+// ModelProvider is implemented by models that know the provider serving
+// them. The provider is recorded as gen_ai.system on the model's chat
+// spans, using the OpenTelemetry names, e.g. "openai" or "aws.bedrock".
+type ModelProvider interface {
+	Provider() string
+}
+
 // GenerateOptions holds generation parameters.
 type GenerateOptions struct {
 	StopSequences []string
 	Tools         []Tool
 	Temperature   float64
 	MaxTokens     int64
+	ToolChoice    string
+	LiveSearch    *LiveSearch // for GrokModel; other models ignore it
 }
 
 // GenerateOption is a functional option for Generate.
@@ -36,6 +60,20 @@ func WithTools(tools ...Tool) GenerateOption {
 	return func(o *GenerateOptions) { o.Tools = tools }
 }
 
+// Tool choices for WithToolChoice.
+const (
+	ToolChoiceAuto     = "auto"     // the model decides whether to call tools
+	ToolChoiceNone     = "none"     // the model must not call tools
+	ToolChoiceRequired = "required" // the model must call at least one tool
+)
+
+// WithToolChoice controls tool calls: choice is ToolChoiceAuto,
+// ToolChoiceNone, ToolChoiceRequired or the name of a tool the model must
+// call. It applies only with WithTools.
+func WithToolChoice(choice string) GenerateOption {
+	return func(o *GenerateOptions) { o.ToolChoice = choice }
+}
+
 // WithTemperature sets generation temperature.
 func WithTemperature(t float64) GenerateOption {
 	return func(o *GenerateOptions) { o.Temperature = t }
@@ -52,6 +90,15 @@ type OpenAIModel struct {
 	modelID     string
 	temperature float64
 	maxTokens   int64
+	noTools     atomic.Bool // the backend has no function calling
+	provider    string      // empty for OpenAI-compatible servers
+	normalize   MessageNormalizer
+	clientOpts  []option.RequestOption
+	// Set by models built on OpenAIModel: callOpts adds provider request
+	// fields for a call, and extra reads the provider fields of its
+	// response, or of each streamed chunk.
+	callOpts func(o *GenerateOptions) []option.RequestOption
+	extra    func(ctx context.Context, fields map[string]respjson.Field)
 }
 
 // OpenAIOption configures OpenAIModel.
@@ -67,29 +114,43 @@ func WithOpenAIMaxTokens(n int64) OpenAIOption {
 	return func(m *OpenAIModel) { m.maxTokens = n }
 }
 
+// WithOpenAIToolCalling sets whether the backend supports function
+// calling. It defaults to true; set it to false for local models served
+// without tool support. A backend that rejects a request with tools is
+// also marked as unsupported.
+func WithOpenAIToolCalling(enabled bool) OpenAIOption {
+	return func(m *OpenAIModel) { m.noTools.Store(!enabled) }
+}
+
+// WithOpenAIMessageNormalizer rewrites messages with n before they are
+// sent, for OpenAI-compatible servers with stricter rules on message
+// order, such as StrictAlternation.
+func WithOpenAIMessageNormalizer(n MessageNormalizer) OpenAIOption {
+	return func(m *OpenAIModel) { m.normalize = n }
+}
+
+// WithOpenAIHTTPClient sends requests with c, e.g. to go through a proxy,
+// present a client certificate or add tracing.
+func WithOpenAIHTTPClient(c *http.Client) OpenAIOption {
+	return func(m *OpenAIModel) { m.clientOpts = append(m.clientOpts, option.WithHTTPClient(c)) }
+}
+
 // NewOpenAIModel creates an OpenAI model using the official SDK.
 func NewOpenAIModel(modelID, apiKey string, opts ...OpenAIOption) *OpenAIModel {
-	client := openai.NewClient(option.WithAPIKey(apiKey))
-	m := &OpenAIModel{
-		client:      client,
-		modelID:     modelID,
-		temperature: 0.7,
-		maxTokens:   4096,
-	}
-	for _, opt := range opts {
-		opt(m)
-	}
+	m := newOpenAIModel(modelID, opts, option.WithAPIKey(apiKey))
+	m.provider = "openai"
 	return m
 }
 
 // NewOpenAIModelWithBaseURL creates an OpenAI-compatible model with custom base URL.
 func NewOpenAIModelWithBaseURL(modelID, apiKey, baseURL string, opts ...OpenAIOption) *OpenAIModel {
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-		option.WithBaseURL(baseURL),
-	)
+	return newOpenAIModel(modelID, opts, option.WithAPIKey(apiKey), option.WithBaseURL(baseURL))
+}
+
+// newOpenAIModel applies opts and creates the model's client with
+// clientOpts.
+func newOpenAIModel(modelID string, opts []OpenAIOption, clientOpts ...option.RequestOption) *OpenAIModel {
 	m := &OpenAIModel{
-		client:      client,
 		modelID:     modelID,
 		temperature: 0.7,
 		maxTokens:   4096,
@@ -97,11 +158,63 @@ func NewOpenAIModelWithBaseURL(modelID, apiKey, baseURL string, opts ...OpenAIOp
 	for _, opt := range opts {
 		opt(m)
 	}
+	m.client = openai.NewClient(append(clientOpts, m.clientOpts...)...)
 	return m
 }
 
 func (m *OpenAIModel) ModelID() string { return m.modelID }
 
+// Provider returns "openai" for models created with NewOpenAIModel,
+// "az.ai.openai" for Azure OpenAI, and "" for other OpenAI-compatible
+// servers, whose provider is unknown.
+func (m *OpenAIModel) Provider() string { return m.provider }
+
+// SupportsToolCalling reports whether the backend supports function
+// calling, as set with WithOpenAIToolCalling or learned from a rejected
+// request.
+func (m *OpenAIModel) SupportsToolCalling() bool { return !m.noTools.Load() }
+
+// toolChoiceParam converts a WithToolChoice choice to its OpenAI form.
+func toolChoiceParam(choice string) openai.ChatCompletionToolChoiceOptionUnionParam {
+	switch choice {
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(choice)}
+	}
+	return openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice})
+}
+
+// requestError normalizes an error from the backend with
+// NormalizeProviderError and toolsError.
+func (m *OpenAIModel) requestError(err error, options *GenerateOptions) error {
+	return NormalizeProviderError(openAIStatus(err), m.toolsError(err, options))
+}
+
+// openAIStatus returns the HTTP status of an OpenAI API error, or 0.
+func openAIStatus(err error) int {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// toolsError marks the model as lacking function calling if err shows the
+// backend rejected a request for its tools, and wraps err with
+// ErrToolCallingUnsupported.
+func (m *OpenAIModel) toolsError(err error, options *GenerateOptions) error {
+	if len(options.Tools) == 0 {
+		return err
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"does not support tools", "tools are not supported", "tools is not supported", "tool calling is not supported", "function calling is not supported", "tool use is not supported"} {
+		if strings.Contains(msg, s) {
+			m.noTools.Store(true)
+			return fmt.Errorf("%w: %w", ErrToolCallingUnsupported, err)
+		}
+	}
+	return err
+}
+
 // Generate sends messages to OpenAI and returns response.
 func (m *OpenAIModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
 	options := &GenerateOptions{
@@ -133,25 +246,36 @@ func (m *OpenAIModel) Generate(ctx context.Context, messages []Message, opts ...
 	// Add tools if provided
 	if len(options.Tools) > 0 {
 		params.Tools = m.convertTools(options.Tools)
+		if options.ToolChoice != "" {
+			params.ToolChoice = toolChoiceParam(options.ToolChoice)
+		}
 	}
 
 	// Make the API call
-	resp, err := m.client.Chat.Completions.New(ctx, params)
+	resp, err := m.client.Chat.Completions.New(ctx, params, m.callOptions(options)...)
 	if err != nil {
-		return nil, fmt.Errorf("openai completion failed: %w", err)
+		return nil, fmt.Errorf("openai completion failed: %w", m.requestError(err, options))
 	}
 
 	if len(resp.Choices) == 0 {
 		return nil, fmt.Errorf("no response choices returned")
 	}
 
+	if m.extra != nil {
+		m.extra(ctx, resp.JSON.ExtraFields)
+	}
 	choice := resp.Choices[0]
+	if choice.FinishReason == "content_filter" && choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+		return nil, fmt.Errorf("openai completion failed: %w: the response was blocked", ErrContentFiltered)
+	}
 	result := &Message{
-		Role:    RoleAssistant,
-		Content: choice.Message.Content,
+		Role:      RoleAssistant,
+		Content:   choice.Message.Content,
+		Reasoning: extraString(choice.Message.JSON.ExtraFields, "reasoning_content"),
 		TokenUsage: &TokenUsage{
-			InputTokens:  int(resp.Usage.PromptTokens),
-			OutputTokens: int(resp.Usage.CompletionTokens),
+			InputTokens:       int(resp.Usage.PromptTokens),
+			OutputTokens:      int(resp.Usage.CompletionTokens),
+			CachedInputTokens: int(resp.Usage.PromptTokensDetails.CachedTokens),
 		},
 	}
 
@@ -174,12 +298,19 @@ func (m *OpenAIModel) Generate(ctx context.Context, messages []Message, opts ...
 }
 
 func (m *OpenAIModel) convertMessages(messages []Message) []openai.ChatCompletionMessageParamUnion {
+	if m.normalize != nil {
+		messages = m.normalize(messages)
+	}
 	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages))
 	for _, msg := range messages {
 		switch msg.Role {
 		case RoleSystem:
 			result = append(result, openai.SystemMessage(msg.Content))
 		case RoleUser:
+			if len(msg.Images) > 0 {
+				result = append(result, openai.UserMessage(userContentParts(msg)))
+				continue
+			}
 			result = append(result, openai.UserMessage(msg.Content))
 		case RoleAssistant:
 			result = append(result, openai.AssistantMessage(msg.Content))
@@ -191,6 +322,36 @@ func (m *OpenAIModel) convertMessages(messages []Message) []openai.ChatCompletio
 	return result
 }
 
+// callOptions returns the provider request options for a call.
+func (m *OpenAIModel) callOptions(options *GenerateOptions) []option.RequestOption {
+	if m.callOpts == nil {
+		return nil
+	}
+	return m.callOpts(options)
+}
+
+// extraString returns the string field name that a provider added to
+// an OpenAI response, such as DeepSeek's reasoning_content, or "".
+func extraString(fields map[string]respjson.Field, name string) string {
+	var s string
+	if f, ok := fields[name]; ok {
+		// Fields the SDK does not know are never marked valid.
+		json.Unmarshal([]byte(f.Raw()), &s)
+	}
+	return s
+}
+
+// userContentParts converts a user message with images to text and
+// image_url content parts, embedding the images as data URIs.
+func userContentParts(msg Message) []openai.ChatCompletionContentPartUnionParam {
+	parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(msg.Content)}
+	for _, img := range msg.Images {
+		uri := "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img)
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: uri}))
+	}
+	return parts
+}
+
 func (m *OpenAIModel) convertTools(tools []Tool) []openai.ChatCompletionToolUnionParam {
 	result := make([]openai.ChatCompletionToolUnionParam, 0, len(tools))
 	for _, tool := range tools {
@@ -232,10 +393,12 @@ type StreamingModel interface {
 
 // StreamDelta represents a streaming chunk.
 type StreamDelta struct {
-	Content   string     `json:"content,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
-	Done      bool       `json:"done"`
-	Error     error      `json:"error,omitempty"`
+	Content    string      `json:"content,omitempty"`
+	Reasoning  string      `json:"reasoning,omitempty"` // see Message.Reasoning
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	TokenUsage *TokenUsage `json:"token_usage,omitempty"` // set on the final delta
+	Done       bool        `json:"done"`
+	Error      error       `json:"error,omitempty"`
 }
 
 // GenerateStream implements streaming generation using official SDK.
@@ -263,7 +426,15 @@ func (m *OpenAIModel) GenerateStream(ctx context.Context, messages []Message, op
 		}
 	}
 
-	stream := m.client.Chat.Completions.NewStreaming(ctx, params)
+	if len(options.Tools) > 0 {
+		params.Tools = m.convertTools(options.Tools)
+		if options.ToolChoice != "" {
+			params.ToolChoice = toolChoiceParam(options.ToolChoice)
+		}
+	}
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	stream := m.client.Chat.Completions.NewStreaming(ctx, params, m.callOptions(options)...)
 
 	ch := make(chan StreamDelta)
 	go func() {
@@ -273,10 +444,17 @@ func (m *OpenAIModel) GenerateStream(ctx context.Context, messages []Message, op
 		for stream.Next() {
 			chunk := stream.Current()
 			acc.AddChunk(chunk)
+			if m.extra != nil && len(chunk.JSON.ExtraFields) > 0 {
+				m.extra(ctx, chunk.JSON.ExtraFields)
+			}
 
 			// Send content delta
-			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				ch <- StreamDelta{Content: chunk.Choices[0].Delta.Content}
+			if len(chunk.Choices) > 0 {
+				delta := chunk.Choices[0].Delta
+				reasoning := extraString(delta.JSON.ExtraFields, "reasoning_content")
+				if delta.Content != "" || reasoning != "" {
+					ch <- StreamDelta{Content: delta.Content, Reasoning: reasoning}
+				}
 			}
 
 			// Check for finished tool calls
@@ -294,11 +472,15 @@ func (m *OpenAIModel) GenerateStream(ctx context.Context, messages []Message, op
 		}
 
 		if stream.Err() != nil {
-			ch <- StreamDelta{Error: stream.Err(), Done: true}
+			ch <- StreamDelta{Error: m.requestError(stream.Err(), options), Done: true}
 			return
 		}
 
-		ch <- StreamDelta{Done: true}
+		ch <- StreamDelta{Done: true, TokenUsage: &TokenUsage{
+			InputTokens:       int(acc.Usage.PromptTokens),
+			OutputTokens:      int(acc.Usage.CompletionTokens),
+			CachedInputTokens: int(acc.Usage.PromptTokensDetails.CachedTokens),
+		}}
 	}()
 
 	return ch, nil
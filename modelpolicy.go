@@ -0,0 +1,56 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// WithAllowedModels limits the run to models with the given IDs, and so
+// do the runs of the managed and spawned agents it starts. A run whose
+// agent's model is not allowed fails with ErrModelNotAllowed before its
+// first step, WithErrorRecovery does not switch to a fallback model that
+// is not allowed, and calls to other models, such as a ResponsePolicy's
+// or a JudgeVerifier's, fail with ErrModelNotAllowed. Within a run that
+// is itself limited, the limits combine.
+func WithAllowedModels(ids ...string) RunOption {
+	return func(o *RunOptions) { o.Models = ids }
+}
+
+type allowedModelsKey struct{}
+
+// startAllowedModels sets the run's model allowlist, from options and any
+// inherited from the run that started this one, and attaches it to ctx
+// for the agent's sub-agents.
+func (a *BaseAgent) startAllowedModels(ctx context.Context, options *RunOptions) context.Context {
+	allowed, limited := ctx.Value(allowedModelsKey{}).([]string)
+	if options.Models != nil {
+		if limited {
+			allowed = slices.DeleteFunc(slices.Clone(options.Models), func(id string) bool { return !slices.Contains(allowed, id) })
+		} else {
+			allowed, limited = options.Models, true
+		}
+	}
+	if !limited {
+		a.allowedModels = nil
+		return ctx
+	}
+	// A non-nil empty list allows no model.
+	a.allowedModels = append([]string{}, allowed...)
+	return context.WithValue(ctx, allowedModelsKey{}, a.allowedModels)
+}
+
+// modelAllowed reports whether the current run may use m.
+func (a *BaseAgent) modelAllowed(m Model) bool {
+	return a.allowedModels == nil || slices.Contains(a.allowedModels, m.ModelID())
+}
+
+// checkModel returns an error matching ErrModelNotAllowed if the run ctx
+// belongs to may not use m.
+func checkModel(ctx context.Context, m Model) error {
+	allowed, ok := ctx.Value(allowedModelsKey{}).([]string)
+	if !ok || slices.Contains(allowed, m.ModelID()) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrModelNotAllowed, m.ModelID())
+}
@@ -0,0 +1,100 @@
+package neko_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+// namedModel is a replay model reporting a given model ID.
+type namedModel struct {
+	*testutil.ReplayModel
+	id string
+}
+
+func (m namedModel) ModelID() string { return m.id }
+
+func TestAllowedModelsRefusesAgentModel(t *testing.T) {
+	model := namedModel{testutil.NewReplayModel(), "big"}
+	agent := neko.NewToolCallingAgent(neko.WithModel(model))
+	_, err := agent.Run(context.Background(), "task", neko.WithAllowedModels("small"))
+	if !errors.Is(err, neko.ErrModelNotAllowed) {
+		t.Fatalf("err = %v, want ErrModelNotAllowed", err)
+	}
+	if n := len(model.Requests()); n != 0 {
+		t.Errorf("model called %d times", n)
+	}
+}
+
+func TestAllowedModelsApplyToManagedAgents(t *testing.T) {
+	sub := namedModel{testutil.NewReplayModel(), "big"}
+	helper := neko.NewToolCallingAgent(neko.WithName("helper"), neko.WithDescription("helps"), neko.WithModel(sub))
+	manager := neko.NewToolCallingAgent(
+		neko.WithModel(namedModel{testutil.NewReplayModel(
+			&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "1", Name: "helper", Arguments: map[string]any{"task": "help"}}}},
+			&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "2", Name: "final_answer", Arguments: map[string]any{"answer": "done"}}}},
+		), "small"}),
+		neko.WithManagedAgents(helper),
+	)
+	result, err := manager.Run(context.Background(), "task", neko.WithAllowedModels("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := result.Steps[1].(*neko.ActionStep)
+	if !strings.Contains(step.Observations, "model not allowed") {
+		t.Errorf("observations = %q, want the managed agent refused", step.Observations)
+	}
+	if n := len(sub.Requests()); n != 0 {
+		t.Errorf("managed agent's model called %d times", n)
+	}
+}
+
+func TestAllowedModelsBlockFallback(t *testing.T) {
+	fallback := namedModel{testutil.NewReplayModel(), "big"}
+	agent := neko.NewToolCallingAgent(
+		neko.WithModel(namedModel{testutil.NewReplayModel(), "small"}),
+		neko.WithErrorRecovery(neko.ErrorRecovery{After: 1, FallbackModel: fallback}),
+	)
+	if _, err := agent.Run(context.Background(), "task", neko.WithMaxSteps(3), neko.WithAllowedModels("small")); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fallback.Requests()); n != 0 {
+		t.Errorf("fallback model called %d times", n)
+	}
+}
+
+func TestAllowedToolsApplyToManagedAgents(t *testing.T) {
+	called := false
+	shell := neko.NewFuncTool("shell", "runs a command", map[string]neko.ToolInput{"cmd": {Type: "string", Description: "command"}}, "string",
+		func(map[string]any) (any, error) { called = true; return "ok", nil })
+	sub := testutil.NewReplayModel(
+		&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "1", Name: "shell", Arguments: map[string]any{"cmd": "id"}}}},
+		&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "2", Name: "final_answer", Arguments: map[string]any{"answer": "helped"}}}},
+	)
+	helper := neko.NewToolCallingAgent(neko.WithName("helper"), neko.WithDescription("helps"), neko.WithModel(sub), neko.WithToolList(shell))
+	manager := neko.NewToolCallingAgent(
+		neko.WithModel(testutil.NewReplayModel(
+			&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "1", Name: "helper", Arguments: map[string]any{"task": "help"}}}},
+			&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "2", Name: "final_answer", Arguments: map[string]any{"answer": "done"}}}},
+		)),
+		neko.WithManagedAgents(helper),
+	)
+	if _, err := manager.Run(context.Background(), "task", neko.WithAllowedTools("helper")); err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Error("managed agent called a tool that is not allowed")
+	}
+	reqs := sub.Requests()
+	if len(reqs) < 2 {
+		t.Fatalf("managed agent's model called %d times", len(reqs))
+	}
+	last := reqs[1][len(reqs[1])-1]
+	if !strings.Contains(last.Content, "not allowed") {
+		t.Errorf("managed agent saw %q, want the tool call rejected", last.Content)
+	}
+}
@@ -0,0 +1,112 @@
+package neko
+
+import (
+	"context"
+	"slices"
+)
+
+// MessageNormalizer rewrites the messages sent to a model, for providers
+// that reject some sequences of messages Memory.ToMessages produces, such
+// as an assistant's output followed by its tool calls as two assistant
+// messages.
+type MessageNormalizer func([]Message) []Message
+
+// MergeAdjacentMessages merges adjacent messages with the same role into
+// one, joining their content with a blank line and keeping all images and
+// tool calls.
+func MergeAdjacentMessages(msgs []Message) []Message {
+	out := make([]Message, 0, len(msgs))
+	for _, m := range msgs {
+		if n := len(out); n > 0 && out[n-1].Role == m.Role {
+			out[n-1] = mergeMessages(out[n-1], m)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func mergeMessages(a, b Message) Message {
+	switch {
+	case a.Content == "":
+		a.Content = b.Content
+	case b.Content != "":
+		a.Content += "\n\n" + b.Content
+	}
+	a.Images = append(slices.Clip(a.Images), b.Images...)
+	a.ToolCalls = append(slices.Clip(a.ToolCalls), b.ToolCalls...)
+	return a
+}
+
+// StrictAlternation is a MessageNormalizer for providers that require a
+// single leading system message followed by user and assistant messages in
+// strict alternation, starting and ending with a user message. Tool
+// messages and later system messages become user messages, adjacent
+// messages with the same role are merged, and placeholder user messages
+// are inserted where the conversation would otherwise open or end with
+// the assistant.
+func StrictAlternation(msgs []Message) []Message {
+	var system []Message
+	for len(msgs) > 0 && msgs[0].Role == RoleSystem {
+		system, msgs = append(system, msgs[0]), msgs[1:]
+	}
+	rest := make([]Message, 0, len(msgs)+2)
+	for _, m := range msgs {
+		if m.Role != RoleAssistant {
+			m.Role = RoleUser
+		}
+		rest = append(rest, m)
+	}
+	rest = MergeAdjacentMessages(rest)
+	if len(rest) == 0 || rest[0].Role != RoleUser {
+		rest = append([]Message{{Role: RoleUser, Content: "Begin."}}, rest...)
+	}
+	if rest[len(rest)-1].Role != RoleUser {
+		rest = append(rest, Message{Role: RoleUser, Content: "Continue."})
+	}
+	return append(MergeAdjacentMessages(system), rest...)
+}
+
+// NormalizeMessages returns a model that passes messages through n before
+// each call to m, for backends without a normalizer option of their own.
+// It is a streaming model if m is, and reports m's support for tool
+// calling.
+func NormalizeMessages(m Model, n MessageNormalizer) Model {
+	nm := &normalizedModel{Model: m, normalize: n}
+	if sm, ok := m.(StreamingModel); ok {
+		return &normalizedStreamingModel{normalizedModel: nm, stream: sm}
+	}
+	return nm
+}
+
+type normalizedModel struct {
+	Model
+	normalize MessageNormalizer
+}
+
+func (m *normalizedModel) Generate(ctx context.Context, messages []Message, opts ...GenerateOption) (*Message, error) {
+	return m.Model.Generate(ctx, m.normalize(messages), opts...)
+}
+
+func (m *normalizedModel) Provider() string {
+	if p, ok := m.Model.(ModelProvider); ok {
+		return p.Provider()
+	}
+	return ""
+}
+
+func (m *normalizedModel) SupportsToolCalling() bool {
+	if s, ok := m.Model.(ToolCallingSupport); ok {
+		return s.SupportsToolCalling()
+	}
+	return true
+}
+
+type normalizedStreamingModel struct {
+	*normalizedModel
+	stream StreamingModel
+}
+
+func (m *normalizedStreamingModel) GenerateStream(ctx context.Context, messages []Message, opts ...GenerateOption) (<-chan StreamDelta, error) {
+	return m.stream.GenerateStream(ctx, m.normalize(messages), opts...)
+}
@@ -0,0 +1,229 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Output formats for OutputConstraints.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// OutputConstraints describe the final answers an agent must give. They
+// are stated in the system prompt and checked on every final answer; an
+// answer that breaks them is rejected and the model asked to reformat it.
+type OutputConstraints struct {
+	// Language is the English name of the answer's language, e.g.
+	// "French". Answers clearly in another language are rejected; the
+	// check knows common languages and is skipped for others.
+	Language string
+	// MaxLength is the longest answer allowed, in characters.
+	MaxLength int
+	// Format is FormatPlain (no Markdown), FormatMarkdown or FormatJSON
+	// (a valid JSON document).
+	Format string
+}
+
+// WithOutputConstraints sets constraints on the agent's final answers.
+func WithOutputConstraints(c OutputConstraints) AgentOption {
+	return func(a *BaseAgent) {
+		a.output = &c
+		a.finalChecks = append(a.finalChecks, c.check)
+	}
+}
+
+// outputPrompt states the agent's output constraints for the system
+// prompt.
+func (a *BaseAgent) outputPrompt() string {
+	c := a.output
+	if c == nil {
+		return ""
+	}
+	var rules []string
+	if c.Language != "" {
+		rules = append(rules, fmt.Sprintf("Write it in %s, whatever the language of the task or sources.", c.Language))
+	}
+	if c.MaxLength > 0 {
+		rules = append(rules, fmt.Sprintf("Keep it under %d characters.", c.MaxLength))
+	}
+	switch c.Format {
+	case FormatPlain:
+		rules = append(rules, "Use plain text: no Markdown headings, emphasis, links, tables or code blocks.")
+	case FormatMarkdown:
+		rules = append(rules, "Format it as Markdown.")
+	case FormatJSON:
+		rules = append(rules, "Give a single valid JSON document and nothing else, without code fences.")
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+	return "\n\n## Final answer format\n- " + strings.Join(rules, "\n- ")
+}
+
+var markdownSyntax = regexp.MustCompile("(?m)^#{1,6} |\\*\\*[^*]+\\*\\*|__[^_]+__|```|\\[[^\\]]+\\]\\([^)]+\\)|^\\|.*\\|\\s*$")
+
+// check is a FinalAnswerCheck enforcing the constraints.
+func (c OutputConstraints) check(ctx context.Context, answer any, steps []Step) error {
+	text, isString := answer.(string)
+	if o, ok := answer.(*Output); ok {
+		text, isString = o.Text, o.Kind == OutputText
+	} else if !isString {
+		data, err := json.Marshal(answer)
+		if err != nil {
+			text = fmt.Sprint(answer)
+		} else {
+			text = string(data)
+		}
+	}
+	var problems []string
+	if n := len([]rune(text)); c.MaxLength > 0 && n > c.MaxLength {
+		problems = append(problems, fmt.Sprintf("it is %d characters long; shorten it to at most %d", n, c.MaxLength))
+	}
+	switch c.Format {
+	case FormatPlain:
+		if m := markdownSyntax.FindString(text); m != "" {
+			problems = append(problems, fmt.Sprintf("it uses Markdown (%q); rewrite it as plain text", strings.TrimSpace(m)))
+		}
+	case FormatJSON:
+		if isString && !json.Valid([]byte(strings.TrimSpace(text))) {
+			problems = append(problems, "it is not valid JSON; give only the JSON document, without code fences or commentary")
+		}
+	}
+	if c.Language != "" && c.Format != FormatJSON {
+		if lang := otherLanguage(text, c.Language); lang != "" {
+			problems = append(problems, fmt.Sprintf("it appears to be in %s; translate it into %s", lang, c.Language))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("reformat it: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// languageScripts are the scripts of languages not written in Latin
+// script.
+var languageScripts = map[string][]*unicode.RangeTable{
+	"chinese":   {unicode.Han},
+	"japanese":  {unicode.Hiragana, unicode.Katakana, unicode.Han},
+	"korean":    {unicode.Hangul},
+	"russian":   {unicode.Cyrillic},
+	"ukrainian": {unicode.Cyrillic},
+	"arabic":    {unicode.Arabic},
+	"persian":   {unicode.Arabic},
+	"greek":     {unicode.Greek},
+	"hebrew":    {unicode.Hebrew},
+	"hindi":     {unicode.Devanagari},
+	"thai":      {unicode.Thai},
+}
+
+// stopwords are frequent words of languages written in Latin script.
+var stopwords = map[string][]string{
+	"english":    {"the", "and", "is", "of", "to", "in", "that", "it", "with", "for", "are", "was"},
+	"french":     {"le", "la", "les", "et", "est", "des", "une", "que", "dans", "pour", "du", "pas"},
+	"spanish":    {"el", "los", "las", "y", "es", "que", "en", "por", "una", "del", "con", "para"},
+	"german":     {"der", "die", "das", "und", "ist", "nicht", "mit", "ein", "eine", "zu", "auf", "den"},
+	"portuguese": {"os", "as", "e", "é", "que", "em", "um", "uma", "não", "do", "da", "para"},
+	"italian":    {"il", "gli", "e", "è", "di", "che", "un", "una", "per", "non", "della", "sono"},
+}
+
+// otherLanguage returns the language text is clearly written in, if it
+// is not want. It returns "" when text is in want, too short to tell, or
+// want is not a language it knows.
+func otherLanguage(text, want string) string {
+	want = strings.ToLower(strings.TrimSpace(want))
+	var letters, latin int
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+		}
+		for lang, tables := range languageScripts {
+			if unicode.IsOneOf(tables, r) {
+				counts[lang]++
+			}
+		}
+	}
+	if letters < 20 {
+		return ""
+	}
+	if _, ok := languageScripts[want]; ok {
+		if counts[want]*10 >= letters*3 {
+			return ""
+		}
+		if latin*2 > letters {
+			return latinLanguage(text, "")
+		}
+		return scriptLanguage(counts, letters)
+	}
+	if _, ok := stopwords[want]; !ok {
+		return ""
+	}
+	if latin*2 < letters {
+		return scriptLanguage(counts, letters)
+	}
+	return latinLanguage(text, want)
+}
+
+// scriptLanguage names the non-Latin script most of the letters are in.
+func scriptLanguage(counts map[string]int, letters int) string {
+	best, n := "", 0
+	for lang, c := range counts {
+		if c > n || (c == n && lang < best) {
+			best, n = lang, c
+		}
+	}
+	if n*2 < letters {
+		return "another script"
+	}
+	switch best {
+	case "ukrainian", "russian":
+		return "Cyrillic script"
+	case "persian", "arabic":
+		return "Arabic script"
+	case "japanese":
+		if counts["chinese"] == counts["japanese"] {
+			return "Chinese"
+		}
+	}
+	return strings.ToUpper(best[:1]) + best[1:]
+}
+
+// latinLanguage guesses the Latin-script language of text from its
+// stopwords, returning it if it is clearly not want.
+func latinLanguage(text, want string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	if len(words) < 8 {
+		return ""
+	}
+	scores := map[string]int{}
+	for _, w := range words {
+		for lang, list := range stopwords {
+			for _, s := range list {
+				if w == s {
+					scores[lang]++
+				}
+			}
+		}
+	}
+	best, n := "", 0
+	for lang, s := range scores {
+		if s > n || (s == n && lang < best) {
+			best, n = lang, s
+		}
+	}
+	if best == "" || best == want || n < 3 || n < 2*scores[want] {
+		return ""
+	}
+	return strings.ToUpper(best[:1]) + best[1:]
+}
@@ -0,0 +1,114 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PackageInstaller is implemented by executors that can install packages
+// into their sandbox.
+type PackageInstaller interface {
+	Install(ctx context.Context, pkgs ...string) error
+}
+
+// PackagePolicy controls which packages a CodeAgent may install. The
+// model requests installs with "!pip install <pkg>" or "%pip install
+// <pkg>" lines in its code; those lines are removed before execution.
+type PackagePolicy struct {
+	// Allowed lists installable package names. "*" allows any package.
+	Allowed []string
+	// Preinstall lists packages installed at the start of every run.
+	Preinstall []string
+}
+
+// WithPackagePolicy lets a CodeAgent install packages within its executor.
+func WithPackagePolicy(p PackagePolicy) AgentOption {
+	return func(a *BaseAgent) { a.packagePolicy = &p }
+}
+
+// allows reports whether pkg may be installed. Version specifiers and
+// extras are ignored when matching.
+func (p *PackagePolicy) allows(pkg string) bool {
+	name := packageName(pkg)
+	for _, allowed := range p.Allowed {
+		if allowed == "*" || strings.EqualFold(packageName(allowed), name) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	pipInstallLine = regexp.MustCompile(`(?m)^[ \t]*[!%]pip3?[ \t]+install[ \t]+(.*)$`)
+	packageNameEnd = regexp.MustCompile(`[\[<>=!~; ]`)
+)
+
+func packageName(spec string) string {
+	if loc := packageNameEnd.FindStringIndex(spec); loc != nil {
+		spec = spec[:loc[0]]
+	}
+	return strings.ToLower(strings.ReplaceAll(spec, "_", "-"))
+}
+
+// extractPipInstalls removes pip install lines from code and returns the
+// remaining code and the requested package specs. Flags such as -q or
+// --upgrade are dropped.
+func extractPipInstalls(code string) (string, []string) {
+	var pkgs []string
+	for _, m := range pipInstallLine.FindAllStringSubmatch(code, -1) {
+		for _, field := range strings.Fields(m[1]) {
+			if !strings.HasPrefix(field, "-") {
+				pkgs = append(pkgs, field)
+			}
+		}
+	}
+	return pipInstallLine.ReplaceAllString(code, ""), pkgs
+}
+
+// packageSession installs packages for one run, caching successful
+// installs so repeated requests are free.
+type packageSession struct {
+	policy    *PackagePolicy
+	installer PackageInstaller
+	installed map[string]bool
+}
+
+func newPackageSession(policy *PackagePolicy, executor CodeExecutor) *packageSession {
+	installer, _ := executor.(PackageInstaller)
+	return &packageSession{policy: policy, installer: installer, installed: make(map[string]bool)}
+}
+
+// install installs the allowed subset of pkgs and returns one observation
+// line per package that was denied or failed.
+func (s *packageSession) install(ctx context.Context, pkgs []string) []string {
+	var notes, todo []string
+	for _, pkg := range pkgs {
+		switch {
+		case s.installed[packageName(pkg)]:
+		case s.policy == nil || !s.policy.allows(pkg):
+			notes = append(notes, fmt.Sprintf("Package installation denied: %s is not in the allowed list.", pkg))
+		case s.installer == nil:
+			notes = append(notes, fmt.Sprintf("Package installation not supported by this executor: %s", pkg))
+		default:
+			todo = append(todo, pkg)
+		}
+	}
+	if len(todo) == 0 {
+		return notes
+	}
+	err := s.installer.Install(ctx, todo...)
+	entry := AuditEntry{Kind: AuditCommand, Source: "pip", Detail: "pip install " + strings.Join(todo, " ")}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	RecordAudit(ctx, entry)
+	if err != nil {
+		return append(notes, fmt.Sprintf("Package installation failed for %s: %v", strings.Join(todo, ", "), err))
+	}
+	for _, pkg := range todo {
+		s.installed[packageName(pkg)] = true
+	}
+	return notes
+}
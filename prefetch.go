@@ -0,0 +1,120 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// ToolPredictor predicts tool calls the model is likely to make in the
+// next step, given the run's steps so far, which it must not modify.
+type ToolPredictor func(steps []Step) []ToolCall
+
+// WithToolPrefetch makes the agent run the calls p predicts while the
+// model generates each step, and serve their results if the model makes
+// the same calls, with the same arguments, in that step. Results not used
+// by the end of the step are discarded.
+//
+// Predicted calls run before the model makes them, so p should only
+// predict calls without side effects, such as searches and page fetches.
+// Approval set with WithToolApproval still applies before a prefetched
+// result is used.
+func WithToolPrefetch(p ToolPredictor) AgentOption {
+	return func(a *BaseAgent) { a.predictor = p }
+}
+
+type prefetchKey struct{}
+
+// prefetch is a predicted tool call running in the background.
+type prefetch struct {
+	done   chan struct{}
+	step   ActionStep // collects what the tool records
+	result any
+	err    error
+}
+
+// prefetches are the predicted calls of a step, by call key.
+type prefetches struct {
+	mu    sync.Mutex
+	calls map[string]*prefetch
+}
+
+// startPrefetch starts the calls predicted for the next step, returning
+// ctx with them attached for callTool. They run under ctx, so they are
+// canceled with the step.
+func (a *BaseAgent) startPrefetch(ctx context.Context) context.Context {
+	if a.predictor == nil {
+		return ctx
+	}
+	ps := &prefetches{calls: map[string]*prefetch{}}
+	for _, tc := range a.predictor(a.memory.Steps) {
+		key, ok := prefetchCallKey(tc)
+		if !ok || ps.calls[key] != nil || tc.Name == "final_answer" || !a.isAllowed(tc.Name) {
+			continue
+		}
+		tool, ok := a.tools.Get(tc.Name)
+		if !ok {
+			continue
+		}
+		p := &prefetch{done: make(chan struct{})}
+		ps.calls[key] = p
+		go func() {
+			defer close(p.done)
+			pctx := withStepRecorder(ctx, &p.step)
+			if ct, ok := tool.(ContextTool); ok {
+				p.result, p.err = ct.ExecuteContext(pctx, tc.Arguments)
+			} else {
+				p.result, p.err = tool.Execute(tc.Arguments)
+			}
+		}()
+	}
+	if len(ps.calls) == 0 {
+		return ctx
+	}
+	a.logger().Debug("prefetching tool calls", "count", len(ps.calls))
+	recordProfile(ctx, func(p *StepProfile) { p.Prefetched += len(ps.calls) })
+	return context.WithValue(ctx, prefetchKey{}, ps)
+}
+
+// takePrefetch returns the call prefetched for tc, if any, once it has
+// finished. Each prefetched call is used once.
+func (a *BaseAgent) takePrefetch(ctx context.Context, tc ToolCall) *prefetch {
+	ps, _ := ctx.Value(prefetchKey{}).(*prefetches)
+	if ps == nil {
+		return nil
+	}
+	key, ok := prefetchCallKey(tc)
+	if !ok {
+		return nil
+	}
+	ps.mu.Lock()
+	p := ps.calls[key]
+	delete(ps.calls, key)
+	ps.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return &prefetch{err: context.Cause(ctx)}
+	}
+	for _, c := range p.step.Citations {
+		RecordCitation(ctx, c)
+	}
+	for _, art := range p.step.Artifacts {
+		RecordArtifact(ctx, art)
+	}
+	a.logger().Debug("prefetch hit", "tool", tc.Name)
+	recordProfile(ctx, func(p *StepProfile) { p.PrefetchHits++ })
+	return p
+}
+
+// prefetchCallKey identifies a call by its tool and arguments.
+func prefetchCallKey(tc ToolCall) (string, bool) {
+	args, err := json.Marshal(tc.Arguments)
+	if err != nil {
+		return "", false
+	}
+	return tc.Name + string(args), true
+}
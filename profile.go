@@ -0,0 +1,247 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// WithProfiling records a performance profile of each run in
+// RunResult.Profile and writes a report of it to w when the run
+// finishes, with suggestions for making the agent faster and cheaper. A
+// nil w writes to os.Stderr.
+func WithProfiling(w io.Writer) AgentOption {
+	return func(a *BaseAgent) {
+		if w == nil {
+			w = os.Stderr
+		}
+		a.profiling = true
+		a.events.Subscribe(EventRunCompleted, func(e Event) {
+			if r := e.(RunCompletedEvent).Result; r != nil && r.Profile != nil {
+				io.WriteString(w, r.Profile.Report())
+			}
+		})
+	}
+}
+
+// Profile is a run's performance profile.
+type Profile struct {
+	Steps []StepProfile `json:"steps"` // one per action step, in order
+}
+
+// StepProfile is the profile of one action step. Prompt sizes are those
+// of the step's last model call.
+type StepProfile struct {
+	StepNumber     int `json:"step_number"`
+	PromptMessages int `json:"prompt_messages"`
+	PromptChars    int `json:"prompt_chars"`
+	// ObservationChars are the prompt characters repeating observations
+	// of earlier steps.
+	ObservationChars  int             `json:"observation_chars"`
+	InputTokens       int             `json:"input_tokens"`
+	CachedInputTokens int             `json:"cached_input_tokens"`
+	OutputTokens      int             `json:"output_tokens"`
+	ModelCalls        int             `json:"model_calls"`
+	ModelLatencies    []time.Duration `json:"model_latencies"` // one per model call
+	ToolCalls         int             `json:"tool_calls"`
+	ToolErrors        int             `json:"tool_errors"`
+	ToolLatency       time.Duration   `json:"tool_latency"`
+	Candidates        int             `json:"candidates,omitempty"` // actions sampled with WithActionSampling
+	Prefetched        int             `json:"prefetched,omitempty"`
+	PrefetchHits      int             `json:"prefetch_hits,omitempty"`
+	Failed            bool            `json:"failed,omitempty"` // the step ended with an error
+}
+
+// Retries returns the model calls the step made beyond one per sampled
+// candidate, such as the retry in prompted mode after a model rejected
+// native tool calls.
+func (p StepProfile) Retries() int { return max(p.ModelCalls-max(p.Candidates, 1), 0) }
+
+type profileKey struct{}
+
+// profiler collects a run's step profiles. Tools bridged into executed
+// code may record from other goroutines.
+type profiler struct {
+	mu    sync.Mutex
+	steps map[int]*StepProfile
+}
+
+// recordProfile applies fn to the profile of the action step ctx belongs
+// to, if the run is profiled.
+func recordProfile(ctx context.Context, fn func(*StepProfile)) {
+	p, ok := ctx.Value(profileKey{}).(*profiler)
+	if !ok {
+		return
+	}
+	r, ok := ctx.Value(stepKey{}).(*stepRecorder)
+	if !ok {
+		return
+	}
+	n := r.step.StepNumber
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sp := p.steps[n]
+	if sp == nil {
+		sp = &StepProfile{StepNumber: n}
+		p.steps[n] = sp
+	}
+	fn(sp)
+}
+
+// recordModelCall profiles a model call made with msgs.
+func (a *BaseAgent) recordModelCall(ctx context.Context, msgs []Message, resp *Message, elapsed time.Duration) {
+	if ctx.Value(profileKey{}) == nil {
+		return
+	}
+	var chars, obs int
+	for _, m := range msgs {
+		chars += len(m.Content)
+	}
+	for _, s := range a.memory.ActionSteps() {
+		obs += len(s.Observations)
+	}
+	recordProfile(ctx, func(p *StepProfile) {
+		p.PromptMessages, p.PromptChars, p.ObservationChars = len(msgs), chars, obs
+		p.ModelCalls++
+		p.ModelLatencies = append(p.ModelLatencies, elapsed)
+		if resp != nil && resp.TokenUsage != nil {
+			p.InputTokens += resp.TokenUsage.InputTokens
+			p.CachedInputTokens += resp.TokenUsage.CachedInputTokens
+			p.OutputTokens += resp.TokenUsage.OutputTokens
+		}
+	})
+}
+
+// profile returns the run's profile, or nil if it is not profiled.
+func (a *BaseAgent) profile(ctx context.Context) *Profile {
+	p, ok := ctx.Value(profileKey{}).(*profiler)
+	if !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := &Profile{}
+	for _, s := range a.memory.ActionSteps() {
+		sp := StepProfile{StepNumber: s.StepNumber}
+		if recorded := p.steps[s.StepNumber]; recorded != nil {
+			sp = *recorded
+		}
+		sp.Failed = s.Error != nil
+		out.Steps = append(out.Steps, sp)
+	}
+	return out
+}
+
+// Report renders the profile as a table of steps followed by suggestions.
+func (p *Profile) Report() string {
+	var sb strings.Builder
+	sb.WriteString("Run profile\n\n")
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "step\tprompt chars\tobservations\tinput tok\tcached\toutput tok\tmodel calls\tmodel time\ttool calls\ttool time\t")
+	for _, s := range p.Steps {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%s\t%d\t%d\t%s\t%d\t%s\t\n",
+			s.StepNumber, s.PromptChars, percent(s.ObservationChars, s.PromptChars), s.InputTokens,
+			percent(s.CachedInputTokens, s.InputTokens), s.OutputTokens, s.ModelCalls,
+			sumDurations(s.ModelLatencies).Round(time.Millisecond), s.ToolCalls, s.ToolLatency.Round(time.Millisecond))
+	}
+	tw.Flush()
+	if tips := p.Suggestions(); len(tips) > 0 {
+		sb.WriteString("\nSuggestions:\n")
+		for _, tip := range tips {
+			sb.WriteString("- " + tip + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Suggestions returns optimizations the profile points to, most
+// significant first.
+func (p *Profile) Suggestions() []string {
+	var t StepProfile
+	var modelTime time.Duration
+	var failed, retries int
+	for _, s := range p.Steps {
+		t.PromptChars += s.PromptChars
+		t.ObservationChars += s.ObservationChars
+		t.InputTokens += s.InputTokens
+		t.CachedInputTokens += s.CachedInputTokens
+		t.ModelCalls += s.ModelCalls
+		t.ToolCalls += s.ToolCalls
+		t.ToolErrors += s.ToolErrors
+		t.ToolLatency += s.ToolLatency
+		t.Prefetched += s.Prefetched
+		t.PrefetchHits += s.PrefetchHits
+		modelTime += sumDurations(s.ModelLatencies)
+		retries += s.Retries()
+		if s.Failed {
+			failed++
+		}
+	}
+
+	type tip struct {
+		weight float64
+		text   string
+	}
+	var tips []tip
+	add := func(weight float64, format string, args ...any) {
+		tips = append(tips, tip{weight, fmt.Sprintf(format, args...)})
+	}
+	if t.PromptChars > 0 {
+		if share := float64(t.ObservationChars) / float64(t.PromptChars); share >= 0.4 {
+			add(share, "%s of prompt characters were repeated observations; summarize or truncate long tool outputs.", percent(t.ObservationChars, t.PromptChars))
+		}
+	}
+	if n := len(p.Steps); n >= 2 {
+		first, last := p.Steps[0].PromptChars, p.Steps[n-1].PromptChars
+		if first > 0 && last >= 4*first {
+			add(0.5, "Prompts grew %.0fx over %d steps, from %d to %d characters; consider fewer steps per run or compacting memory.", float64(last)/float64(first), n, first, last)
+		}
+	}
+	if t.CachedInputTokens > 0 && t.CachedInputTokens*2 < t.InputTokens {
+		add(0.5, "Only %s of input tokens were served from the prompt cache; keep the system prompt and early messages unchanged between calls.", percent(t.CachedInputTokens, t.InputTokens))
+	}
+	if retries > 0 {
+		add(float64(retries)/float64(t.ModelCalls), "%d model calls (%s) %s retries after the model rejected native tool calls; enable WithPromptedToolCalls to skip the failed attempts.", retries, percent(retries, t.ModelCalls), pluralVerb(retries))
+	}
+	if failed > 0 {
+		add(float64(failed)/float64(len(p.Steps)), "%d of %d steps failed and had to be retried; check the errors and clarify tool descriptions or the system prompt.", failed, len(p.Steps))
+	}
+	if t.ToolErrors > 0 {
+		add(float64(t.ToolErrors)/float64(t.ToolCalls), "%d of %d tool calls failed.", t.ToolErrors, t.ToolCalls)
+	}
+	if t.Prefetched > 0 && t.PrefetchHits*2 < t.Prefetched {
+		add(0.3, "%d of %d prefetched tool calls were unused; make the predictor more selective.", t.Prefetched-t.PrefetchHits, t.Prefetched)
+	}
+	if total := modelTime + t.ToolLatency; total > 0 {
+		if share := float64(t.ToolLatency) / float64(total); share >= 0.5 && t.Prefetched == 0 {
+			add(share, "Tools took %s of the time spent in model and tool calls; consider WithToolPrefetch or caching tool results.", percent(int(t.ToolLatency), int(total)))
+		}
+	}
+	sort.SliceStable(tips, func(i, j int) bool { return tips[i].weight > tips[j].weight })
+	out := make([]string, len(tips))
+	for i, t := range tips {
+		out[i] = t.text
+	}
+	return out
+}
+
+func percent(part, whole int) string {
+	if whole <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(part)/float64(whole))
+}
+
+func sumDurations(ds []time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range ds {
+		total += d
+	}
+	return total
+}
@@ -0,0 +1,91 @@
+package neko
+
+import "fmt"
+
+// ErrorRecovery configures WithErrorRecovery.
+type ErrorRecovery struct {
+	After int // consecutive failed steps that trigger the recovery; defaults to 3
+	// FallbackModel replaces the agent's model for the rest of the run.
+	FallbackModel Model
+	// Temperature, if set, is used for the rest of the run's model calls,
+	// e.g. a lower one to make a flaky model more predictable.
+	Temperature *float64
+}
+
+// Adaptation is published, and recorded in traces, when WithErrorRecovery
+// changes how a run calls its model.
+type Adaptation struct {
+	StepNumber    int      `json:"step_number"` // the last failed step
+	FailedSteps   int      `json:"failed_steps"`
+	PreviousModel string   `json:"previous_model"`
+	Model         string   `json:"model"` // the model used from the next step
+	Temperature   *float64 `json:"temperature,omitempty"`
+}
+
+func (e *Adaptation) EventType() string { return EventAdaptation }
+
+func (e *Adaptation) String() string {
+	s := fmt.Sprintf("%d consecutive failed steps: switched from %s to %s", e.FailedSteps, e.PreviousModel, e.Model)
+	if e.Model == e.PreviousModel {
+		s = fmt.Sprintf("%d consecutive failed steps: kept %s", e.FailedSteps, e.Model)
+	}
+	if e.Temperature != nil {
+		s += fmt.Sprintf(" at temperature %g", *e.Temperature)
+	}
+	return s
+}
+
+// WithErrorRecovery adapts a run after r.After consecutive failed steps:
+// it switches to r.FallbackModel and sets r.Temperature, whichever are
+// set, for the rest of the run, and publishes an Adaptation. It adapts at
+// most once per run; the next run starts with the agent's own model.
+func WithErrorRecovery(r ErrorRecovery) AgentOption {
+	if r.After <= 0 {
+		r.After = 3
+	}
+	return func(a *BaseAgent) { a.recovery = &r }
+}
+
+// recoveryState tracks a run's failed steps and any adaptation made.
+type recoveryState struct {
+	failures    int
+	adapted     bool
+	model       Model // the agent's own model, restored after the run
+	temperature *float64
+}
+
+// checkRecovery counts step's failure and adapts the run if the recovery
+// threshold is reached.
+func (a *BaseAgent) checkRecovery(step *ActionStep) {
+	r, s := a.recovery, &a.recoveryState
+	if r == nil || s.adapted {
+		return
+	}
+	if step.Error == nil {
+		s.failures = 0
+		return
+	}
+	if s.failures++; s.failures < r.After {
+		return
+	}
+	s.adapted = true
+	e := &Adaptation{StepNumber: step.StepNumber, FailedSteps: s.failures, PreviousModel: a.model.ModelID(), Temperature: r.Temperature}
+	if r.FallbackModel != nil && !a.modelAllowed(r.FallbackModel) {
+		a.logger().Warn("fallback model not allowed in this run", "model", r.FallbackModel.ModelID())
+	} else if r.FallbackModel != nil {
+		s.model = a.model
+		a.model = r.FallbackModel
+	}
+	s.temperature = r.Temperature
+	e.Model = a.model.ModelID()
+	a.logger().Warn("adapting run after failed steps", "step", step.StepNumber, "adaptation", e.String())
+	a.events.Publish(e)
+}
+
+// endRecovery restores the agent's own model after a run.
+func (a *BaseAgent) endRecovery() {
+	if a.recoveryState.model != nil {
+		a.model = a.recoveryState.model
+	}
+	a.recoveryState = recoveryState{}
+}
@@ -0,0 +1,275 @@
+package neko
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector finds sensitive data in text. Implement it to plug custom
+// detection, e.g. an NER model for names, into a Redactor.
+type Detector interface {
+	// Kind names what the detector finds; it appears in the replacement.
+	Kind() string
+	// FindAll returns the byte ranges of each match as [start, end) pairs,
+	// like regexp.FindAllStringIndex.
+	FindAll(text string) [][]int
+}
+
+// NewRegexpDetector creates a detector reporting the matches of re.
+func NewRegexpDetector(kind string, re *regexp.Regexp) Detector {
+	return &regexpDetector{kind: kind, re: re}
+}
+
+type regexpDetector struct {
+	kind  string
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+func (d *regexpDetector) Kind() string { return d.kind }
+
+func (d *regexpDetector) FindAll(text string) [][]int {
+	matches := d.re.FindAllStringIndex(text, -1)
+	if d.valid == nil {
+		return matches
+	}
+	var out [][]int
+	for _, m := range matches {
+		if d.valid(text[m[0]:m[1]]) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	apiKeyPattern     = regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{16,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{30,}|xox[abposr]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})\b|(?i:bearer)\s+[A-Za-z0-9._~+/-]{16,}=*`)
+	creditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	phonePattern      = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b`)
+	ipv4Pattern       = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+)
+
+// EmailDetector finds email addresses.
+func EmailDetector() Detector { return NewRegexpDetector("email", emailPattern) }
+
+// APIKeyDetector finds common API key and token formats (OpenAI, AWS,
+// GitHub, Slack, Google) and bearer tokens.
+func APIKeyDetector() Detector { return NewRegexpDetector("api_key", apiKeyPattern) }
+
+// CreditCardDetector finds card numbers that pass the Luhn check.
+func CreditCardDetector() Detector {
+	return &regexpDetector{kind: "credit_card", re: creditCardPattern, valid: luhnValid}
+}
+
+// PhoneDetector finds North American style phone numbers, with optional
+// country code.
+func PhoneDetector() Detector { return NewRegexpDetector("phone", phonePattern) }
+
+// IPAddressDetector finds IPv4 addresses.
+func IPAddressDetector() Detector { return NewRegexpDetector("ip_address", ipv4Pattern) }
+
+// NameDetector finds the given names, or other known words, as whole words
+// regardless of case.
+func NameDetector(names ...string) Detector {
+	quoted := make([]string, 0, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			quoted = append(quoted, regexp.QuoteMeta(n))
+		}
+	}
+	if len(quoted) == 0 {
+		return NewRegexpDetector("name", regexp.MustCompile(`[^\s\S]`))
+	}
+	return NewRegexpDetector("name", regexp.MustCompile(`(?i)\b(?:`+strings.Join(quoted, "|")+`)\b`))
+}
+
+// DefaultDetectors returns the detectors used by NewRedactor when none
+// are given: emails, API keys, credit cards, phone numbers and IP
+// addresses.
+func DefaultDetectors() []Detector {
+	return []Detector{EmailDetector(), APIKeyDetector(), CreditCardDetector(), PhoneDetector(), IPAddressDetector()}
+}
+
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// Redactor replaces sensitive data found by its detectors with
+// "[REDACTED:<kind>]". It is safe for concurrent use.
+type Redactor struct {
+	detectors []Detector
+}
+
+// NewRedactor creates a redactor using detectors, or DefaultDetectors if
+// none are given.
+func NewRedactor(detectors ...Detector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+	return &Redactor{detectors: detectors}
+}
+
+type redaction struct {
+	start, end int
+	kind       string
+}
+
+// Redact returns text with every detected match replaced. Overlapping
+// matches are merged and labelled with the first detector's kind.
+func (r *Redactor) Redact(text string) string {
+	var found []redaction
+	for _, d := range r.detectors {
+		for _, m := range d.FindAll(text) {
+			found = append(found, redaction{m[0], m[1], d.Kind()})
+		}
+	}
+	if len(found) == 0 {
+		return text
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].start < found[j].start })
+	var sb strings.Builder
+	pos := 0
+	for i := 0; i < len(found); {
+		cur := found[i]
+		for i++; i < len(found) && found[i].start < cur.end; i++ {
+			cur.end = max(cur.end, found[i].end)
+		}
+		sb.WriteString(text[pos:cur.start])
+		sb.WriteString("[REDACTED:" + cur.kind + "]")
+		pos = cur.end
+	}
+	sb.WriteString(text[pos:])
+	return sb.String()
+}
+
+// RedactJSON redacts every string value in a JSON document, keeping its
+// structure, so redacted traces and events decode as before. Object keys
+// and numbers are left as is.
+func (r *Redactor) RedactJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.redactValue(v))
+}
+
+func (r *Redactor) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case []any:
+		for i := range v {
+			v[i] = r.redactValue(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = r.redactValue(v[k])
+		}
+	}
+	return v
+}
+
+// RedactTrace copies a JSONL trace from src to dst with every string
+// value redacted, e.g. before sharing a trace written without
+// WithTraceRedactor.
+func (r *Redactor) RedactTrace(dst io.Writer, src io.Reader) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		data, err := r.RedactJSON(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("trace line %d: %w", line, err)
+		}
+		if _, err := dst.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// NewRedactingHandler wraps h so that log messages and string attribute
+// values are redacted before they are handled. Use it with WithLogger to
+// keep sensitive data out of agent logs.
+func NewRedactingHandler(h slog.Handler, r *Redactor) slog.Handler {
+	return &redactingHandler{next: h, redactor: r}
+}
+
+type redactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.redactor.Redact(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.Redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]any, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, h.redactor.Redact(err.Error()))
+		}
+		return slog.String(a.Key, h.redactor.Redact(fmt.Sprint(v.Any())))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,150 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Replay is a recorded run loaded from a trace, for stepping through its
+// steps and re-executing it from any action step with edits applied.
+type Replay struct {
+	Result *RunResult
+	Memory *Memory
+	Err    error // the recorded run's error, if it failed
+}
+
+// LoadReplay reads a JSONL trace written by WithTraceWriter. Like
+// LoadTrace, it loads the trace's last run.
+func LoadReplay(r io.Reader) (*Replay, error) {
+	result, memory, err := LoadTrace(r)
+	if memory == nil {
+		return nil, err
+	}
+	return &Replay{Result: result, Memory: memory, Err: err}, nil
+}
+
+// Task returns the recorded task, or "" if the trace has none.
+func (r *Replay) Task() string {
+	return memoryTask(r.Memory)
+}
+
+func memoryTask(m *Memory) string {
+	for _, s := range m.Steps {
+		if t, ok := s.(*TaskStep); ok {
+			return t.Task
+		}
+	}
+	return ""
+}
+
+// ActionStep returns the recorded action step numbered n, or nil.
+func (r *Replay) ActionStep(n int) *ActionStep {
+	for _, s := range r.Memory.ActionSteps() {
+		if s.StepNumber == n {
+			return s
+		}
+	}
+	return nil
+}
+
+// Print writes the recorded run to w as WithConsoleOutput would have
+// while it ran.
+func (r *Replay) Print(w io.Writer) {
+	c := newConsoleReporter(w)
+	for _, s := range r.Memory.Steps {
+		c.report(s)
+	}
+}
+
+// PrintStep writes action step n to w.
+func (r *Replay) PrintStep(w io.Writer, n int) error {
+	s := r.ActionStep(n)
+	if s == nil {
+		return fmt.Errorf("trace has no action step %d", n)
+	}
+	newConsoleReporter(w).report(s)
+	return nil
+}
+
+// ReplayOption edits a recorded run before it is re-executed.
+type ReplayOption func(m *Memory, from int) error
+
+// WithReplayTask replaces the task the run was given.
+func WithReplayTask(task string) ReplayOption {
+	return func(m *Memory, _ int) error {
+		for i, s := range m.Steps {
+			if t, ok := s.(*TaskStep); ok {
+				c := *t
+				c.Task = task
+				m.Steps[i] = &c
+				return nil
+			}
+		}
+		return fmt.Errorf("trace has no task step")
+	}
+}
+
+// WithReplaySystemPrompt replaces the system prompt the run was given.
+func WithReplaySystemPrompt(prompt string) ReplayOption {
+	return func(m *Memory, _ int) error {
+		m.SystemPrompt = prompt
+		return nil
+	}
+}
+
+// WithReplayObservation replaces the observations, i.e. the tool results
+// or execution output, the model saw after action step n. Step n must come
+// before the step the replay starts from.
+func WithReplayObservation(n int, observations string) ReplayOption {
+	return func(m *Memory, from int) error {
+		if n >= from {
+			return fmt.Errorf("step %d is re-executed, its observations cannot be replaced", n)
+		}
+		for i, s := range m.Steps {
+			if a, ok := s.(*ActionStep); ok && a.StepNumber == n {
+				c := *a
+				c.Observations = observations
+				c.Error = nil
+				m.Steps[i] = &c
+				return nil
+			}
+		}
+		return fmt.Errorf("trace has no action step %d", n)
+	}
+}
+
+// Fork returns the run's memory as it was before action step from, with
+// opts applied. The recorded memory is not modified.
+func (r *Replay) Fork(from int, opts ...ReplayOption) (*Memory, error) {
+	if r.ActionStep(from) == nil {
+		return nil, fmt.Errorf("trace has no action step %d", from)
+	}
+	m := NewMemory(r.Memory.SystemPrompt)
+	for _, s := range r.Memory.Steps {
+		if a, ok := s.(*ActionStep); ok && a.StepNumber >= from {
+			break
+		}
+		m.AddStep(s)
+	}
+	for _, opt := range opts {
+		if err := opt(m, from); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Rerun re-executes the run on agent from action step from, with opts
+// applied to the steps before it. The agent should be configured like the
+// recorded one, with the same tools, and must honor WithResume as
+// ToolCallingAgent and CodeAgent do. The recorded system prompt is used
+// unless replaced. To pass other run options, call Fork and run the agent
+// with WithResume directly.
+func (r *Replay) Rerun(ctx context.Context, agent Agent, from int, opts ...ReplayOption) (*RunResult, error) {
+	m, err := r.Fork(from, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return agent.Run(ctx, memoryTask(m), WithResume(m))
+}
@@ -0,0 +1,132 @@
+package neko
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// ToHTML renders the run as a self-contained HTML report: the task, any
+// plans, each step's thought, code, tool calls, observations and images,
+// and a token, cost and timing summary. Images are inlined as data URIs so
+// the file can be shared on its own.
+func (r *RunResult) ToHTML() string {
+	var sb strings.Builder
+	if err := reportTemplate.Execute(&sb, r); err != nil {
+		return fmt.Sprintf("<!-- failed to render report: %s -->", template.HTMLEscapeString(err.Error()))
+	}
+	return sb.String()
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"thought":   consoleThought,
+	"arguments": formatArguments,
+	"imageURI":  imageURI,
+	"isImage":   func(a Artifact) bool { return len(a.Data) > 0 && strings.HasPrefix(artifactMIMEType(a), "image/") },
+	"seconds":   func(t Timing) string { return fmt.Sprintf("%.2fs", t.Duration.Seconds()) },
+	"cost":      func(c float64) string { return fmt.Sprintf("$%.4f", c) },
+	"value":     func(v any) string { return fmt.Sprintf("%v", v) },
+}).Parse(reportHTML))
+
+// imageURI encodes image bytes as a data URI.
+func imageURI(data []byte) template.URL {
+	return template.URL("data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+func artifactMIMEType(a Artifact) string {
+	if a.MIMEType != "" {
+		return a.MIMEType
+	}
+	return http.DetectContentType(a.Data)
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Agent run report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+h1 { font-size: 1.5em; }
+section { border: 1px solid #d0d7de; border-radius: 6px; margin: 1em 0; padding: 0.5em 1em; }
+section h2 { font-size: 1.1em; margin: 0.5em 0; }
+pre { background: #f6f8fa; padding: 0.75em; border-radius: 6px; overflow-x: auto; white-space: pre-wrap; }
+.thought { color: #59636e; font-style: italic; white-space: pre-wrap; }
+.error pre { background: #ffebe9; }
+.final { border-color: #1a7f37; }
+.meta { color: #59636e; font-size: 0.9em; }
+img { max-width: 100%; border: 1px solid #d0d7de; margin: 0.5em 0; }
+table { border-collapse: collapse; }
+td, th { padding: 0.25em 1em 0.25em 0; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Agent run report</h1>
+<table class="summary">
+<tr><th>State</th><td>{{.State}}</td></tr>
+<tr><th>Steps</th><td>{{len .Steps}}</td></tr>
+<tr><th>Duration</th><td>{{seconds .Timing}}</td></tr>
+{{- with .TokenUsage}}
+<tr><th>Tokens</th><td>{{.Total}} (input {{.InputTokens}}, output {{.OutputTokens}})</td></tr>
+{{- end}}
+{{- if .Cost}}
+<tr><th>Cost</th><td>{{cost .Cost}}</td></tr>
+{{- end}}
+</table>
+{{range .Steps}}
+{{- if eq .StepType "task"}}
+<section>
+<h2>Task</h2>
+<pre>{{.Task}}</pre>
+{{- range .Images}}
+<img src="{{imageURI .}}" alt="task image">
+{{- end}}
+</section>
+{{- else if eq .StepType "planning"}}
+<section>
+<h2>Plan</h2>
+<pre>{{.Plan}}</pre>
+<p class="meta">{{seconds .Timing}}{{with .TokenUsage}} · {{.InputTokens}} input / {{.OutputTokens}} output tokens{{end}}</p>
+</section>
+{{- else if eq .StepType "action"}}
+<section{{if .IsFinal}} class="final"{{end}}>
+<h2>Step {{.StepNumber}}</h2>
+{{- with thought .ModelOutput}}
+<p class="thought">{{.}}</p>
+{{- end}}
+{{- with .CodeAction}}
+<h3>Code</h3>
+<pre>{{.}}</pre>
+{{- end}}
+{{- range .ToolCalls}}
+<h3>Tool call: {{.Name}}</h3>
+<pre>{{arguments .Arguments}}</pre>
+{{- end}}
+{{- with .Observations}}
+<h3>Observations</h3>
+<pre>{{.}}</pre>
+{{- end}}
+{{- range .Artifacts}}
+{{- if isImage .}}
+<img src="{{imageURI .Data}}" alt="{{.Name}}">
+{{- else}}
+<p class="meta">Artifact: {{.Name}}{{with .Path}} ({{.}}){{end}}</p>
+{{- end}}
+{{- end}}
+{{- with .Error}}
+<div class="error"><h3>Error</h3><pre>{{.}}</pre></div>
+{{- end}}
+<p class="meta">{{seconds .Timing}}{{with .TokenUsage}} · {{.InputTokens}} input / {{.OutputTokens}} output tokens{{end}}</p>
+</section>
+{{- else if eq .StepType "final_answer"}}
+<section class="final">
+<h2>Final answer</h2>
+<pre>{{value .Output}}</pre>
+</section>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`
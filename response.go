@@ -0,0 +1,124 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Strategies for reducing tool responses over a ResponsePolicy's limit.
+const (
+	// ReduceTruncate keeps the start of the response.
+	ReduceTruncate = "truncate"
+	// ReduceSummarize replaces the response with a summary written by a
+	// model, falling back to truncation if the model fails.
+	ReduceSummarize = "summarize"
+	// ReduceArtifact records the full response as an artifact, and writes
+	// it to the run's workspace if there is one, and keeps the start of
+	// the response with a note saying where the rest is.
+	ReduceArtifact = "artifact"
+)
+
+// ResponsePolicy limits how much of a tool's response enters memory as
+// an observation.
+type ResponsePolicy struct {
+	MaxBytes int    // responses up to this size are kept as they are
+	Strategy string // ReduceTruncate, the default, ReduceSummarize or ReduceArtifact
+	// Model writes summaries for ReduceSummarize, e.g. a cheap model.
+	// Defaults to the agent's model.
+	Model Model
+}
+
+// WithResponsePolicy applies p to the responses of the named tool, or of
+// every tool without a policy of its own if tool is "*". Policies are
+// applied to a ToolCallingAgent's observations; a CodeAgent's tools
+// return their results to its code, which prints what it needs.
+func WithResponsePolicy(tool string, p ResponsePolicy) AgentOption {
+	return func(a *BaseAgent) {
+		if a.responsePolicies == nil {
+			a.responsePolicies = make(map[string]ResponsePolicy)
+		}
+		a.responsePolicies[tool] = p
+	}
+}
+
+// reduceResponse applies the response policy of tc's tool to its
+// response, the index-th of the step's calls.
+func (a *BaseAgent) reduceResponse(ctx context.Context, tc ToolCall, index int, response string) string {
+	p, ok := a.responsePolicies[tc.Name]
+	if !ok {
+		p, ok = a.responsePolicies["*"]
+	}
+	if !ok || tc.Name == "final_answer" || p.MaxBytes <= 0 || len(response) <= p.MaxBytes {
+		return response
+	}
+	switch p.Strategy {
+	case ReduceSummarize:
+		summary, err := a.summarizeResponse(ctx, tc, p, response)
+		if err == nil {
+			return summary
+		}
+		a.logger().Warn("summarizing tool response failed; truncating", "tool", tc.Name, "error", err)
+	case ReduceArtifact:
+		return a.storeResponse(ctx, tc, index, p, response)
+	}
+	kept := truncateBytes(response, p.MaxBytes)
+	return kept + fmt.Sprintf("\n...(truncated, %d of %d bytes shown)", len(kept), len(response))
+}
+
+// summarizeResponse asks the policy's model for a summary of response.
+func (a *BaseAgent) summarizeResponse(ctx context.Context, tc ToolCall, p ResponsePolicy, response string) (string, error) {
+	model := p.Model
+	if model == nil {
+		model = a.model
+	}
+	prompt := fmt.Sprintf(`Summarize the following output of the tool %s in at most %d bytes.
+Keep every fact, number, name, date and URL that could answer a question about it; drop boilerplate.
+Reply with the summary only.
+
+%s`, tc.Name, p.MaxBytes, WrapUntrusted(tc.Name, response))
+	if err := checkModel(ctx, model); err != nil {
+		return "", err
+	}
+	resp, err := model.Generate(ctx, []Message{{Role: RoleUser, Content: prompt}})
+	if err != nil {
+		return "", err
+	}
+	if resp.TokenUsage != nil {
+		RecordTokenUsage(context.WithValue(ctx, toolNameKey{}, tc.Name), *resp.TokenUsage)
+	}
+	return fmt.Sprintf("Summary of %d bytes of output:\n%s", len(response), truncateBytes(resp.Content, p.MaxBytes)), nil
+}
+
+// storeResponse records response as an artifact and returns its start
+// with a link to the rest.
+func (a *BaseAgent) storeResponse(ctx context.Context, tc ToolCall, index int, p ResponsePolicy, response string) string {
+	step := 0
+	if r, ok := ctx.Value(stepKey{}).(*stepRecorder); ok {
+		step = r.step.StepNumber
+	}
+	name := fmt.Sprintf("%s-step%d-%d.txt", tc.Name, step, index+1)
+	path := "responses/" + name
+	RecordArtifact(ctx, Artifact{Name: name, Path: path, MIMEType: "text/plain; charset=utf-8", Data: []byte(response)})
+	where := fmt.Sprintf("the run's artifact %s", name)
+	if w := WorkspaceFromContext(ctx); w != nil {
+		if err := w.WriteFile(path, []byte(response)); err == nil {
+			where = fmt.Sprintf("the workspace file %s", path)
+		} else {
+			a.logger().Warn("storing tool response in workspace failed", "tool", tc.Name, "error", err)
+		}
+	}
+	kept := truncateBytes(response, p.MaxBytes)
+	return kept + fmt.Sprintf("\n...(%d of %d bytes shown; the full output is in %s)", len(kept), len(response), where)
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a character.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,554 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/rpc/nekopb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RunResultToProto converts a run result, with its steps, to its protobuf
+// form. Outputs and tool arguments convert as they do to JSON.
+func RunResultToProto(r *neko.RunResult) (*nekopb.RunResult, error) {
+	steps, err := stepsToProto(r.Steps)
+	if err != nil {
+		return nil, err
+	}
+	out := &nekopb.RunResult{
+		Output:         toValue(r.Output),
+		State:          r.State,
+		Steps:          steps,
+		TokenUsage:     toTokenUsage(r.TokenUsage),
+		Cost:           r.Cost,
+		DurationMs:     r.Timing.Duration.Milliseconds(),
+		Artifacts:      toArtifacts(r.Artifacts),
+		Citations:      toCitations(r.Citations),
+		Timing:         toTiming(r.Timing),
+		Latency:        toLatency(r.Latency),
+		UsageBreakdown: toUsageBreakdown(r.UsageBreakdown),
+		Profile:        toProfile(r.Profile),
+		Confidence:     r.Confidence,
+		Abstained:      r.Abstained,
+	}
+	for _, e := range r.Audit {
+		out.Audit = append(out.Audit, &nekopb.AuditEntry{Time: timestamppb.New(e.Time), Kind: e.Kind, Source: e.Source, Detail: e.Detail, Error: e.Error})
+	}
+	return out, nil
+}
+
+// RunResultFromProto converts a run result from its protobuf form.
+func RunResultFromProto(r *nekopb.RunResult) (*neko.RunResult, error) {
+	steps, err := stepsFromProto(r.GetSteps())
+	if err != nil {
+		return nil, err
+	}
+	out := &neko.RunResult{
+		Output:         fromValue(r.GetOutput()),
+		State:          r.GetState(),
+		Steps:          steps,
+		Artifacts:      fromArtifacts(r.GetArtifacts()),
+		Citations:      fromCitations(r.GetCitations()),
+		TokenUsage:     fromTokenUsage(r.GetTokenUsage()),
+		Cost:           r.GetCost(),
+		Latency:        fromLatency(r.GetLatency()),
+		UsageBreakdown: fromUsageBreakdown(r.GetUsageBreakdown()),
+		Profile:        fromProfile(r.GetProfile()),
+		Confidence:     r.Confidence,
+		Abstained:      r.GetAbstained(),
+		Timing:         fromTiming(r.GetTiming()),
+	}
+	if r.GetTiming() == nil {
+		out.Timing.Duration = time.Duration(r.GetDurationMs()) * time.Millisecond
+	}
+	for _, e := range r.GetAudit() {
+		out.Audit = append(out.Audit, neko.AuditEntry{Time: fromTimestamp(e.GetTime()), Kind: e.GetKind(), Source: e.GetSource(), Detail: e.GetDetail(), Error: e.GetError()})
+	}
+	return out, nil
+}
+
+// MemoryToProto converts an agent's memory to its protobuf form.
+func MemoryToProto(m *neko.Memory) (*nekopb.Memory, error) {
+	steps, err := stepsToProto(m.Steps)
+	if err != nil {
+		return nil, err
+	}
+	return &nekopb.Memory{SystemPrompt: m.SystemPrompt, Steps: steps}, nil
+}
+
+// MemoryFromProto converts memory from its protobuf form, e.g. to resume
+// a run with neko.WithResume.
+func MemoryFromProto(m *nekopb.Memory) (*neko.Memory, error) {
+	steps, err := stepsFromProto(m.GetSteps())
+	if err != nil {
+		return nil, err
+	}
+	out := neko.NewMemory(m.GetSystemPrompt())
+	out.Steps = steps
+	return out, nil
+}
+
+// MessageToProto converts a chat message to its protobuf form.
+func MessageToProto(m neko.Message) *nekopb.Message {
+	return &nekopb.Message{
+		Role:       string(m.Role),
+		Content:    m.Content,
+		ToolCalls:  toToolCalls(m.ToolCalls),
+		TokenUsage: toTokenUsage(m.TokenUsage),
+		Images:     m.Images,
+		Reasoning:  m.Reasoning,
+	}
+}
+
+// MessageFromProto converts a chat message from its protobuf form.
+func MessageFromProto(m *nekopb.Message) neko.Message {
+	return neko.Message{
+		Role:       neko.MessageRole(m.GetRole()),
+		Content:    m.GetContent(),
+		ToolCalls:  fromToolCalls(m.GetToolCalls()),
+		TokenUsage: fromTokenUsage(m.GetTokenUsage()),
+		Images:     m.GetImages(),
+		Reasoning:  m.GetReasoning(),
+	}
+}
+
+// StepToProto converts a step to its protobuf form. Steps of types
+// registered with neko.RegisterStepType are carried as JSON.
+func StepToProto(step neko.Step) (*nekopb.Step, error) {
+	out := &nekopb.Step{Type: step.StepType()}
+	switch s := step.(type) {
+	case *neko.TaskStep:
+		out.Task = s.Task
+		out.Images = s.Images
+		for _, f := range s.Files {
+			out.Files = append(out.Files, &nekopb.Attachment{Name: f.Name, Path: f.Path, Size: f.Size, MimeType: f.MIMEType, Preview: f.Preview})
+		}
+	case *neko.PlanningStep:
+		out.Plan = s.Plan
+		out.TokenUsage = toTokenUsage(s.TokenUsage)
+		out.DurationMs = s.Timing.Duration.Milliseconds()
+		out.Timing = toTiming(s.Timing)
+	case *neko.ActionStep:
+		out.StepNumber = int32(s.StepNumber)
+		out.ModelOutput = s.ModelOutput
+		out.Reasoning = s.Reasoning
+		out.CodeAction = s.CodeAction
+		out.ToolCalls = toToolCalls(s.ToolCalls)
+		out.Observations = s.Observations
+		out.IsFinal = s.IsFinal
+		out.TokenUsage = toTokenUsage(s.TokenUsage)
+		out.DurationMs = s.Timing.Duration.Milliseconds()
+		out.Timing = toTiming(s.Timing)
+		out.Artifacts = toArtifacts(s.Artifacts)
+		out.Citations = toCitations(s.Citations)
+		out.ObservationImages = s.ObservationImages
+		out.Latency = toStepLatency(s.Latency)
+		if s.Error != nil {
+			out.Error = s.Error.Error()
+		}
+		for _, c := range s.Candidates {
+			out.Candidates = append(out.Candidates, &nekopb.Candidate{
+				ModelOutput: c.ModelOutput, ToolCalls: toToolCalls(c.ToolCalls), Code: c.Code,
+				Score: c.Score, Error: c.Error, Selected: c.Selected,
+			})
+		}
+		out.AgentUsage = toUsageMap(s.AgentUsage)
+		if s.ToolUsage != nil {
+			out.ToolUsage = make(map[string]*nekopb.TokenUsage, len(s.ToolUsage))
+			for name, u := range s.ToolUsage {
+				out.ToolUsage[name] = toTokenUsage(&u)
+			}
+		}
+	case *neko.FinalAnswerStep:
+		out.Output = toValue(s.Output)
+	default:
+		data, err := neko.MarshalStep(step)
+		if err != nil {
+			return nil, fmt.Errorf("%s step: %w", step.StepType(), err)
+		}
+		out.Json = data
+	}
+	return out, nil
+}
+
+// StepFromProto converts a step from its protobuf form.
+func StepFromProto(step *nekopb.Step) (neko.Step, error) {
+	switch step.GetType() {
+	case "task":
+		s := &neko.TaskStep{Task: step.GetTask(), Images: step.GetImages()}
+		for _, f := range step.GetFiles() {
+			s.Files = append(s.Files, neko.Attachment{Name: f.GetName(), Path: f.GetPath(), Size: f.GetSize(), MIMEType: f.GetMimeType(), Preview: f.GetPreview()})
+		}
+		return s, nil
+	case "planning":
+		return &neko.PlanningStep{Plan: step.GetPlan(), Timing: stepTiming(step), TokenUsage: fromTokenUsage(step.GetTokenUsage())}, nil
+	case "action":
+		s := &neko.ActionStep{
+			StepNumber:        int(step.GetStepNumber()),
+			Timing:            stepTiming(step),
+			ModelOutput:       step.GetModelOutput(),
+			Reasoning:         step.GetReasoning(),
+			CodeAction:        step.GetCodeAction(),
+			ToolCalls:         fromToolCalls(step.GetToolCalls()),
+			Observations:      step.GetObservations(),
+			Artifacts:         fromArtifacts(step.GetArtifacts()),
+			Citations:         fromCitations(step.GetCitations()),
+			ObservationImages: step.GetObservationImages(),
+			TokenUsage:        fromTokenUsage(step.GetTokenUsage()),
+			Latency:           fromStepLatency(step.GetLatency()),
+			IsFinal:           step.GetIsFinal(),
+		}
+		if step.GetError() != "" {
+			s.Error = errors.New(step.GetError())
+		}
+		for _, c := range step.GetCandidates() {
+			s.Candidates = append(s.Candidates, neko.Candidate{
+				ModelOutput: c.GetModelOutput(), ToolCalls: fromToolCalls(c.GetToolCalls()), Code: c.GetCode(),
+				Score: c.GetScore(), Error: c.GetError(), Selected: c.GetSelected(),
+			})
+		}
+		s.AgentUsage = fromUsageMap(step.GetAgentUsage())
+		if step.ToolUsage != nil {
+			s.ToolUsage = make(map[string]neko.TokenUsage, len(step.ToolUsage))
+			for name, u := range step.ToolUsage {
+				s.ToolUsage[name] = *fromTokenUsage(u)
+			}
+		}
+		return s, nil
+	case "final_answer":
+		return &neko.FinalAnswerStep{Output: fromValue(step.GetOutput())}, nil
+	}
+	if len(step.GetJson()) == 0 {
+		return nil, fmt.Errorf("%s step has no JSON encoding", step.GetType())
+	}
+	return neko.UnmarshalStep(step.GetJson())
+}
+
+func stepsToProto(steps []neko.Step) ([]*nekopb.Step, error) {
+	var out []*nekopb.Step
+	for i, s := range steps {
+		ps, err := StepToProto(s)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		out = append(out, ps)
+	}
+	return out, nil
+}
+
+func stepsFromProto(steps []*nekopb.Step) ([]neko.Step, error) {
+	var out []neko.Step
+	for i, ps := range steps {
+		s, err := StepFromProto(ps)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// stepTiming returns a step's timing, or for steps without one, as sent
+// by older servers, its duration.
+func stepTiming(step *nekopb.Step) neko.Timing {
+	if step.GetTiming() == nil {
+		return neko.Timing{Duration: time.Duration(step.GetDurationMs()) * time.Millisecond}
+	}
+	return fromTiming(step.GetTiming())
+}
+
+func toTiming(t neko.Timing) *nekopb.Timing {
+	return &nekopb.Timing{StartTime: timestamppb.New(t.StartTime), EndTime: timestamppb.New(t.EndTime), Duration: durationpb.New(t.Duration)}
+}
+
+func fromTiming(t *nekopb.Timing) neko.Timing {
+	if t == nil {
+		return neko.Timing{}
+	}
+	return neko.Timing{StartTime: fromTimestamp(t.GetStartTime()), EndTime: fromTimestamp(t.GetEndTime()), Duration: t.GetDuration().AsDuration()}
+}
+
+// fromTimestamp converts a timestamp, keeping a missing one as the zero
+// time rather than the Unix epoch.
+func fromTimestamp(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func toToolCalls(calls []neko.ToolCall) []*nekopb.ToolCall {
+	var out []*nekopb.ToolCall
+	for _, tc := range calls {
+		out = append(out, &nekopb.ToolCall{Id: tc.ID, Name: tc.Name, Arguments: toStruct(tc.Arguments)})
+	}
+	return out
+}
+
+func fromToolCalls(calls []*nekopb.ToolCall) []neko.ToolCall {
+	var out []neko.ToolCall
+	for _, tc := range calls {
+		call := neko.ToolCall{ID: tc.GetId(), Name: tc.GetName()}
+		if tc.GetArguments() != nil {
+			call.Arguments = tc.GetArguments().AsMap()
+		}
+		out = append(out, call)
+	}
+	return out
+}
+
+func toTokenUsage(u *neko.TokenUsage) *nekopb.TokenUsage {
+	if u == nil {
+		return nil
+	}
+	return &nekopb.TokenUsage{
+		InputTokens:       int64(u.InputTokens),
+		OutputTokens:      int64(u.OutputTokens),
+		CachedInputTokens: int64(u.CachedInputTokens),
+	}
+}
+
+func fromTokenUsage(u *nekopb.TokenUsage) *neko.TokenUsage {
+	if u == nil {
+		return nil
+	}
+	return &neko.TokenUsage{
+		InputTokens:       int(u.GetInputTokens()),
+		OutputTokens:      int(u.GetOutputTokens()),
+		CachedInputTokens: int(u.GetCachedInputTokens()),
+	}
+}
+
+func toUsage(u neko.Usage) *nekopb.Usage {
+	return &nekopb.Usage{
+		InputTokens:       int64(u.InputTokens),
+		OutputTokens:      int64(u.OutputTokens),
+		CachedInputTokens: int64(u.CachedInputTokens),
+		Cost:              u.Cost,
+	}
+}
+
+func fromUsage(u *nekopb.Usage) neko.Usage {
+	return neko.Usage{
+		TokenUsage: neko.TokenUsage{
+			InputTokens:       int(u.GetInputTokens()),
+			OutputTokens:      int(u.GetOutputTokens()),
+			CachedInputTokens: int(u.GetCachedInputTokens()),
+		},
+		Cost: u.GetCost(),
+	}
+}
+
+func toUsageMap(m map[string]neko.Usage) map[string]*nekopb.Usage {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]*nekopb.Usage, len(m))
+	for name, u := range m {
+		out[name] = toUsage(u)
+	}
+	return out
+}
+
+func fromUsageMap(m map[string]*nekopb.Usage) map[string]neko.Usage {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]neko.Usage, len(m))
+	for name, u := range m {
+		out[name] = fromUsage(u)
+	}
+	return out
+}
+
+func toUsageBreakdown(b *neko.UsageBreakdown) *nekopb.UsageBreakdown {
+	if b == nil {
+		return nil
+	}
+	return &nekopb.UsageBreakdown{Agent: toUsage(b.Agent), ByAgent: toUsageMap(b.ByAgent), ByTool: toUsageMap(b.ByTool), Total: toUsage(b.Total)}
+}
+
+func fromUsageBreakdown(b *nekopb.UsageBreakdown) *neko.UsageBreakdown {
+	if b == nil {
+		return nil
+	}
+	return &neko.UsageBreakdown{Agent: fromUsage(b.GetAgent()), ByAgent: fromUsageMap(b.GetByAgent()), ByTool: fromUsageMap(b.GetByTool()), Total: fromUsage(b.GetTotal())}
+}
+
+func toStepLatency(l neko.StepLatency) *nekopb.StepLatency {
+	out := &nekopb.StepLatency{Model: durationpb.New(l.Model), Tools: durationpb.New(l.Tools), Execution: durationpb.New(l.Execution)}
+	if l.ByTool != nil {
+		out.ByTool = make(map[string]*durationpb.Duration, len(l.ByTool))
+		for name, d := range l.ByTool {
+			out.ByTool[name] = durationpb.New(d)
+		}
+	}
+	return out
+}
+
+func fromStepLatency(l *nekopb.StepLatency) neko.StepLatency {
+	out := neko.StepLatency{Model: l.GetModel().AsDuration(), Tools: l.GetTools().AsDuration(), Execution: l.GetExecution().AsDuration()}
+	if l.GetByTool() != nil {
+		out.ByTool = make(map[string]time.Duration, len(l.ByTool))
+		for name, d := range l.ByTool {
+			out.ByTool[name] = d.AsDuration()
+		}
+	}
+	return out
+}
+
+func toLatency(l *neko.Latency) *nekopb.Latency {
+	if l == nil {
+		return nil
+	}
+	out := &nekopb.Latency{Total: toStepLatency(l.Total)}
+	for _, s := range l.Steps {
+		out.Steps = append(out.Steps, toStepLatency(s))
+	}
+	return out
+}
+
+func fromLatency(l *nekopb.Latency) *neko.Latency {
+	if l == nil {
+		return nil
+	}
+	out := &neko.Latency{Total: fromStepLatency(l.GetTotal())}
+	for _, s := range l.GetSteps() {
+		out.Steps = append(out.Steps, fromStepLatency(s))
+	}
+	return out
+}
+
+func toProfile(p *neko.Profile) *nekopb.Profile {
+	if p == nil {
+		return nil
+	}
+	out := &nekopb.Profile{}
+	for _, s := range p.Steps {
+		ps := &nekopb.StepProfile{
+			StepNumber:        int32(s.StepNumber),
+			PromptMessages:    int64(s.PromptMessages),
+			PromptChars:       int64(s.PromptChars),
+			ObservationChars:  int64(s.ObservationChars),
+			InputTokens:       int64(s.InputTokens),
+			CachedInputTokens: int64(s.CachedInputTokens),
+			OutputTokens:      int64(s.OutputTokens),
+			ModelCalls:        int32(s.ModelCalls),
+			ToolCalls:         int32(s.ToolCalls),
+			ToolErrors:        int32(s.ToolErrors),
+			ToolLatency:       durationpb.New(s.ToolLatency),
+			Candidates:        int32(s.Candidates),
+			Prefetched:        int32(s.Prefetched),
+			PrefetchHits:      int32(s.PrefetchHits),
+			Failed:            s.Failed,
+		}
+		for _, d := range s.ModelLatencies {
+			ps.ModelLatencies = append(ps.ModelLatencies, durationpb.New(d))
+		}
+		out.Steps = append(out.Steps, ps)
+	}
+	return out
+}
+
+func fromProfile(p *nekopb.Profile) *neko.Profile {
+	if p == nil {
+		return nil
+	}
+	out := &neko.Profile{}
+	for _, s := range p.GetSteps() {
+		sp := neko.StepProfile{
+			StepNumber:        int(s.GetStepNumber()),
+			PromptMessages:    int(s.GetPromptMessages()),
+			PromptChars:       int(s.GetPromptChars()),
+			ObservationChars:  int(s.GetObservationChars()),
+			InputTokens:       int(s.GetInputTokens()),
+			CachedInputTokens: int(s.GetCachedInputTokens()),
+			OutputTokens:      int(s.GetOutputTokens()),
+			ModelCalls:        int(s.GetModelCalls()),
+			ToolCalls:         int(s.GetToolCalls()),
+			ToolErrors:        int(s.GetToolErrors()),
+			ToolLatency:       s.GetToolLatency().AsDuration(),
+			Candidates:        int(s.GetCandidates()),
+			Prefetched:        int(s.GetPrefetched()),
+			PrefetchHits:      int(s.GetPrefetchHits()),
+			Failed:            s.GetFailed(),
+		}
+		for _, d := range s.GetModelLatencies() {
+			sp.ModelLatencies = append(sp.ModelLatencies, d.AsDuration())
+		}
+		out.Steps = append(out.Steps, sp)
+	}
+	return out
+}
+
+func toArtifacts(artifacts []neko.Artifact) []*nekopb.Artifact {
+	var out []*nekopb.Artifact
+	for _, a := range artifacts {
+		out = append(out, &nekopb.Artifact{Name: a.Name, Path: a.Path, MimeType: a.MIMEType, Data: a.Data})
+	}
+	return out
+}
+
+func fromArtifacts(artifacts []*nekopb.Artifact) []neko.Artifact {
+	var out []neko.Artifact
+	for _, a := range artifacts {
+		out = append(out, neko.Artifact{Name: a.GetName(), Path: a.GetPath(), MIMEType: a.GetMimeType(), Data: a.GetData()})
+	}
+	return out
+}
+
+func toCitations(citations []neko.Citation) []*nekopb.Citation {
+	var out []*nekopb.Citation
+	for _, c := range citations {
+		out = append(out, &nekopb.Citation{Url: c.URL, Title: c.Title, Snippet: c.Snippet, Source: c.Source})
+	}
+	return out
+}
+
+func fromCitations(citations []*nekopb.Citation) []neko.Citation {
+	var out []neko.Citation
+	for _, c := range citations {
+		out = append(out, neko.Citation{URL: c.GetUrl(), Title: c.GetTitle(), Snippet: c.GetSnippet(), Source: c.GetSource()})
+	}
+	return out
+}
+
+// toValue converts v to a protobuf Value, going through JSON for types
+// structpb does not handle directly and falling back to its string form.
+func toValue(v any) *structpb.Value {
+	if pv, err := structpb.NewValue(v); err == nil {
+		return pv
+	}
+	if data, err := json.Marshal(v); err == nil {
+		var generic any
+		if json.Unmarshal(data, &generic) == nil {
+			if pv, err := structpb.NewValue(generic); err == nil {
+				return pv
+			}
+		}
+	}
+	return structpb.NewStringValue(fmt.Sprint(v))
+}
+
+// fromValue converts a protobuf Value back to the value JSON would decode.
+func fromValue(v *structpb.Value) any {
+	if v == nil {
+		return nil
+	}
+	return v.AsInterface()
+}
+
+func toStruct(m map[string]any) *structpb.Struct {
+	if m == nil {
+		return nil
+	}
+	if v := toValue(m); v.GetStructValue() != nil {
+		return v.GetStructValue()
+	}
+	return &structpb.Struct{}
+}
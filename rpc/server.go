@@ -0,0 +1,164 @@
+// Package rpc serves an agent over gRPC using the AgentService defined in
+// proto/neko/v1/agent.proto:
+//
+//	s := grpc.NewServer()
+//	nekopb.RegisterAgentServiceServer(s, rpc.NewServer(agent))
+//
+// The same messages encode runs outside gRPC: RunResultToProto,
+// MemoryToProto and their inverses convert without losing data, for
+// storing traces compactly with proto.Marshal or exchanging them with
+// workers and analysis tools.
+//
+// The generated code in nekopb is rebuilt with go generate, which needs
+// buf, protoc-gen-go and protoc-gen-go-grpc on PATH.
+package rpc
+
+//go:generate sh -c "cd ../proto && buf generate"
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/rpc/nekopb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements nekopb.AgentServiceServer for an agent. Runs execute
+// one at a time, since an agent keeps its memory between steps.
+type Server struct {
+	nekopb.UnimplementedAgentServiceServer
+	agent neko.Agent
+	mu    sync.Mutex // held by the run in progress
+}
+
+// NewServer creates a gRPC service for agent.
+func NewServer(agent neko.Agent) *Server {
+	return &Server{agent: agent}
+}
+
+// RunAgent runs the agent on a task and returns the result.
+func (s *Server) RunAgent(ctx context.Context, req *nekopb.RunAgentRequest) (*nekopb.RunAgentResponse, error) {
+	if req.GetTask() == "" {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, err := s.agent.Run(ctx, req.GetTask(), runOptions(req)...)
+	if err != nil {
+		return nil, runError(err)
+	}
+	pr, err := RunResultToProto(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &nekopb.RunAgentResponse{Result: pr}, nil
+}
+
+// StreamRun runs the agent on a task, sending each step, model output
+// chunk, budget warning and tool progress note as it happens, then the
+// result. Progress other than the result is only streamed for agents that
+// publish events, such as ToolCallingAgent and CodeAgent.
+func (s *Server) StreamRun(req *nekopb.RunAgentRequest, stream grpc.ServerStreamingServer[nekopb.RunEvent]) error {
+	if req.GetTask() == "" {
+		return status.Error(codes.InvalidArgument, "task is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sendMu sync.Mutex
+	var sendErr error
+	send := func(ev *nekopb.RunEvent, err error) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if sendErr != nil {
+			return
+		}
+		if err != nil {
+			sendErr = status.Error(codes.Internal, err.Error())
+			return
+		}
+		sendErr = stream.Send(ev)
+	}
+	if src, ok := s.agent.(interface{ Events() *neko.EventBus }); ok {
+		bus := src.Events()
+		defer bus.Subscribe(neko.EventStep, func(e neko.Event) {
+			step, err := StepToProto(e.(neko.StepEvent).Step)
+			send(&nekopb.RunEvent{Event: &nekopb.RunEvent_Step{Step: step}}, err)
+		})()
+		defer bus.Subscribe(neko.EventModelDelta, func(e neko.Event) {
+			ev := e.(neko.ModelDeltaEvent)
+			send(&nekopb.RunEvent{Event: &nekopb.RunEvent_Delta{Delta: &nekopb.ModelDelta{StepNumber: int32(ev.StepNumber), Content: ev.Delta}}}, nil)
+		})()
+		defer bus.Subscribe(neko.EventBudgetWarning, func(e neko.Event) {
+			w := e.(*neko.BudgetWarning)
+			send(&nekopb.RunEvent{Event: &nekopb.RunEvent_BudgetWarning{BudgetWarning: &nekopb.BudgetWarning{
+				Kind: w.Kind, Threshold: w.Threshold, Used: w.Used, Limit: w.Limit, StepNumber: int32(w.StepNumber),
+			}}}, nil)
+		})()
+		defer bus.Subscribe(neko.EventToolProgress, func(e neko.Event) {
+			p := e.(*neko.ToolProgress)
+			send(&nekopb.RunEvent{Event: &nekopb.RunEvent_ToolProgress{ToolProgress: &nekopb.ToolProgress{
+				StepNumber: int32(p.StepNumber), Tool: p.Tool, CallId: p.CallID, ElapsedMs: p.Elapsed.Milliseconds(), Note: p.Note, Canceled: p.Canceled,
+			}}}, nil)
+		})()
+	}
+
+	result, err := s.agent.Run(stream.Context(), req.GetTask(), runOptions(req)...)
+	if err != nil {
+		return runError(err)
+	}
+	pr, err := RunResultToProto(result)
+	send(&nekopb.RunEvent{Event: &nekopb.RunEvent_Result{Result: pr}}, err)
+	return sendErr
+}
+
+// ListTools lists the tools and managed agents the agent can call.
+func (s *Server) ListTools(ctx context.Context, req *nekopb.ListToolsRequest) (*nekopb.ListToolsResponse, error) {
+	src, ok := s.agent.(interface{ Tools() []neko.Tool })
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "agent does not list its tools")
+	}
+	resp := &nekopb.ListToolsResponse{}
+	for _, t := range src.Tools() {
+		tool := &nekopb.Tool{
+			Name:        t.Name(),
+			Description: t.Description(),
+			OutputType:  t.OutputType(),
+			Inputs:      make(map[string]*nekopb.ToolInput),
+		}
+		for name, in := range t.Inputs() {
+			tool.Inputs[name] = &nekopb.ToolInput{Type: in.Type, Description: in.Description, Required: in.Required}
+		}
+		resp.Tools = append(resp.Tools, tool)
+	}
+	return resp, nil
+}
+
+func runOptions(req *nekopb.RunAgentRequest) []neko.RunOption {
+	var opts []neko.RunOption
+	if req.GetMaxSteps() > 0 {
+		opts = append(opts, neko.WithMaxSteps(int(req.GetMaxSteps())))
+	}
+	if req.ResetMemory != nil {
+		opts = append(opts, neko.WithReset(req.GetResetMemory()))
+	}
+	if len(req.GetImages()) > 0 {
+		opts = append(opts, neko.WithImages(req.GetImages()...))
+	}
+	return opts
+}
+
+// runError maps a run error to a gRPC status.
+func runError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,29 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/rpc/nekopb"
+	"github.com/gocnn/neko/testutil"
+)
+
+func TestRunAgentSendsImagesToModel(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	model := testutil.NewReplayModel(&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{
+		{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": "a cat"}},
+	}})
+	agent := neko.NewToolCallingAgent(neko.WithModel(model), neko.WithModelCapabilities(neko.Capabilities{Tools: true, Vision: true}))
+	if _, err := NewServer(agent).RunAgent(context.Background(), &nekopb.RunAgentRequest{Task: "what is this?", Images: [][]byte{image}}); err != nil {
+		t.Fatal(err)
+	}
+	var got [][]byte
+	for _, msg := range model.Requests()[0] {
+		got = append(got, msg.Images...)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], image) {
+		t.Errorf("model saw images %q, want the request's image", got)
+	}
+}
@@ -0,0 +1,129 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RunStatus is the state of a run started with RunAsync.
+type RunStatus string
+
+// Run statuses.
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunCanceled  RunStatus = "canceled"
+)
+
+// RunHandle controls a run started with RunAsync.
+type RunHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	status   RunStatus
+	canceled bool
+	result   *RunResult
+	err      error
+}
+
+// RunAsync starts agent on task in a new goroutine and returns at once.
+// Cancelling ctx or calling Cancel on the handle stops the run: the
+// cancellation reaches the model call, tools and executor of the current
+// step, and cleanup hooks registered with AddCleanup run before the run
+// ends.
+func RunAsync(ctx context.Context, agent Agent, task string, opts ...RunOption) *RunHandle {
+	ctx, cancel := context.WithCancel(ctx)
+	h := &RunHandle{cancel: cancel, done: make(chan struct{}), status: RunRunning}
+	go func() {
+		defer cancel()
+		result, err := agent.Run(ctx, task, opts...)
+		h.mu.Lock()
+		h.result, h.err = result, err
+		switch {
+		case err == nil:
+			h.status = RunSucceeded
+		case h.canceled || errors.Is(err, context.Canceled):
+			h.status = RunCanceled
+		default:
+			h.status = RunFailed
+		}
+		h.mu.Unlock()
+		close(h.done)
+	}()
+	return h
+}
+
+// Cancel stops the run. It does not wait for the run to end; use Result
+// or Done for that.
+func (h *RunHandle) Cancel() {
+	h.mu.Lock()
+	if h.status == RunRunning {
+		h.canceled = true
+	}
+	h.mu.Unlock()
+	h.cancel()
+}
+
+// Status returns the run's current status.
+func (h *RunHandle) Status() RunStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Done returns a channel closed when the run ends.
+func (h *RunHandle) Done() <-chan struct{} { return h.done }
+
+// Result waits for the run to end and returns what Run returned.
+func (h *RunHandle) Result() (*RunResult, error) {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result, h.err
+}
+
+// cleanups holds the cleanup hooks registered during a run.
+type cleanups struct {
+	mu    sync.Mutex
+	hooks []func(context.Context) error
+}
+
+type cleanupKey struct{}
+
+// AddCleanup registers fn to run when the run ctx belongs to ends, whether
+// it finished, failed or was cancelled. Tools and executors use it to
+// release what they hold for the run, such as containers or browser
+// sessions. Hooks run in reverse order of registration with a context
+// that is not cancelled but keeps ctx's values; their errors are logged.
+// Outside a run, AddCleanup does nothing and reports false.
+func AddCleanup(ctx context.Context, fn func(context.Context) error) bool {
+	c, ok := ctx.Value(cleanupKey{}).(*cleanups)
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, fn)
+	return true
+}
+
+// runCleanups runs the cleanup hooks registered on ctx.
+func (a *BaseAgent) runCleanups(ctx context.Context) {
+	c, ok := ctx.Value(cleanupKey{}).(*cleanups)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	hooks := c.hooks
+	c.hooks = nil
+	c.mu.Unlock()
+	ctx = context.WithoutCancel(ctx)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			a.logger().Warn("cleanup failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RunQueue admits agent runs: at most a fixed number run at once across
+// the agents sharing it, and further Run calls wait their turn, first
+// come first served, until a run finishes or their context ends.
+type RunQueue struct {
+	limit int
+
+	mu        sync.Mutex
+	running   int
+	waiting   []chan struct{}
+	abandoned int64
+}
+
+// RunQueueStats is a snapshot of a RunQueue.
+type RunQueueStats struct {
+	Limit   int `json:"limit"`
+	Running int `json:"running"`
+	Waiting int `json:"waiting"` // the queue depth
+	// Abandoned counts the runs whose context ended while they waited.
+	Abandoned int64 `json:"abandoned"`
+}
+
+// NewRunQueue creates a queue running up to maxRuns runs at once. A
+// limit below 1 is treated as 1.
+func NewRunQueue(maxRuns int) *RunQueue {
+	return &RunQueue{limit: max(maxRuns, 1)}
+}
+
+// WithRunQueue makes the agent's runs wait in q, shared with the other
+// agents given it, e.g. the agents of every session a server creates.
+//
+// An agent runs one task at a time, since its memory holds one run, so q
+// limits concurrency across agents, never within one: Run calls on a busy
+// agent wait for it first, and take a place in q once it is free, so a
+// busy agent does not hold places others could use.
+func WithRunQueue(q *RunQueue) AgentOption {
+	return func(a *BaseAgent) { a.runQueue = q }
+}
+
+// WithMaxConcurrentRuns gives the agent a run queue of its own with a
+// limit of n. Since an agent runs one task at a time, this only makes
+// excess Run calls wait their turn, abandoning the wait when their
+// context ends, with QueuedRuns reporting how many wait; it does not let
+// one agent run tasks concurrently. To limit runs across agents, create
+// one RunQueue and give it to each with WithRunQueue.
+func WithMaxConcurrentRuns(n int) AgentOption {
+	return WithRunQueue(NewRunQueue(n))
+}
+
+// Stats returns the queue's current state.
+func (q *RunQueue) Stats() RunQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return RunQueueStats{Limit: q.limit, Running: q.running, Waiting: len(q.waiting), Abandoned: q.abandoned}
+}
+
+// RegisterMetrics reports the queue's state with meter, as the gauges
+// neko.run_queue.running and neko.run_queue.waiting and the counter
+// neko.run_queue.abandoned.
+func (q *RunQueue) RegisterMetrics(meter metric.Meter) error {
+	running, err := meter.Int64ObservableGauge("neko.run_queue.running", metric.WithDescription("Agent runs in progress"))
+	if err != nil {
+		return err
+	}
+	waiting, err := meter.Int64ObservableGauge("neko.run_queue.waiting", metric.WithDescription("Agent runs waiting to start"))
+	if err != nil {
+		return err
+	}
+	abandoned, err := meter.Int64ObservableCounter("neko.run_queue.abandoned", metric.WithDescription("Agent runs whose context ended while they waited"))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := q.Stats()
+		o.ObserveInt64(running, int64(s.Running))
+		o.ObserveInt64(waiting, int64(s.Waiting))
+		o.ObserveInt64(abandoned, s.Abandoned)
+		return nil
+	}, running, waiting, abandoned)
+	return err
+}
+
+// Acquire waits for a place to run, or for ctx to end. The returned
+// function gives the place back. Agents given q call it for each run;
+// call it directly to hold other work, such as a server's runs, to the
+// same limit.
+func (q *RunQueue) Acquire(ctx context.Context) (release func(), err error) {
+	q.mu.Lock()
+	if q.running < q.limit && len(q.waiting) == 0 {
+		q.running++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	ready := make(chan struct{})
+	q.waiting = append(q.waiting, ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-ready:
+		// Admitted while giving up: pass the place on.
+		q.releaseLocked()
+	default:
+		for i, ch := range q.waiting {
+			if ch == ready {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+	q.abandoned++
+	return nil, context.Cause(ctx)
+}
+
+func (q *RunQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+// releaseLocked frees a place, handing it to the first waiting run.
+func (q *RunQueue) releaseLocked() {
+	if len(q.waiting) > 0 {
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+		return
+	}
+	q.running--
+}
+
+// QueuedRuns returns the number of the agent's Run calls waiting to
+// start, for the agent or for a place in its run queue.
+func (a *BaseAgent) QueuedRuns() int { return int(a.queued.Load()) }
+
+// admit waits until a run may start: its turn on the agent, which runs
+// one task at a time, then a place in the agent's run queue, if any. The
+// returned function ends the run's admission.
+func (a *BaseAgent) admit(ctx context.Context) (release func(), err error) {
+	a.queued.Add(1)
+	defer a.queued.Add(-1)
+	own, err := a.turns.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("run not started: %w", err)
+	}
+	if a.runQueue == nil {
+		return own, nil
+	}
+	shared, err := a.runQueue.Acquire(ctx)
+	if err != nil {
+		own()
+		return nil, fmt.Errorf("run not started: %w", err)
+	}
+	return func() {
+		shared()
+		own()
+	}, nil
+}
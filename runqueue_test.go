@@ -0,0 +1,59 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunQueueLimitAndOrder(t *testing.T) {
+	q := NewRunQueue(1)
+	release, err := q.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 3)
+	for i := range 3 {
+		go func() {
+			r, err := q.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			r()
+		}()
+		// Wait for each to queue so the arrival order is known.
+		for q.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if s := q.Stats(); s.Running != 1 || s.Waiting != 3 {
+		t.Fatalf("stats = %+v, want 1 running and 3 waiting", s)
+	}
+	release()
+	for want := range 3 {
+		if got := <-order; got != want {
+			t.Errorf("run %d started in place %d", got, want)
+		}
+	}
+	if s := q.Stats(); s.Running != 0 || s.Waiting != 0 {
+		t.Errorf("stats = %+v, want an empty queue", s)
+	}
+}
+
+func TestRunQueueAbandon(t *testing.T) {
+	q := NewRunQueue(1)
+	release, _ := q.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	release()
+	if s := q.Stats(); s.Running != 0 || s.Waiting != 0 || s.Abandoned != 1 {
+		t.Errorf("stats = %+v, want an empty queue with 1 abandoned", s)
+	}
+}
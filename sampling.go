@@ -0,0 +1,170 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Candidate is an action the model proposed for a step sampled with
+// WithActionSampling.
+type Candidate struct {
+	ModelOutput string     `json:"model_output,omitempty"`
+	ToolCalls   []ToolCall `json:"tool_calls,omitempty"`
+	Code        string     `json:"code,omitempty"`
+	Score       float64    `json:"score"`
+	Error       string     `json:"error,omitempty"` // why the candidate could not be generated or scored
+	Selected    bool       `json:"selected,omitempty"`
+}
+
+// Verifier scores a candidate action, higher being better. steps are the
+// run's steps so far, which it must not modify.
+type Verifier func(ctx context.Context, steps []Step, c Candidate) (float64, error)
+
+// ActionSampling configures WithActionSampling.
+type ActionSampling struct {
+	K        int      // candidates sampled per step
+	Verifier Verifier // scores the candidates
+	// When selects the steps to sample, given the run's steps so far. A
+	// nil When samples every step.
+	When func(steps []Step) bool
+}
+
+// WithActionSampling makes the agent sample K candidate actions for the
+// steps s.When selects, instead of one, and execute only the candidate
+// s.Verifier scores highest. All candidates, with their scores, are kept
+// in the step's Candidates for audit, and the step's token usage includes
+// them all.
+func WithActionSampling(s ActionSampling) AgentOption {
+	return func(a *BaseAgent) { a.sampling = &s }
+}
+
+// sampleAction generates the action for step with gen, sampling
+// candidates and returning the best one when sampling applies to the
+// step. code extracts a CodeAgent's code from model output.
+func (a *BaseAgent) sampleAction(ctx context.Context, step *ActionStep, gen func() (*Message, error), code func(string) string) (*Message, error) {
+	s := a.sampling
+	if s == nil || s.K < 2 || s.Verifier == nil || (s.When != nil && !s.When(a.memory.Steps)) {
+		return gen()
+	}
+	var (
+		resps    []*Message
+		best     = -1
+		usage    TokenUsage
+		firstErr error
+	)
+	for range s.K {
+		resp, err := gen()
+		if resp != nil && resp.TokenUsage != nil {
+			usage.InputTokens += resp.TokenUsage.InputTokens
+			usage.OutputTokens += resp.TokenUsage.OutputTokens
+			usage.CachedInputTokens += resp.TokenUsage.CachedInputTokens
+		}
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		c := Candidate{}
+		if resp != nil {
+			c.ModelOutput, c.ToolCalls = resp.Content, resp.ToolCalls
+			if code != nil {
+				c.Code = code(resp.Content)
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			c.Error = err.Error()
+			resp = nil
+		} else if score, err := s.Verifier(ctx, a.memory.Steps, c); err != nil {
+			c.Error = "verifier: " + err.Error()
+		} else {
+			c.Score = score
+		}
+		resps = append(resps, resp)
+		step.Candidates = append(step.Candidates, c)
+		if resp != nil && (best < 0 || c.better(step.Candidates[best])) {
+			best = len(resps) - 1
+		}
+	}
+	recordProfile(ctx, func(p *StepProfile) { p.Candidates = s.K })
+	if best < 0 {
+		return &Message{Role: RoleAssistant, TokenUsage: &usage}, firstErr
+	}
+	step.Candidates[best].Selected = true
+	a.logger().Debug("sampled actions", "step", step.StepNumber, "candidates", s.K, "selected", best, "score", step.Candidates[best].Score)
+	resp := *resps[best]
+	resp.TokenUsage = &usage
+	return &resp, nil
+}
+
+// better reports whether c should be executed rather than o: scored
+// candidates beat those the verifier failed on, then higher scores win.
+func (c Candidate) better(o Candidate) bool {
+	if (c.Error == "") != (o.Error == "") {
+		return c.Error == ""
+	}
+	return c.Score > o.Score
+}
+
+var judgeScore = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// JudgeVerifier returns a Verifier asking model to rate each candidate
+// from 0 to 10 against criteria, such as "Prefers read-only actions and
+// never deletes data", given the task and the latest steps.
+func JudgeVerifier(model Model, criteria string) Verifier {
+	return func(ctx context.Context, steps []Step, c Candidate) (float64, error) {
+		action := c.Code
+		if action == "" && len(c.ToolCalls) > 0 {
+			action = formatToolCalls(c.ToolCalls)
+		}
+		prompt := fmt.Sprintf(`Rate a candidate next action of an AI agent, given its progress so far.
+Criteria: %s
+
+%s
+Candidate thought: %s
+Candidate action: %s
+
+Reply with only a score from 0 (harmful or useless) to 10 (the best possible next action).`,
+			criteria, judgeTranscript(steps), strings.TrimSpace(c.ModelOutput), action)
+		if err := checkModel(ctx, model); err != nil {
+			return 0, err
+		}
+		resp, err := model.Generate(ctx, []Message{{Role: RoleUser, Content: prompt}})
+		if err != nil {
+			return 0, err
+		}
+		m := judgeScore.FindString(resp.Content)
+		if m == "" {
+			return 0, fmt.Errorf("no score in judge reply %q", truncate(resp.Content, 80))
+		}
+		return strconv.ParseFloat(m, 64)
+	}
+}
+
+// judgeTranscript renders the task and the last few action steps for a
+// judge.
+func judgeTranscript(steps []Step) string {
+	var sb strings.Builder
+	var actions []*ActionStep
+	for _, s := range steps {
+		switch s := s.(type) {
+		case *TaskStep:
+			fmt.Fprintf(&sb, "Task: %s\n", s.Task)
+		case *ActionStep:
+			actions = append(actions, s)
+		}
+	}
+	if len(actions) > 5 {
+		actions = actions[len(actions)-5:]
+	}
+	for _, s := range actions {
+		fmt.Fprintf(&sb, "\nStep %d:\n", s.StepNumber)
+		for _, m := range s.ToMessages() {
+			fmt.Fprintf(&sb, "%s: %s\n", m.Role, truncate(m.Content, 500))
+		}
+	}
+	return sb.String()
+}
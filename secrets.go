@@ -0,0 +1,401 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSecretNotFound is matched by errors from SecretProviders that do not
+// have the secret asked for.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider supplies secrets, such as API keys, to tools and
+// executors while they run, so that keys need not be passed to their
+// constructors or kept in agent configs. See WithSecrets and
+// LookupSecret.
+type SecretProvider interface {
+	// Secret returns the named secret, or an error matching
+	// ErrSecretNotFound.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// EnvSecrets returns a provider reading each secret from the environment
+// variable named prefix followed by the secret's name.
+func EnvSecrets(prefix string) SecretProvider { return envSecrets(prefix) }
+
+type envSecrets string
+
+func (p envSecrets) Secret(_ context.Context, name string) (string, error) {
+	if v, ok := os.LookupEnv(string(p) + name); ok && v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+// FileSecrets returns a provider reading each secret from the file of its
+// name in dir, as Docker and Kubernetes mount secrets. Surrounding
+// whitespace is trimmed.
+func FileSecrets(dir string) SecretProvider { return fileSecrets(dir) }
+
+type fileSecrets string
+
+func (p fileSecrets) Secret(_ context.Context, name string) (string, error) {
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(string(p), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ChainSecrets returns a provider asking each of providers in turn,
+// returning the first secret found.
+func ChainSecrets(providers ...SecretProvider) SecretProvider { return chainSecrets(providers) }
+
+type chainSecrets []SecretProvider
+
+func (c chainSecrets) Secret(ctx context.Context, name string) (string, error) {
+	for _, p := range c {
+		v, err := p.Secret(ctx, name)
+		if !errors.Is(err, ErrSecretNotFound) {
+			return v, err
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+// VaultSecrets reads secrets from the keys of one secret in a HashiCorp
+// Vault KV version 2 engine. The secret is read on first use and cached
+// for five minutes.
+type VaultSecrets struct {
+	addr  string
+	token string
+	mount string
+	path  string
+
+	client *http.Client
+	mu     sync.Mutex
+	data   map[string]string
+	read   time.Time
+}
+
+// VaultOption configures VaultSecrets.
+type VaultOption func(*VaultSecrets)
+
+// WithVaultHTTPClient sets the HTTP client used for requests to Vault,
+// e.g. to present a client certificate.
+func WithVaultHTTPClient(c *http.Client) VaultOption {
+	return func(v *VaultSecrets) { v.client = c }
+}
+
+// NewVaultSecrets creates a provider for the secret at path in the KV
+// engine mounted at mount, e.g. "secret", of the Vault server at addr,
+// authenticating with token.
+func NewVaultSecrets(addr, token, mount, path string, opts ...VaultOption) *VaultSecrets {
+	v := &VaultSecrets{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		mount:  strings.Trim(mount, "/"),
+		path:   strings.Trim(path, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Secret returns the value of the key name.
+func (v *VaultSecrets) Secret(ctx context.Context, name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.data == nil || time.Since(v.read) > 5*time.Minute {
+		data, err := v.fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+		v.data, v.read = data, time.Now()
+	}
+	s, ok := v.data[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return s, nil
+}
+
+func (v *VaultSecrets) fetch(ctx context.Context) (map[string]string, error) {
+	u := fmt.Sprintf("%s/v1/%s/data/%s", v.addr, url.PathEscape(v.mount), v.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: reading %s/%s: %s", v.mount, v.path, resp.Status)
+	}
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	data := make(map[string]string, len(body.Data.Data))
+	for k, val := range body.Data.Data {
+		if s, ok := val.(string); ok {
+			data[k] = s
+		} else {
+			data[k] = fmt.Sprint(val)
+		}
+	}
+	return data, nil
+}
+
+// WithSecrets lets the agent's tools and executors look up secrets from
+// p with LookupSecret. Managed agents without secrets of their own use
+// their manager's. Every secret looked up is redacted, as
+// "[REDACTED:secret]", from the observations the model sees and from the
+// agent's logs, traces and events: from step errors, model output, code
+// and tool call arguments, from streamed model output, and from the
+// errors recorded on model, tool and code spans.
+//
+// Neko does not encrypt secrets. Looked-up values are held in memory in
+// plain text until the agent is discarded, so store them encrypted at
+// rest in the provider's backend, such as Vault, rather than relying on
+// the agent to protect them.
+func WithSecrets(p SecretProvider) AgentOption {
+	return func(a *BaseAgent) {
+		a.secrets = &secretStore{provider: p, values: map[string]string{}}
+		a.secrets.redactor = &Redactor{detectors: []Detector{a.secrets}}
+	}
+}
+
+// secretStore remembers the secrets looked up through it, to redact
+// them. It is a Detector finding their values.
+type secretStore struct {
+	provider SecretProvider
+	redactor *Redactor
+
+	mu     sync.RWMutex
+	values map[string]string // by name
+}
+
+// minSecretLength is the length below which secret values are not
+// redacted, since they would match ordinary text.
+const minSecretLength = 6
+
+func (s *secretStore) lookup(ctx context.Context, name string) (string, error) {
+	v, err := s.provider.Secret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.values[name] = v
+	s.mu.Unlock()
+	return v, nil
+}
+
+func (s *secretStore) Kind() string { return "secret" }
+
+func (s *secretStore) FindAll(text string) [][]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found [][]int
+	for _, v := range s.values {
+		if len(v) < minSecretLength {
+			continue
+		}
+		for i := 0; ; {
+			j := strings.Index(text[i:], v)
+			if j < 0 {
+				break
+			}
+			found = append(found, []int{i + j, i + j + len(v)})
+			i += j + len(v)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i][0] < found[j][0] })
+	return found
+}
+
+type secretsKey struct{}
+
+// startSecrets attaches the agent's secrets to ctx for its tools,
+// executors and managed agents.
+func (a *BaseAgent) startSecrets(ctx context.Context) context.Context {
+	if a.secrets == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, secretsKey{}, a.secrets)
+}
+
+// LookupSecret returns the named secret from the provider of the agent
+// running with ctx; see WithSecrets. It returns an error matching
+// ErrSecretNotFound outside a run of such an agent.
+func LookupSecret(ctx context.Context, name string) (string, error) {
+	s, ok := ctx.Value(secretsKey{}).(*secretStore)
+	if !ok {
+		return "", fmt.Errorf("%w: %s (no secret provider)", ErrSecretNotFound, name)
+	}
+	return s.lookup(ctx, name)
+}
+
+// redactSecrets removes from text the secrets looked up so far in the
+// run ctx belongs to.
+func redactSecrets(ctx context.Context, text string) string {
+	s, ok := ctx.Value(secretsKey{}).(*secretStore)
+	if !ok {
+		return text
+	}
+	return s.redactor.Redact(text)
+}
+
+// redactError returns err with the secrets looked up so far in the run
+// ctx belongs to removed from its message. The result still matches err
+// with errors.Is and errors.As.
+func redactError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if redacted := redactSecrets(ctx, msg); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactStep removes looked-up secrets from the parts of step that are
+// sent back to the model, logged or published: its error, model output,
+// reasoning, code and tool call arguments. Observations are redacted when
+// they are built.
+func redactStep(ctx context.Context, step *ActionStep) {
+	step.Error = redactError(ctx, step.Error)
+	step.ModelOutput = redactSecrets(ctx, step.ModelOutput)
+	step.Reasoning = redactSecrets(ctx, step.Reasoning)
+	step.CodeAction = redactSecrets(ctx, step.CodeAction)
+	if _, ok := ctx.Value(secretsKey{}).(*secretStore); ok && len(step.ToolCalls) > 0 {
+		calls := make([]ToolCall, len(step.ToolCalls))
+		for i, tc := range step.ToolCalls {
+			tc.Arguments, _ = redactCopy(ctx, tc.Arguments).(map[string]any)
+			calls[i] = tc
+		}
+		step.ToolCalls = calls
+	}
+}
+
+// redactCopy returns a copy of v, a decoded JSON value, with looked-up
+// secrets removed from its strings. v itself is left as is, since tool
+// call arguments are shared with the tools that received them.
+func redactCopy(ctx context.Context, v any) any {
+	switch v := v.(type) {
+	case string:
+		return redactSecrets(ctx, v)
+	case []any:
+		c := make([]any, len(v))
+		for i := range v {
+			c[i] = redactCopy(ctx, v[i])
+		}
+		return c
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		c := make(map[string]any, len(v))
+		for k := range v {
+			c[k] = redactCopy(ctx, v[k])
+		}
+		return c
+	}
+	return v
+}
+
+// secretStream removes looked-up secrets from streamed model output
+// before it is published. It holds back the end of the stream while it
+// could be the start of a secret, so a secret split across deltas is
+// still removed.
+type secretStream struct {
+	store   *secretStore
+	pending string
+}
+
+func newSecretStream(ctx context.Context) *secretStream {
+	s, _ := ctx.Value(secretsKey{}).(*secretStore)
+	return &secretStream{store: s}
+}
+
+// write adds delta to the stream and returns the text that is now safe
+// to publish.
+func (s *secretStream) write(delta string) string {
+	if s.store == nil {
+		return delta
+	}
+	text := s.pending + delta
+	cut := len(text) - s.store.partialSuffix(text)
+	for _, m := range s.store.FindAll(text) {
+		if m[0] < cut && m[1] > cut {
+			cut = m[0]
+			break
+		}
+	}
+	s.pending = text[cut:]
+	return s.store.redactor.Redact(text[:cut])
+}
+
+// flush returns the text held back at the end of the stream.
+func (s *secretStream) flush() string {
+	if s.store == nil {
+		return ""
+	}
+	text := s.pending
+	s.pending = ""
+	return s.store.redactor.Redact(text)
+}
+
+// partialSuffix returns the length of the longest suffix of text that is
+// the start, but not the whole, of a secret looked up so far.
+func (s *secretStore) partialSuffix(text string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, v := range s.values {
+		if len(v) < minSecretLength {
+			continue
+		}
+		for k := min(len(v)-1, len(text)); k > n; k-- {
+			if strings.HasSuffix(text, v[:k]) {
+				n = k
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package neko_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+type mapSecrets map[string]string
+
+func (m mapSecrets) Secret(_ context.Context, name string) (string, error) {
+	if v, ok := m[name]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", neko.ErrSecretNotFound, name)
+}
+
+var errLeak = errors.New("request failed")
+
+// leakyExecutor fails with the looked-up secret in its error unless the
+// code gives a final answer.
+type leakyExecutor struct{}
+
+func (leakyExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	if strings.Contains(code, "final_answer(") {
+		return &neko.ExecutionResult{Output: "done", IsFinal: true}, nil
+	}
+	key, err := neko.LookupSecret(ctx, "API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	return &neko.ExecutionResult{Logs: "using " + key}, fmt.Errorf("traceback: key=%s: %w", key, errLeak)
+}
+
+func TestSecretsRedactedFromStepErrors(t *testing.T) {
+	const secret = "s3cr3t-value-123"
+	model := testutil.NewReplayModel(
+		&neko.Message{Role: neko.RoleAssistant, Content: "<code>call_api()</code>"},
+		&neko.Message{Role: neko.RoleAssistant, Content: `<code>final_answer("done")</code>`},
+	)
+	agent := neko.NewCodeAgent(leakyExecutor{},
+		neko.WithModel(model),
+		neko.WithSecrets(mapSecrets{"API_KEY": secret}),
+	)
+	result, err := agent.Run(context.Background(), "call the API")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := result.Steps[1].(*neko.ActionStep)
+	if !ok || step.Error == nil {
+		t.Fatalf("step 1 = %#v, want a failed action step", result.Steps[1])
+	}
+	if strings.Contains(step.Error.Error(), secret) || strings.Contains(step.Observations, secret) {
+		t.Errorf("secret leaked into step: error %q, observations %q", step.Error, step.Observations)
+	}
+	if !errors.Is(step.Error, errLeak) {
+		t.Errorf("redacted error %v no longer matches its cause", step.Error)
+	}
+	for _, msg := range model.Requests()[1] {
+		if strings.Contains(msg.Content, secret) {
+			t.Errorf("secret sent to the model in %s message: %q", msg.Role, msg.Content)
+		}
+	}
+}
+
+// streamingReplay streams a replay model's responses a few bytes at a
+// time.
+type streamingReplay struct{ *testutil.ReplayModel }
+
+func (m streamingReplay) GenerateStream(ctx context.Context, msgs []neko.Message, opts ...neko.GenerateOption) (<-chan neko.StreamDelta, error) {
+	resp, err := m.Generate(ctx, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan neko.StreamDelta, len(resp.Content)+1)
+	for s := resp.Content; s != ""; s = s[min(4, len(s)):] {
+		ch <- neko.StreamDelta{Content: s[:min(4, len(s))]}
+	}
+	ch <- neko.StreamDelta{ToolCalls: resp.ToolCalls, Done: true}
+	close(ch)
+	return ch, nil
+}
+
+// loginTool looks up the API key and records the tokens it is called
+// with.
+type loginTool struct {
+	*neko.FuncTool
+	tokens []string
+}
+
+func (t *loginTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	if _, err := neko.LookupSecret(ctx, "API_KEY"); err != nil {
+		return nil, err
+	}
+	t.tokens = append(t.tokens, fmt.Sprint(args["token"]))
+	return "logged in", nil
+}
+
+func TestSecretsRedactedFromEvents(t *testing.T) {
+	const secret = "s3cr3t-value-123"
+	login := &loginTool{FuncTool: neko.NewFuncTool("login", "logs in", map[string]neko.ToolInput{"token": {Type: "string", Description: "token"}}, "string", nil)}
+	model := streamingReplay{testutil.NewReplayModel(
+		&neko.Message{Role: neko.RoleAssistant, Content: "Logging in.", ToolCalls: []neko.ToolCall{{ID: "1", Name: "login", Arguments: map[string]any{"token": "none"}}}},
+		&neko.Message{Role: neko.RoleAssistant, Content: "The key is " + secret + ", retrying.", ToolCalls: []neko.ToolCall{{ID: "2", Name: "login", Arguments: map[string]any{"token": secret}}}},
+		&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: "3", Name: "final_answer", Arguments: map[string]any{"answer": "done"}}}},
+	)}
+	agent := neko.NewToolCallingAgent(
+		neko.WithModel(model),
+		neko.WithToolList(login),
+		neko.WithSecrets(mapSecrets{"API_KEY": secret}),
+	)
+	var streamed strings.Builder
+	agent.Events().Subscribe(neko.EventModelDelta, func(e neko.Event) { streamed.WriteString(e.(neko.ModelDeltaEvent).Delta) })
+	var calls []neko.ToolCall
+	agent.Events().Subscribe(neko.EventStep, func(e neko.Event) {
+		if s, ok := e.(neko.StepEvent).Step.(*neko.ActionStep); ok {
+			calls = append(calls, s.ToolCalls...)
+		}
+	})
+	if _, err := agent.Run(context.Background(), "log in"); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(streamed.String(), secret) || !strings.Contains(streamed.String(), "[REDACTED:secret]") {
+		t.Errorf("streamed %q, want the secret redacted", streamed.String())
+	}
+	if !strings.Contains(streamed.String(), ", retrying.") {
+		t.Errorf("streamed %q, want the text after the secret", streamed.String())
+	}
+	for _, tc := range calls {
+		if strings.Contains(fmt.Sprint(tc.Arguments), secret) {
+			t.Errorf("secret published in %s arguments: %v", tc.Name, tc.Arguments)
+		}
+	}
+	if len(login.tokens) != 2 || login.tokens[1] != secret {
+		t.Errorf("tool called with tokens %q, want the secret unredacted", login.tokens)
+	}
+}
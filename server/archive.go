@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// ErrSessionNotArchived is returned when restoring a session that is not
+// in the archive.
+var ErrSessionNotArchived = errors.New("session not archived")
+
+// ArchivedSession is the state of a session saved when it expired.
+type ArchivedSession struct {
+	ID         string        `json:"id"`
+	Tenant     string        `json:"tenant,omitempty"` // the ID of the tenant that opened it
+	Turns      int           `json:"turns"`
+	TTL        time.Duration `json:"ttl,omitempty"` // set if the session had its own TTL
+	LastUsed   time.Time     `json:"last_used"`
+	ArchivedAt time.Time     `json:"archived_at"`
+	Memory     *neko.Memory  `json:"memory"` // the conversation's steps
+}
+
+// SessionArchive is cold storage for expired sessions; see
+// WithSessionArchive.
+type SessionArchive interface {
+	Save(s *ArchivedSession) error
+	// Load returns the latest saved state of the session, or an error
+	// matching ErrSessionNotArchived.
+	Load(id string) (*ArchivedSession, error)
+}
+
+// FileArchive is a SessionArchive appending sessions to a JSONL file, one
+// per line. A session archived again, after being restored, has a new
+// line; Load returns the last.
+type FileArchive struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileArchive creates an archive in the JSONL file at path, created
+// on the first save.
+func NewFileArchive(path string) *FileArchive {
+	return &FileArchive{path: path}
+}
+
+// Save appends s to the file.
+func (a *FileArchive) Save(s *ArchivedSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("archive session %s: %w", s.ID, err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load scans the file for the last line saving the session.
+func (a *FileArchive) Load(id string) (*ArchivedSession, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.Open(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotArchived, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var head struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &head) == nil && head.ID == id {
+			found = append(found[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotArchived, id)
+	}
+	var s ArchivedSession
+	if err := json.Unmarshal(found, &s); err != nil {
+		return nil, fmt.Errorf("archived session %s: %w", id, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// ChatCompletionRequest is the subset of the OpenAI chat completions
+// request that the facade understands. Other fields are ignored.
+type ChatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+}
+
+// ChatMessage is an OpenAI chat message. Content is a string or a list of
+// content parts; text parts and data URL images are used.
+type ChatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+// ChatCompletion is a non-streaming chat completions response.
+type ChatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *ChatUsage   `json:"usage,omitempty"`
+}
+
+// ChatChoice is a choice of a chat completion or chunk. Message is set in
+// responses and Delta in streamed chunks.
+type ChatChoice struct {
+	Index        int        `json:"index"`
+	Message      *ChatDelta `json:"message,omitempty"`
+	Delta        *ChatDelta `json:"delta,omitempty"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+// ChatDelta is an assistant message or a streamed piece of one. The
+// agent's intermediate model output is sent as ReasoningContent and its
+// final answer as Content.
+type ChatDelta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// ChatUsage reports the tokens used by the whole run.
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// handleChatCompletions runs the agent on a chat conversation in the
+// OpenAI chat completions API shape, so OpenAI clients and chat UIs can
+// use the agent as a model. Each request is a fresh run: earlier turns
+// and system messages are given to the agent as context for the last user
+// message.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&req); err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	task, images, err := chatTask(req.Messages)
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rn, err := s.start(r.Context(), &RunRequest{Task: task, Images: images})
+	if err != nil {
+		writeChatError(w, errorStatus(err), err.Error())
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = s.agent.Name()
+	}
+	id := "chatcmpl-" + strings.TrimPrefix(rn.ID, "run_")
+	created := time.Now().Unix()
+
+	if req.Stream {
+		s.streamChat(w, r, rn, id, created, model)
+		return
+	}
+
+	select {
+	case <-rn.done:
+	case <-r.Context().Done():
+		rn.cancel()
+		<-rn.done
+	}
+	snap := s.snapshot(rn)
+	if snap.Status != StatusSucceeded {
+		writeChatError(w, http.StatusInternalServerError, snap.Error)
+		return
+	}
+	finish := finishReason(snap.Result)
+	writeJSON(w, http.StatusOK, ChatCompletion{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   model,
+		Choices: []ChatChoice{{
+			Message:      &ChatDelta{Role: "assistant", Content: outputText(snap.Result)},
+			FinishReason: &finish,
+		}},
+		Usage: chatUsage(snap.Result),
+	})
+}
+
+// streamChat sends the run as chat.completion.chunk events ending with
+// "data: [DONE]".
+func (s *Server) streamChat(w http.ResponseWriter, r *http.Request, rn *run, id string, created int64, model string) {
+	chunk := func(delta ChatDelta, finish *string, usage *ChatUsage) error {
+		data, err := json.Marshal(ChatCompletion{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChatChoice{{Delta: &delta, FinishReason: finish}},
+			Usage:   usage,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		return err
+	}
+
+	sentRole, sentAnswer := false, false
+	s.streamSSE(w, r, rn, 0, func(e sseEvent) error {
+		if !sentRole {
+			sentRole = true
+			if err := chunk(ChatDelta{Role: "assistant"}, nil, nil); err != nil {
+				return err
+			}
+		}
+		switch e.name {
+		case "delta":
+			return chunk(ChatDelta{ReasoningContent: e.value.(DeltaPayload).Content}, nil, nil)
+		case "answer_delta":
+			sentAnswer = true
+			return chunk(ChatDelta{Content: e.value.(DeltaPayload).Content}, nil, nil)
+		case "done":
+			done := e.value.(Run)
+			if done.Status != StatusSucceeded {
+				data, _ := json.Marshal(chatError{Error: chatErrorBody{Message: done.Error, Type: "server_error"}})
+				_, err := fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+				return err
+			}
+			if !sentAnswer {
+				if err := chunk(ChatDelta{Content: outputText(done.Result)}, nil, nil); err != nil {
+					return err
+				}
+			}
+			finish := finishReason(done.Result)
+			if err := chunk(ChatDelta{}, &finish, chatUsage(done.Result)); err != nil {
+				return err
+			}
+			_, err := fmt.Fprint(w, "data: [DONE]\n\n")
+			return err
+		}
+		return nil
+	})
+}
+
+// handleModels lists the agent as the only model, for clients that check
+// the model list before chatting.
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	name := s.agent.Name()
+	if name == "" {
+		name = "neko-agent"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   []map[string]any{{"id": name, "object": "model", "owned_by": "neko"}},
+	})
+}
+
+// chatTask turns a conversation into an agent task: the last user message,
+// preceded by any system instructions and earlier turns.
+func chatTask(messages []ChatMessage) (string, [][]byte, error) {
+	last := -1
+	for i, m := range messages {
+		if m.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return "", nil, fmt.Errorf("messages must include a user message")
+	}
+
+	var instructions, history []string
+	var images [][]byte
+	for i, m := range messages[:last+1] {
+		text, imgs, err := chatContent(m.Content)
+		if err != nil {
+			return "", nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		switch {
+		case m.Role == "system" || m.Role == "developer":
+			instructions = append(instructions, text)
+		case i == last:
+			images = append(images, imgs...)
+			var sb strings.Builder
+			if len(instructions) > 0 {
+				sb.WriteString("Instructions:\n" + strings.Join(instructions, "\n") + "\n\n")
+			}
+			if len(history) > 0 {
+				sb.WriteString("Conversation so far:\n" + strings.Join(history, "\n") + "\n\n")
+			}
+			sb.WriteString(text)
+			return sb.String(), images, nil
+		default:
+			history = append(history, m.Role+": "+text)
+		}
+	}
+	return "", nil, fmt.Errorf("messages must include a user message")
+}
+
+// chatContent decodes message content given as a string or content parts.
+func chatContent(raw json.RawMessage) (string, [][]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil, nil
+	}
+	var parts []chatContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil, fmt.Errorf("invalid content: %w", err)
+	}
+	var texts []string
+	var images [][]byte
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			texts = append(texts, p.Text)
+		case "image_url":
+			if p.ImageURL == nil {
+				continue
+			}
+			img, err := decodeDataURL(p.ImageURL.URL)
+			if err != nil {
+				return "", nil, err
+			}
+			images = append(images, img)
+		}
+	}
+	return strings.Join(texts, "\n"), images, nil
+}
+
+// decodeDataURL decodes a base64 data URL. Remote image URLs are not
+// fetched.
+func decodeDataURL(u string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(u, "data:")
+	if !ok {
+		return nil, fmt.Errorf("only data URL images are supported")
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return nil, fmt.Errorf("image data URL must be base64 encoded")
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
+func outputText(result *neko.RunResult) string {
+	if result == nil || result.Output == nil {
+		return ""
+	}
+	if s, ok := result.Output.(string); ok {
+		return s
+	}
+	return fmt.Sprint(result.Output)
+}
+
+// finishReason is "stop" for answered runs and "length" for runs that hit
+// their step limit or budget.
+func finishReason(result *neko.RunResult) string {
+	if result != nil && (result.State == "max_steps_error" || result.State == "budget_exceeded") {
+		return "length"
+	}
+	return "stop"
+}
+
+func chatUsage(result *neko.RunResult) *ChatUsage {
+	if result == nil || result.TokenUsage == nil {
+		return nil
+	}
+	return &ChatUsage{
+		PromptTokens:     result.TokenUsage.InputTokens,
+		CompletionTokens: result.TokenUsage.OutputTokens,
+		TotalTokens:      result.TokenUsage.Total(),
+	}
+}
+
+type chatError struct {
+	Error chatErrorBody `json:"error"`
+}
+
+type chatErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// writeChatError writes an error in the OpenAI error shape.
+func writeChatError(w http.ResponseWriter, status int, msg string) {
+	typ := "server_error"
+	if status < 500 {
+		typ = "invalid_request_error"
+	}
+	writeJSON(w, status, chatError{Error: chatErrorBody{Message: msg, Type: typ}})
+}
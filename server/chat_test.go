@@ -0,0 +1,44 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+func TestChatCompletionImageReachesModel(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	model := testutil.NewReplayModel(&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{
+		{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": "a cat"}},
+	}})
+	agent := neko.NewToolCallingAgent(neko.WithModel(model), neko.WithModelCapabilities(neko.Capabilities{Tools: true, Vision: true}))
+	body := `{"messages":[{"role":"user","content":[` +
+		`{"type":"text","text":"what is this?"},` +
+		`{"type":"image_url","image_url":{"url":"data:image/png;base64,` + base64.StdEncoding.EncodeToString(image) + `"}}]}]}`
+	w := httptest.NewRecorder()
+	New(agent).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "a cat") {
+		t.Errorf("response = %s, want the agent's answer", w.Body)
+	}
+
+	requests := model.Requests()
+	if len(requests) == 0 {
+		t.Fatal("model was not called")
+	}
+	var got [][]byte
+	for _, msg := range requests[0] {
+		got = append(got, msg.Images...)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], image) {
+		t.Errorf("model saw images %q, want the request's image", got)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// ErrShuttingDown is returned for runs requested while the server shuts
+// down.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// RunCheckpoint is the state of a run interrupted by Shutdown.
+type RunCheckpoint struct {
+	ID             string    `json:"id"`
+	Tenant         string    `json:"tenant,omitempty"` // the ID of the tenant that started it
+	Task           string    `json:"task"`
+	CreatedAt      time.Time `json:"created_at"`
+	CheckpointedAt time.Time `json:"checkpointed_at"`
+	// Memory holds the steps the run recorded, starting with its task,
+	// and is nil if the run was still queued. Continue the run on
+	// another server with neko.WithResume.
+	Memory *neko.Memory `json:"memory,omitempty"`
+}
+
+// CheckpointStore saves runs interrupted by Shutdown; see
+// WithCheckpointStore.
+type CheckpointStore interface {
+	Save(c *RunCheckpoint) error
+}
+
+// WithCheckpointStore saves the runs that Shutdown cancels to store, so
+// they can be continued after a restart.
+func WithCheckpointStore(store CheckpointStore) Option {
+	return func(s *Server) { s.checkpoints = store }
+}
+
+// FileCheckpoints is a CheckpointStore appending checkpoints to a JSONL
+// file, one per line.
+type FileCheckpoints struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCheckpoints creates a store in the JSONL file at path, created
+// on the first save.
+func NewFileCheckpoints(path string) *FileCheckpoints {
+	return &FileCheckpoints{path: path}
+}
+
+// Save appends c to the file.
+func (f *FileCheckpoints) Save(c *RunCheckpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("checkpoint run %s: %w", c.ID, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load returns the checkpoints in the file, oldest first. A missing file
+// has none.
+func (f *FileCheckpoints) Load() ([]*RunCheckpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cps []*RunCheckpoint
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var c RunCheckpoint
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("checkpoint line %d: %w", line, err)
+		}
+		cps = append(cps, &c)
+	}
+	return cps, scanner.Err()
+}
+
+// ShutdownError is returned by Shutdown when runs did not finish in time.
+type ShutdownError struct {
+	// Runs are the IDs of the canceled runs, checkpointed if the server
+	// has a CheckpointStore.
+	Runs []string
+	// Err is the shutdown context's error, joined with any checkpoint
+	// failures.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown interrupted %d runs (%s): %v", len(e.Runs), strings.Join(e.Runs, ", "), e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error { return e.Err }
+
+// checkpoint returns the state of rn for resuming it. s.mu must be held.
+func (s *Server) checkpoint(rn *run) *RunCheckpoint {
+	c := &RunCheckpoint{ID: rn.ID, Task: rn.Task, CreatedAt: rn.CreatedAt, CheckpointedAt: time.Now().UTC()}
+	if rn.tenant != nil {
+		c.Tenant = rn.tenant.ID
+	}
+	for _, ev := range rn.events {
+		if p, ok := ev.value.(StepPayload); ok {
+			if c.Memory == nil {
+				c.Memory = neko.NewMemory(rn.systemPrompt)
+			}
+			c.Memory.AddStep(p.Step)
+		}
+	}
+	return c
+}
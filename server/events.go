@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// sseHeartbeat is how often an idle event stream sends a comment to keep
+// proxies from closing it.
+const sseHeartbeat = 15 * time.Second
+
+// sseEvent is one recorded Server-Sent Event of a run.
+type sseEvent struct {
+	id    int
+	name  string
+	data  []byte
+	value any // the payload before encoding
+}
+
+// StepPayload is the data of a "step" event.
+type StepPayload struct {
+	StepType string    `json:"step_type"`
+	Step     neko.Step `json:"step"`
+}
+
+// DeltaPayload is the data of a "delta" event: a chunk of model output,
+// for "answer_delta" events a chunk of a final answer streamed with
+// neko.WithStreamedFinalAnswer, or for "execution_log" events a line of
+// executor output.
+type DeltaPayload struct {
+	StepNumber int    `json:"step_number"`
+	Content    string `json:"content"`
+}
+
+// eventSource is implemented by agents that publish events, such as
+// ToolCallingAgent and CodeAgent.
+type eventSource interface {
+	Events() *neko.EventBus
+}
+
+// streamEvents records the agent's events for rn until the returned
+// function is called. Agents without an event bus only produce "status"
+// and "done" events.
+func (s *Server) streamEvents(rn *run) (unsubscribe func()) {
+	src, ok := s.agent.(eventSource)
+	if !ok {
+		return func() {}
+	}
+	bus := src.Events()
+	unsubs := []func(){
+		bus.Subscribe(neko.EventRunStarted, func(e neko.Event) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			rn.systemPrompt = e.(neko.RunStartedEvent).SystemPrompt
+		}),
+		bus.Subscribe(neko.EventStep, func(e neko.Event) {
+			step := e.(neko.StepEvent).Step
+			s.emit(rn, "step", StepPayload{StepType: step.StepType(), Step: step})
+		}),
+		bus.Subscribe(neko.EventModelDelta, func(e neko.Event) {
+			ev := e.(neko.ModelDeltaEvent)
+			s.emit(rn, "delta", DeltaPayload{StepNumber: ev.StepNumber, Content: ev.Delta})
+		}),
+		bus.Subscribe(neko.EventAnswerDelta, func(e neko.Event) {
+			ev := e.(neko.AnswerDeltaEvent)
+			s.emit(rn, "answer_delta", DeltaPayload{StepNumber: ev.StepNumber, Content: ev.Delta})
+		}),
+		bus.Subscribe(neko.EventExecutionLog, func(e neko.Event) {
+			ev := e.(neko.ExecutionLogEvent)
+			s.emit(rn, "execution_log", DeltaPayload{StepNumber: ev.StepNumber, Content: ev.Line})
+		}),
+		bus.Subscribe(neko.EventBudgetWarning, func(e neko.Event) {
+			s.emit(rn, "budget_warning", e)
+		}),
+		bus.Subscribe(neko.EventToolProgress, func(e neko.Event) {
+			s.emit(rn, "tool_progress", e)
+		}),
+	}
+	return func() {
+		for _, fn := range unsubs {
+			fn()
+		}
+	}
+}
+
+func (s *Server) emit(rn *run, name string, v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appendEvent(rn, name, v)
+}
+
+// appendEvent records an event and wakes the run's streams. s.mu must be
+// held.
+func (s *Server) appendEvent(rn *run, name string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	rn.events = append(rn.events, sseEvent{id: len(rn.events) + 1, name: name, data: data, value: v})
+	close(rn.changed)
+	rn.changed = make(chan struct{})
+}
+
+// handleRunEvents streams a run's events from its start, or after the
+// Last-Event-ID a reconnecting client sends, and ends with a "done" event
+// carrying the finished Run.
+func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	rn, ok := s.lookup(r, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	next := 0
+	if id, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && id > 0 {
+		next = id
+	}
+	s.streamSSE(w, r, rn, next, func(e sseEvent) error {
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.name, e.data)
+		return err
+	})
+}
+
+// streamSSE starts an event stream response and calls write for each of
+// rn's events from index next on as they are recorded, until the run
+// finishes, a write fails or the client goes away. Idle streams get
+// keep-alive comments.
+func (s *Server) streamSSE(w http.ResponseWriter, r *http.Request, rn *run, next int, write func(sseEvent) error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		s.mu.Lock()
+		var pending []sseEvent
+		if next < len(rn.events) {
+			pending = rn.events[next:]
+		}
+		changed, finished := rn.changed, rn.FinishedAt != nil
+		s.mu.Unlock()
+
+		for _, e := range pending {
+			if err := write(e); err != nil {
+				return
+			}
+		}
+		next += len(pending)
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,548 @@
+// Package server exposes an agent as an HTTP service.
+//
+//	POST /v1/runs               start a run; returns the RunResult, or 202 and a run ID when async
+//	GET  /v1/runs/{id}          get a run's status and, once finished, its result
+//	GET  /v1/runs/{id}/events   stream a run's progress as Server-Sent Events
+//	POST /v1/chat/completions   run the agent behind the OpenAI chat completions API
+//	GET  /v1/models             list the agent as a model, for OpenAI clients
+//	GET  /v1/agent              the agent's neko.Manifest: tools, managed agents, model and limits
+//	GET  /v1/sessions           multi-turn WebSocket sessions, see WithSessionManager
+//	GET  /v1/usage              the calling tenant's usage, see WithTenants
+//	GET  /healthz               liveness check; 503 once shutting down
+//	GET  /                      web chat UI, see WithUI
+//
+// A run request is JSON:
+//
+//	{"task": "...", "async": true, "max_steps": 10, "reset": true, "images": ["<base64>"]}
+//
+// The event stream sends "status", "step", "delta" (streamed model output),
+// "answer_delta" (a streamed final answer), "execution_log",
+// "budget_warning", "tool_progress" and finally "done" events, each with a JSON payload. Reconnecting clients resume after their Last-Event-ID.
+//
+// Runs execute one at a time, since an agent keeps its memory between
+// steps; further requests wait in the "queued" state, and leave the queue
+// when canceled. WithRunQueue also holds runs to a limit shared with
+// other servers and agents.
+//
+// Shutdown drains the server: new runs are refused while in-flight ones
+// finish, and runs still unfinished at its deadline are canceled and, with
+// WithCheckpointStore, checkpointed for resuming elsewhere.
+//
+// With WithTenants, requests must carry a tenant's API key, and each
+// tenant's runs are limited to its allowed models and tools and its quota.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Run states.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// RunRequest is the body of POST /v1/runs.
+type RunRequest struct {
+	Task     string   `json:"task"`
+	Async    bool     `json:"async,omitempty"`
+	MaxSteps int      `json:"max_steps,omitempty"` // defaults to the agent's
+	Reset    *bool    `json:"reset,omitempty"`     // defaults to true
+	Images   [][]byte `json:"images,omitempty"`    // base64 in JSON
+}
+
+// Run is a run's state, as returned by GET /v1/runs/{id}.
+type Run struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	Task       string          `json:"task"`
+	Result     *neko.RunResult `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Server serves an agent over HTTP.
+type Server struct {
+	agent           neko.Agent
+	runTimeout      time.Duration
+	retention       time.Duration
+	shutdownTimeout time.Duration
+	maxBodyBytes    int64
+
+	ctx    context.Context // canceled to abort in-flight runs
+	cancel context.CancelFunc
+	turns  *neko.RunQueue // admits runs to the agent one at a time
+	queue  *neko.RunQueue // shared limit set with WithRunQueue, if any
+	wg     sync.WaitGroup
+	mux    *http.ServeMux
+
+	sessions *SessionManager
+	ui       bool
+	tenants  map[string]*tenant // by API key
+
+	checkpoints CheckpointStore
+
+	mu       sync.Mutex
+	runs     map[string]*run
+	draining bool // set by Shutdown to refuse new runs
+}
+
+// run is a Run plus the state needed to manage it. Its events and
+// changed channel are guarded by Server.mu.
+type run struct {
+	Run
+	cancel  context.CancelFunc
+	done    chan struct{}
+	events  []sseEvent
+	changed chan struct{} // closed and replaced when events are added
+	tenant  *tenant
+
+	systemPrompt string // set when the run starts, for checkpoints
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithRunTimeout cancels runs that take longer than d. Zero, the default,
+// means no limit.
+func WithRunTimeout(d time.Duration) Option {
+	return func(s *Server) { s.runTimeout = d }
+}
+
+// WithRetention sets how long finished runs can be fetched. Defaults to
+// one hour.
+func WithRetention(d time.Duration) Option {
+	return func(s *Server) { s.retention = d }
+}
+
+// WithShutdownTimeout sets how long ListenAndServe waits for in-flight
+// runs when shutting down before canceling them. Defaults to 30 seconds.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Server) { s.shutdownTimeout = d }
+}
+
+// WithMaxBodyBytes limits the size of request bodies. Defaults to 32 MiB.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) { s.maxBodyBytes = n }
+}
+
+// WithRunQueue makes runs wait for a place in q once the agent is free,
+// so q limits the runs of this server together with the other servers
+// and agents given it, e.g. the agents a SessionManager creates. Do not
+// give q to the server's agent as well, or each run would need two
+// places.
+func WithRunQueue(q *neko.RunQueue) Option {
+	return func(s *Server) { s.queue = q }
+}
+
+// New creates a server for agent. The agent may be nil when the server
+// only serves sessions from WithSessionManager.
+func New(agent neko.Agent, opts ...Option) *Server {
+	s := &Server{
+		agent:           agent,
+		retention:       time.Hour,
+		shutdownTimeout: 30 * time.Second,
+		maxBodyBytes:    32 << 20,
+		turns:           neko.NewRunQueue(1),
+		mux:             http.NewServeMux(),
+		runs:            make(map[string]*run),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if agent != nil {
+		s.mux.HandleFunc("POST /v1/runs", s.handleCreateRun)
+		s.mux.HandleFunc("GET /v1/runs/{id}", s.handleGetRun)
+		s.mux.HandleFunc("GET /v1/runs/{id}/events", s.handleRunEvents)
+		s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+		s.mux.HandleFunc("GET /v1/models", s.handleModels)
+		s.mux.HandleFunc("GET /v1/agent", s.handleDescribe)
+		if s.ui {
+			s.mux.HandleFunc("GET /{$}", s.handleUI)
+		}
+	}
+	if s.sessions != nil {
+		s.mux.HandleFunc("GET /v1/sessions", s.handleSession)
+	}
+	if s.tenants != nil {
+		s.mux.HandleFunc("GET /v1/usage", s.handleUsage)
+	}
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if s.isDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is canceled, then shuts down
+// gracefully: it stops accepting requests and waits for in-flight runs up
+// to the shutdown timeout before canceling them, as Shutdown does.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	hs := &http.Server{Addr: addr, Handler: s}
+	errc := make(chan error, 1)
+	go func() { errc <- hs.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	s.drain()
+	err := hs.Shutdown(shutdownCtx)
+	// The runs' error reports which were interrupted.
+	if serr := s.Shutdown(shutdownCtx); serr != nil {
+		err = serr
+	}
+	return err
+}
+
+// Shutdown stops accepting runs and waits for in-flight ones, including
+// async and queued ones, to finish. When ctx ends first, the remaining
+// runs are canceled, saved to the CheckpointStore if there is one, and
+// reported in a *ShutdownError wrapping ctx's error. New runs are refused
+// with ErrShuttingDown from the start, but other requests are not, so
+// stop serving them too, e.g. with http.Server.Shutdown.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.drain()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	var unfinished []*run
+	for _, rn := range s.runs {
+		if rn.FinishedAt == nil {
+			unfinished = append(unfinished, rn)
+		}
+	}
+	s.mu.Unlock()
+	slices.SortFunc(unfinished, func(a, b *run) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	s.cancel()
+	<-done
+
+	serr := &ShutdownError{}
+	errs := []error{ctx.Err()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rn := range unfinished {
+		if rn.Status != StatusCanceled {
+			continue // finished before being canceled
+		}
+		serr.Runs = append(serr.Runs, rn.ID)
+		if s.checkpoints != nil {
+			if err := s.checkpoints.Save(s.checkpoint(rn)); err != nil {
+				errs = append(errs, fmt.Errorf("checkpoint run %s: %w", rn.ID, err))
+			}
+		}
+	}
+	if len(serr.Runs) == 0 {
+		return ctx.Err()
+	}
+	serr.Err = errors.Join(errs...)
+	return serr
+}
+
+// admit waits until a run may start: the agent's turn, since it runs one
+// task at a time and its events must not mix with another run's, then a
+// place in the shared run queue, if any.
+func (s *Server) admit(ctx context.Context) (release func(), err error) {
+	own, err := s.turns.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.queue == nil {
+		return own, nil
+	}
+	shared, err := s.queue.Acquire(ctx)
+	if err != nil {
+		own()
+		return nil, err
+	}
+	return func() {
+		shared()
+		own()
+	}, nil
+}
+
+// drain makes the server refuse new runs. Once it returns, no run can be
+// added to s.wg, so waiting on it is safe.
+func (s *Server) drain() {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+}
+
+// isDraining reports whether the server refuses new runs.
+func (s *Server) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+func (s *Server) handleCreateRun(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.Task == "" {
+		writeError(w, http.StatusBadRequest, "task is required")
+		return
+	}
+
+	rn, err := s.start(r.Context(), &req)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	if req.Async {
+		w.Header().Set("Location", "/v1/runs/"+rn.ID)
+		writeJSON(w, http.StatusAccepted, s.snapshot(rn))
+		return
+	}
+
+	select {
+	case <-rn.done:
+	case <-r.Context().Done():
+		rn.cancel()
+		<-rn.done
+	}
+	snap := s.snapshot(rn)
+	if snap.Status != StatusSucceeded {
+		writeError(w, http.StatusInternalServerError, snap.Error)
+		return
+	}
+	writeJSON(w, http.StatusOK, snap.Result)
+}
+
+func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	rn, ok := s.lookup(r, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(rn))
+}
+
+// handleDescribe serves the agent's manifest, listing only the tools the
+// request's tenant may call.
+func (s *Server) handleDescribe(w http.ResponseWriter, r *http.Request) {
+	m := &neko.Manifest{Name: s.agent.Name(), Description: s.agent.Description()}
+	if d, ok := s.agent.(neko.Describer); ok {
+		m = d.Describe()
+	}
+	if t := tenantFrom(r.Context()); t != nil && len(t.Tools) > 0 {
+		m = m.Restrict(t.Tools...)
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// lookup returns a run started by the request's tenant.
+func (s *Server) lookup(r *http.Request, id string) (*run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rn, ok := s.runs[id]
+	if !ok || rn.tenant != tenantFrom(r.Context()) {
+		return nil, false
+	}
+	return rn, true
+}
+
+// start registers a run and executes it in the background.
+func (s *Server) start(reqCtx context.Context, req *RunRequest) (*run, error) {
+	if s.isDraining() {
+		return nil, ErrShuttingDown
+	}
+	opts := runOptions(req)
+	t := tenantFrom(reqCtx)
+	if t != nil {
+		limits, err := t.admit(s.agent)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, limits...)
+	}
+	id, err := newID("run_")
+	if err != nil {
+		return nil, err
+	}
+	// Async runs outlive the request; sync runs end with it.
+	parent := s.ctx
+	if !req.Async {
+		parent = mergeCancel(s.ctx, reqCtx)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	if s.runTimeout > 0 {
+		ctx, cancel = withTimeout(ctx, cancel, s.runTimeout)
+	}
+	rn := &run{
+		Run:     Run{ID: id, Status: StatusQueued, Task: req.Task, CreatedAt: time.Now().UTC()},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+		tenant:  t,
+	}
+
+	// The draining check and wg.Add happen under s.mu, as drain does,
+	// so Shutdown either waits for this run or it is refused.
+	s.mu.Lock()
+	if s.draining || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		cancel()
+		return nil, ErrShuttingDown
+	}
+	s.prune()
+	s.runs[id] = rn
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go s.execute(ctx, rn, opts)
+	return rn, nil
+}
+
+func (s *Server) execute(ctx context.Context, rn *run, opts []neko.RunOption) {
+	defer s.wg.Done()
+	defer close(rn.done)
+	defer rn.cancel()
+
+	var result *neko.RunResult
+	release, err := s.admit(ctx)
+	if err == nil {
+		defer release()
+		s.setStatus(rn, StatusRunning)
+		unsubscribe := s.streamEvents(rn)
+		metered := func(*neko.RunResult) {}
+		if rn.tenant != nil {
+			metered = rn.tenant.meter(s.agent)
+		}
+		result, err = s.agent.Run(ctx, rn.Task, opts...)
+		metered(result)
+		unsubscribe()
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rn.Result, rn.FinishedAt = result, &now
+	switch {
+	case err == nil:
+		rn.Status = StatusSucceeded
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		rn.Status, rn.Error = StatusCanceled, err.Error()
+	default:
+		rn.Status, rn.Error = StatusFailed, err.Error()
+	}
+	s.appendEvent(rn, "done", rn.Run)
+}
+
+func (s *Server) setStatus(rn *run, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rn.Status = status
+	s.appendEvent(rn, "status", map[string]string{"status": status})
+}
+
+// snapshot returns a copy of a run's public state.
+func (s *Server) snapshot(rn *run) Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return rn.Run
+}
+
+// prune drops finished runs older than the retention period. s.mu must be
+// held.
+func (s *Server) prune() {
+	cutoff := time.Now().Add(-s.retention)
+	for id, rn := range s.runs {
+		if rn.FinishedAt != nil && rn.FinishedAt.Before(cutoff) {
+			delete(s.runs, id)
+		}
+	}
+}
+
+func runOptions(req *RunRequest) []neko.RunOption {
+	var opts []neko.RunOption
+	if req.MaxSteps > 0 {
+		opts = append(opts, neko.WithMaxSteps(req.MaxSteps))
+	}
+	if req.Reset != nil {
+		opts = append(opts, neko.WithReset(*req.Reset))
+	}
+	if len(req.Images) > 0 {
+		opts = append(opts, neko.WithImages(req.Images...))
+	}
+	return opts
+}
+
+func newID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// mergeCancel returns a context derived from a that is also canceled
+// when b is.
+func mergeCancel(a, b context.Context) context.Context {
+	ctx, cancel := context.WithCancelCause(a)
+	context.AfterFunc(b, func() { cancel(context.Cause(b)) })
+	return ctx
+}
+
+// withTimeout adds a timeout to ctx, returning a cancel func that also
+// calls cancel.
+func withTimeout(ctx context.Context, cancel context.CancelFunc, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancelTimeout := context.WithTimeout(ctx, d)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// blockingAgent runs until release is closed or its context ends.
+type blockingAgent struct {
+	release chan struct{}
+}
+
+func (a *blockingAgent) Run(ctx context.Context, task string, opts ...neko.RunOption) (*neko.RunResult, error) {
+	select {
+	case <-a.release:
+		return &neko.RunResult{Output: task, State: "success"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *blockingAgent) Name() string        { return "blocking" }
+func (a *blockingAgent) Description() string { return "" }
+
+func TestShutdownWaitsForRuns(t *testing.T) {
+	agent := &blockingAgent{release: make(chan struct{})}
+	s := New(agent)
+	rn, err := s.start(context.Background(), &RunRequest{Task: "t", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Shutdown(context.Background()) }()
+	for !s.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := s.start(context.Background(), &RunRequest{Task: "late", Async: true}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("start while draining: err = %v, want ErrShuttingDown", err)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("Shutdown returned %v before the run finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(agent.release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.snapshot(rn).Status; got != StatusSucceeded {
+		t.Errorf("status = %s, want %s", got, StatusSucceeded)
+	}
+}
+
+func TestShutdownRacingStarts(t *testing.T) {
+	agent := &blockingAgent{release: make(chan struct{})}
+	close(agent.release)
+	s := New(agent)
+
+	var mu sync.Mutex
+	var started []*run
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rn, err := s.start(context.Background(), &RunRequest{Task: "t", Async: true}); err == nil {
+				mu.Lock()
+				started = append(started, rn)
+				mu.Unlock()
+			}
+		}()
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, rn := range started {
+		select {
+		case <-rn.done:
+		default:
+			t.Errorf("run %s still in flight after Shutdown returned", rn.ID)
+		}
+	}
+}
+
+func TestShutdownCancelsAfterDeadline(t *testing.T) {
+	s := New(&blockingAgent{release: make(chan struct{})})
+	rn, err := s.start(context.Background(), &RunRequest{Task: "t", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var serr *ShutdownError
+	if err := s.Shutdown(ctx); !errors.As(err, &serr) {
+		t.Fatalf("err = %v, want a *ShutdownError", err)
+	}
+	if len(serr.Runs) != 1 || serr.Runs[0] != rn.ID {
+		t.Errorf("interrupted runs = %v, want [%s]", serr.Runs, rn.ID)
+	}
+}
+
+func TestQueuedRunLeavesWhenCanceled(t *testing.T) {
+	agent := &blockingAgent{release: make(chan struct{})}
+	defer close(agent.release)
+	s := New(agent)
+	if _, err := s.start(context.Background(), &RunRequest{Task: "first", Async: true}); err != nil {
+		t.Fatal(err)
+	}
+	rn, err := s.start(context.Background(), &RunRequest{Task: "second", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rn.cancel()
+	select {
+	case <-rn.done:
+	case <-time.After(time.Second):
+		t.Fatal("canceled run still waiting for the agent")
+	}
+	if got := s.snapshot(rn).Status; got != StatusCanceled {
+		t.Errorf("status = %s, want %s", got, StatusCanceled)
+	}
+}
+
+func TestServersShareRunQueue(t *testing.T) {
+	q := neko.NewRunQueue(1)
+	a := &blockingAgent{release: make(chan struct{})}
+	b := &blockingAgent{release: make(chan struct{})}
+	s1, s2 := New(a, WithRunQueue(q)), New(b, WithRunQueue(q))
+
+	rn1, err := s1.start(context.Background(), &RunRequest{Task: "a", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s1.snapshot(rn1).Status != StatusRunning {
+		time.Sleep(time.Millisecond)
+	}
+	rn2, err := s2.start(context.Background(), &RunRequest{Task: "b", Async: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s2.snapshot(rn2).Status; got != StatusQueued {
+		t.Errorf("second server's run is %s, want %s", got, StatusQueued)
+	}
+	close(a.release)
+	close(b.release)
+	<-rn2.done
+	if got := s2.snapshot(rn2).Status; got != StatusSucceeded {
+		t.Errorf("status = %s, want %s", got, StatusSucceeded)
+	}
+}
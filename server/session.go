@@ -0,0 +1,318 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// AgentFactory creates the agent for a new session. It must apply opts,
+// which connect the agent to the session, e.g.:
+//
+//	func(opts ...neko.AgentOption) neko.Agent {
+//		return neko.NewToolCallingAgent(append([]neko.AgentOption{neko.WithModel(model)}, opts...)...)
+//	}
+type AgentFactory func(opts ...neko.AgentOption) neko.Agent
+
+// ErrRunInProgress is returned when a session is asked to run while a
+// turn is already running.
+var ErrRunInProgress = errors.New("a run is already in progress")
+
+// SessionManager keeps multi-turn conversations, each with its own agent
+// whose memory carries over from one turn to the next.
+type SessionManager struct {
+	factory AgentFactory
+	ttl     time.Duration
+	archive SessionArchive
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// SessionOption configures a SessionManager.
+type SessionOption func(*SessionManager)
+
+// WithSessionTTL closes sessions idle for longer than d, unless they
+// have a TTL of their own; see Session.SetTTL. Defaults to 30 minutes.
+func WithSessionTTL(d time.Duration) SessionOption {
+	return func(m *SessionManager) { m.ttl = d }
+}
+
+// WithSessionArchive saves sessions to a when they expire, so Restore can
+// bring them back. Sessions closed with Close, and sessions without a
+// turn, are not archived.
+func WithSessionArchive(a SessionArchive) SessionOption {
+	return func(m *SessionManager) { m.archive = a }
+}
+
+// NewSessionManager creates a session manager whose sessions use agents
+// made by factory.
+func NewSessionManager(factory AgentFactory, opts ...SessionOption) *SessionManager {
+	m := &SessionManager{factory: factory, ttl: 30 * time.Minute, sessions: make(map[string]*Session)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Create starts a new session.
+func (m *SessionManager) Create() (*Session, error) {
+	id, err := newID("sess_")
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{ID: id, lastUsed: time.Now()}
+	s.agent = m.factory(neko.WithToolApproval(s.approve))
+
+	m.mu.Lock()
+	expired := m.prune()
+	m.sessions[id] = s
+	m.mu.Unlock()
+	m.archiveAll(expired)
+	return s, nil
+}
+
+// Get returns a live session.
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	expired := m.prune()
+	s, ok := m.sessions[id]
+	m.mu.Unlock()
+	m.archiveAll(expired)
+	return s, ok
+}
+
+// Restore returns the session with the given ID, bringing it back from
+// the archive if it expired: a new agent continues the conversation
+// where it stopped. It returns an error matching ErrSessionNotArchived
+// if the session is neither live nor archived.
+func (m *SessionManager) Restore(id string) (*Session, error) {
+	if s, ok := m.Get(id); ok {
+		return s, nil
+	}
+	if m.archive == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotArchived, id)
+	}
+	a, err := m.archive.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{ID: id, owner: a.Tenant, turns: a.Turns, ttl: a.TTL, memory: a.Memory, restored: a.Memory, lastUsed: time.Now()}
+	s.agent = m.factory(neko.WithToolApproval(s.approve))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if live, ok := m.sessions[id]; ok {
+		return live, nil // restored concurrently
+	}
+	m.sessions[id] = s
+	return s, nil
+}
+
+// Close interrupts a session's run, if any, and forgets the session.
+func (m *SessionManager) Close(id string) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if ok {
+		s.Interrupt()
+	}
+}
+
+// prune closes idle sessions and returns them. m.mu must be held.
+func (m *SessionManager) prune() []*Session {
+	now := time.Now()
+	var expired []*Session
+	for id, s := range m.sessions {
+		if s.expired(now, m.ttl) {
+			delete(m.sessions, id)
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
+
+// archiveAll saves expired sessions to the archive, if any.
+func (m *SessionManager) archiveAll(expired []*Session) {
+	if m.archive == nil {
+		return
+	}
+	for _, s := range expired {
+		a := s.archived()
+		if a == nil {
+			continue
+		}
+		if err := m.archive.Save(a); err != nil {
+			slog.Error("archiving session failed", "session", s.ID, "error", err)
+		}
+	}
+}
+
+// ApprovalRequest asks the session's client to allow a tool call.
+type ApprovalRequest struct {
+	ID       string        `json:"id"`
+	ToolCall neko.ToolCall `json:"tool_call"`
+}
+
+// Session is a multi-turn conversation with an agent. It runs one turn at
+// a time.
+type Session struct {
+	ID     string
+	agent  neko.Agent
+	tenant *tenant
+	owner  string // the tenant ID of a restored session
+
+	mu       sync.Mutex
+	turns    int
+	running  bool
+	cancel   context.CancelFunc
+	lastUsed time.Time
+	ttl      time.Duration
+	memory   *neko.Memory // the conversation after the last turn
+	restored *neko.Memory // the archived conversation the next turn continues
+	approver func(ctx context.Context, req ApprovalRequest) (bool, error)
+	nextReq  int
+}
+
+// Agent returns the session's agent.
+func (s *Session) Agent() neko.Agent { return s.agent }
+
+// SetApprover sets the function asked to approve tool calls. With none,
+// tool calls are allowed.
+func (s *Session) SetApprover(fn func(ctx context.Context, req ApprovalRequest) (bool, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approver = fn
+}
+
+// SetTTL closes the session once idle for longer than d, instead of the
+// manager's TTL. Zero restores the manager's.
+func (s *Session) SetTTL(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = d
+}
+
+// Run runs the next turn. The first turn starts with empty memory; later
+// turns continue the conversation unless opts reset it. Sessions opened by
+// a tenant run within its limits.
+func (s *Session) Run(ctx context.Context, task string, opts ...neko.RunOption) (*neko.RunResult, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrRunInProgress
+	}
+	opts = append([]neko.RunOption{neko.WithReset(s.turns == 0)}, opts...)
+	if s.restored != nil && s.turns > 0 {
+		opts = append([]neko.RunOption{neko.WithMemory(s.restored)}, opts...)
+	}
+	metered := func(*neko.RunResult) {}
+	if s.tenant != nil {
+		limits, err := s.tenant.admit(s.agent)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		opts = append(opts, limits...)
+		metered = s.tenant.meter(s.agent)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.running, s.cancel = true, cancel
+	s.turns++
+	s.restored = nil
+	s.mu.Unlock()
+
+	defer func() {
+		cancel()
+		s.mu.Lock()
+		s.running, s.cancel, s.lastUsed = false, nil, time.Now()
+		s.mu.Unlock()
+	}()
+	result, err := s.agent.Run(ctx, task, opts...)
+	if result != nil {
+		s.mu.Lock()
+		s.memory = &neko.Memory{Steps: result.Steps}
+		s.mu.Unlock()
+	}
+	metered(result)
+	return result, err
+}
+
+// Interrupt cancels the running turn, if any.
+func (s *Session) Interrupt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Reset makes the next turn start a new conversation.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns = 0
+	s.memory, s.restored = nil, nil
+}
+
+func (s *Session) approve(ctx context.Context, tc neko.ToolCall) (bool, error) {
+	s.mu.Lock()
+	approver := s.approver
+	s.nextReq++
+	id := fmt.Sprintf("%s-%d", s.ID, s.nextReq)
+	s.mu.Unlock()
+	if approver == nil {
+		return true, nil
+	}
+	return approver(ctx, ApprovalRequest{ID: id, ToolCall: tc})
+}
+
+// ownedBy reports whether the session belongs to t, nil for sessions
+// opened without a tenant, and gives a restored session to its tenant.
+func (s *Session) ownedBy(t *tenant) bool {
+	if s.tenant != nil {
+		return s.tenant == t
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == "" {
+		return t == nil
+	}
+	if t == nil || t.ID != s.owner {
+		return false
+	}
+	s.tenant = t
+	return true
+}
+
+// expired reports whether the session has been idle for longer than its
+// TTL, or ttl if it has none.
+func (s *Session) expired(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ttl > 0 {
+		ttl = s.ttl
+	}
+	return !s.running && s.lastUsed.Before(now.Add(-ttl))
+}
+
+// archived returns the session's state for the archive, or nil if it has
+// no conversation to keep.
+func (s *Session) archived() *ArchivedSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.turns == 0 || s.memory == nil {
+		return nil
+	}
+	a := &ArchivedSession{ID: s.ID, Tenant: s.owner, Turns: s.turns, TTL: s.ttl, LastUsed: s.lastUsed, ArchivedAt: time.Now().UTC(), Memory: s.memory}
+	if s.tenant != nil {
+		a.Tenant = s.tenant.ID
+	}
+	return a
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Tenant is a team sharing the server. Its requests carry one of its API
+// keys as "Authorization: Bearer <key>" or in the X-API-Key header.
+// Browser WebSocket clients, which cannot set headers, may instead pass it
+// in the api_key query parameter of the upgrade request; other requests
+// ignore the parameter, since URLs end up in access logs.
+type Tenant struct {
+	ID      string
+	APIKeys []string
+	// Models lists the model IDs the tenant's runs may use. Empty allows
+	// any model.
+	Models []string
+	// Tools lists the tools and managed agents the tenant's runs may
+	// call. Empty allows all of them.
+	Tools []string
+	// Quota limits the tokens and cost the tenant uses in each Period;
+	// zero limits are unlimited. Each run is given what remains of the
+	// quota as a budget that stops it, so Thresholds warn at fractions of
+	// that remainder.
+	Quota neko.Budget
+	// Period is how often usage is reset. Zero never resets it.
+	Period time.Duration
+}
+
+// Usage is a tenant's consumption in its current period, as returned by
+// GET /v1/usage.
+type Usage struct {
+	Tenant string    `json:"tenant"`
+	Tokens int       `json:"tokens"`
+	Cost   float64   `json:"cost"`
+	Since  time.Time `json:"since"`
+}
+
+// Errors for requests outside a tenant's limits. ErrModelNotAllowed is
+// neko.ErrModelNotAllowed, which also fails runs that switch to a model
+// outside the tenant's list.
+var (
+	ErrModelNotAllowed = neko.ErrModelNotAllowed
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+)
+
+// WithTenants requires an API key on every request except GET /healthz
+// and the UI page, and runs each tenant's requests within its allowlists
+// and quota. Runs and sessions are only visible to the tenant that
+// started them. Usage is kept in memory, so it resets when the server
+// restarts.
+func WithTenants(tenants ...Tenant) Option {
+	return func(s *Server) {
+		s.tenants = make(map[string]*tenant)
+		for _, t := range tenants {
+			ts := &tenant{Tenant: t, usage: Usage{Tenant: t.ID, Since: time.Now().UTC()}}
+			for _, key := range t.APIKeys {
+				s.tenants[key] = ts
+			}
+		}
+	}
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant that made a request, for handlers
+// wrapped around the server.
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	t := tenantFrom(ctx)
+	if t == nil {
+		return Tenant{}, false
+	}
+	return t.Tenant, true
+}
+
+func tenantFrom(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantKey{}).(*tenant)
+	return t
+}
+
+// authenticate attaches the request's tenant to its context. It writes an
+// error and returns false for requests without a valid API key.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if s.tenants == nil || r.URL.Path == "/healthz" || (r.Method == http.MethodGet && r.URL.Path == "/") {
+		return r, true
+	}
+	t, ok := s.tenants[apiKey(r)]
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="neko"`)
+		if strings.HasPrefix(r.URL.Path, "/v1/chat/") || r.URL.Path == "/v1/models" {
+			writeChatError(w, http.StatusUnauthorized, "invalid API key")
+		} else {
+			writeError(w, http.StatusUnauthorized, "invalid API key")
+		}
+		return nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), tenantKey{}, t)), true
+}
+
+func apiKey(r *http.Request) string {
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return auth
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("api_key")
+	}
+	return ""
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	t := tenantFrom(r.Context())
+	if t == nil {
+		writeError(w, http.StatusNotFound, "tenants are not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, t.Usage())
+}
+
+// tenant is a Tenant and its usage.
+type tenant struct {
+	Tenant
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// Usage returns the tenant's usage in the current period.
+func (t *tenant) Usage() Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll()
+	return t.usage
+}
+
+// roll starts a new period if the current one is over. t.mu must be held.
+func (t *tenant) roll() {
+	if t.Period > 0 && time.Since(t.usage.Since) >= t.Period {
+		t.usage = Usage{Tenant: t.ID, Since: time.Now().UTC()}
+	}
+}
+
+func (t *tenant) charge(tokens int, cost float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roll()
+	t.usage.Tokens += tokens
+	t.usage.Cost += cost
+}
+
+// admit checks that the tenant may run agent and returns the run options
+// that apply its limits. Runs are refused up front if the agent's own
+// model is not allowed; other models the run reaches, such as a fallback
+// model or a sub-agent's, are checked by neko.WithAllowedModels as they
+// are used.
+func (t *tenant) admit(agent neko.Agent) ([]neko.RunOption, error) {
+	var opts []neko.RunOption
+	if len(t.Models) > 0 {
+		id := ""
+		if m, ok := agent.(interface{ Model() neko.Model }); ok && m.Model() != nil {
+			id = m.Model().ModelID()
+		}
+		if !slices.Contains(t.Models, id) {
+			return nil, fmt.Errorf("%w: %q", ErrModelNotAllowed, id)
+		}
+		opts = append(opts, neko.WithAllowedModels(t.Models...))
+	}
+
+	if len(t.Tools) > 0 {
+		opts = append(opts, neko.WithAllowedTools(t.Tools...))
+	}
+	u := t.Usage()
+	b := t.Quota
+	if b.MaxTokens > 0 {
+		if u.Tokens >= b.MaxTokens {
+			return nil, fmt.Errorf("%w: used %d of %d tokens", ErrQuotaExceeded, u.Tokens, b.MaxTokens)
+		}
+		b.MaxTokens -= u.Tokens
+	}
+	if b.MaxCost > 0 {
+		if u.Cost >= b.MaxCost {
+			return nil, fmt.Errorf("%w: used $%.4f of $%.4f", ErrQuotaExceeded, u.Cost, b.MaxCost)
+		}
+		b.MaxCost -= u.Cost
+	}
+	if b.MaxTokens > 0 || b.MaxCost > 0 {
+		b.Stop = true
+		opts = append(opts, neko.WithRunBudget(b))
+	}
+	return opts, nil
+}
+
+// meter charges the tokens agent uses to the tenant as its steps finish,
+// so concurrent runs see each other's usage. A step is charged for the
+// agent's model calls, the runs of the managed and spawned agents it
+// called and the usage its tools recorded. The returned function ends
+// metering; agents without an event bus are charged for the steps of the
+// whole result instead.
+func (t *tenant) meter(agent neko.Agent) (done func(*neko.RunResult)) {
+	var pricing neko.Pricing
+	if p, ok := agent.(interface{ Pricing() neko.Pricing }); ok {
+		pricing = p.Pricing()
+	}
+	src, ok := agent.(eventSource)
+	if !ok {
+		return func(result *neko.RunResult) {
+			if result == nil {
+				return
+			}
+			if len(result.Steps) == 0 && result.TokenUsage != nil {
+				t.charge(result.TokenUsage.Total(), result.Cost)
+				return
+			}
+			for _, step := range result.Steps {
+				t.charge(stepUsage(step, pricing))
+			}
+		}
+	}
+	unsubscribe := src.Events().Subscribe(neko.EventStep, func(e neko.Event) {
+		t.charge(stepUsage(e.(neko.StepEvent).Step, pricing))
+	})
+	return func(*neko.RunResult) { unsubscribe() }
+}
+
+// stepUsage returns the tokens and cost of step, including its sub-agent
+// runs and tool-recorded usage, pricing the agent's own with pricing.
+func stepUsage(step neko.Step, pricing neko.Pricing) (tokens int, cost float64) {
+	add := func(u *neko.TokenUsage) {
+		if u != nil {
+			tokens += u.Total()
+			cost += u.Cost(pricing)
+		}
+	}
+	switch s := step.(type) {
+	case *neko.ActionStep:
+		add(s.TokenUsage)
+		for _, u := range s.AgentUsage {
+			tokens += u.Total()
+			cost += u.Cost
+		}
+		for _, u := range s.ToolUsage {
+			add(&u)
+		}
+	case *neko.PlanningStep:
+		add(s.TokenUsage)
+	}
+	return tokens, cost
+}
+
+// errorStatus is the HTTP status for an error starting a run.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrModelNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
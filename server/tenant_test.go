@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+// busAgent publishes scripted steps on its event bus when run.
+type busAgent struct {
+	bus   *neko.EventBus
+	steps []neko.Step
+}
+
+func (a *busAgent) Run(ctx context.Context, task string, opts ...neko.RunOption) (*neko.RunResult, error) {
+	if a.bus != nil {
+		for _, s := range a.steps {
+			a.bus.Publish(neko.StepEvent{Step: s})
+		}
+	}
+	return &neko.RunResult{Steps: a.steps}, nil
+}
+
+func (a *busAgent) Name() string           { return "bus" }
+func (a *busAgent) Description() string    { return "" }
+func (a *busAgent) Events() *neko.EventBus { return a.bus }
+func (a *busAgent) Pricing() neko.Pricing  { return neko.Pricing{InputPerMillion: 1e6} }
+func (a *busAgent) Model() neko.Model      { return nil }
+
+// withoutEvents hides a busAgent's event bus.
+type withoutEvents struct{ neko.Agent }
+
+func (a withoutEvents) Pricing() neko.Pricing { return a.Agent.(*busAgent).Pricing() }
+
+func TestMeterChargesSubAgentsAndTools(t *testing.T) {
+	step := &neko.ActionStep{
+		StepNumber: 1,
+		TokenUsage: &neko.TokenUsage{InputTokens: 10},
+		AgentUsage: map[string]neko.Usage{"researcher": {TokenUsage: neko.TokenUsage{InputTokens: 100}, Cost: 5}},
+		ToolUsage:  map[string]neko.TokenUsage{"summarize": {InputTokens: 1}},
+	}
+
+	for _, bus := range []*neko.EventBus{neko.NewEventBus(), nil} {
+		agent := &busAgent{bus: bus, steps: []neko.Step{step}}
+		var a neko.Agent = agent
+		if bus == nil {
+			a = withoutEvents{agent}
+		}
+		tn := &tenant{}
+		done := tn.meter(a)
+		result, _ := a.Run(context.Background(), "task")
+		done(result)
+
+		u := tn.Usage()
+		if u.Tokens != 111 || math.Abs(u.Cost-16) > 1e-9 {
+			t.Errorf("events=%v: usage = %d tokens, $%g; want 111 tokens, $16", bus != nil, u.Tokens, u.Cost)
+		}
+	}
+}
+
+// modelAgent is an agent reporting a model with a fixed ID.
+type modelAgent struct {
+	neko.Agent
+	id string
+}
+
+func (a modelAgent) Model() neko.Model { return neko.NewOpenAIModel(a.id, "") }
+
+func TestAdmitLimitsModels(t *testing.T) {
+	tn := &tenant{Tenant: Tenant{Models: []string{"small"}}}
+	if _, err := tn.admit(modelAgent{id: "big"}); !errors.Is(err, ErrModelNotAllowed) {
+		t.Fatalf("err = %v, want ErrModelNotAllowed", err)
+	}
+	opts, err := tn.admit(modelAgent{id: "small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o neko.RunOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.Models) != 1 || o.Models[0] != "small" {
+		t.Errorf("run models = %v, want the tenant's allowlist", o.Models)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := New(&busAgent{}, WithTenants(Tenant{ID: "team", APIKeys: []string{"secret"}}))
+	for _, tc := range []struct {
+		name   string
+		target string
+		header map[string]string
+		want   int
+	}{
+		{"no key", "/v1/usage", nil, http.StatusUnauthorized},
+		{"wrong key", "/v1/usage", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"bearer", "/v1/usage", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"header", "/v1/usage", map[string]string{"X-API-Key": "secret"}, http.StatusOK},
+		{"query", "/v1/usage?api_key=secret", nil, http.StatusUnauthorized},
+		{"query on upgrade", "/v1/usage?api_key=secret", map[string]string{"Upgrade": "websocket"}, http.StatusOK},
+		{"health", "/healthz", nil, http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		for k, v := range tc.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	s := New(&busAgent{}, WithTenants(Tenant{ID: "team", APIKeys: []string{"secret"}, Quota: neko.Budget{MaxTokens: 100}}))
+	s.tenants["secret"].charge(100, 0)
+	r := httptest.NewRequest(http.MethodPost, "/v1/runs", strings.NewReader(`{"task":"hi"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "quota exceeded") {
+		t.Errorf("body = %s, want the quota error", w.Body)
+	}
+}
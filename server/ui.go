@@ -0,0 +1,23 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// WithUI serves a web chat page at GET / for talking to the agent and
+// inspecting the steps of each answer as they happen. The page is
+// self-contained and uses the run and event stream endpoints, so a
+// conversation continues the agent's memory until "New chat" is clicked.
+func WithUI() Option {
+	return func(s *Server) { s.ui = true }
+}
+
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(uiPage)
+}
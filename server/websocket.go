@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gocnn/neko"
+	"github.com/gorilla/websocket"
+)
+
+// ClientMessage is a message sent by a WebSocket client. Type is one of:
+//
+//	run        start a turn with Task, and optionally MaxSteps
+//	approval   answer the approval request ID with Approved
+//	interrupt  cancel the running turn
+//	reset      start a new conversation on the next turn
+//	close      end the session
+type ClientMessage struct {
+	Type     string `json:"type"`
+	Task     string `json:"task,omitempty"`
+	MaxSteps int    `json:"max_steps,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Approved bool   `json:"approved,omitempty"`
+}
+
+// ServerMessage is a message sent to a WebSocket client. Type is one of
+// "session" (sent on connect, with SessionID), "step", "delta",
+// "answer_delta", "budget_warning", "tool_progress" (with Progress),
+// "approval_request" (with ID and ToolCall), "result", "interrupted" or
+// "error".
+type ServerMessage struct {
+	Type       string              `json:"type"`
+	SessionID  string              `json:"session_id,omitempty"`
+	StepType   string              `json:"step_type,omitempty"`
+	Step       neko.Step           `json:"step,omitempty"`
+	StepNumber int                 `json:"step_number,omitempty"`
+	Content    string              `json:"content,omitempty"`
+	Budget     *neko.BudgetWarning `json:"budget,omitempty"`
+	Progress   *neko.ToolProgress  `json:"progress,omitempty"`
+	ID         string              `json:"id,omitempty"`
+	ToolCall   *neko.ToolCall      `json:"tool_call,omitempty"`
+	Result     *neko.RunResult     `json:"result,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+var errConnectionClosed = errors.New("connection closed")
+
+var upgrader = websocket.Upgrader{}
+
+// WithSessionManager serves multi-turn sessions from m over WebSocket at
+// GET /v1/sessions. Connecting without a session_id query parameter
+// starts a new session; passing the ID from the "session" message resumes
+// it. Closing the connection interrupts the running turn, but the session
+// stays available until it expires, and after that if the manager
+// archives sessions. Cross-origin connections are refused.
+func WithSessionManager(m *SessionManager) Option {
+	return func(s *Server) { s.sessions = m }
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	var sess *Session
+	if id := r.URL.Query().Get("session_id"); id != "" {
+		var err error
+		if sess, err = s.sessions.Restore(id); err != nil || !sess.ownedBy(tenantFrom(r.Context())) {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+	} else {
+		var err error
+		if sess, err = s.sessions.Create(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		sess.tenant = tenantFrom(r.Context())
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn, manager: s.sessions, session: sess, approvals: make(map[string]chan bool), closed: make(chan struct{})}
+	c.serve(s.ctx)
+}
+
+// wsConn is one WebSocket connection to a session.
+type wsConn struct {
+	conn    *websocket.Conn
+	manager *SessionManager
+	session *Session
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	approvals map[string]chan bool
+	closed    chan struct{}
+}
+
+// serve handles client messages until the connection closes, then
+// interrupts the running turn and waits for it to end.
+func (c *wsConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	unsubscribe := func() {}
+	if src, ok := c.session.Agent().(eventSource); ok {
+		unsubscribe = c.forwardEvents(src.Events())
+	}
+	var runs sync.WaitGroup
+	defer func() {
+		cancel()
+		close(c.closed)
+		runs.Wait()
+		unsubscribe()
+		c.conn.Close()
+	}()
+
+	c.session.SetApprover(c.approve)
+	c.send(ServerMessage{Type: "session", SessionID: c.session.ID})
+	for {
+		var msg ClientMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "run":
+			runs.Add(1)
+			go func() {
+				defer runs.Done()
+				c.run(ctx, msg)
+			}()
+		case "approval":
+			c.answer(msg.ID, msg.Approved)
+		case "interrupt":
+			c.session.Interrupt()
+		case "reset":
+			c.session.Reset()
+		case "close":
+			c.manager.Close(c.session.ID)
+			return
+		default:
+			c.send(ServerMessage{Type: "error", Error: "unknown message type: " + msg.Type})
+		}
+	}
+}
+
+func (c *wsConn) run(ctx context.Context, msg ClientMessage) {
+	if msg.Task == "" {
+		c.send(ServerMessage{Type: "error", Error: "task is required"})
+		return
+	}
+	var opts []neko.RunOption
+	if msg.MaxSteps > 0 {
+		opts = append(opts, neko.WithMaxSteps(msg.MaxSteps))
+	}
+	result, err := c.session.Run(ctx, msg.Task, opts...)
+	switch {
+	case err == nil:
+		c.send(ServerMessage{Type: "result", Result: result})
+	case errors.Is(err, context.Canceled):
+		c.send(ServerMessage{Type: "interrupted"})
+	default:
+		c.send(ServerMessage{Type: "error", Error: err.Error()})
+	}
+}
+
+// forwardEvents sends the agent's progress to the client until the
+// returned function is called.
+func (c *wsConn) forwardEvents(bus *neko.EventBus) (unsubscribe func()) {
+	unsubs := []func(){
+		bus.Subscribe(neko.EventStep, func(e neko.Event) {
+			step := e.(neko.StepEvent).Step
+			c.send(ServerMessage{Type: "step", StepType: step.StepType(), Step: step})
+		}),
+		bus.Subscribe(neko.EventModelDelta, func(e neko.Event) {
+			ev := e.(neko.ModelDeltaEvent)
+			c.send(ServerMessage{Type: "delta", StepNumber: ev.StepNumber, Content: ev.Delta})
+		}),
+		bus.Subscribe(neko.EventAnswerDelta, func(e neko.Event) {
+			ev := e.(neko.AnswerDeltaEvent)
+			c.send(ServerMessage{Type: "answer_delta", StepNumber: ev.StepNumber, Content: ev.Delta})
+		}),
+		bus.Subscribe(neko.EventBudgetWarning, func(e neko.Event) {
+			c.send(ServerMessage{Type: "budget_warning", Budget: e.(*neko.BudgetWarning)})
+		}),
+		bus.Subscribe(neko.EventToolProgress, func(e neko.Event) {
+			c.send(ServerMessage{Type: "tool_progress", Progress: e.(*neko.ToolProgress)})
+		}),
+	}
+	return func() {
+		for _, fn := range unsubs {
+			fn()
+		}
+	}
+}
+
+// approve asks the client about a tool call and waits for the answer.
+// Calls are denied once the connection is gone.
+func (c *wsConn) approve(ctx context.Context, req ApprovalRequest) (bool, error) {
+	reply := make(chan bool, 1)
+	c.mu.Lock()
+	c.approvals[req.ID] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.approvals, req.ID)
+		c.mu.Unlock()
+	}()
+
+	c.send(ServerMessage{Type: "approval_request", ID: req.ID, ToolCall: &req.ToolCall})
+	select {
+	case ok := <-reply:
+		return ok, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-c.closed:
+		return false, errConnectionClosed
+	}
+}
+
+func (c *wsConn) answer(id string, approved bool) {
+	c.mu.Lock()
+	reply, ok := c.approvals[id]
+	c.mu.Unlock()
+	if !ok {
+		c.send(ServerMessage{Type: "error", Error: "no pending approval " + id})
+		return
+	}
+	select {
+	case reply <- approved:
+	default: // already answered
+	}
+}
+
+func (c *wsConn) send(msg ServerMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteJSON(msg)
+}
@@ -0,0 +1,64 @@
+package neko
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Skill bundles the tools, instructions and examples an agent needs for
+// one kind of work, such as web research or data analysis, so they can
+// be added together with WithSkills.
+type Skill struct {
+	Name        string
+	Description string
+	Tools       []Tool
+	// Instructions tell the model how to use the tools well, e.g. to
+	// read a page before citing it.
+	Instructions string
+	Examples     []Example
+}
+
+// WithSkills adds skills to the agent: their tools are registered, their
+// descriptions and instructions are appended to the system prompt,
+// including one set with WithSystemPrompt, and their examples are added
+// as with WithExamples.
+func WithSkills(skills ...*Skill) AgentOption {
+	return func(a *BaseAgent) {
+		for _, s := range skills {
+			for _, t := range s.Tools {
+				a.tools.Register(t)
+			}
+			a.skills = append(a.skills, s)
+			a.examples = append(a.examples, s.Examples...)
+		}
+	}
+}
+
+// Skills returns the skills added with WithSkills.
+func (a *BaseAgent) Skills() []*Skill { return a.skills }
+
+// skillsPrompt renders the agent's skills for the system prompt.
+func (a *BaseAgent) skillsPrompt() string {
+	if len(a.skills) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n## Skills\n")
+	for _, s := range a.skills {
+		fmt.Fprintf(&sb, "\n### %s\n", s.Name)
+		if s.Description != "" {
+			sb.WriteString(s.Description + "\n")
+		}
+		if len(s.Tools) > 0 {
+			names := make([]string, len(s.Tools))
+			for i, t := range s.Tools {
+				names[i] = t.Name()
+			}
+			fmt.Fprintf(&sb, "Tools: %s\n", strings.Join(names, ", "))
+		}
+		if s.Instructions != "" {
+			sb.WriteString("\n" + strings.TrimSpace(s.Instructions) + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
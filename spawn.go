@@ -0,0 +1,181 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AgentTemplate creates sub-agents on demand, letting a manager spawn as
+// many as a task needs, e.g. one researcher per subtopic.
+type AgentTemplate struct {
+	Name        string
+	Description string
+	// New creates an agent. Each call must return a new agent, since a
+	// spawned agent keeps its memory for the follow-up tasks it is sent.
+	New func() Agent
+	// Max limits how many agents the template spawns in one run; zero is
+	// unlimited.
+	Max int
+}
+
+// WithAgentTemplates lets the agent spawn sub-agents from templates with
+// the spawn_agent tool. Each call runs a task on a new agent, or on one
+// spawned earlier in the run when the model names it. Spawned agents live
+// until the run ends; those implementing io.Closer are then closed.
+func WithAgentTemplates(templates ...AgentTemplate) AgentOption {
+	return func(a *BaseAgent) {
+		if a.templates == nil {
+			a.templates = make(map[string]AgentTemplate)
+		}
+		for _, t := range templates {
+			a.templates[t.Name] = t
+		}
+		a.tools.Register(&spawnTool{templates: a.templates})
+	}
+}
+
+// spawned holds the agents spawned during a run.
+type spawned struct {
+	mu      sync.Mutex
+	agents  map[string]Agent
+	from    map[string]string // template of each agent
+	counts  map[string]int    // spawned agents by template
+	running map[string]bool
+}
+
+type spawnKey struct{}
+
+// startSpawning attaches the run's spawned agents to ctx and closes them
+// when the run ends.
+func (a *BaseAgent) startSpawning(ctx context.Context) context.Context {
+	if len(a.templates) == 0 {
+		return ctx
+	}
+	s := &spawned{agents: map[string]Agent{}, from: map[string]string{}, counts: map[string]int{}, running: map[string]bool{}}
+	ctx = context.WithValue(ctx, spawnKey{}, s)
+	AddCleanup(ctx, func(context.Context) error { return s.close() })
+	return ctx
+}
+
+// close closes the spawned agents that implement io.Closer.
+func (s *spawned) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for name, agent := range s.agents {
+		if c, ok := agent.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing spawned agent %s: %w", name, err))
+			}
+		}
+	}
+	s.agents = nil
+	return errors.Join(errs...)
+}
+
+// acquire returns the agent called name, spawning it from t if it does
+// not exist yet, and marks it running. An existing agent must have been
+// spawned from t. Unnamed agents are named after their template and
+// number.
+func (s *spawned) acquire(name string, t AgentTemplate) (agent Agent, spawnedAs string, reused bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.agents == nil {
+		return nil, "", false, fmt.Errorf("the run has ended")
+	}
+	if s.running[name] {
+		return nil, "", false, fmt.Errorf("agent %s is already running a task", name)
+	}
+	if agent, ok := s.agents[name]; ok {
+		if s.from[name] != t.Name {
+			return nil, "", false, fmt.Errorf("agent %s was spawned from template %s, not %s", name, s.from[name], t.Name)
+		}
+		s.running[name] = true
+		return agent, name, true, nil
+	}
+	if t.Max > 0 && s.counts[t.Name] >= t.Max {
+		return nil, "", false, fmt.Errorf("template %s already spawned its maximum of %d agents", t.Name, t.Max)
+	}
+	s.counts[t.Name]++
+	if name == "" {
+		name = fmt.Sprintf("%s_%d", t.Name, s.counts[t.Name])
+	}
+	agent = t.New()
+	s.agents[name] = agent
+	s.from[name] = t.Name
+	s.running[name] = true
+	return agent, name, false, nil
+}
+
+func (s *spawned) release(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, name)
+}
+
+// spawnTool is the spawn_agent tool added by WithAgentTemplates.
+type spawnTool struct {
+	templates map[string]AgentTemplate
+}
+
+func (t *spawnTool) Name() string       { return "spawn_agent" }
+func (t *spawnTool) OutputType() string { return "string" }
+
+func (t *spawnTool) Description() string {
+	names := make([]string, 0, len(t.templates))
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("Runs a task on a new sub-agent created from a template, and returns its answer. Give the agent a name to send it follow-up tasks later, with its memory of earlier ones. Templates:")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n- %s: %s", name, t.templates[name].Description)
+	}
+	return sb.String()
+}
+
+func (t *spawnTool) Inputs() map[string]ToolInput {
+	return map[string]ToolInput{
+		"template": {Type: "string", Description: "Template to create the agent from", Required: true},
+		"task":     {Type: "string", Description: "Task for the agent", Required: true},
+		"name":     {Type: "string", Description: "Name of the agent, to reuse it in later calls"},
+	}
+}
+
+func (t *spawnTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext spawns or reuses an agent of ctx's run and runs the task.
+func (t *spawnTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	s, ok := ctx.Value(spawnKey{}).(*spawned)
+	if !ok {
+		return nil, fmt.Errorf("spawn_agent can only be called during a run")
+	}
+	templateName, _ := args["template"].(string)
+	task, _ := args["task"].(string)
+	name, _ := args["name"].(string)
+	if task == "" {
+		return nil, fmt.Errorf("task is required")
+	}
+	tmpl, ok := t.templates[templateName]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent template: %q", templateName)
+	}
+	agent, name, reused, err := s.acquire(name, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(name)
+	var opts []RunOption
+	if reused {
+		opts = append(opts, WithReset(false))
+	}
+	return runManagedAgent(ctx, name, agent, task, opts...)
+}
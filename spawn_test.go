@@ -0,0 +1,45 @@
+package neko_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+func spawnCall(id, template, name string) *neko.Message {
+	return &neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: id, Name: "spawn_agent", Arguments: map[string]any{
+		"template": template, "task": "work", "name": name,
+	}}}}
+}
+
+func TestSpawnRejectsNameFromOtherTemplate(t *testing.T) {
+	spawns := 0
+	template := func(name string) neko.AgentTemplate {
+		return neko.AgentTemplate{Name: name, Description: name, New: func() neko.Agent {
+			spawns++
+			return neko.NewToolCallingAgent(neko.WithModel(testutil.NewReplayModel(finalAnswer("ok", nil))))
+		}}
+	}
+	manager := neko.NewToolCallingAgent(
+		neko.WithModel(testutil.NewReplayModel(
+			spawnCall("1", "researcher", "alice"),
+			spawnCall("2", "writer", "alice"),
+			finalAnswer("done", nil),
+		)),
+		neko.WithAgentTemplates(template("researcher"), template("writer")),
+	)
+	result, err := manager.Run(context.Background(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := result.Steps[2].(*neko.ActionStep)
+	if !strings.Contains(step.Observations, "spawned from template researcher, not writer") {
+		t.Errorf("observations = %q, want the reuse refused", step.Observations)
+	}
+	if spawns != 1 {
+		t.Errorf("spawned %d agents, want 1", spawns)
+	}
+}
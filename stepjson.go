@@ -0,0 +1,210 @@
+package neko
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Steps encode their StepType as "step_type", so RunResult and Memory
+// can decode them back to their concrete types.
+
+var (
+	stepTypesMu sync.RWMutex
+	stepTypes   = map[string]func() Step{}
+)
+
+// RegisterStepType lets steps of a type defined outside this package be
+// decoded by UnmarshalStep, RunResult and Memory. newStep returns a
+// pointer to decode into; stepType must match its StepType.
+func RegisterStepType(stepType string, newStep func() Step) {
+	stepTypesMu.Lock()
+	defer stepTypesMu.Unlock()
+	stepTypes[stepType] = newStep
+}
+
+// MarshalStep encodes a step as a JSON object with its "step_type",
+// adding the field for step types that do not encode it themselves.
+func MarshalStep(step Step) ([]byte, error) {
+	data, err := json.Marshal(step)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		StepType *string `json:"step_type"`
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return nil, fmt.Errorf("%s step does not encode as a JSON object", step.StepType())
+	}
+	if err := json.Unmarshal(data, &probe); err != nil || probe.StepType != nil {
+		return data, err
+	}
+	prefix, _ := json.Marshal(step.StepType())
+	rest := data[1:]
+	if !bytes.Equal(bytes.TrimSpace(rest), []byte("}")) {
+		rest = append([]byte{','}, rest...)
+	}
+	return append(append([]byte(`{"step_type":`), prefix...), rest...), nil
+}
+
+// UnmarshalStep decodes a step encoded by MarshalStep.
+func UnmarshalStep(data []byte) (Step, error) {
+	var probe struct {
+		StepType string `json:"step_type"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if probe.StepType == "" {
+		return nil, fmt.Errorf("step has no step_type")
+	}
+	return decodeStep(probe.StepType, data)
+}
+
+// decodeStep decodes a JSON-encoded step of the given type.
+func decodeStep(stepType string, data []byte) (Step, error) {
+	var step Step
+	switch stepType {
+	case "task":
+		step = &TaskStep{}
+	case "action":
+		step = &ActionStep{}
+	case "planning":
+		step = &PlanningStep{}
+	case "final_answer":
+		step = &FinalAnswerStep{}
+	default:
+		stepTypesMu.RLock()
+		newStep, ok := stepTypes[stepType]
+		stepTypesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown step type: %q", stepType)
+		}
+		step = newStep()
+	}
+	if err := json.Unmarshal(data, step); err != nil {
+		return nil, fmt.Errorf("failed to decode %s step: %w", stepType, err)
+	}
+	return step, nil
+}
+
+func marshalSteps(steps []Step) ([]json.RawMessage, error) {
+	if steps == nil {
+		return nil, nil
+	}
+	raw := make([]json.RawMessage, len(steps))
+	for i, s := range steps {
+		data, err := MarshalStep(s)
+		if err != nil {
+			return nil, err
+		}
+		raw[i] = data
+	}
+	return raw, nil
+}
+
+func unmarshalSteps(raw []json.RawMessage) ([]Step, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	steps := make([]Step, len(raw))
+	for i, data := range raw {
+		step, err := UnmarshalStep(data)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+// MarshalJSON encodes the step with its step_type.
+func (s *TaskStep) MarshalJSON() ([]byte, error) {
+	type plain TaskStep
+	return json.Marshal(struct {
+		StepType string `json:"step_type"`
+		*plain
+	}{s.StepType(), (*plain)(s)})
+}
+
+// MarshalJSON encodes the step with its step_type.
+func (s *PlanningStep) MarshalJSON() ([]byte, error) {
+	type plain PlanningStep
+	return json.Marshal(struct {
+		StepType string `json:"step_type"`
+		*plain
+	}{s.StepType(), (*plain)(s)})
+}
+
+// MarshalJSON encodes the step with its step_type.
+func (s *FinalAnswerStep) MarshalJSON() ([]byte, error) {
+	type plain FinalAnswerStep
+	return json.Marshal(struct {
+		StepType string `json:"step_type"`
+		*plain
+	}{s.StepType(), (*plain)(s)})
+}
+
+// MarshalJSON encodes the result with a step_type on each step.
+func (r *RunResult) MarshalJSON() ([]byte, error) {
+	type plain RunResult
+	steps, err := marshalSteps(r.Steps)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		*plain
+		Steps []json.RawMessage `json:"steps"`
+	}{(*plain)(r), steps})
+}
+
+// UnmarshalJSON decodes a result, restoring each step's concrete type.
+func (r *RunResult) UnmarshalJSON(data []byte) error {
+	type plain RunResult
+	aux := struct {
+		*plain
+		Steps []json.RawMessage `json:"steps"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	steps, err := unmarshalSteps(aux.Steps)
+	if err != nil {
+		return err
+	}
+	r.Steps = steps
+	return nil
+}
+
+// MarshalJSON encodes memory with a step_type on each step.
+func (m *Memory) MarshalJSON() ([]byte, error) {
+	steps, err := marshalSteps(m.Steps)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		SystemPrompt string            `json:"system_prompt"`
+		Steps        []json.RawMessage `json:"steps"`
+	}{m.SystemPrompt, steps})
+}
+
+// UnmarshalJSON decodes memory, restoring each step's concrete type.
+func (m *Memory) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		SystemPrompt string            `json:"system_prompt"`
+		Steps        []json.RawMessage `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	steps, err := unmarshalSteps(aux.Steps)
+	if err != nil {
+		return err
+	}
+	m.SystemPrompt, m.Steps = aux.SystemPrompt, steps
+	if m.Steps == nil {
+		m.Steps = make([]Step, 0)
+	}
+	return nil
+}
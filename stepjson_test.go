@@ -0,0 +1,79 @@
+package neko
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// noteStep is a step type defined outside the built-in ones, whose JSON
+// has no step_type of its own.
+type noteStep struct {
+	Note string `json:"note"`
+}
+
+func (s *noteStep) StepType() string      { return "test_note" }
+func (s *noteStep) ToMessages() []Message { return nil }
+
+func TestMemoryJSONRoundTrip(t *testing.T) {
+	RegisterStepType("test_note", func() Step { return &noteStep{} })
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := NewMemory("system")
+	m.AddStep(&TaskStep{Task: "task"})
+	m.AddStep(&PlanningStep{Plan: "plan", Timing: Timing{StartTime: start}, TokenUsage: &TokenUsage{InputTokens: 3, OutputTokens: 4}})
+	m.AddStep(&ActionStep{
+		StepNumber: 1,
+		Timing:     Timing{StartTime: start},
+		ToolCalls:  []ToolCall{{ID: "1", Name: "search", Arguments: map[string]any{"q": "go"}}},
+		Error:      errors.New("tool failed"),
+		TokenUsage: &TokenUsage{InputTokens: 10, OutputTokens: 5},
+		AgentUsage: map[string]Usage{"helper": {TokenUsage: TokenUsage{InputTokens: 1}, Cost: 0.5}},
+		ToolUsage:  map[string]TokenUsage{"search": {OutputTokens: 2}},
+	})
+	m.AddStep(&noteStep{Note: "note"})
+	m.AddStep(&FinalAnswerStep{Output: "done"})
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Memory
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Steps) != len(m.Steps) {
+		t.Fatalf("decoded %d steps, want %d", len(got.Steps), len(m.Steps))
+	}
+	for i, step := range got.Steps {
+		if reflect.TypeOf(step) != reflect.TypeOf(m.Steps[i]) {
+			t.Errorf("step %d decoded as %T, want %T", i, step, m.Steps[i])
+		}
+	}
+	action := got.Steps[2].(*ActionStep)
+	if action.Error == nil || action.Error.Error() != "tool failed" {
+		t.Errorf("action error = %v, want tool failed", action.Error)
+	}
+	if got.TotalTokens() != m.TotalTokens() {
+		t.Errorf("total tokens = %+v, want %+v", got.TotalTokens(), m.TotalTokens())
+	}
+	if got.Steps[3].(*noteStep).Note != "note" {
+		t.Errorf("note step = %+v", got.Steps[3])
+	}
+	again, err := json.Marshal(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("re-encoded memory differs:\n%s\n%s", again, data)
+	}
+}
+
+func TestUnmarshalStepErrors(t *testing.T) {
+	for _, data := range []string{`{"task":"x"}`, `{"step_type":"unknown"}`, `{"step_type":"action","step_number":"one"}`} {
+		if _, err := UnmarshalStep([]byte(data)); err == nil {
+			t.Errorf("UnmarshalStep(%s) succeeded", data)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepTimeoutPolicy decides what a run does after a step times out.
+type StepTimeoutPolicy int
+
+const (
+	// StepTimeoutContinue records the timeout as the step's error, which
+	// the model sees, and goes on to the next step.
+	StepTimeoutContinue StepTimeoutPolicy = iota
+	// StepTimeoutAbort ends the run with the "step_timeout" state.
+	StepTimeoutAbort
+)
+
+// WithStepTimeout limits each step, the model call and the tool calls or
+// code it leads to, to d. A step that runs longer is cancelled and
+// recorded with what it produced so far: streamed model output, the
+// observations of finished tool calls and the logs of streamed code
+// execution. Its error matches ErrStepTimeout. What happens next is set
+// by WithStepTimeoutPolicy.
+func WithStepTimeout(d time.Duration) AgentOption {
+	return func(a *BaseAgent) { a.stepTimeout = d }
+}
+
+// WithStepTimeoutPolicy sets what a run does after a step times out. The
+// default is StepTimeoutContinue.
+func WithStepTimeoutPolicy(p StepTimeoutPolicy) AgentOption {
+	return func(a *BaseAgent) { a.stepTimeoutPolicy = p }
+}
+
+// withStepTimeout returns ctx limited by the step timeout, if any.
+func (a *BaseAgent) withStepTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.stepTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, a.stepTimeout, ErrStepTimeout)
+}
+
+// stepTimedOut reports whether the step timeout, rather than ctx, cancelled
+// stepCtx, and if so records it as the step's error.
+func (a *BaseAgent) stepTimedOut(ctx, stepCtx context.Context, step *ActionStep) bool {
+	if ctx.Err() != nil || !errors.Is(context.Cause(stepCtx), ErrStepTimeout) {
+		return false
+	}
+	step.Error = fmt.Errorf("%w: the step did not finish within %s", ErrStepTimeout, a.stepTimeout)
+	return true
+}
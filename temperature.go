@@ -0,0 +1,109 @@
+package neko
+
+import "encoding/json"
+
+// TemperatureState is what a TemperatureSchedule decides a model call's
+// temperature from.
+type TemperatureState struct {
+	Step     int // the number of the action step being generated
+	Failures int // consecutive failed steps before it
+	// Repeats is how many steps in a row, up to the last one, repeated
+	// the action of the step before them: the same tool calls with the
+	// same arguments, or the same code. Above zero, the agent is looping.
+	Repeats int
+}
+
+// TemperatureSchedule returns the temperature of the model calls of a
+// step.
+type TemperatureSchedule func(s TemperatureState) float64
+
+// WithTemperatureSchedule sets the temperature of each step's model calls
+// with schedule, e.g. AdaptiveTemperature.Schedule. A temperature set by
+// WithErrorRecovery takes precedence once the run is adapted.
+func WithTemperatureSchedule(schedule TemperatureSchedule) AgentOption {
+	return func(a *BaseAgent) { a.tempSchedule = schedule }
+}
+
+// AdaptiveTemperature is a TemperatureSchedule that cools as the run
+// makes progress, to converge on an answer, and heats up when the agent
+// repeats itself, to break out of the loop:
+//
+//	neko.WithTemperatureSchedule(neko.AdaptiveTemperature{
+//		Start: 0.7, Min: 0.1, Step: 0.05, Error: 0.1, Loop: 0.3,
+//	}.Schedule)
+type AdaptiveTemperature struct {
+	Start float64 // the first step's temperature
+	Min   float64 // the lowest temperature reached by cooling
+	Max   float64 // the highest temperature reached by heating; defaults to 1
+	Step  float64 // lowered by this much per step after the first
+	Error float64 // lowered by this much per consecutive failed step
+	Loop  float64 // raised by this much per repeated action
+}
+
+// Schedule returns the temperature for s.
+func (t AdaptiveTemperature) Schedule(s TemperatureState) float64 {
+	high := t.Max
+	if high <= 0 {
+		high = 1
+	}
+	temp := t.Start - t.Step*float64(max(s.Step-1, 0)) - t.Error*float64(s.Failures)
+	temp = max(temp, t.Min)
+	if s.Repeats > 0 {
+		temp += t.Loop * float64(s.Repeats)
+	}
+	return min(temp, high)
+}
+
+// temperatureState tracks a run's steps for its temperature schedule.
+type temperatureState struct {
+	TemperatureState
+	lastAction string
+}
+
+// observe records a finished step for the schedule.
+func (s *temperatureState) observe(step *ActionStep) {
+	if step.Error != nil {
+		s.Failures++
+	} else {
+		s.Failures = 0
+	}
+	action := actionKey(step)
+	if action != "" && action == s.lastAction {
+		s.Repeats++
+	} else {
+		s.Repeats = 0
+	}
+	s.lastAction = action
+}
+
+// actionKey identifies a step's action, ignoring tool call IDs, or is
+// empty if the step took none.
+func actionKey(step *ActionStep) string {
+	if step.CodeAction != "" {
+		return step.CodeAction
+	}
+	if len(step.ToolCalls) == 0 {
+		return ""
+	}
+	type call struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	calls := make([]call, len(step.ToolCalls))
+	for i, tc := range step.ToolCalls {
+		calls[i] = call{tc.Name, tc.Arguments}
+	}
+	data, _ := json.Marshal(calls)
+	return string(data)
+}
+
+// scheduledTemperature returns the temperature option for the model calls
+// of step, if the agent has a schedule.
+func (a *BaseAgent) scheduledTemperature(step int) (GenerateOption, bool) {
+	if a.tempSchedule == nil {
+		return nil, false
+	}
+	s := a.tempState.TemperatureState
+	s.Step = step
+	return WithTemperature(a.tempSchedule(s)), true
+}
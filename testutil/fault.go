@@ -0,0 +1,232 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// ErrInjectedFault is returned by calls a FaultInjector fails, unless
+// Faults.Err is set.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Faults configures what a FaultInjector does to calls. Rates are
+// probabilities between 0 and 1, drawn independently for each call.
+type Faults struct {
+	ErrorRate   float64 // calls that fail with Err
+	LatencyRate float64 // calls delayed by up to Latency
+	Latency     time.Duration
+	// MalformedRate is the share of successful calls whose output is
+	// mangled: model responses are cut short and lose their tool call
+	// arguments, tool results become truncated strings, and executor
+	// results lose their output and half their logs.
+	MalformedRate float64
+	Err           error // defaults to ErrInjectedFault
+}
+
+// FaultCounts is how many faults a FaultInjector has injected.
+type FaultCounts struct {
+	Calls     int
+	Errors    int
+	Delays    int
+	Malformed int
+}
+
+// FaultInjector wraps models, tools and executors to make their calls
+// slow, fail or return malformed output at configured rates, to test how
+// agents, retries and recovery policies cope. The faults are drawn from a
+// random source seeded with seed, so a run whose calls happen in the same
+// order injects the same faults every time.
+type FaultInjector struct {
+	faults Faults
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	counts FaultCounts
+}
+
+// NewFaultInjector creates a fault injector.
+func NewFaultInjector(seed int64, faults Faults) *FaultInjector {
+	if faults.Err == nil {
+		faults.Err = ErrInjectedFault
+	}
+	return &FaultInjector{faults: faults, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Counts returns the faults injected so far.
+func (f *FaultInjector) Counts() FaultCounts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts
+}
+
+// fault is what happens to one call.
+type fault struct {
+	delay     time.Duration
+	err       error
+	malformed bool
+}
+
+// draw decides the fault for the next call. Every call draws the same
+// number of values so one decision does not shift the others.
+func (f *FaultInjector) draw(target string) fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts.Calls++
+	delayed, delay, failed, malformed := f.rng.Float64(), f.rng.Float64(), f.rng.Float64(), f.rng.Float64()
+	var d fault
+	if delayed < f.faults.LatencyRate && f.faults.Latency > 0 {
+		d.delay = time.Duration(delay * float64(f.faults.Latency))
+		f.counts.Delays++
+	}
+	if failed < f.faults.ErrorRate {
+		d.err = fmt.Errorf("%s: %w", target, f.faults.Err)
+		f.counts.Errors++
+	} else if malformed < f.faults.MalformedRate {
+		d.malformed = true
+		f.counts.Malformed++
+	}
+	return d
+}
+
+// wait sleeps for the fault's delay or until ctx is done.
+func (d fault) wait(ctx context.Context) error {
+	if d.delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Model wraps m with the injector's faults.
+func (f *FaultInjector) Model(m neko.Model) neko.Model {
+	return &faultModel{Model: m, f: f}
+}
+
+type faultModel struct {
+	neko.Model
+	f *FaultInjector
+}
+
+func (m *faultModel) Generate(ctx context.Context, messages []neko.Message, opts ...neko.GenerateOption) (*neko.Message, error) {
+	d := m.f.draw("model " + m.ModelID())
+	if err := d.wait(ctx); err != nil {
+		return nil, err
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	msg, err := m.Model.Generate(ctx, messages, opts...)
+	if err != nil || !d.malformed || msg == nil {
+		return msg, err
+	}
+	out := *msg
+	out.Content = truncateHalf(out.Content)
+	out.ToolCalls = make([]neko.ToolCall, len(msg.ToolCalls))
+	for i, tc := range msg.ToolCalls {
+		out.ToolCalls[i] = neko.ToolCall{ID: tc.ID, Name: tc.Name}
+	}
+	return &out, nil
+}
+
+// Tool wraps t with the injector's faults.
+func (f *FaultInjector) Tool(t neko.Tool) neko.Tool {
+	return &faultTool{Tool: t, f: f}
+}
+
+type faultTool struct {
+	neko.Tool
+	f *FaultInjector
+}
+
+func (t *faultTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *faultTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	d := t.f.draw("tool " + t.Name())
+	if err := d.wait(ctx); err != nil {
+		return nil, err
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	var out any
+	var err error
+	if ct, ok := t.Tool.(neko.ContextTool); ok {
+		out, err = ct.ExecuteContext(ctx, args)
+	} else {
+		out, err = t.Tool.Execute(args)
+	}
+	if err != nil || !d.malformed {
+		return out, err
+	}
+	return truncateHalf(fmt.Sprint(out)), nil
+}
+
+// Executor wraps e with the injector's faults. The wrapper starts and
+// closes e's sessions and reports its language.
+func (f *FaultInjector) Executor(e neko.CodeExecutor) neko.CodeExecutor {
+	return &faultExecutor{inner: e, f: f}
+}
+
+type faultExecutor struct {
+	inner neko.CodeExecutor
+	f     *FaultInjector
+}
+
+func (e *faultExecutor) Execute(ctx context.Context, code string, state map[string]any) (*neko.ExecutionResult, error) {
+	d := e.f.draw("executor")
+	if err := d.wait(ctx); err != nil {
+		return &neko.ExecutionResult{State: state}, err
+	}
+	if d.err != nil {
+		return &neko.ExecutionResult{State: state}, d.err
+	}
+	res, err := e.inner.Execute(ctx, code, state)
+	if err != nil || !d.malformed || res == nil {
+		return res, err
+	}
+	out := *res
+	out.Output, out.IsFinal = nil, false
+	out.Logs = truncateHalf(res.Logs)
+	return &out, nil
+}
+
+func (e *faultExecutor) Start(ctx context.Context) error {
+	if se, ok := e.inner.(neko.SessionExecutor); ok {
+		return se.Start(ctx)
+	}
+	return nil
+}
+
+func (e *faultExecutor) Close() error {
+	if se, ok := e.inner.(neko.SessionExecutor); ok {
+		return se.Close()
+	}
+	return nil
+}
+
+func (e *faultExecutor) Language() string {
+	if le, ok := e.inner.(neko.LanguageExecutor); ok {
+		return le.Language()
+	}
+	return "python"
+}
+
+// truncateHalf cuts s to half its length, respecting UTF-8.
+func truncateHalf(s string) string {
+	r := []rune(s)
+	return string(r[:len(r)/2])
+}
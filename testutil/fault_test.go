@@ -0,0 +1,62 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+func TestFaultInjectorIsSeeded(t *testing.T) {
+	draws := func() []bool {
+		f := NewFaultInjector(7, Faults{ErrorRate: 0.5})
+		tool := f.Tool(upperTool{})
+		var failed []bool
+		for range 20 {
+			_, err := tool.Execute(map[string]any{"text": "x"})
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	a, b := draws(), draws()
+	errs := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d: faults differ between runs with the same seed", i)
+		}
+		if a[i] {
+			errs++
+		}
+	}
+	if errs == 0 || errs == len(a) {
+		t.Errorf("%d of %d calls failed at rate 0.5", errs, len(a))
+	}
+}
+
+func TestFaultInjectorFaults(t *testing.T) {
+	f := NewFaultInjector(1, Faults{ErrorRate: 1})
+	model := f.Model(NewReplayModel(toolCall("1", "final_answer", map[string]any{"answer": "x"})))
+	if _, err := model.Generate(context.Background(), nil); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("model err = %v, want ErrInjectedFault", err)
+	}
+
+	f = NewFaultInjector(1, Faults{MalformedRate: 1})
+	model = f.Model(NewReplayModel(&neko.Message{Role: neko.RoleAssistant, Content: "abcdef", ToolCalls: []neko.ToolCall{
+		{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": "x"}},
+	}}))
+	msg, err := model.Generate(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Content != "abc" || msg.ToolCalls[0].Arguments != nil {
+		t.Errorf("malformed response = %+v, want it cut short without arguments", msg)
+	}
+	out, err := f.Tool(upperTool{}).Execute(map[string]any{"text": "abcd"})
+	if err != nil || out != "AB" {
+		t.Errorf("malformed tool output = %v, %v, want AB", out, err)
+	}
+	if c := f.Counts(); c.Calls != 2 || c.Malformed != 2 {
+		t.Errorf("counts = %+v", c)
+	}
+}
@@ -0,0 +1,187 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+// GoldenStep is the part of a step compared with golden files: what the
+// agent did with the model's output, without timings or token counts.
+type GoldenStep struct {
+	Type         string           `json:"type"`
+	StepNumber   int              `json:"step_number,omitempty"`
+	Task         string           `json:"task,omitempty"`
+	Plan         string           `json:"plan,omitempty"`
+	CodeAction   string           `json:"code_action,omitempty"`
+	ToolCalls    []GoldenToolCall `json:"tool_calls,omitempty"`
+	Observations string           `json:"observations,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	IsFinal      bool             `json:"is_final_answer,omitempty"`
+	Output       any              `json:"output,omitempty"`
+}
+
+// GoldenToolCall is a tool call without its ID.
+type GoldenToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// GoldenSteps returns the comparable form of a run's steps.
+func GoldenSteps(result *neko.RunResult) []GoldenStep {
+	steps := make([]GoldenStep, 0, len(result.Steps))
+	for _, step := range result.Steps {
+		g := GoldenStep{Type: step.StepType()}
+		switch s := step.(type) {
+		case *neko.TaskStep:
+			g.Task = s.Task
+		case *neko.PlanningStep:
+			g.Plan = s.Plan
+		case *neko.ActionStep:
+			g.StepNumber, g.CodeAction, g.Observations, g.IsFinal = s.StepNumber, s.CodeAction, s.Observations, s.IsFinal
+			for _, tc := range s.ToolCalls {
+				g.ToolCalls = append(g.ToolCalls, GoldenToolCall{Name: tc.Name, Arguments: tc.Arguments})
+			}
+			if s.Error != nil {
+				g.Error = s.Error.Error()
+			}
+		case *neko.FinalAnswerStep:
+			g.Output = s.Output
+		}
+		steps = append(steps, g)
+	}
+	return steps
+}
+
+// GoldenOption configures AssertGolden.
+type GoldenOption func(*goldenOptions)
+
+type goldenOptions struct {
+	scrubs []scrub
+}
+
+type scrub struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// WithScrub replaces matches of pattern with replacement before comparing,
+// for output that changes from run to run such as temporary paths or
+// timestamps.
+func WithScrub(pattern, replacement string) GoldenOption {
+	re := regexp.MustCompile(pattern)
+	return func(o *goldenOptions) { o.scrubs = append(o.scrubs, scrub{re: re, repl: replacement}) }
+}
+
+// AssertGolden compares the run's steps, as returned by GoldenSteps, with
+// the JSON golden file at path and fails t with a line diff if they
+// differ. With the UPDATE_GOLDEN environment variable set, it writes the
+// file instead.
+func AssertGolden(t testing.TB, path string, result *neko.RunResult, opts ...GoldenOption) {
+	t.Helper()
+	var o goldenOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, err := json.MarshalIndent(GoldenSteps(result), "", "  ")
+	if err != nil {
+		t.Fatalf("encode golden steps: %v", err)
+	}
+	got := string(data) + "\n"
+	for _, s := range o.scrubs {
+		got = s.re.ReplaceAllString(got, s.repl)
+	}
+
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist; run with UPDATE_GOLDEN=1 to create it", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n")), []byte(got)) {
+		t.Errorf("steps differ from %s (-want +got):\n%s", path, diff(string(want), got))
+	}
+}
+
+// diff returns a line diff of a and b with three lines of context.
+func diff(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j]})
+			j++
+		}
+	}
+
+	const context = 3
+	var sb strings.Builder
+	last := -1
+	for k, l := range lines {
+		near := false
+		for d := max(0, k-context); d <= min(len(lines)-1, k+context); d++ {
+			if lines[d].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			sb.WriteString("  ...\n")
+		}
+		fmt.Fprintf(&sb, "%c %s\n", l.op, l.text)
+		last = k
+	}
+	return sb.String()
+}
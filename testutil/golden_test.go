@@ -0,0 +1,93 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+// recordingTB records failures instead of failing the test. Use it
+// through record, since Fatal stops the calling goroutine.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (tb *recordingTB) Helper() {}
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.failures = append(tb.failures, fmt.Sprintf(format, args...))
+}
+func (tb *recordingTB) Fatalf(format string, args ...any) {
+	tb.Errorf(format, args...)
+	runtime.Goexit()
+}
+func (tb *recordingTB) Fatal(args ...any) { tb.Fatalf("%s", fmt.Sprint(args...)) }
+
+// record runs fn with a recordingTB and returns its failures.
+func record(t testing.TB, fn func(testing.TB)) []string {
+	tb := &recordingTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(tb)
+	}()
+	<-done
+	return tb.failures
+}
+
+func goldenRun(t *testing.T, answer string) *neko.RunResult {
+	t.Helper()
+	model := NewReplayModel(toolCall("1", "final_answer", map[string]any{"answer": answer}))
+	result, err := neko.NewToolCallingAgent(neko.WithModel(model)).Run(context.Background(), "answer in /tmp/run-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "run.golden.json")
+	scrub := WithScrub(`run-\d+`, "run-N")
+
+	t.Setenv("UPDATE_GOLDEN", "1")
+	AssertGolden(t, path, goldenRun(t, "42"), scrub)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "run-N") || strings.Contains(string(data), "run-123") {
+		t.Errorf("golden file not scrubbed:\n%s", data)
+	}
+
+	t.Setenv("UPDATE_GOLDEN", "")
+	AssertGolden(t, path, goldenRun(t, "42"), scrub)
+
+	changed := goldenRun(t, "43")
+	failures := record(t, func(tb testing.TB) { AssertGolden(tb, path, changed, scrub) })
+	removed := regexp.MustCompile(`(?m)^-\s+"output": "42"$`)
+	added := regexp.MustCompile(`(?m)^\+\s+"output": "43"$`)
+	if len(failures) != 1 || !removed.MatchString(failures[0]) || !added.MatchString(failures[0]) {
+		t.Errorf("failures = %q, want a diff of the output", failures)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	result := goldenRun(t, "42")
+	failures = record(t, func(tb testing.TB) { AssertGolden(tb, missing, result) })
+	if len(failures) != 1 || !strings.Contains(failures[0], "UPDATE_GOLDEN=1") {
+		t.Errorf("failures = %q, want a hint to create the file", failures)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc\n", "a\nx\nc\n")
+	if want := "  a\n- b\n+ x\n  c\n"; got != want {
+		t.Errorf("diff = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+// Package testutil helps test agents deterministically: ReplayModel
+// stands in for a real model by replaying recorded responses, and
+// AssertGolden compares the steps of the resulting run with a golden
+// file, so changes to prompts, parsing or tool handling show up as diffs
+// in ordinary Go tests:
+//
+//	func TestResearch(t *testing.T) {
+//		model, err := testutil.LoadReplayModel("testdata/research.jsonl")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		agent := neko.NewCodeAgent(executor, neko.WithModel(model), neko.WithToolList(tools...))
+//		result, err := agent.Run(ctx, model.Task())
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		testutil.AssertGolden(t, "testdata/research.golden.json", result)
+//	}
+//
+// Run the tests with UPDATE_GOLDEN=1 to write the golden files.
+//
+// FaultInjector wraps models, tools and executors to inject seeded
+// latency, errors and malformed output, for testing robustness.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gocnn/neko"
+)
+
+// ReplayModel is a Model that returns scripted responses in order.
+type ReplayModel struct {
+	task      string
+	responses []replayResponse
+
+	mu       sync.Mutex
+	next     int
+	requests [][]neko.Message
+}
+
+type replayResponse struct {
+	msg *neko.Message
+	err error
+}
+
+// NewReplayModel returns a model that answers with responses in order.
+func NewReplayModel(responses ...*neko.Message) *ReplayModel {
+	m := &ReplayModel{}
+	for _, r := range responses {
+		m.responses = append(m.responses, replayResponse{msg: r})
+	}
+	return m
+}
+
+// LoadReplayModel returns a model that replays the model responses of the
+// last run in a trace written by neko.WithTraceWriter. Action steps whose
+// model call failed replay the failure.
+func LoadReplayModel(path string) (*ReplayModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, memory, err := neko.LoadTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m := &ReplayModel{}
+	for _, step := range memory.Steps {
+		switch s := step.(type) {
+		case *neko.TaskStep:
+			// Earlier runs continued by this one are not replayed.
+			m.task, m.responses = s.Task, nil
+		case *neko.PlanningStep:
+			m.responses = append(m.responses, replayResponse{msg: &neko.Message{
+				Role: neko.RoleAssistant, Content: s.Plan, TokenUsage: s.TokenUsage,
+			}})
+		case *neko.ActionStep:
+			if s.Error != nil && s.ModelOutput == "" && len(s.ToolCalls) == 0 {
+				m.responses = append(m.responses, replayResponse{err: s.Error})
+				continue
+			}
+			m.responses = append(m.responses, replayResponse{msg: &neko.Message{
+				Role: neko.RoleAssistant, Content: s.ModelOutput, ToolCalls: s.ToolCalls, TokenUsage: s.TokenUsage,
+			}})
+		}
+	}
+	return m, nil
+}
+
+// Task returns the task of the run loaded by LoadReplayModel.
+func (m *ReplayModel) Task() string { return m.task }
+
+func (m *ReplayModel) ModelID() string { return "replay" }
+
+// Generate returns the next response, or an error once all have been
+// used.
+func (m *ReplayModel) Generate(ctx context.Context, messages []neko.Message, opts ...neko.GenerateOption) (*neko.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, messages)
+	if m.next >= len(m.responses) {
+		return nil, errors.New("replay model: no responses left")
+	}
+	r := m.responses[m.next]
+	m.next++
+	if r.err != nil {
+		return nil, r.err
+	}
+	msg := *r.msg
+	return &msg, nil
+}
+
+// Requests returns the messages of each call made so far.
+func (m *ReplayModel) Requests() [][]neko.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]neko.Message(nil), m.requests...)
+}
+
+// Remaining returns the number of responses not yet used.
+func (m *ReplayModel) Remaining() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.responses) - m.next
+}
@@ -0,0 +1,74 @@
+package testutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gocnn/neko"
+)
+
+func toolCall(id, name string, args map[string]any) *neko.Message {
+	return &neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{{ID: id, Name: name, Arguments: args}}}
+}
+
+type upperTool struct{}
+
+func (upperTool) Name() string        { return "upper" }
+func (upperTool) Description() string { return "upper-cases text" }
+func (upperTool) OutputType() string  { return "string" }
+func (upperTool) Inputs() map[string]neko.ToolInput {
+	return map[string]neko.ToolInput{"text": {Type: "string", Required: true}}
+}
+func (upperTool) Execute(args map[string]any) (any, error) {
+	s, _ := args["text"].(string)
+	out := []rune(s)
+	for i, r := range out {
+		if r >= 'a' && r <= 'z' {
+			out[i] = r - 'a' + 'A'
+		}
+	}
+	return string(out), nil
+}
+
+func TestReplayModelFromTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := NewReplayModel(
+		toolCall("1", "upper", map[string]any{"text": "neko"}),
+		toolCall("2", "final_answer", map[string]any{"answer": "NEKO"}),
+	)
+	agent := neko.NewToolCallingAgent(neko.WithModel(recorded), neko.WithToolList(upperTool{}), neko.WithTraceWriter(f))
+	want, err := agent.Run(context.Background(), "shout neko")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := LoadReplayModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Task() != "shout neko" || model.Remaining() != 2 {
+		t.Fatalf("task %q with %d responses, want the recorded run", model.Task(), model.Remaining())
+	}
+	agent = neko.NewToolCallingAgent(neko.WithModel(model), neko.WithToolList(upperTool{}))
+	got, err := agent.Run(context.Background(), model.Task())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(GoldenSteps(got), GoldenSteps(want)) {
+		t.Errorf("replayed steps = %+v, want %+v", GoldenSteps(got), GoldenSteps(want))
+	}
+	if n := len(model.Requests()); n != 2 {
+		t.Errorf("recorded %d requests, want 2", n)
+	}
+	if _, err := model.Generate(context.Background(), nil); err == nil {
+		t.Error("Generate succeeded with no responses left")
+	}
+}
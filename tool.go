@@ -1,8 +1,10 @@
 package neko
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -15,6 +17,14 @@ type Tool interface {
 	Execute(args map[string]any) (any, error)
 }
 
+// ContextTool is a Tool that receives the calling step's context, for
+// cancellation and run-scoped services such as the audit log. Agents call
+// ExecuteContext instead of Execute when a tool implements it.
+type ContextTool interface {
+	Tool
+	ExecuteContext(ctx context.Context, args map[string]any) (any, error)
+}
+
 // BaseTool provides common tool functionality.
 type BaseTool struct {
 	name        string
@@ -108,14 +118,20 @@ func (r *ToolRegistry) ToJSONSchema() []map[string]any {
 }
 
 // ToCodePrompt generates Python-style function signatures for CodeAgent.
+// Tools and parameters are listed in name order, which is also the order
+// executors bind positional arguments in.
 func (r *ToolRegistry) ToCodePrompt() string {
 	var sb strings.Builder
-	for _, tool := range r.tools {
+	names := r.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		tool := r.tools[name]
 		sb.WriteString(fmt.Sprintf("def %s(", tool.Name()))
 
 		params := []string{}
-		for name, input := range tool.Inputs() {
-			params = append(params, fmt.Sprintf("%s: %s", name, goTypeToPython(input.Type)))
+		inputs := tool.Inputs()
+		for _, name := range ToolParamNames(tool) {
+			params = append(params, fmt.Sprintf("%s: %s", name, goTypeToPython(inputs[name].Type)))
 		}
 		sb.WriteString(strings.Join(params, ", "))
 		sb.WriteString(fmt.Sprintf(") -> %s:\n", goTypeToPython(tool.OutputType())))
@@ -124,6 +140,31 @@ func (r *ToolRegistry) ToCodePrompt() string {
 	return sb.String()
 }
 
+// toJSPrompt renders the registry's tools as JavaScript function stubs
+// taking an object of named arguments, as JSExecutor binds them.
+func (r *ToolRegistry) toJSPrompt() string {
+	var sb strings.Builder
+	names := r.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		tool := r.tools[name]
+		sb.WriteString(fmt.Sprintf("/** %s */\n", tool.Description()))
+		sb.WriteString(fmt.Sprintf("function %s({%s}) {}\n\n", tool.Name(), strings.Join(ToolParamNames(tool), ", ")))
+	}
+	return sb.String()
+}
+
+// ToolParamNames returns a tool's input names in a stable order: sorted by
+// name. Code executors bind positional arguments to inputs in this order.
+func ToolParamNames(tool Tool) []string {
+	names := make([]string, 0, len(tool.Inputs()))
+	for name := range tool.Inputs() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func goTypeToPython(t string) string {
 	switch t {
 	case "string":
@@ -0,0 +1,35 @@
+package tool
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Option configures the built-in tools that make HTTP requests.
+type Option func(*options)
+
+type options struct {
+	client *http.Client
+}
+
+// WithHTTPClient makes the tool send its requests with c, e.g. to go
+// through a proxy, present a client certificate or add tracing. The
+// requests are still recorded in the run's audit log.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.client = c }
+}
+
+// newClient returns the HTTP client for a tool: the one given with
+// WithHTTPClient, or one with a 30 second timeout, with requests audited
+// under source.
+func newClient(source string, opts []Option) *http.Client {
+	o := options{client: &http.Client{Timeout: 30 * time.Second}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := *o.client
+	c.Transport = neko.NewAuditTransport(c.Transport, source)
+	return &c
+}
@@ -0,0 +1,188 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gocnn/neko"
+)
+
+// workspace returns the workspace of the run ctx belongs to.
+func workspace(ctx context.Context) (*neko.Workspace, error) {
+	w := neko.WorkspaceFromContext(ctx)
+	if w == nil {
+		return nil, fmt.Errorf("no workspace: the agent needs neko.WithWorkspace")
+	}
+	return w, nil
+}
+
+// ReadFileTool reads text files from the run's workspace.
+type ReadFileTool struct {
+	neko.BaseTool
+	maxLength int
+}
+
+// NewReadFileTool creates a file reading tool returning at most maxLength
+// bytes of a file, or 50000 if maxLength is not positive.
+func NewReadFileTool(maxLength int) *ReadFileTool {
+	if maxLength <= 0 {
+		maxLength = 50000
+	}
+	return &ReadFileTool{maxLength: maxLength}
+}
+
+func (t *ReadFileTool) Name() string { return "read_file" }
+func (t *ReadFileTool) Description() string {
+	return "Reads a text file from the workspace, including files written by code."
+}
+func (t *ReadFileTool) OutputType() string { return "string" }
+
+func (t *ReadFileTool) Inputs() map[string]neko.ToolInput {
+	return map[string]neko.ToolInput{
+		"path": {Type: "string", Description: "File path relative to the workspace", Required: true},
+	}
+}
+
+func (t *ReadFileTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads the file from the workspace of ctx's run.
+func (t *ReadFileTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	name, ok := args["path"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	w, err := workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := w.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%s is not a text file (%d bytes)", name, len(data))
+	}
+	if len(data) > t.maxLength {
+		return string(data[:t.maxLength]) + "\n...(truncated)", nil
+	}
+	return string(data), nil
+}
+
+// WriteFileTool writes text files to the run's workspace, where code run
+// by the agent can read them. Written files are recorded as artifacts.
+type WriteFileTool struct {
+	neko.BaseTool
+}
+
+// NewWriteFileTool creates a file writing tool.
+func NewWriteFileTool() *WriteFileTool {
+	return &WriteFileTool{}
+}
+
+func (t *WriteFileTool) Name() string { return "write_file" }
+func (t *WriteFileTool) Description() string {
+	return "Writes a text file to the workspace, replacing any existing file."
+}
+func (t *WriteFileTool) OutputType() string { return "string" }
+
+func (t *WriteFileTool) Inputs() map[string]neko.ToolInput {
+	return map[string]neko.ToolInput{
+		"path":    {Type: "string", Description: "File path relative to the workspace", Required: true},
+		"content": {Type: "string", Description: "Text to write", Required: true},
+	}
+}
+
+func (t *WriteFileTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext writes the file to the workspace of ctx's run.
+func (t *WriteFileTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	name, ok := args["path"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return nil, fmt.Errorf("content is required")
+	}
+	w, err := workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := []byte(content)
+	err = w.WriteFile(name, data)
+	entry := neko.AuditEntry{Kind: neko.AuditFileWrite, Source: t.Name(), Detail: name}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	neko.RecordAudit(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	neko.RecordArtifact(ctx, neko.Artifact{Name: path.Base(name), Path: name, MIMEType: mimeType, Data: data})
+	return fmt.Sprintf("Wrote %d bytes to %s", len(data), name), nil
+}
+
+// ListFilesTool lists the files in the run's workspace.
+type ListFilesTool struct {
+	neko.BaseTool
+}
+
+// NewListFilesTool creates a file listing tool.
+func NewListFilesTool() *ListFilesTool {
+	return &ListFilesTool{}
+}
+
+func (t *ListFilesTool) Name() string { return "list_files" }
+func (t *ListFilesTool) Description() string {
+	return "Lists the files in the workspace, or in one of its directories, with their sizes."
+}
+func (t *ListFilesTool) OutputType() string { return "string" }
+
+func (t *ListFilesTool) Inputs() map[string]neko.ToolInput {
+	return map[string]neko.ToolInput{
+		"path": {Type: "string", Description: "Directory relative to the workspace; the whole workspace if empty"},
+	}
+}
+
+func (t *ListFilesTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists the workspace of ctx's run.
+func (t *ListFilesTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
+	dir, _ := args["path"].(string)
+	w, err := workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := w.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return "No files.", nil
+	}
+	var b strings.Builder
+	for _, f := range files {
+		if f.IsDir {
+			fmt.Fprintf(&b, "%s/\n", f.Path)
+		} else {
+			fmt.Fprintf(&b, "%s (%d bytes)\n", f.Path, f.Size)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
@@ -1,11 +1,11 @@
 package tool
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gocnn/neko"
 )
@@ -18,12 +18,12 @@ type VisitWebpageTool struct {
 }
 
 // NewVisitWebpageTool creates a webpage visiting tool.
-func NewVisitWebpageTool(maxLength int) *VisitWebpageTool {
+func NewVisitWebpageTool(maxLength int, opts ...Option) *VisitWebpageTool {
 	if maxLength <= 0 {
 		maxLength = 50000
 	}
 	return &VisitWebpageTool{
-		client:    &http.Client{Timeout: 30 * time.Second},
+		client:    newClient("visit_webpage", opts),
 		maxLength: maxLength,
 	}
 }
@@ -39,6 +39,11 @@ func (t *VisitWebpageTool) Inputs() map[string]neko.ToolInput {
 }
 
 func (t *VisitWebpageTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext fetches the page, cancelling the request with ctx.
+func (t *VisitWebpageTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
 	urlStr, ok := args["url"].(string)
 	if !ok || urlStr == "" {
 		return nil, fmt.Errorf("url is required")
@@ -48,7 +53,7 @@ func (t *VisitWebpageTool) Execute(args map[string]any) (any, error) {
 		urlStr = "https://" + urlStr
 	}
 
-	req, err := http.NewRequest("GET", urlStr, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +76,7 @@ func (t *VisitWebpageTool) Execute(args map[string]any) (any, error) {
 
 	// Basic HTML to text conversion (simplified)
 	content := string(body)
+	neko.RecordCitation(ctx, neko.Citation{URL: resp.Request.URL.String(), Title: htmlTitle(content), Source: t.Name()})
 	content = stripHTML(content)
 
 	if len(content) > t.maxLength {
@@ -80,6 +86,25 @@ func (t *VisitWebpageTool) Execute(args map[string]any) (any, error) {
 	return content, nil
 }
 
+// htmlTitle returns the text of the page's <title> element, if any.
+func htmlTitle(s string) string {
+	lower := strings.ToLower(s)
+	start := strings.Index(lower, "<title")
+	if start < 0 {
+		return ""
+	}
+	open := strings.Index(lower[start:], ">")
+	if open < 0 {
+		return ""
+	}
+	start += open + 1
+	end := strings.Index(lower[start:], "</title>")
+	if end < 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(s[start:start+end]), " ")
+}
+
 func stripHTML(s string) string {
 	// Simple HTML tag removal
 	var result strings.Builder
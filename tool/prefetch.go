@@ -0,0 +1,39 @@
+package tool
+
+import "github.com/gocnn/neko"
+
+// PrefetchTopResults returns a neko.ToolPredictor for research agents:
+// after a step that searched the web, it predicts visits to the first n
+// results not visited yet, so pages are fetched while the model decides
+// which to read.
+func PrefetchTopResults(n int) neko.ToolPredictor {
+	return func(steps []neko.Step) []neko.ToolCall {
+		visited := map[string]bool{}
+		var last *neko.ActionStep
+		for _, s := range steps {
+			if as, ok := s.(*neko.ActionStep); ok {
+				last = as
+				for _, c := range as.Citations {
+					if c.Source == "visit_webpage" {
+						visited[c.URL] = true
+					}
+				}
+			}
+		}
+		if last == nil {
+			return nil
+		}
+		var calls []neko.ToolCall
+		for _, c := range last.Citations {
+			if len(calls) == n {
+				break
+			}
+			if c.Source != "web_search" || c.URL == "" || visited[c.URL] {
+				continue
+			}
+			visited[c.URL] = true
+			calls = append(calls, neko.ToolCall{Name: "visit_webpage", Arguments: map[string]any{"url": c.URL}})
+		}
+		return calls
+	}
+}
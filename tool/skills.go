@@ -0,0 +1,44 @@
+package tool
+
+import "github.com/gocnn/neko"
+
+// WebResearchSkill returns a skill for answering questions from the web,
+// with the web search and webpage tools.
+func WebResearchSkill(maxResults int) *neko.Skill {
+	return &neko.Skill{
+		Name:        "Web research",
+		Description: "Finding and reading web pages to answer questions with sources.",
+		Tools:       []neko.Tool{NewWebSearchTool(maxResults), NewVisitWebpageTool(0)},
+		Instructions: `Search first, then visit the most relevant results: snippets are often incomplete or out of date.
+Prefer primary and authoritative sources, and check important facts against a second source.
+Cite the URLs of the pages you relied on in the final answer.`,
+		Examples: []neko.Example{{
+			Task: "When was the Eiffel Tower completed?",
+			Trajectory: `Thought: I will search for the completion date.
+web_search(query="Eiffel Tower completion date")
+Thought: The official site is among the results; I will read it.
+visit_webpage(url="https://www.toureiffel.paris/en/the-monument/history")
+Thought: The page says it was completed in March 1889.
+final_answer(answer="March 1889 (https://www.toureiffel.paris/en/the-monument/history)")`,
+		}},
+	}
+}
+
+// DataAnalysisSkill returns a skill for numeric questions, with the
+// calculator tool.
+func DataAnalysisSkill() *neko.Skill {
+	return &neko.Skill{
+		Name:        "Data analysis",
+		Description: "Computing exact figures from data given in the task or found with other tools.",
+		Tools:       []neko.Tool{NewCalculatorTool()},
+		Instructions: `Never do arithmetic in your head: compute every figure with a tool and keep full precision until the final answer.
+State units, and round only in the final answer.`,
+		Examples: []neko.Example{{
+			Task: "A price rose from 80 to 92. By what percentage did it rise?",
+			Trajectory: `Thought: The increase is (92 - 80) / 80 as a percentage.
+calculator(expression="(92 - 80) / 80 * 100")
+Thought: The result is 15.
+final_answer(answer="15%")`,
+		}},
+	}
+}
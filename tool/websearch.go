@@ -1,12 +1,12 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/gocnn/neko"
 )
@@ -19,14 +19,14 @@ type WebSearchTool struct {
 }
 
 // NewWebSearchTool creates a web search tool.
-func NewWebSearchTool(maxResults int) *WebSearchTool {
+func NewWebSearchTool(maxResults int, opts ...Option) *WebSearchTool {
 	if maxResults <= 0 {
 		maxResults = 10
 	}
 	return &WebSearchTool{
 		BaseTool:   neko.BaseTool{},
 		maxResults: maxResults,
-		client:     &http.Client{Timeout: 30 * time.Second},
+		client:     newClient("web_search", opts),
 	}
 }
 
@@ -45,12 +45,17 @@ func (t *WebSearchTool) Inputs() map[string]neko.ToolInput {
 func (t *WebSearchTool) OutputType() string { return "string" }
 
 func (t *WebSearchTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the search, cancelling the request with ctx.
+func (t *WebSearchTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
 		return nil, fmt.Errorf("query is required")
 	}
 
-	results, err := t.search(query)
+	results, err := t.search(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +68,7 @@ func (t *WebSearchTool) Execute(args map[string]any) (any, error) {
 	sb.WriteString("## Search Results\n\n")
 	for _, r := range results {
 		sb.WriteString(fmt.Sprintf("[%s](%s)\n%s\n\n", r.Title, r.URL, r.Snippet))
+		neko.RecordCitation(ctx, neko.Citation{URL: r.URL, Title: r.Title, Snippet: r.Snippet, Source: t.Name()})
 	}
 	return sb.String(), nil
 }
@@ -73,11 +79,11 @@ type searchResult struct {
 	Snippet string
 }
 
-func (t *WebSearchTool) search(query string) ([]searchResult, error) {
+func (t *WebSearchTool) search(ctx context.Context, query string) ([]searchResult, error) {
 	// Using DuckDuckGo HTML endpoint (simplified)
 	apiURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -104,12 +110,14 @@ type SerpAPISearchTool struct {
 	client     *http.Client
 }
 
-// NewSerpAPISearchTool creates a SerpAPI-based search tool.
-func NewSerpAPISearchTool(apiKey string, maxResults int) *SerpAPISearchTool {
+// NewSerpAPISearchTool creates a SerpAPI-based search tool. If apiKey is
+// empty, the key is looked up as the secret SERPAPI_API_KEY of the
+// running agent; see neko.WithSecrets.
+func NewSerpAPISearchTool(apiKey string, maxResults int, opts ...Option) *SerpAPISearchTool {
 	return &SerpAPISearchTool{
 		apiKey:     apiKey,
 		maxResults: maxResults,
-		client:     &http.Client{Timeout: 30 * time.Second},
+		client:     newClient("web_search", opts),
 	}
 }
 
@@ -124,15 +132,31 @@ func (t *SerpAPISearchTool) Inputs() map[string]neko.ToolInput {
 }
 
 func (t *SerpAPISearchTool) Execute(args map[string]any) (any, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the search, cancelling the request with ctx.
+func (t *SerpAPISearchTool) ExecuteContext(ctx context.Context, args map[string]any) (any, error) {
 	query, _ := args["query"].(string)
 	if query == "" {
 		return nil, fmt.Errorf("query is required")
 	}
+	apiKey := t.apiKey
+	if apiKey == "" {
+		var err error
+		if apiKey, err = neko.LookupSecret(ctx, "SERPAPI_API_KEY"); err != nil {
+			return nil, err
+		}
+	}
 
 	apiURL := fmt.Sprintf("https://serpapi.com/search.json?q=%s&api_key=%s&num=%d",
-		url.QueryEscape(query), t.apiKey, t.maxResults)
+		url.QueryEscape(query), url.QueryEscape(apiKey), t.maxResults)
 
-	resp, err := t.client.Get(apiURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +178,7 @@ func (t *SerpAPISearchTool) Execute(args map[string]any) (any, error) {
 	sb.WriteString("## Search Results\n\n")
 	for _, r := range result.OrganicResults {
 		fmt.Fprintf(&sb, "[%s](%s)\n%s\n\n", r.Title, r.Link, r.Snippet)
+		neko.RecordCitation(ctx, neko.Citation{URL: r.Link, Title: r.Title, Snippet: r.Snippet, Source: t.Name()})
 	}
 	return sb.String(), nil
 }
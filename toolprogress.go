@@ -0,0 +1,173 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ToolProgressPolicy sets when a long tool call is reported and whether
+// the model may cancel it; see WithToolProgress.
+type ToolProgressPolicy struct {
+	// After is how long a call runs before its first progress note, 10
+	// seconds by default.
+	After time.Duration
+	// Every is the interval between later notes, After by default.
+	Every time.Duration
+	// AskModel has the model decide, with each note, whether to keep
+	// waiting for the call or cancel it and continue without its result.
+	AskModel bool
+	// MaxQuestions bounds how often the model is asked per call, 3 times
+	// by default; after that the call is waited for.
+	MaxQuestions int
+	// MaxWait cancels calls still running after this long, whatever the
+	// model decided. Zero waits for calls to finish.
+	MaxWait time.Duration
+}
+
+// ToolProgress is published each time a note is due on a tool call that
+// has run past WithToolProgress's threshold.
+type ToolProgress struct {
+	StepNumber int           `json:"step_number"`
+	Tool       string        `json:"tool"`
+	CallID     string        `json:"call_id,omitempty"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Note       string        `json:"note"` // e.g. "Still working on web_search (30s)…"
+	// Canceled reports that the call is being canceled, because the model
+	// chose to stop waiting or it ran past MaxWait.
+	Canceled bool `json:"canceled,omitempty"`
+}
+
+func (p *ToolProgress) EventType() string { return EventToolProgress }
+
+func (p *ToolProgress) String() string { return p.Note }
+
+// WithToolProgress publishes a ToolProgress note while a tool call,
+// including a managed agent's run, takes longer than policy allows, and
+// optionally asks the model whether to keep waiting. A canceled call
+// fails with an error matching ErrToolCallCanceled; its tool is
+// canceled through its context, and one that ignores it is left to
+// finish in the background.
+func WithToolProgress(policy ToolProgressPolicy) AgentOption {
+	if policy.After <= 0 {
+		policy.After = 10 * time.Second
+	}
+	if policy.Every <= 0 {
+		policy.Every = policy.After
+	}
+	if policy.MaxQuestions <= 0 {
+		policy.MaxQuestions = 3
+	}
+	return func(a *BaseAgent) { a.toolProgress = &policy }
+}
+
+// WithToolProgressCallback calls fn with the progress notes of long tool
+// calls; see WithToolProgress.
+func WithToolProgressCallback(fn func(p *ToolProgress)) AgentOption {
+	return func(a *BaseAgent) {
+		a.events.Subscribe(EventToolProgress, func(e Event) {
+			p := e.(*ToolProgress)
+			a.runCallback("tool_progress", func() { fn(p) })
+		})
+	}
+}
+
+// watchTool runs call for tc, reporting on it and canceling it as the
+// agent's tool progress policy says.
+func (a *BaseAgent) watchTool(ctx context.Context, tc ToolCall, call func(context.Context) (any, error)) (any, error) {
+	p := a.toolProgress
+	if p == nil || tc.Name == "final_answer" {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	type outcome struct {
+		result any
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := call(ctx)
+		done <- outcome{result, err}
+	}()
+
+	start := time.Now()
+	timer := time.NewTimer(p.After)
+	defer timer.Stop()
+	for asked := 0; ; {
+		select {
+		case o := <-done:
+			return o.result, o.err
+		case <-timer.C:
+		}
+		elapsed := time.Since(start)
+		var reason string
+		if p.MaxWait > 0 && elapsed >= p.MaxWait {
+			reason = fmt.Sprintf("it ran longer than %s", p.MaxWait)
+		} else if p.AskModel && asked < p.MaxQuestions {
+			asked++
+			if a.stopWaiting(ctx, tc, elapsed) {
+				reason = "the model chose not to wait for it"
+			}
+		}
+		a.events.Publish(&ToolProgress{
+			StepNumber: stepNumber(ctx),
+			Tool:       tc.Name,
+			CallID:     tc.ID,
+			Elapsed:    elapsed,
+			Note:       fmt.Sprintf("Still working on %s (%s)…", tc.Name, roundElapsed(elapsed)),
+			Canceled:   reason != "",
+		})
+		if reason != "" {
+			err := fmt.Errorf("%w: %s after %s: %s", ErrToolCallCanceled, tc.Name, roundElapsed(elapsed), reason)
+			a.logger().Warn("canceling tool call", "tool", tc.Name, "elapsed", elapsed, "reason", reason)
+			cancel(err)
+			return nil, err
+		}
+		next := p.Every
+		if p.MaxWait > 0 {
+			next = min(next, p.MaxWait-elapsed)
+		}
+		timer.Reset(next)
+	}
+}
+
+// stopWaiting asks the model whether to cancel tc, which has run for
+// elapsed. A failed question keeps waiting.
+func (a *BaseAgent) stopWaiting(ctx context.Context, tc ToolCall, elapsed time.Duration) bool {
+	args, _ := json.Marshal(tc.Arguments)
+	msgs := append(a.memory.ToMessages(), Message{Role: RoleUser, Content: fmt.Sprintf(
+		"Your call to %s with arguments %s has been running for %s without returning. Reply WAIT to keep waiting for its result, or CANCEL to cancel it and continue without it.",
+		tc.Name, args, roundElapsed(elapsed))})
+	resp, err := a.model.Generate(ctx, msgs)
+	if err != nil {
+		a.logger().Warn("asking whether to wait for tool call failed", "tool", tc.Name, "error", err)
+		return false
+	}
+	if resp.TokenUsage != nil {
+		RecordTokenUsage(ctx, *resp.TokenUsage)
+	}
+	return strings.Contains(strings.ToUpper(resp.Content), "CANCEL")
+}
+
+// roundElapsed rounds d for notes: to tenths of a second under ten
+// seconds, to seconds above.
+func roundElapsed(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
+
+// stepNumber returns the number of the step ctx belongs to, or 0.
+func stepNumber(ctx context.Context) int {
+	r, ok := ctx.Value(stepKey{}).(*stepRecorder)
+	if !ok {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.step.StepNumber
+}
@@ -0,0 +1,240 @@
+package neko
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WithPromptedToolCalls sets whether a ToolCallingAgent asks for tool calls
+// as JSON in the model's text instead of using native function calling.
+// By default it does so only when the model implements ToolCallingSupport
+// and reports no support, when the model's Capabilities lack Tools, or
+// when the backend rejects a request with ErrToolCallingUnsupported, in
+// which case the rest of the run is prompted.
+func WithPromptedToolCalls(enabled bool) AgentOption {
+	return func(a *BaseAgent) { a.promptedTools = &enabled }
+}
+
+// WithForcedFinalAnswer sets whether a ToolCallingAgent makes the model
+// call final_answer on the last step a run allows, with what it has found
+// so far, rather than end the run with "max_steps_error". It is enabled
+// by default.
+func WithForcedFinalAnswer(enabled bool) AgentOption {
+	return func(a *BaseAgent) { a.forceFinal = &enabled }
+}
+
+// lastStepChoice returns the tool choice for step and, on the last step
+// of a run forced to give a final answer, a message telling the model.
+func (a *BaseAgent) lastStepChoice(step, last int) (string, *Message) {
+	if step != last || (a.forceFinal != nil && !*a.forceFinal) {
+		return "", nil
+	}
+	return "final_answer", &Message{Role: RoleUser, Content: "This is the last step allowed. Call final_answer now with the best answer you can give from what you have found so far."}
+}
+
+// usePromptedTools reports whether tool calls should be prompted.
+func (a *BaseAgent) usePromptedTools() bool {
+	if a.promptedTools != nil {
+		return *a.promptedTools
+	}
+	if m, ok := a.model.(ToolCallingSupport); ok && !m.SupportsToolCalling() {
+		return true
+	}
+	if c, ok := a.modelCapabilities(); ok {
+		return !c.Tools
+	}
+	return false
+}
+
+// generateToolCalls calls the model with tools, natively or, if *prompted,
+// through the prompt. A native call the backend rejects for lack of
+// function calling is retried prompted, and *prompted is set for the rest
+// of the run. A prompted response that holds no valid tool call is
+// returned with an ErrParsing. choice is passed on with WithToolChoice,
+// or stated in the prompt.
+func (a *ToolCallingAgent) generateToolCalls(ctx context.Context, step int, msgs []Message, tools []Tool, choice string, prompted *bool) (*Message, error) {
+	if !*prompted {
+		resp, err := a.generate(ctx, step, msgs, WithTools(tools...), WithToolChoice(choice))
+		if !errors.Is(err, ErrToolCallingUnsupported) {
+			return resp, err
+		}
+		a.logger().Warn("model does not support tool calling, prompting for tool calls", "model", a.model.ModelID())
+		*prompted = true
+	}
+	resp, err := a.generate(ctx, step, withToolCallPrompt(msgs, tools, choice))
+	if err != nil {
+		return nil, err
+	}
+	calls, err := parsePromptedToolCalls(resp.Content, step)
+	if err != nil {
+		return resp, err
+	}
+	resp.ToolCalls = calls
+	return resp, nil
+}
+
+// withToolCallPrompt returns msgs with the tools, the JSON tool call
+// format and any tool choice appended to the system prompt.
+func withToolCallPrompt(msgs []Message, tools []Tool, choice string) []Message {
+	var sb strings.Builder
+	sb.WriteString(`
+
+You cannot call functions directly. To call a tool, reply with a single JSON object and nothing else:
+{"thought": "<why you are calling the tool>", "tool": "<tool name>", "arguments": {<argument name>: <value>, ...}}
+
+Tools and their arguments:
+`)
+	for _, t := range tools {
+		args := make([]string, 0, len(t.Inputs()))
+		for _, name := range ToolParamNames(t) {
+			in := t.Inputs()[name]
+			arg := fmt.Sprintf("%s (%s", name, cmp.Or(in.Type, "any"))
+			if !in.Required {
+				arg += ", optional"
+			}
+			args = append(args, arg+"): "+in.Description)
+		}
+		fmt.Fprintf(&sb, "- %s: %s\n", t.Name(), t.Description())
+		for _, arg := range args {
+			fmt.Fprintf(&sb, "    %s\n", arg)
+		}
+	}
+	sb.WriteString(`
+When you have the answer, call final_answer:
+{"thought": "I know the answer.", "tool": "final_answer", "arguments": {"answer": "<the answer>"}}`)
+	switch choice {
+	case "", ToolChoiceAuto:
+	case ToolChoiceNone:
+		sb.WriteString("\n\nDo not call a tool in your next reply.")
+	case ToolChoiceRequired:
+		sb.WriteString("\n\nYour next reply must call a tool.")
+	default:
+		fmt.Fprintf(&sb, "\n\nYour next reply must call %s.", choice)
+	}
+
+	out := make([]Message, len(msgs))
+	copy(out, msgs)
+	if len(out) > 0 && out[0].Role == RoleSystem {
+		out[0].Content += sb.String()
+	} else {
+		out = append([]Message{{Role: RoleSystem, Content: strings.TrimSpace(sb.String())}}, out...)
+	}
+	return out
+}
+
+// promptedToolCall is a tool call written by the model as JSON. Models
+// given the format often vary the key names, so common variants are
+// accepted.
+type promptedToolCall struct {
+	Tool       string          `json:"tool"`
+	Name       string          `json:"name"`
+	Function   json.RawMessage `json:"function"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Args       json.RawMessage `json:"args"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// parsePromptedToolCalls parses the tool calls in a prompted response: the
+// first JSON object or array in text, possibly inside a Markdown fence.
+// An array or a "tool_calls" key holds several calls.
+func parsePromptedToolCalls(text string, step int) ([]ToolCall, error) {
+	data, ok := firstJSONValue(text)
+	if !ok {
+		return nil, NewErrParsing(`no tool call found; reply with a JSON object {"tool": ..., "arguments": {...}}, calling final_answer when done`, nil)
+	}
+	var raw []promptedToolCall
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, NewErrParsing("invalid tool call JSON", err)
+		}
+	} else {
+		var wrapper struct {
+			ToolCalls []promptedToolCall `json:"tool_calls"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, NewErrParsing("invalid tool call JSON", err)
+		}
+		raw = wrapper.ToolCalls
+		if raw == nil {
+			var call promptedToolCall
+			json.Unmarshal(data, &call)
+			raw = []promptedToolCall{call}
+		}
+	}
+
+	calls := make([]ToolCall, 0, len(raw))
+	for i, c := range raw {
+		name := cmp.Or(c.Tool, c.Name)
+		argData := c.Arguments
+		if len(c.Function) > 0 {
+			// The OpenAI shape: {"function": {"name": ..., "arguments": ...}}.
+			var fn struct {
+				Name      string          `json:"name"`
+				Arguments json.RawMessage `json:"arguments"`
+			}
+			if json.Unmarshal(c.Function, &fn) == nil {
+				name, argData = cmp.Or(name, fn.Name), fn.Arguments
+			} else {
+				json.Unmarshal(c.Function, &name)
+			}
+		}
+		if name == "" {
+			return nil, NewErrParsing(`tool call has no "tool" name`, nil)
+		}
+		for _, d := range []json.RawMessage{c.Args, c.Parameters} {
+			if len(argData) == 0 {
+				argData = d
+			}
+		}
+		args, err := decodePromptedArgs(argData)
+		if err != nil {
+			return nil, NewErrParsing(fmt.Sprintf("invalid arguments for %s", name), err)
+		}
+		calls = append(calls, ToolCall{ID: fmt.Sprintf("call_%d_%d", step, i), Name: name, Arguments: args})
+	}
+	if len(calls) == 0 {
+		return nil, NewErrParsing("empty tool_calls", nil)
+	}
+	return calls, nil
+}
+
+// decodePromptedArgs decodes tool call arguments given as an object or as
+// a JSON string holding one.
+func decodePromptedArgs(data json.RawMessage) (map[string]any, error) {
+	args := map[string]any{}
+	if len(data) == 0 || string(data) == "null" {
+		return args, nil
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		data = json.RawMessage(s)
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// firstJSONValue returns the first complete JSON object, or array of
+// objects, in text.
+func firstJSONValue(text string) ([]byte, bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var v json.RawMessage
+		if dec.Decode(&v) != nil {
+			continue
+		}
+		if v[0] == '[' && json.Unmarshal(v, &[]map[string]any{}) != nil {
+			continue
+		}
+		return v, true
+	}
+	return nil, false
+}
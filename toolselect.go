@@ -0,0 +1,100 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WithToolSelection offers a ToolCallingAgent's model only the topN tools,
+// including managed agents, most relevant to each step, which keeps
+// prompts short and tool choice accurate when many tools are registered.
+// Relevance is the embedding similarity between a tool's name and
+// description and the task followed by the model's latest output.
+// final_answer is always offered. If embedding fails, every tool is
+// offered. A CodeAgent's prompt lists all tools, so it ignores this.
+func WithToolSelection(e Embedder, topN int) AgentOption {
+	return func(a *BaseAgent) {
+		a.toolSelector = &toolSelector{embedder: e, topN: topN, vectors: map[string][]float64{}}
+	}
+}
+
+type toolSelector struct {
+	embedder Embedder
+	topN     int
+	mu       sync.Mutex
+	vectors  map[string][]float64 // tool embeddings by tool text
+}
+
+// selectTools returns the tools to offer the model for the next step.
+func (a *BaseAgent) selectTools(ctx context.Context, task string, tools []Tool) []Tool {
+	s := a.toolSelector
+	if s == nil || len(tools) <= s.topN+1 {
+		return tools
+	}
+	query := task
+	if steps := a.memory.ActionSteps(); len(steps) > 0 {
+		query += "\n\n" + steps[len(steps)-1].ModelOutput
+	}
+	ranked, err := s.rank(ctx, query, tools)
+	if err != nil {
+		a.logger().Warn("tool selection failed; offering all tools", "error", err)
+		return tools
+	}
+	names := make([]string, len(ranked))
+	for i, t := range ranked {
+		names[i] = t.Name()
+	}
+	a.logger().Debug("tools selected", "tools", strings.Join(names, ","))
+	return ranked
+}
+
+// rank returns final_answer and the topN tools most similar to query, in
+// order of similarity.
+func (s *toolSelector) rank(ctx context.Context, query string, tools []Tool) ([]Tool, error) {
+	texts := make([]string, len(tools))
+	s.mu.Lock()
+	missing := []string{query}
+	for i, t := range tools {
+		texts[i] = t.Name() + ": " + t.Description()
+		if _, ok := s.vectors[texts[i]]; !ok {
+			missing = append(missing, texts[i])
+		}
+	}
+	s.mu.Unlock()
+
+	vectors, err := s.embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(missing) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(missing))
+	}
+	queryVec := vectors[0]
+	s.mu.Lock()
+	for i, text := range missing[1:] {
+		s.vectors[text] = vectors[i+1]
+	}
+	scores := make(map[string]float64, len(tools))
+	for i, t := range tools {
+		scores[t.Name()] = cosineSimilarity(queryVec, s.vectors[texts[i]])
+	}
+	s.mu.Unlock()
+
+	var final []Tool
+	candidates := make([]Tool, 0, len(tools))
+	for _, t := range tools {
+		if t.Name() == "final_answer" {
+			final = append(final, t)
+		} else {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return scores[candidates[i].Name()] > scores[candidates[j].Name()] })
+	if len(candidates) > s.topN {
+		candidates = candidates[:s.topN]
+	}
+	return append(candidates, final...), nil
+}
@@ -0,0 +1,236 @@
+package neko
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TraceVersion is the version of the JSONL trace format written by
+// WithTraceWriter.
+const TraceVersion = 1
+
+// Trace event types.
+const (
+	TraceRunStarted   = "run_started"
+	TraceStep         = "step"
+	TraceRunCompleted = "run_completed"
+	TraceBudget       = "budget_warning"
+	TraceAdaptation   = "adaptation"
+)
+
+// TraceEvent is one line of a JSONL run trace. A run is recorded as a
+// run_started event, one step event per step in the order steps are added
+// to memory, a budget_warning event per budget threshold reached, an
+// adaptation event if WithErrorRecovery adapted the run, and a
+// run_completed event. The run_completed result omits its steps, since
+// they are already in the trace.
+type TraceEvent struct {
+	Version      int            `json:"v"`
+	Type         string         `json:"type"`
+	Time         time.Time      `json:"time"`
+	Agent        string         `json:"agent,omitempty"`
+	Task         string         `json:"task,omitempty"`
+	SystemPrompt string         `json:"system_prompt,omitempty"`
+	Reset        bool           `json:"reset,omitempty"`
+	StepType     string         `json:"step_type,omitempty"`
+	Step         Step           `json:"step,omitempty"`
+	Budget       *BudgetWarning `json:"budget,omitempty"`
+	Adaptation   *Adaptation    `json:"adaptation,omitempty"`
+	Result       *RunResult     `json:"result,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// UnmarshalJSON decodes an event, restoring Step as the concrete type
+// named by StepType.
+func (e *TraceEvent) UnmarshalJSON(data []byte) error {
+	type plain TraceEvent
+	aux := struct {
+		*plain
+		Step json.RawMessage `json:"step,omitempty"`
+	}{plain: (*plain)(e)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Step) == 0 || string(aux.Step) == "null" {
+		return nil
+	}
+	step, err := decodeStep(e.StepType, aux.Step)
+	if err != nil {
+		return err
+	}
+	e.Step = step
+	return nil
+}
+
+// TraceOption configures a trace writer.
+type TraceOption func(*traceWriter)
+
+// WithTraceRedactor redacts every string in the trace with r before it is
+// written, so the trace can be shared or stored without sensitive data.
+func WithTraceRedactor(r *Redactor) TraceOption {
+	return func(t *traceWriter) { t.redactor = r }
+}
+
+// WithTraceWriter appends every run event to w as a JSON line while the
+// agent runs. Use LoadTrace to read the trace back. Write failures are
+// logged and do not affect the run.
+func WithTraceWriter(w io.Writer, opts ...TraceOption) AgentOption {
+	return func(a *BaseAgent) {
+		t := &traceWriter{w: w, agent: a}
+		for _, opt := range opts {
+			opt(t)
+		}
+		a.events.Subscribe(EventRunStarted, t.runStarted)
+		a.events.Subscribe(EventStep, t.step)
+		a.events.Subscribe(EventBudgetWarning, t.budgetWarning)
+		a.events.Subscribe(EventAdaptation, t.adaptation)
+		a.events.Subscribe(EventRunCompleted, t.runCompleted)
+	}
+}
+
+type traceWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	redactor *Redactor
+	agent    *BaseAgent
+}
+
+// runStarted records a run start. A resumed run, or one started from
+// WithMemory, is recorded as a new run followed by the steps it continues
+// from, so the trace stands alone.
+func (t *traceWriter) runStarted(e Event) {
+	ev := e.(RunStartedEvent)
+	var from *Memory
+	switch {
+	case ev.Resume != nil:
+		from = ev.Resume
+	case ev.Memory != nil:
+		from = &Memory{SystemPrompt: ev.SystemPrompt, Steps: ev.Memory.Steps}
+	default:
+		t.write(&TraceEvent{Type: TraceRunStarted, Task: ev.Task, SystemPrompt: t.agent.memory.SystemPrompt, Reset: ev.Reset})
+		return
+	}
+	t.write(&TraceEvent{Type: TraceRunStarted, Task: ev.Task, SystemPrompt: from.SystemPrompt, Reset: true})
+	for _, step := range from.Steps {
+		t.write(&TraceEvent{Type: TraceStep, StepType: step.StepType(), Step: step})
+	}
+}
+
+func (t *traceWriter) step(e Event) {
+	step := e.(StepEvent).Step
+	t.write(&TraceEvent{Type: TraceStep, StepType: step.StepType(), Step: step})
+}
+
+func (t *traceWriter) budgetWarning(e Event) {
+	t.write(&TraceEvent{Type: TraceBudget, Budget: e.(*BudgetWarning)})
+}
+
+func (t *traceWriter) adaptation(e Event) {
+	t.write(&TraceEvent{Type: TraceAdaptation, Adaptation: e.(*Adaptation)})
+}
+
+func (t *traceWriter) runCompleted(e Event) {
+	ev := e.(RunCompletedEvent)
+	event := &TraceEvent{Type: TraceRunCompleted}
+	if ev.Result != nil {
+		r := *ev.Result
+		r.Steps = nil
+		event.Result = &r
+	}
+	if ev.Err != nil {
+		event.Error = ev.Err.Error()
+	}
+	t.write(event)
+}
+
+func (t *traceWriter) write(event *TraceEvent) {
+	event.Version = TraceVersion
+	event.Time = time.Now().UTC()
+	event.Agent = t.agent.name
+	if err := t.encode(event); err != nil {
+		t.agent.logger().Warn("trace write failed", "event", event.Type, "error", err)
+	}
+}
+
+func (t *traceWriter) encode(event *TraceEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if t.redactor != nil {
+		if data, err = t.redactor.RedactJSON(data); err != nil {
+			return err
+		}
+	}
+	if s := t.agent.secrets; s != nil {
+		if data, err = s.redactor.RedactJSON(data); err != nil {
+			return err
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err = t.w.Write(append(data, '\n'))
+	return err
+}
+
+// LoadTrace reads a JSONL trace written by WithTraceWriter and rebuilds
+// the last run's result and the agent memory at its end. Runs started
+// without reset continue the memory of the run before them. If the trace
+// ends before the run completed, the result has state "incomplete".
+func LoadTrace(r io.Reader) (*RunResult, *Memory, error) {
+	var (
+		memory *Memory
+		result *RunResult
+		runErr error
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, nil, fmt.Errorf("trace line %d: %w", line, err)
+		}
+		if event.Version > TraceVersion {
+			return nil, nil, fmt.Errorf("trace line %d: unsupported trace version %d", line, event.Version)
+		}
+		switch event.Type {
+		case TraceRunStarted:
+			if memory == nil || event.Reset {
+				memory = NewMemory(event.SystemPrompt)
+			}
+			result = &RunResult{State: "incomplete", Timing: Timing{StartTime: event.Time}}
+			runErr = nil
+		case TraceStep:
+			if memory == nil {
+				return nil, nil, fmt.Errorf("trace line %d: step before run start", line)
+			}
+			memory.AddStep(event.Step)
+		case TraceRunCompleted:
+			if memory == nil {
+				return nil, nil, fmt.Errorf("trace line %d: run completion before run start", line)
+			}
+			if event.Result != nil {
+				result = event.Result
+			}
+			if event.Error != "" {
+				runErr = errors.New(event.Error)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if memory == nil {
+		return nil, nil, fmt.Errorf("trace contains no runs")
+	}
+	result.Steps = memory.Steps
+	return result, memory, runErr
+}
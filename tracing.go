@@ -0,0 +1,136 @@
+package neko
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Span attribute keys from the OpenTelemetry GenAI semantic conventions.
+const (
+	attrOperationName = attribute.Key("gen_ai.operation.name")
+	attrSystem        = attribute.Key("gen_ai.system")
+	attrRequestModel  = attribute.Key("gen_ai.request.model")
+	attrInputTokens   = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens  = attribute.Key("gen_ai.usage.output_tokens")
+	attrAgentName     = attribute.Key("gen_ai.agent.name")
+	attrToolName      = attribute.Key("gen_ai.tool.name")
+	attrToolCallID    = attribute.Key("gen_ai.tool.call.id")
+	attrStepNumber    = attribute.Key("neko.step.number")
+	attrRunState      = attribute.Key("neko.run.state")
+	attrCodeLanguage  = attribute.Key("neko.code.language")
+	attrCost          = attribute.Key("neko.usage.cost")
+)
+
+// WithTracer emits OpenTelemetry spans for runs, steps, model calls, tool
+// calls and code executions. Spans nest under any span already in the
+// context passed to Run, and managed agents' runs nest under the tool
+// call that invoked them.
+func WithTracer(t trace.Tracer) AgentOption {
+	return func(a *BaseAgent) { a.tracer = t }
+}
+
+var noopTracer = noop.NewTracerProvider().Tracer("")
+
+func (a *BaseAgent) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	t := a.tracer
+	if t == nil {
+		t = noopTracer
+	}
+	return t.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startRunSpan starts the span covering a whole Run.
+func (a *BaseAgent) startRunSpan(ctx context.Context) (context.Context, trace.Span) {
+	name := "invoke_agent"
+	if a.name != "" {
+		name += " " + a.name
+	}
+	return a.startSpan(ctx, name,
+		attrOperationName.String("invoke_agent"),
+		attrAgentName.String(a.name),
+	)
+}
+
+// endRunSpan records the outcome of a run on its span and ends it.
+func endRunSpan(span trace.Span, result *RunResult, err error) {
+	if result != nil {
+		span.SetAttributes(attrRunState.String(result.State))
+		if result.TokenUsage != nil {
+			span.SetAttributes(
+				attrInputTokens.Int(result.TokenUsage.InputTokens),
+				attrOutputTokens.Int(result.TokenUsage.OutputTokens),
+			)
+		}
+		if result.Cost > 0 {
+			span.SetAttributes(attrCost.Float64(result.Cost))
+		}
+	}
+	endSpan(span, err)
+}
+
+// startStepSpan starts the span covering one action step.
+func (a *BaseAgent) startStepSpan(ctx context.Context, step int) (context.Context, trace.Span) {
+	return a.startSpan(ctx, fmt.Sprintf("step %d", step), attrStepNumber.Int(step))
+}
+
+// endStepSpan records an action step's outcome on its span and ends it.
+func endStepSpan(span trace.Span, step *ActionStep) {
+	if step.TokenUsage != nil {
+		span.SetAttributes(
+			attrInputTokens.Int(step.TokenUsage.InputTokens),
+			attrOutputTokens.Int(step.TokenUsage.OutputTokens),
+		)
+	}
+	endSpan(span, step.Error)
+}
+
+// startChatSpan starts the span covering one model call. gen_ai.system is
+// set only for models that report their provider.
+func (a *BaseAgent) startChatSpan(ctx context.Context) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attrOperationName.String("chat"),
+		attrRequestModel.String(a.model.ModelID()),
+	}
+	if p, ok := a.model.(ModelProvider); ok && p.Provider() != "" {
+		attrs = append(attrs, attrSystem.String(p.Provider()))
+	}
+	return a.startSpan(ctx, "chat "+a.model.ModelID(), attrs...)
+}
+
+// endChatSpan records a model response's token usage on its span and ends it.
+func endChatSpan(span trace.Span, resp *Message, err error) {
+	if resp != nil && resp.TokenUsage != nil {
+		span.SetAttributes(
+			attrInputTokens.Int(resp.TokenUsage.InputTokens),
+			attrOutputTokens.Int(resp.TokenUsage.OutputTokens),
+		)
+	}
+	endSpan(span, err)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startToolSpan starts the span covering one tool call.
+func (a *BaseAgent) startToolSpan(ctx context.Context, tc ToolCall) (context.Context, trace.Span) {
+	return a.startSpan(ctx, "execute_tool "+tc.Name,
+		attrOperationName.String("execute_tool"),
+		attrToolName.String(tc.Name),
+		attrToolCallID.String(tc.ID),
+	)
+}
+
+// startCodeSpan starts the span covering one code execution.
+func (a *BaseAgent) startCodeSpan(ctx context.Context, language string) (context.Context, trace.Span) {
+	return a.startSpan(ctx, "execute_code", attrCodeLanguage.String(language))
+}
@@ -0,0 +1,54 @@
+package neko
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// attrTracer records the attributes of the last span it started.
+type attrTracer struct {
+	embedded.Tracer
+	attrs []attribute.KeyValue
+}
+
+func (t *attrTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	t.attrs = cfg.Attributes()
+	return noopTracer.Start(ctx, name)
+}
+
+func (t *attrTracer) value(key attribute.Key) (string, bool) {
+	for _, kv := range t.attrs {
+		if kv.Key == key {
+			return kv.Value.AsString(), true
+		}
+	}
+	return "", false
+}
+
+func TestChatSpanSystem(t *testing.T) {
+	tests := []struct {
+		model Model
+		want  string
+	}{
+		{NewOpenAIModel("gpt-4o", "key"), "openai"},
+		{NewAzureOpenAIModel("gpt-4o", "https://example.openai.azure.com", "key"), "az.ai.openai"},
+		{NewGroqModel("llama-3.3-70b-versatile", "key"), "groq"},
+		{NormalizeMessages(NewDeepSeekModel("deepseek-chat", "key"), StrictAlternation), "deepseek"},
+		{NewOpenAIModelWithBaseURL("llama3", "key", "http://localhost:11434/v1"), ""},
+	}
+	for _, tt := range tests {
+		tracer := &attrTracer{}
+		a := &BaseAgent{model: tt.model, tracer: tracer}
+		_, span := a.startChatSpan(context.Background())
+		span.End()
+		got, ok := tracer.value(attrSystem)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("%s: gen_ai.system = %q (set %v), want %q", tt.model.ModelID(), got, ok, tt.want)
+		}
+	}
+}
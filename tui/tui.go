@@ -0,0 +1,454 @@
+// Package tui shows a live terminal dashboard for local agent runs: the
+// steps taken so far, the model's output as it streams, and token and cost
+// counters. Keys pause the run, approve or deny tool calls, and abort it.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gocnn/neko"
+)
+
+// Dashboard drives an agent run inside a terminal UI. Create it before the
+// agent and pass AgentOptions to the agent's constructor, then start runs
+// with Run.
+type Dashboard struct {
+	approve     bool
+	pricing     neko.Pricing
+	programOpts []tea.ProgramOption
+
+	mu      sync.Mutex
+	program *tea.Program
+	gate    *pauseGate
+}
+
+// Option configures a Dashboard.
+type Option func(*Dashboard)
+
+// WithApproval asks for confirmation in the UI before every tool call.
+func WithApproval() Option {
+	return func(d *Dashboard) { d.approve = true }
+}
+
+// WithPricing shows a running cost based on the model's token prices.
+func WithPricing(p neko.Pricing) Option {
+	return func(d *Dashboard) { d.pricing = p }
+}
+
+// WithProgramOptions passes options to the underlying bubbletea program,
+// e.g. tea.WithInput or tea.WithOutput.
+func WithProgramOptions(opts ...tea.ProgramOption) Option {
+	return func(d *Dashboard) { d.programOpts = append(d.programOpts, opts...) }
+}
+
+// New creates a dashboard.
+func New(opts ...Option) *Dashboard {
+	d := &Dashboard{gate: newPauseGate()}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// AgentOptions returns the options that connect an agent to the
+// dashboard. Model output only streams with a neko.StreamingModel. The
+// dashboard subscribes to the agent's events rather than using callbacks,
+// which run asynchronously, so that pausing blocks the run.
+func (d *Dashboard) AgentOptions() []neko.AgentOption {
+	opts := []neko.AgentOption{
+		func(a *neko.BaseAgent) {
+			a.Events().Subscribe(neko.EventStep, func(e neko.Event) { d.onStep(e.(neko.StepEvent).Step) })
+			a.Events().Subscribe(neko.EventModelDelta, func(e neko.Event) {
+				ev := e.(neko.ModelDeltaEvent)
+				d.onDelta(ev.StepNumber, ev.Delta)
+			})
+			a.Events().Subscribe(neko.EventAnswerDelta, func(e neko.Event) {
+				ev := e.(neko.AnswerDeltaEvent)
+				d.onDelta(ev.StepNumber, ev.Delta)
+			})
+			a.Events().Subscribe(neko.EventBudgetWarning, d.onBudgetWarning)
+		},
+	}
+	if d.approve {
+		opts = append(opts, neko.WithToolApproval(d.onToolCall))
+	}
+	return opts
+}
+
+// Run runs agent on task while showing the dashboard, and returns once
+// the user closes it. Aborting from the UI cancels the run's context.
+func (d *Dashboard) Run(ctx context.Context, agent neko.Agent, task string, opts ...neko.RunOption) (*neko.RunResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m := newModel(agent.Name(), task, d.pricing, d.gate, cancel)
+	program := tea.NewProgram(m, append([]tea.ProgramOption{tea.WithAltScreen()}, d.programOpts...)...)
+	d.mu.Lock()
+	d.program = program
+	d.mu.Unlock()
+
+	var (
+		result *neko.RunResult
+		runErr error
+		wg     sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result, runErr = agent.Run(ctx, task, opts...)
+		program.Send(runDoneMsg{result: result, err: runErr})
+	}()
+
+	_, uiErr := program.Run()
+	cancel()
+	d.gate.resume()
+	wg.Wait()
+
+	d.mu.Lock()
+	d.program = nil
+	d.mu.Unlock()
+	if uiErr != nil && runErr == nil {
+		return result, uiErr
+	}
+	return result, runErr
+}
+
+func (d *Dashboard) send(msg tea.Msg) {
+	d.mu.Lock()
+	p := d.program
+	d.mu.Unlock()
+	if p != nil {
+		p.Send(msg)
+	}
+}
+
+func (d *Dashboard) onStep(step neko.Step) {
+	d.send(stepMsg{step: step})
+	d.gate.wait()
+}
+
+func (d *Dashboard) onBudgetWarning(e neko.Event) {
+	d.send(warningMsg{text: e.(*neko.BudgetWarning).String()})
+}
+
+func (d *Dashboard) onDelta(step int, delta string) {
+	d.send(deltaMsg{step: step, text: delta})
+	d.gate.wait()
+}
+
+func (d *Dashboard) onToolCall(ctx context.Context, tc neko.ToolCall) (bool, error) {
+	reply := make(chan bool, 1)
+	d.send(approvalMsg{call: tc, reply: reply})
+	select {
+	case ok := <-reply:
+		return ok, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// pauseGate blocks the agent between events while the run is paused.
+type pauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	ch     chan struct{}
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{}
+}
+
+func (g *pauseGate) toggle() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.ch)
+		return false
+	}
+	g.paused, g.ch = true, make(chan struct{})
+	return true
+}
+
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.ch)
+	}
+}
+
+func (g *pauseGate) wait() {
+	g.mu.Lock()
+	ch, paused := g.ch, g.paused
+	g.mu.Unlock()
+	if paused {
+		<-ch
+	}
+}
+
+type stepMsg struct{ step neko.Step }
+
+type warningMsg struct{ text string }
+
+type deltaMsg struct {
+	step int
+	text string
+}
+
+type approvalMsg struct {
+	call  neko.ToolCall
+	reply chan bool
+}
+
+type runDoneMsg struct {
+	result *neko.RunResult
+	err    error
+}
+
+// stepRow is one line of the step list.
+type stepRow struct {
+	number   int
+	status   string
+	summary  string
+	duration time.Duration
+	tokens   int
+}
+
+type model struct {
+	agent   string
+	task    string
+	pricing neko.Pricing
+	gate    *pauseGate
+	abort   context.CancelFunc
+
+	rows     []stepRow
+	current  int
+	output   strings.Builder
+	tokens   neko.TokenUsage
+	paused   bool
+	pending  *approvalMsg
+	done     *runDoneMsg
+	aborted  bool
+	warnings []string
+	width    int
+	height   int
+}
+
+func newModel(agent, task string, pricing neko.Pricing, gate *pauseGate, abort context.CancelFunc) *model {
+	return &model{agent: agent, task: task, pricing: pricing, gate: gate, abort: abort, current: 1}
+}
+
+func (m *model) Init() tea.Cmd { return nil }
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		return m, m.handleKey(msg)
+	case stepMsg:
+		m.addStep(msg.step)
+	case warningMsg:
+		m.warnings = append(m.warnings, msg.text)
+	case deltaMsg:
+		if msg.step != m.current {
+			m.current = msg.step
+			m.output.Reset()
+		}
+		m.output.WriteString(msg.text)
+	case approvalMsg:
+		m.pending = &msg
+	case runDoneMsg:
+		m.done = &msg
+		m.pending = nil
+	}
+	return m, nil
+}
+
+func (m *model) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "y":
+		if m.pending != nil {
+			m.pending.reply <- true
+			m.pending = nil
+		}
+	case "n":
+		if m.pending != nil {
+			m.pending.reply <- false
+			m.pending = nil
+		}
+	case "p", " ":
+		if m.done == nil {
+			m.paused = m.gate.toggle()
+		}
+	case "a":
+		if m.done == nil {
+			m.aborted = true
+			m.abort()
+			if m.paused {
+				m.gate.resume()
+				m.paused = false
+			}
+		}
+	case "q", "ctrl+c", "esc":
+		if m.done == nil {
+			m.abort()
+		}
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *model) addStep(step neko.Step) {
+	if s, ok := step.(*neko.ActionStep); ok {
+		row := stepRow{number: s.StepNumber, status: "ok", duration: s.Timing.Duration}
+		switch {
+		case s.Error != nil:
+			row.status = "error"
+			row.summary = firstLine(s.Error.Error())
+		case s.IsFinal:
+			row.status = "final"
+		}
+		if row.summary == "" {
+			row.summary = stepSummary(s)
+		}
+		if s.TokenUsage != nil {
+			row.tokens = s.TokenUsage.Total()
+			m.tokens.InputTokens += s.TokenUsage.InputTokens
+			m.tokens.OutputTokens += s.TokenUsage.OutputTokens
+		}
+		m.rows = append(m.rows, row)
+		m.current = s.StepNumber + 1
+		m.output.Reset()
+	}
+}
+
+func stepSummary(s *neko.ActionStep) string {
+	if len(s.ToolCalls) > 0 {
+		names := make([]string, len(s.ToolCalls))
+		for i, tc := range s.ToolCalls {
+			names[i] = tc.Name
+		}
+		return "called " + strings.Join(names, ", ")
+	}
+	if s.CodeAction != "" {
+		return firstLine(s.CodeAction)
+	}
+	return firstLine(s.ModelOutput)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " …"
+	}
+	if r := []rune(s); len(r) > 70 {
+		s = string(r[:70]) + "…"
+	}
+	return s
+}
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	dimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	okStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	finalStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+	boxStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	promptStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("13"))
+	counterStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+)
+
+func (m *model) View() string {
+	var sb strings.Builder
+	title := "neko"
+	if m.agent != "" {
+		title += " · " + m.agent
+	}
+	sb.WriteString(titleStyle.Render(title) + "  " + dimStyle.Render(firstLine(m.task)) + "\n\n")
+
+	counters := fmt.Sprintf("tokens in %d · out %d", m.tokens.InputTokens, m.tokens.OutputTokens)
+	if m.pricing != (neko.Pricing{}) {
+		counters += fmt.Sprintf(" · cost $%.4f", m.tokens.Cost(m.pricing))
+	}
+	sb.WriteString(counterStyle.Render(counters) + "\n\n")
+
+	for _, r := range m.rows {
+		status := okStyle.Render("✓")
+		switch r.status {
+		case "error":
+			status = errorStyle.Render("✗")
+		case "final":
+			status = finalStyle.Render("★")
+		}
+		fmt.Fprintf(&sb, "%s Step %-3d %s %s\n", status, r.number, dimStyle.Render(fmt.Sprintf("%6.2fs %6d tok", r.duration.Seconds(), r.tokens)), r.summary)
+	}
+	if m.done == nil {
+		fmt.Fprintf(&sb, "%s Step %-3d\n", dimStyle.Render("…"), m.current)
+	}
+	for _, w := range m.warnings {
+		sb.WriteString(errorStyle.Render("budget: "+w) + "\n")
+	}
+
+	if out := m.tail(m.output.String()); out != "" && m.done == nil {
+		sb.WriteString("\n" + boxStyle.Render(out) + "\n")
+	}
+
+	sb.WriteString("\n")
+	switch {
+	case m.pending != nil:
+		sb.WriteString(promptStyle.Render(fmt.Sprintf("Allow tool call %s(%s)? [y/n]", m.pending.call.Name, formatArgs(m.pending.call.Arguments))) + "\n")
+	case m.done != nil:
+		if m.done.err != nil {
+			sb.WriteString(errorStyle.Render("Run failed: "+m.done.err.Error()) + "\n")
+		} else {
+			sb.WriteString(finalStyle.Render(fmt.Sprintf("Final answer: %v", m.done.result.Output)) + "\n")
+		}
+		sb.WriteString(dimStyle.Render("q quit") + "\n")
+	default:
+		state := "running"
+		if m.paused {
+			state = "paused"
+		}
+		if m.aborted {
+			state = "aborting"
+		}
+		sb.WriteString(dimStyle.Render(state+" · p pause/resume · a abort · q quit") + "\n")
+	}
+	return sb.String()
+}
+
+// tail returns the last lines of s that fit on screen.
+func (m *model) tail(s string) string {
+	maxLines := 10
+	if m.height > 0 {
+		maxLines = max(3, m.height-len(m.rows)-12)
+	}
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatArgs(args map[string]any) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, args[k])
+	}
+	return firstLine(strings.Join(parts, ", "))
+}
@@ -0,0 +1,256 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Kinds of typed final answers.
+const (
+	OutputText  = "text"
+	OutputImage = "image"
+	OutputFile  = "file"
+	OutputTable = "table"
+)
+
+// Output is a typed final answer: text, an image or file the run
+// produced, or a table. It is the RunResult.Output of agents with
+// WithTypedOutputs, and prints as text for callers that expect prose.
+type Output struct {
+	Kind string `json:"kind"`
+	// Text is the answer of a text output and the caption of the others.
+	Text string `json:"text,omitempty"`
+	// Artifact is the image or file, with its contents.
+	Artifact *Artifact `json:"artifact,omitempty"`
+	Table    *Table    `json:"table,omitempty"`
+}
+
+// Table is a tabular final answer.
+type Table struct {
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// String returns the output as text: the caption of an image or file
+// followed by its name, and a table as a Markdown table.
+func (o *Output) String() string {
+	var sb strings.Builder
+	sb.WriteString(o.Text)
+	if o.Kind == OutputText {
+		return sb.String()
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n\n")
+	}
+	switch {
+	case o.Artifact != nil:
+		fmt.Fprintf(&sb, "[%s: %s]", o.Kind, o.Artifact.Name)
+	case o.Table != nil:
+		cells := func(row []string) {
+			sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		cells(o.Table.Columns)
+		rule := make([]string, len(o.Table.Columns))
+		for i := range rule {
+			rule[i] = "---"
+		}
+		cells(rule)
+		for _, row := range o.Table.Rows {
+			values := make([]string, len(row))
+			for i, v := range row {
+				values[i] = strings.ReplaceAll(fmt.Sprint(v), "|", `\|`)
+			}
+			cells(values)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// AsOutput returns v as a typed final answer, if it is one: an *Output,
+// or its JSON form as decoded into a map, e.g. from a loaded trace.
+func AsOutput(v any) (*Output, bool) {
+	switch o := v.(type) {
+	case *Output:
+		return o, o != nil
+	case Output:
+		return &o, true
+	case map[string]any:
+		kind, _ := o["kind"].(string)
+		switch kind {
+		case OutputText, OutputImage, OutputFile, OutputTable:
+		default:
+			return nil, false
+		}
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, false
+		}
+		var out Output
+		if json.Unmarshal(data, &out) != nil {
+			return nil, false
+		}
+		return &out, true
+	}
+	return nil, false
+}
+
+// WithTypedOutputs lets the agent answer with an image, a file or a
+// table instead of text, and makes each final answer, and so
+// RunResult.Output, an *Output. Images and files are those the run
+// produced: artifacts of its steps or files in its workspace. A ToolCallingAgent's final_answer tool gains
+// type, path, columns and rows arguments; a CodeAgent passes final_answer
+// a dict such as {"type": "image", "path": "chart.png", "caption": "..."}.
+func WithTypedOutputs() AgentOption {
+	return func(a *BaseAgent) { a.typedOutputs = true }
+}
+
+// addOutputInputs gives the final_answer tool the arguments of typed
+// outputs.
+func (t *FinalAnswerTool) addOutputInputs() {
+	inputs := make(map[string]ToolInput, len(t.inputs)+4)
+	for name, in := range t.inputs {
+		inputs[name] = in
+	}
+	inputs["answer"] = ToolInput{Type: "string", Description: "The final answer to the problem, or the caption of an image, file or table", Required: true}
+	inputs["type"] = ToolInput{Type: "string", Description: `The kind of answer: "text" (the default), "image", "file" or "table"`}
+	inputs["path"] = ToolInput{Type: "string", Description: "For an image or file, the path of the file produced in this run"}
+	inputs["columns"] = ToolInput{Type: "array", Description: "For a table, the column names"}
+	inputs["rows"] = ToolInput{Type: "array", Description: "For a table, the rows, each an array of cell values"}
+	t.inputs = inputs
+}
+
+// typedOutputPrompt explains typed outputs in the system prompt.
+func (a *BaseAgent) typedOutputPrompt(code bool) string {
+	if !a.typedOutputs {
+		return ""
+	}
+	how := `Set final_answer's type argument to "image" or "file" with the path of a file you produced, or to "table" with columns and rows; the answer is then its caption.`
+	if code {
+		how = `Call final_answer with a dict: final_answer({"type": "image", "path": "chart.png", "caption": "..."}) for an image or "file" for another file you saved, or final_answer({"type": "table", "columns": [...], "rows": [[...], ...]}) for a table.`
+	}
+	return "\n\n## Answer types\nWhen the answer is a chart, document or table, give it as such rather than describing it or naming its path in text. " + how
+}
+
+// takeOutput makes a final answer typed, given the answer and the
+// arguments of the final_answer call that gave it, nil for a CodeAgent,
+// and the step giving it. An error rejects an answer whose image or file
+// cannot be found.
+func (a *BaseAgent) takeOutput(ctx context.Context, answer any, args map[string]any, step *ActionStep) (any, error) {
+	if !a.typedOutputs {
+		return answer, nil
+	}
+	fields := args
+	if fields == nil {
+		m, ok := answer.(map[string]any)
+		if s, isString := answer.(string); isString {
+			ok = json.Unmarshal([]byte(strings.TrimSpace(s)), &m) == nil
+		}
+		if _, typed := m["type"]; !ok || !typed {
+			return &Output{Kind: OutputText, Text: outputText(answer)}, nil
+		}
+		fields = m
+	}
+	kind, _ := fields["type"].(string)
+	text := outputText(fields["answer"])
+	if caption, ok := fields["caption"]; ok {
+		text = outputText(caption)
+	}
+	out := &Output{Kind: strings.ToLower(strings.TrimSpace(kind)), Text: text}
+	switch out.Kind {
+	case "", OutputText:
+		out.Kind = OutputText
+	case OutputImage, OutputFile:
+		p, _ := fields["path"].(string)
+		if p == "" {
+			return nil, fmt.Errorf("final answer rejected: give the path of the %s", out.Kind)
+		}
+		artifact, err := a.findArtifact(ctx, p, step)
+		if err != nil {
+			return nil, fmt.Errorf("final answer rejected: %w", err)
+		}
+		if out.Kind == OutputImage && !strings.HasPrefix(artifact.MIMEType, "image/") {
+			return nil, fmt.Errorf("final answer rejected: %s is %s, not an image; give it as a file", p, artifact.MIMEType)
+		}
+		out.Artifact = artifact
+	case OutputTable:
+		table, err := outputTable(fields["columns"], fields["rows"])
+		if err != nil {
+			return nil, fmt.Errorf("final answer rejected: %w", err)
+		}
+		out.Table = table
+	default:
+		return nil, fmt.Errorf(`final answer rejected: unknown answer type %q; use "text", "image", "file" or "table"`, kind)
+	}
+	return out, nil
+}
+
+// outputText returns the text of an answer, JSON for structured values.
+func outputText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// findArtifact returns the file at p produced by the run: the latest
+// artifact with that path or name, or else the file in the workspace.
+func (a *BaseAgent) findArtifact(ctx context.Context, p string, step *ActionStep) (*Artifact, error) {
+	p = strings.TrimPrefix(path.Clean(strings.ReplaceAll(p, `\`, "/")), "./")
+	artifacts := a.memory.Artifacts()
+	if step != nil {
+		artifacts = append(artifacts, step.Artifacts...)
+	}
+	for i := len(artifacts) - 1; i >= 0; i-- {
+		art := artifacts[i]
+		if (art.Path == p || art.Name == p) && len(art.Data) > 0 {
+			return &art, nil
+		}
+	}
+	if w := WorkspaceFromContext(ctx); w != nil {
+		p = strings.TrimPrefix(p, strings.TrimSuffix(w.Dir(), "/")+"/")
+		if data, err := w.ReadFile(p); err == nil {
+			art := &Artifact{Name: path.Base(p), Path: p, MIMEType: mime.TypeByExtension(path.Ext(p)), Data: data}
+			if art.MIMEType == "" {
+				art.MIMEType = http.DetectContentType(data)
+			}
+			return art, nil
+		}
+	}
+	return nil, fmt.Errorf("no file %s was produced in this run", p)
+}
+
+// outputTable reads a table's columns and rows.
+func outputTable(columns, rows any) (*Table, error) {
+	cols, ok := columns.([]any)
+	if !ok || len(cols) == 0 {
+		return nil, fmt.Errorf("give the table's column names")
+	}
+	t := &Table{Columns: make([]string, len(cols))}
+	for i, c := range cols {
+		t.Columns[i] = fmt.Sprint(c)
+	}
+	list, ok := rows.([]any)
+	if !ok && rows != nil {
+		return nil, fmt.Errorf("give the table's rows as a list of rows")
+	}
+	for i, r := range list {
+		row, ok := r.([]any)
+		if !ok || len(row) != len(cols) {
+			return nil, fmt.Errorf("row %d does not have the table's %d columns", i+1, len(cols))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
@@ -2,6 +2,7 @@ package neko
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -22,6 +23,10 @@ type Message struct {
 	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
 	TokenUsage *TokenUsage `json:"token_usage,omitempty"`
 	Images     [][]byte    `json:"images,omitempty"`
+	// Reasoning is the chain of thought a reasoning model returned
+	// separately from its answer, e.g. DeepSeek's reasoning_content. It
+	// is never sent back to the model.
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 // ToolCall represents a tool invocation.
@@ -35,6 +40,9 @@ type ToolCall struct {
 type TokenUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	// CachedInputTokens are the input tokens served from the provider's
+	// prompt cache, for providers that report them.
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
 }
 
 // Total returns total tokens used.
@@ -42,6 +50,17 @@ func (t TokenUsage) Total() int {
 	return t.InputTokens + t.OutputTokens
 }
 
+// Pricing is a model's price in US dollars per million tokens.
+type Pricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// Cost returns the price of the usage in US dollars.
+func (t TokenUsage) Cost(p Pricing) float64 {
+	return (float64(t.InputTokens)*p.InputPerMillion + float64(t.OutputTokens)*p.OutputPerMillion) / 1e6
+}
+
 // Timing captures execution timing.
 type Timing struct {
 	StartTime time.Time     `json:"start_time"`
@@ -59,13 +78,36 @@ func NewTiming(start time.Time) Timing {
 	}
 }
 
+// Artifact is a file or blob produced while executing an action.
+type Artifact struct {
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
 // RunResult holds the result of an agent run.
 type RunResult struct {
 	Output     any         `json:"output"`
-	State      string      `json:"state"` // "success" or "max_steps_error"
-	Steps      []Step      `json:"steps"`
+	State      string      `json:"state"` // "success", "max_steps_error", "budget_exceeded" or "step_timeout"
+	Steps      []Step      `json:"steps"` // a copy of memory, safe to use while the agent runs again
+	Artifacts  []Artifact  `json:"artifacts,omitempty"`
+	Citations  []Citation  `json:"citations,omitempty"` // sources consulted, without duplicates
 	TokenUsage *TokenUsage `json:"token_usage,omitempty"`
-	Timing     Timing      `json:"timing"`
+	Cost       float64     `json:"cost,omitempty"` // US dollars, set when the agent has pricing
+	Latency    *Latency    `json:"latency,omitempty"`
+	// UsageBreakdown attributes the usage to the agent, its managed agents
+	// and its tools.
+	UsageBreakdown *UsageBreakdown `json:"usage_breakdown,omitempty"`
+	Profile        *Profile        `json:"profile,omitempty"` // set when the agent profiles runs
+	Audit          AuditTrail      `json:"audit,omitempty"`
+	// Confidence is the model's confidence in Output, from 0 to 1, for
+	// agents with WithConfidence.
+	Confidence *float64 `json:"confidence,omitempty"`
+	// Abstained reports that Output is Abstention, given instead of an
+	// answer the model was not confident enough in.
+	Abstained bool   `json:"abstained,omitempty"`
+	Timing    Timing `json:"timing"`
 }
 
 // Step is the interface for all step types.
@@ -76,19 +118,61 @@ type Step interface {
 
 // ActionStep represents one action taken by the agent.
 type ActionStep struct {
-	StepNumber   int         `json:"step_number"`
-	Timing       Timing      `json:"timing"`
-	ModelOutput  string      `json:"model_output,omitempty"`
-	CodeAction   string      `json:"code_action,omitempty"`
-	ToolCalls    []ToolCall  `json:"tool_calls,omitempty"`
-	Observations string      `json:"observations,omitempty"`
-	Error        error       `json:"error,omitempty"`
-	TokenUsage   *TokenUsage `json:"token_usage,omitempty"`
-	IsFinal      bool        `json:"is_final_answer"`
+	StepNumber        int         `json:"step_number"`
+	Timing            Timing      `json:"timing"`
+	ModelOutput       string      `json:"model_output,omitempty"`
+	Reasoning         string      `json:"reasoning,omitempty"` // the model's chain of thought, see Message.Reasoning
+	CodeAction        string      `json:"code_action,omitempty"`
+	ToolCalls         []ToolCall  `json:"tool_calls,omitempty"`
+	Observations      string      `json:"observations,omitempty"`
+	Artifacts         []Artifact  `json:"artifacts,omitempty"`
+	Citations         []Citation  `json:"citations,omitempty"`
+	ObservationImages [][]byte    `json:"observation_images,omitempty"`
+	Error             error       `json:"error,omitempty"`
+	TokenUsage        *TokenUsage `json:"token_usage,omitempty"`
+	Latency           StepLatency `json:"latency"`
+	IsFinal           bool        `json:"is_final_answer"`
+	Candidates        []Candidate `json:"candidates,omitempty"` // sampled actions, see WithActionSampling
+	// AgentUsage is the usage of the managed agents called in the step,
+	// and ToolUsage the usage tools recorded with RecordTokenUsage.
+	AgentUsage map[string]Usage      `json:"agent_usage,omitempty"`
+	ToolUsage  map[string]TokenUsage `json:"tool_usage,omitempty"`
 }
 
 func (s *ActionStep) StepType() string { return "action" }
 
+// MarshalJSON encodes the step with its step_type and its error as a
+// string.
+func (s *ActionStep) MarshalJSON() ([]byte, error) {
+	type plain ActionStep
+	var errMsg string
+	if s.Error != nil {
+		errMsg = s.Error.Error()
+	}
+	return json.Marshal(struct {
+		StepType string `json:"step_type"`
+		*plain
+		Error string `json:"error,omitempty"`
+	}{s.StepType(), (*plain)(s), errMsg})
+}
+
+// UnmarshalJSON decodes a step encoded by MarshalJSON. The error, if any,
+// is restored as a plain error with the same message.
+func (s *ActionStep) UnmarshalJSON(data []byte) error {
+	type plain ActionStep
+	aux := struct {
+		*plain
+		Error string `json:"error,omitempty"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Error != "" {
+		s.Error = errors.New(aux.Error)
+	}
+	return nil
+}
+
 func (s *ActionStep) ToMessages() []Message {
 	var msgs []Message
 	// Assistant output (model's response text)
@@ -100,8 +184,8 @@ func (s *ActionStep) ToMessages() []Message {
 		msgs = append(msgs, Message{Role: RoleAssistant, Content: formatToolCalls(s.ToolCalls)})
 	}
 	// Observations as user message
-	if s.Observations != "" {
-		msgs = append(msgs, Message{Role: RoleUser, Content: "Observation:\n" + s.Observations})
+	if s.Observations != "" || len(s.ObservationImages) > 0 {
+		msgs = append(msgs, Message{Role: RoleUser, Content: "Observation:\n" + s.Observations, Images: s.ObservationImages})
 	}
 	// Errors as user message
 	if s.Error != nil {
@@ -130,14 +214,15 @@ func formatToolCalls(toolCalls []ToolCall) string {
 
 // TaskStep represents the initial task.
 type TaskStep struct {
-	Task   string   `json:"task"`
-	Images [][]byte `json:"images,omitempty"`
+	Task   string       `json:"task"`
+	Images [][]byte     `json:"images,omitempty"`
+	Files  []Attachment `json:"files,omitempty"`
 }
 
 func (s *TaskStep) StepType() string { return "task" }
 
 func (s *TaskStep) ToMessages() []Message {
-	return []Message{{Role: RoleUser, Content: "Task:\n" + s.Task}}
+	return []Message{{Role: RoleUser, Content: "Task:\n" + s.Task + attachmentsPrompt(s.Files), Images: s.Images}}
 }
 
 // PlanningStep represents a planning phase.
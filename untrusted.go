@@ -0,0 +1,43 @@
+package neko
+
+import (
+	"regexp"
+	"strings"
+)
+
+var untrustedTag = regexp.MustCompile(`(?i)<(/?)untrusted`)
+
+// WrapUntrusted wraps content that did not come from the developer, such
+// as a user's task, a web page or a tool's output, in delimiters naming
+// its source, so a prompt can tell the model to treat what is inside as
+// data rather than instructions:
+//
+//	<untrusted source="web page">
+//	...
+//	</untrusted>
+//
+// Delimiters inside content are defused, so it cannot close the block
+// early and smuggle text outside it.
+func WrapUntrusted(source, content string) string {
+	source = strings.Map(func(r rune) rune {
+		if r == '"' || r == '<' || r == '>' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, source)
+	content = untrustedTag.ReplaceAllString(content, "&lt;${1}untrusted")
+	return `<untrusted source="` + source + "\">\n" + content + "\n</untrusted>"
+}
+
+// templateEscaper escapes every opening brace, not just "{{", since a lone
+// one next to a delimiter would open an action. Closing braces outside
+// actions are plain text.
+var templateEscaper = strings.NewReplacer("{", `{{"{"}}`)
+
+// EscapeTemplate escapes text/template actions in s, so that s renders as
+// itself when it becomes part of a template's text, e.g. a task pasted
+// into a prompt template before the template is parsed. Values passed as
+// template data need no escaping: they are never parsed.
+func EscapeTemplate(s string) string {
+	return templateEscaper.Replace(s)
+}
@@ -0,0 +1,155 @@
+package neko
+
+import (
+	"context"
+	"sort"
+)
+
+// Usage is token usage with its cost in US dollars.
+type Usage struct {
+	TokenUsage
+	Cost float64 `json:"cost,omitempty"`
+}
+
+func (u *Usage) add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CachedInputTokens += o.CachedInputTokens
+	u.Cost += o.Cost
+}
+
+// UsageBreakdown attributes a run's token usage, for cost attribution in
+// multi-agent systems.
+type UsageBreakdown struct {
+	Agent Usage `json:"agent"` // the agent's own model calls
+	// ByAgent is the usage of each managed agent called, including its
+	// own managed agents.
+	ByAgent map[string]Usage `json:"by_agent,omitempty"`
+	// ByTool is, for each tool and managed agent, the agent's model calls
+	// in the steps after those calling it, split evenly between the tools
+	// a step called, plus any usage the tool recorded with
+	// RecordTokenUsage.
+	ByTool map[string]Usage `json:"by_tool,omitempty"`
+	Total  Usage            `json:"total"` // Agent plus ByAgent
+}
+
+type toolNameKey struct{}
+
+// RecordTokenUsage attributes u to the tool being called with ctx, for
+// tools that call models themselves. The usage is added to the tool's
+// entry in the run's UsageBreakdown; it is not part of the agent's own
+// token usage.
+func RecordTokenUsage(ctx context.Context, u TokenUsage) {
+	name, _ := ctx.Value(toolNameKey{}).(string)
+	r, ok := ctx.Value(stepKey{}).(*stepRecorder)
+	if name == "" || !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.step.ToolUsage == nil {
+		r.step.ToolUsage = make(map[string]TokenUsage)
+	}
+	t := r.step.ToolUsage[name]
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.CachedInputTokens += u.CachedInputTokens
+	r.step.ToolUsage[name] = t
+}
+
+// recordAgentUsage adds the usage of a managed agent's run to the step
+// ctx belongs to.
+func recordAgentUsage(ctx context.Context, name string, result *RunResult) {
+	r, ok := ctx.Value(stepKey{}).(*stepRecorder)
+	if !ok || result == nil {
+		return
+	}
+	u := Usage{Cost: result.Cost}
+	if result.TokenUsage != nil {
+		u.TokenUsage = *result.TokenUsage
+	}
+	if b := result.UsageBreakdown; b != nil {
+		u = b.Total
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.step.AgentUsage == nil {
+		r.step.AgentUsage = make(map[string]Usage)
+	}
+	sum := r.step.AgentUsage[name]
+	sum.add(u)
+	r.step.AgentUsage[name] = sum
+}
+
+// UsageBreakdown attributes the token usage in memory, pricing the
+// agent's own model calls with p.
+func (m *Memory) UsageBreakdown(p Pricing) *UsageBreakdown {
+	b := &UsageBreakdown{ByAgent: map[string]Usage{}, ByTool: map[string]Usage{}}
+	priced := func(t TokenUsage) Usage { return Usage{TokenUsage: t, Cost: t.Cost(p)} }
+	var prevTools []string
+	for _, step := range m.Steps {
+		switch s := step.(type) {
+		case *PlanningStep:
+			if s.TokenUsage != nil {
+				b.Agent.add(priced(*s.TokenUsage))
+			}
+		case *ActionStep:
+			if s.TokenUsage != nil {
+				b.Agent.add(priced(*s.TokenUsage))
+				splitUsage(b.ByTool, prevTools, *s.TokenUsage, p)
+			}
+			for name, u := range s.AgentUsage {
+				sum := b.ByAgent[name]
+				sum.add(u)
+				b.ByAgent[name] = sum
+			}
+			for name, t := range s.ToolUsage {
+				sum := b.ByTool[name]
+				sum.add(priced(t))
+				b.ByTool[name] = sum
+			}
+			prevTools = prevTools[:0]
+			for name := range s.Latency.ByTool {
+				if name != "final_answer" {
+					prevTools = append(prevTools, name)
+				}
+			}
+			sort.Strings(prevTools)
+		}
+	}
+	b.Total = b.Agent
+	for _, u := range b.ByAgent {
+		b.Total.add(u)
+	}
+	if len(b.ByAgent) == 0 {
+		b.ByAgent = nil
+	}
+	if len(b.ByTool) == 0 {
+		b.ByTool = nil
+	}
+	return b
+}
+
+// splitUsage divides t evenly between tools, giving any remainder tokens
+// to the first.
+func splitUsage(byTool map[string]Usage, tools []string, t TokenUsage, p Pricing) {
+	n := len(tools)
+	if n == 0 {
+		return
+	}
+	for i, name := range tools {
+		share := TokenUsage{
+			InputTokens:       t.InputTokens / n,
+			OutputTokens:      t.OutputTokens / n,
+			CachedInputTokens: t.CachedInputTokens / n,
+		}
+		if i == 0 {
+			share.InputTokens += t.InputTokens % n
+			share.OutputTokens += t.OutputTokens % n
+			share.CachedInputTokens += t.CachedInputTokens % n
+		}
+		sum := byTool[name]
+		sum.add(Usage{TokenUsage: share, Cost: share.Cost(p)})
+		byTool[name] = sum
+	}
+}
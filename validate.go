@@ -0,0 +1,147 @@
+package neko
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validator is implemented by agents that can check their configuration
+// before a run. Validate on a BaseAgent-derived agent also validates its
+// managed agents through this interface.
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// ValidationError is a problem found by Validate with one part of an
+// agent, such as "model", `tool "search"` or "executor".
+type ValidationError struct {
+	Component string
+	Err       error
+}
+
+func (e *ValidationError) Error() string { return e.Component + ": " + e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+var (
+	toolNamePattern  = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	schemaInputTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true, "array": true, "object": true, "null": true}
+)
+
+// Validate checks that the agent can run: that its model answers a short
+// request, that each tool's schema is valid function calling JSON, and
+// that its managed agents validate. Problems are returned joined, each as
+// a *ValidationError. Checking the model makes one small model call.
+func (a *BaseAgent) Validate(ctx context.Context) error {
+	var errs []error
+	if err := a.validateModel(ctx); err != nil {
+		errs = append(errs, &ValidationError{Component: "model", Err: err})
+	}
+	seen := map[string]bool{}
+	for _, t := range a.allTools() {
+		component := fmt.Sprintf("tool %q", t.Name())
+		if seen[t.Name()] {
+			errs = append(errs, &ValidationError{Component: component, Err: errors.New("name is used by both a tool and a managed agent")})
+		}
+		seen[t.Name()] = true
+		if err := validateToolSchema(t); err != nil {
+			errs = append(errs, &ValidationError{Component: component, Err: err})
+		}
+	}
+	for name, agent := range a.managedAgents {
+		if v, ok := agent.(Validator); ok {
+			if err := v.Validate(ctx); err != nil {
+				errs = append(errs, &ValidationError{Component: fmt.Sprintf("managed agent %q", name), Err: err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the agent like BaseAgent.Validate and also dry-runs its
+// executor, starting and closing a session for executors that hold one.
+// The dry run uses fresh state, so it does not affect the next run.
+func (a *CodeAgent) Validate(ctx context.Context) error {
+	err := a.BaseAgent.Validate(ctx)
+	if xerr := a.validateExecutor(ctx); xerr != nil {
+		err = errors.Join(err, &ValidationError{Component: "executor", Err: xerr})
+	}
+	return err
+}
+
+func (a *BaseAgent) validateModel(ctx context.Context) error {
+	if a.model == nil {
+		return errors.New("no model configured; use WithModel")
+	}
+	msgs := []Message{{Role: RoleUser, Content: "Reply with OK."}}
+	if _, err := a.model.Generate(ctx, msgs, WithMaxTokens(16)); err != nil {
+		return fmt.Errorf("%s did not answer a test request; check the API key, base URL and model ID: %w", a.model.ModelID(), err)
+	}
+	return nil
+}
+
+// validateToolSchema checks the tool's function calling schema against
+// the rules providers enforce.
+func validateToolSchema(t Tool) error {
+	var problems []string
+	if !toolNamePattern.MatchString(t.Name()) {
+		problems = append(problems, "name must be 1-64 letters, digits, underscores or hyphens")
+	}
+	if strings.TrimSpace(t.Description()) == "" {
+		problems = append(problems, "description is empty, but models rely on it to choose tools")
+	}
+	for _, name := range ToolParamNames(t) {
+		in := t.Inputs()[name]
+		if !toolNamePattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("input %q: name must be 1-64 letters, digits, underscores or hyphens", name))
+		}
+		if !schemaInputTypes[in.Type] {
+			problems = append(problems, fmt.Sprintf("input %q: type %q is not a JSON Schema type (string, number, integer, boolean, array, object or null)", name, in.Type))
+		}
+	}
+	r := NewToolRegistry()
+	r.Register(t)
+	if _, err := json.Marshal(r.ToJSONSchema()); err != nil {
+		problems = append(problems, fmt.Sprintf("schema does not encode as JSON: %v", err))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateExecutor runs a line of code that prints a marker and checks
+// the marker comes back in the logs.
+func (a *CodeAgent) validateExecutor(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.executor == nil {
+		return errors.New("no executor configured")
+	}
+	const marker = "neko preflight ok"
+	code := `print("` + marker + `")`
+	switch executorLanguage(a.executor) {
+	case "javascript":
+		code = `console.log("` + marker + `")`
+	case "bash":
+		code = `echo "` + marker + `"`
+	}
+	if se, ok := a.executor.(SessionExecutor); ok {
+		if err := se.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start session: %w", err)
+		}
+		defer se.Close()
+	}
+	result, err := a.executor.Execute(ctx, code, map[string]any{})
+	if err != nil {
+		return fmt.Errorf("test code failed: %w", err)
+	}
+	if !strings.Contains(result.Logs, marker) {
+		return fmt.Errorf("test code ran but its output was not captured (logs: %q)", truncate(result.Logs, 200))
+	}
+	return nil
+}
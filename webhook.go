@@ -0,0 +1,160 @@
+package neko
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Headers set on webhook requests.
+const (
+	WebhookSignatureHeader = "X-Neko-Signature"
+	WebhookTimestampHeader = "X-Neko-Timestamp"
+)
+
+// WebhookEvent is the JSON body POSTed for each event. Type is "step" for
+// recorded steps, "budget_warning" when a budget threshold is reached and
+// "run_completed" when a run ends.
+type WebhookEvent struct {
+	Type      string         `json:"type"`
+	Agent     string         `json:"agent"`
+	Timestamp time.Time      `json:"timestamp"`
+	StepType  string         `json:"step_type,omitempty"`
+	Step      Step           `json:"step,omitempty"`
+	Budget    *BudgetWarning `json:"budget,omitempty"`
+	Result    *RunResult     `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// WebhookOption configures a webhook sink.
+type WebhookOption func(*webhookSink)
+
+// WithWebhookHTTPClient sets the HTTP client used for delivery.
+func WithWebhookHTTPClient(c *http.Client) WebhookOption {
+	return func(w *webhookSink) { w.client = c }
+}
+
+// WithWebhookRedactor redacts every string in event bodies with r before
+// they are signed and sent.
+func WithWebhookRedactor(r *Redactor) WebhookOption {
+	return func(w *webhookSink) { w.redactor = r }
+}
+
+// WithWebhookStepTypes limits step events to the given step types. Budget
+// warnings and run completion are always sent.
+func WithWebhookStepTypes(types ...string) WebhookOption {
+	return func(w *webhookSink) { w.stepTypes = types }
+}
+
+// WithWebhook POSTs step, budget warning and run completion events to url
+// as JSON. Each
+// request is signed with HMAC-SHA256 over "<timestamp>.<body>" using
+// secret; the hex digest is sent as "sha256=<digest>" in the
+// X-Neko-Signature header and the Unix timestamp in X-Neko-Timestamp.
+// Delivery is synchronous so events arrive in order; failures are logged
+// and do not affect the run.
+func WithWebhook(url, secret string, opts ...WebhookOption) AgentOption {
+	return func(a *BaseAgent) {
+		w := &webhookSink{
+			url:    url,
+			secret: []byte(secret),
+			client: &http.Client{Timeout: 10 * time.Second},
+			agent:  a,
+		}
+		for _, opt := range opts {
+			opt(w)
+		}
+		a.events.Subscribe(EventStep, w.onStep)
+		a.events.Subscribe(EventBudgetWarning, w.onBudgetWarning)
+		a.events.Subscribe(EventRunCompleted, w.onRunEnd)
+	}
+}
+
+// VerifyWebhookSignature reports whether signature, the value of the
+// X-Neko-Signature header, matches body and timestamp under secret.
+func VerifyWebhookSignature(secret, timestamp string, body []byte, signature string) bool {
+	expected := signWebhook([]byte(secret), timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+type webhookSink struct {
+	url       string
+	secret    []byte
+	client    *http.Client
+	stepTypes []string
+	redactor  *Redactor
+	agent     *BaseAgent
+}
+
+func (w *webhookSink) onStep(e Event) {
+	step := e.(StepEvent).Step
+	if len(w.stepTypes) > 0 && !slices.Contains(w.stepTypes, step.StepType()) {
+		return
+	}
+	w.send(&WebhookEvent{Type: "step", StepType: step.StepType(), Step: step})
+}
+
+func (w *webhookSink) onBudgetWarning(e Event) {
+	w.send(&WebhookEvent{Type: EventBudgetWarning, Budget: e.(*BudgetWarning)})
+}
+
+func (w *webhookSink) onRunEnd(e Event) {
+	ev := e.(RunCompletedEvent)
+	event := &WebhookEvent{Type: "run_completed", Result: ev.Result}
+	if ev.Err != nil {
+		event.Error = ev.Err.Error()
+	}
+	w.send(event)
+}
+
+func (w *webhookSink) send(event *WebhookEvent) {
+	event.Agent = w.agent.name
+	event.Timestamp = time.Now().UTC()
+	if err := w.post(event); err != nil {
+		w.agent.logger().Warn("webhook delivery failed", "url", w.url, "event", event.Type, "error", err)
+	}
+}
+
+func (w *webhookSink) post(event *WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if w.redactor != nil {
+		if body, err = w.redactor.RedactJSON(body); err != nil {
+			return err
+		}
+	}
+	timestamp := strconv.FormatInt(event.Timestamp.Unix(), 10)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, signWebhook(w.secret, timestamp, body))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func signWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
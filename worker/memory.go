@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MemoryQueue is an in-process Queue, for tests and for feeding a worker
+// from the same program.
+type MemoryQueue struct {
+	ch chan []byte
+}
+
+// NewMemoryQueue creates a queue holding up to size pending tasks.
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{ch: make(chan []byte, size)}
+}
+
+// Push adds a task, waiting while the queue is full.
+func (q *MemoryQueue) Push(ctx context.Context, t Task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return q.push(ctx, data)
+}
+
+func (q *MemoryQueue) push(ctx context.Context, data []byte) error {
+	select {
+	case q.ch <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Receive implements Queue.
+func (q *MemoryQueue) Receive(ctx context.Context) (Message, error) {
+	select {
+	case data := <-q.ch:
+		return &memoryMessage{queue: q, body: data}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type memoryMessage struct {
+	queue *MemoryQueue
+	body  []byte
+}
+
+func (m *memoryMessage) Body() []byte                  { return m.body }
+func (m *memoryMessage) Ack(ctx context.Context) error { return nil }
+
+func (m *memoryMessage) Nack(ctx context.Context) error {
+	return m.queue.push(ctx, m.body)
+}
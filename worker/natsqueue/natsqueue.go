@@ -0,0 +1,85 @@
+// Package natsqueue implements worker.Queue with a NATS JetStream pull
+// consumer. Workers sharing a durable consumer split its messages
+// between them; messages that are not acknowledged within the consumer's
+// AckWait are redelivered, so it should exceed the longest task, and
+// MaxDeliver should be unlimited or above the worker's attempts.
+package natsqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gocnn/neko/worker"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// pollInterval is how long each pull request waits for a message.
+const pollInterval = 30 * time.Second
+
+// Queue receives tasks from a JetStream consumer and publishes results
+// to a subject. It implements worker.Queue and worker.Publisher.
+type Queue struct {
+	js            jetstream.JetStream
+	consumer      jetstream.Consumer
+	resultSubject string
+}
+
+// New creates a queue reading from consumer and publishing results on
+// resultSubject, which should be captured by a stream so results are
+// kept.
+func New(js jetstream.JetStream, consumer jetstream.Consumer, resultSubject string) *Queue {
+	return &Queue{js: js, consumer: consumer, resultSubject: resultSubject}
+}
+
+// Push publishes a task on subject, which must belong to the stream the
+// consumer reads.
+func Push(ctx context.Context, js jetstream.JetStream, subject string, t worker.Task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(ctx, subject, data)
+	return err
+}
+
+// Receive implements worker.Queue.
+func (q *Queue) Receive(ctx context.Context) (worker.Message, error) {
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, pollInterval)
+		msg, err := q.consumer.Next(jetstream.FetchContext(pollCtx))
+		cancel()
+		switch {
+		case err == nil:
+			return &message{msg: msg}, nil
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+			// No message yet; poll again.
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Publish implements worker.Publisher.
+func (q *Queue) Publish(ctx context.Context, r *worker.Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = q.js.Publish(ctx, q.resultSubject, data)
+	return err
+}
+
+type message struct {
+	msg jetstream.Msg
+}
+
+func (m *message) Body() []byte { return m.msg.Data() }
+
+func (m *message) Ack(ctx context.Context) error { return m.msg.DoubleAck(ctx) }
+
+func (m *message) Nack(ctx context.Context) error { return m.msg.Nak() }
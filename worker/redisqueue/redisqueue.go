@@ -0,0 +1,125 @@
+// Package redisqueue implements worker.Queue with Redis lists.
+//
+// Tasks are pushed onto the list named by the queue and moved atomically
+// to "<name>:processing" while a worker handles them, so a task is never
+// lost if a worker dies; Requeue returns such tasks to the queue. Results
+// are pushed onto "<name>:results".
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gocnn/neko/worker"
+	"github.com/redis/go-redis/v9"
+)
+
+// pollInterval bounds how long a blocking receive waits before checking
+// whether its context is done.
+const pollInterval = 5 * time.Second
+
+// Queue is a task queue in Redis. It implements worker.Queue and
+// worker.Publisher.
+type Queue struct {
+	client     redis.UniversalClient
+	name       string
+	processing string
+	results    string
+}
+
+// New creates a queue stored under name.
+func New(client redis.UniversalClient, name string) *Queue {
+	return &Queue{client: client, name: name, processing: name + ":processing", results: name + ":results"}
+}
+
+// Push adds a task to the queue.
+func (q *Queue) Push(ctx context.Context, t worker.Task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return q.client.LPush(ctx, q.name, data).Err()
+}
+
+// Receive implements worker.Queue.
+func (q *Queue) Receive(ctx context.Context) (worker.Message, error) {
+	for {
+		body, err := q.client.BLMove(ctx, q.name, q.processing, "RIGHT", "LEFT", pollInterval).Bytes()
+		switch {
+		case err == nil:
+			return &message{queue: q, body: body}, nil
+		case errors.Is(err, redis.Nil):
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Publish implements worker.Publisher by pushing r onto the results list.
+func (q *Queue) Publish(ctx context.Context, r *worker.Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return q.client.LPush(ctx, q.results, data).Err()
+}
+
+// PopResult removes the oldest result, waiting up to timeout for one.
+// It returns nil if none arrives.
+func (q *Queue) PopResult(ctx context.Context, timeout time.Duration) (*worker.Result, error) {
+	vals, err := q.client.BRPop(ctx, timeout, q.results).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r worker.Result
+	if err := json.Unmarshal([]byte(vals[1]), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Requeue moves every task in the processing list back to the queue. Call
+// it when no worker is running, e.g. at startup, to recover tasks from
+// workers that exited without finishing them.
+func (q *Queue) Requeue(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		err := q.client.LMove(ctx, q.processing, q.name, "LEFT", "RIGHT").Err()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+type message struct {
+	queue *Queue
+	body  []byte
+}
+
+func (m *message) Body() []byte { return m.body }
+
+func (m *message) Ack(ctx context.Context) error {
+	return m.queue.client.LRem(ctx, m.queue.processing, 1, m.body).Err()
+}
+
+// Nack puts the task back at the head of the queue, so it runs next.
+func (m *message) Nack(ctx context.Context) error {
+	_, err := m.queue.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.LRem(ctx, m.queue.processing, 1, m.body)
+		p.RPush(ctx, m.queue.name, m.body)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,321 @@
+// Package worker runs agents on tasks consumed from a queue, for batch
+// processing at scale:
+//
+//	w := worker.New(newAgent, queue,
+//		worker.WithConcurrency(8),
+//		worker.WithPublisher(queue),
+//	)
+//	err := w.Run(ctx)
+//
+// Queues are reached through the small Queue interface; redisqueue and
+// natsqueue implement it for Redis lists and NATS JetStream, and other
+// brokers such as SQS map onto it directly (receive, delete, and change
+// visibility to zero to release a message).
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gocnn/neko"
+)
+
+// Task is a queued unit of work, encoded as JSON in queue messages.
+type Task struct {
+	ID       string            `json:"id"`
+	Task     string            `json:"task"`
+	MaxSteps int               `json:"max_steps,omitempty"` // defaults to the agent's
+	Images   [][]byte          `json:"images,omitempty"`    // base64 in JSON
+	Metadata map[string]string `json:"metadata,omitempty"`  // copied to the Result
+}
+
+// Task result states.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Result is published once per task, after it succeeds or its last
+// attempt fails.
+type Result struct {
+	TaskID     string            `json:"task_id"`
+	Status     string            `json:"status"`
+	Result     *neko.RunResult   `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Attempts   int               `json:"attempts"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+}
+
+// Message is a task received from a queue.
+type Message interface {
+	// Body returns the JSON-encoded Task.
+	Body() []byte
+	// Ack removes the message from the queue once it has been handled.
+	Ack(ctx context.Context) error
+	// Nack returns the message to the queue for another worker.
+	Nack(ctx context.Context) error
+}
+
+// Queue delivers tasks.
+type Queue interface {
+	// Receive blocks until a message is available or ctx is done.
+	Receive(ctx context.Context) (Message, error)
+}
+
+// Publisher receives task results.
+type Publisher interface {
+	Publish(ctx context.Context, r *Result) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, r *Result) error
+
+// Publish calls f(ctx, r).
+func (f PublisherFunc) Publish(ctx context.Context, r *Result) error { return f(ctx, r) }
+
+// Worker consumes tasks from a queue and runs agents on them.
+type Worker struct {
+	newAgent     func() (neko.Agent, error)
+	queue        Queue
+	publisher    Publisher
+	concurrency  int
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	taskTimeout  time.Duration
+	drainTimeout time.Duration
+	retryIf      func(error) bool
+	log          *slog.Logger
+}
+
+// Option configures a Worker.
+type Option func(*Worker)
+
+// WithConcurrency sets how many tasks run at once, each with its own
+// agent. Defaults to 1.
+func WithConcurrency(n int) Option {
+	return func(w *Worker) { w.concurrency = n }
+}
+
+// WithMaxAttempts sets how many times a failing task is run before its
+// failure is published. Defaults to 3.
+func WithMaxAttempts(n int) Option {
+	return func(w *Worker) { w.maxAttempts = n }
+}
+
+// WithBackoff sets the wait before the first retry, doubled for each
+// further retry up to max. Defaults to 1 second and 1 minute.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(w *Worker) { w.backoff, w.maxBackoff = initial, max }
+}
+
+// WithRetryIf retries only run errors for which fn returns true. By
+// default every error is retried.
+func WithRetryIf(fn func(error) bool) Option {
+	return func(w *Worker) { w.retryIf = fn }
+}
+
+// WithTaskTimeout cancels attempts that take longer than d. Zero, the
+// default, means no limit.
+func WithTaskTimeout(d time.Duration) Option {
+	return func(w *Worker) { w.taskTimeout = d }
+}
+
+// WithDrainTimeout sets how long Run waits for running tasks once its
+// context is done before canceling them and returning them to the queue.
+// Defaults to 30 seconds.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(w *Worker) { w.drainTimeout = d }
+}
+
+// WithPublisher publishes each task's result to p. Without one, results
+// are only logged.
+func WithPublisher(p Publisher) Option {
+	return func(w *Worker) { w.publisher = p }
+}
+
+// WithLogger sets the logger for task outcomes and queue errors. Defaults
+// to slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(w *Worker) { w.log = l }
+}
+
+// New creates a worker that runs agents made by newAgent on tasks from
+// queue. newAgent is called once per concurrent slot, since an agent runs
+// one task at a time; memory is reset for every task.
+func New(newAgent func() (neko.Agent, error), queue Queue, opts ...Option) *Worker {
+	w := &Worker{
+		newAgent:     newAgent,
+		queue:        queue,
+		concurrency:  1,
+		maxAttempts:  3,
+		backoff:      time.Second,
+		maxBackoff:   time.Minute,
+		drainTimeout: 30 * time.Second,
+		log:          slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run consumes tasks until ctx is done, then stops receiving and waits
+// for running tasks up to the drain timeout. Tasks still running after
+// that are canceled and returned to the queue. Run returns an error only
+// if an agent cannot be created.
+func (w *Worker) Run(ctx context.Context) error {
+	agents := make([]neko.Agent, w.concurrency)
+	for i := range agents {
+		a, err := w.newAgent()
+		if err != nil {
+			return fmt.Errorf("create agent: %w", err)
+		}
+		agents[i] = a
+	}
+
+	// Tasks outlive ctx by the drain timeout.
+	taskCtx, cancelTasks := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelTasks()
+	stop := context.AfterFunc(ctx, func() {
+		t := time.AfterFunc(w.drainTimeout, cancelTasks)
+		context.AfterFunc(taskCtx, func() { t.Stop() })
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for _, a := range agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, taskCtx, a)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (w *Worker) loop(ctx, taskCtx context.Context, agent neko.Agent) {
+	failures := 0
+	for ctx.Err() == nil {
+		msg, err := w.queue.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			w.log.Error("receive task", "err", err)
+			sleep(ctx, w.delay(failures))
+			continue
+		}
+		failures = 0
+		w.handle(taskCtx, agent, msg)
+	}
+}
+
+// handle runs one message's task and settles the message.
+func (w *Worker) handle(ctx context.Context, agent neko.Agent, msg Message) {
+	res := &Result{StartedAt: time.Now()}
+	var task Task
+	if err := json.Unmarshal(msg.Body(), &task); err != nil || task.Task == "" {
+		// A malformed message fails the same way every time.
+		if err == nil {
+			err = errors.New("task is required")
+		}
+		res.TaskID, res.Status, res.Error = task.ID, StatusFailed, "invalid task: "+err.Error()
+		w.finish(ctx, msg, res)
+		return
+	}
+	res.TaskID, res.Metadata = task.ID, task.Metadata
+
+	opts := []neko.RunOption{neko.WithReset(true)}
+	if task.MaxSteps > 0 {
+		opts = append(opts, neko.WithMaxSteps(task.MaxSteps))
+	}
+	if len(task.Images) > 0 {
+		opts = append(opts, neko.WithImages(task.Images...))
+	}
+
+	var err error
+	for res.Attempts < w.maxAttempts {
+		res.Attempts++
+		res.Result, err = w.attempt(ctx, agent, task, opts)
+		if err == nil || ctx.Err() != nil || (w.retryIf != nil && !w.retryIf(err)) {
+			break
+		}
+		if res.Attempts < w.maxAttempts {
+			w.log.Warn("task attempt failed", "task", task.ID, "attempt", res.Attempts, "err", err)
+			sleep(ctx, w.delay(res.Attempts))
+		}
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down: leave the task to another worker.
+		nackCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if nerr := msg.Nack(nackCtx); nerr != nil {
+			w.log.Error("return task to queue", "task", task.ID, "err", nerr)
+		}
+		return
+	}
+	if err != nil {
+		res.Status, res.Error, res.Result = StatusFailed, err.Error(), nil
+	} else {
+		res.Status = StatusSucceeded
+	}
+	w.finish(ctx, msg, res)
+}
+
+func (w *Worker) attempt(ctx context.Context, agent neko.Agent, task Task, opts []neko.RunOption) (*neko.RunResult, error) {
+	if w.taskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.taskTimeout)
+		defer cancel()
+	}
+	return agent.Run(ctx, task.Task, opts...)
+}
+
+// finish publishes res and acknowledges msg. A message whose result
+// cannot be published is returned to the queue instead.
+func (w *Worker) finish(ctx context.Context, msg Message, res *Result) {
+	res.FinishedAt = time.Now()
+	w.log.Info("task finished", "task", res.TaskID, "status", res.Status, "attempts", res.Attempts,
+		"duration", res.FinishedAt.Sub(res.StartedAt))
+	if w.publisher != nil {
+		if err := w.publisher.Publish(ctx, res); err != nil {
+			w.log.Error("publish result", "task", res.TaskID, "err", err)
+			if err := msg.Nack(ctx); err != nil {
+				w.log.Error("return task to queue", "task", res.TaskID, "err", err)
+			}
+			return
+		}
+	}
+	if err := msg.Ack(ctx); err != nil {
+		w.log.Error("acknowledge task", "task", res.TaskID, "err", err)
+	}
+}
+
+// delay is the backoff before retry n, counting from 1.
+func (w *Worker) delay(n int) time.Duration {
+	d := w.backoff
+	for i := 1; i < n && d < w.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, w.maxBackoff)
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gocnn/neko"
+	"github.com/gocnn/neko/testutil"
+)
+
+func TestTaskImagesReachModel(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	model := testutil.NewReplayModel(&neko.Message{Role: neko.RoleAssistant, ToolCalls: []neko.ToolCall{
+		{ID: "1", Name: "final_answer", Arguments: map[string]any{"answer": "a cat"}},
+	}})
+	newAgent := func() (neko.Agent, error) {
+		return neko.NewToolCallingAgent(neko.WithModel(model), neko.WithModelCapabilities(neko.Capabilities{Tools: true, Vision: true})), nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := NewMemoryQueue(1)
+	if err := queue.Push(ctx, Task{ID: "1", Task: "what is this?", Images: [][]byte{image}}); err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *Result, 1)
+	w := New(newAgent, queue, WithPublisher(PublisherFunc(func(_ context.Context, r *Result) error {
+		results <- r
+		cancel()
+		return nil
+	})))
+	if err := w.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-results; r.Status != StatusSucceeded {
+		t.Fatalf("task %s: %s", r.Status, r.Error)
+	}
+	var got [][]byte
+	for _, msg := range model.Requests()[0] {
+		got = append(got, msg.Images...)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], image) {
+		t.Errorf("model saw images %q, want the task's image", got)
+	}
+}
@@ -0,0 +1,272 @@
+package neko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrWorkspaceQuota is matched by errors from writes, and from code runs,
+// that took a workspace over its quota.
+var ErrWorkspaceQuota = errors.New("workspace quota exceeded")
+
+// Workspace is a directory shared by a run's tools and code executors, so
+// files written by code are readable by tools and vice versa. File paths
+// are slash-separated and relative to the workspace root; paths leaving
+// the root, including through symbolic links, are rejected.
+type Workspace struct {
+	dir   string
+	root  *os.Root
+	quota int64 // bytes; 0 means unlimited
+	temp  bool
+	mu    sync.Mutex
+}
+
+// WorkspaceFile describes a file or directory in a workspace.
+type WorkspaceFile struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"is_dir,omitempty"`
+}
+
+// NewWorkspace opens dir, creating it if needed, as a workspace holding
+// at most quota bytes of files. A quota of 0 means unlimited.
+func NewWorkspace(dir string, quota int64) (*Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{dir: dir, root: root, quota: quota}, nil
+}
+
+// NewTempWorkspace creates a workspace in a new temporary directory that
+// Close removes.
+func NewTempWorkspace(quota int64) (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "neko-workspace-")
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWorkspace(dir, quota)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	w.temp = true
+	return w, nil
+}
+
+// Dir returns the workspace's root directory on disk.
+func (w *Workspace) Dir() string { return w.dir }
+
+// Quota returns the most bytes the workspace may hold, or 0 if unlimited.
+func (w *Workspace) Quota() int64 { return w.quota }
+
+// ReadFile returns the contents of the named file.
+func (w *Workspace) ReadFile(name string) ([]byte, error) {
+	name, err := cleanWorkspacePath(name)
+	if err != nil {
+		return nil, err
+	}
+	return w.root.ReadFile(name)
+}
+
+// WriteFile writes data to the named file, creating it and its parent
+// directories if needed. It fails with ErrWorkspaceQuota, leaving the
+// file unchanged, if the write would take the workspace over its quota.
+func (w *Workspace) WriteFile(name string, data []byte) error {
+	name, err := cleanWorkspacePath(name)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.quota > 0 {
+		used, err := w.size()
+		if err != nil {
+			return err
+		}
+		if info, err := w.root.Stat(name); err == nil && info.Mode().IsRegular() {
+			used -= info.Size()
+		}
+		if used+int64(len(data)) > w.quota {
+			return fmt.Errorf("writing %s (%d bytes): %w (%d of %d bytes used)", name, len(data), ErrWorkspaceQuota, used, w.quota)
+		}
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := w.root.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return w.root.WriteFile(name, data, 0o644)
+}
+
+// Remove removes the named file or directory and anything it contains.
+func (w *Workspace) Remove(name string) error {
+	name, err := cleanWorkspacePath(name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return fmt.Errorf("cannot remove the workspace root")
+	}
+	return w.root.RemoveAll(name)
+}
+
+// List returns the files and directories under dir, recursively, in path
+// order. An empty dir lists the whole workspace.
+func (w *Workspace) List(dir string) ([]WorkspaceFile, error) {
+	dir, err := cleanWorkspacePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []WorkspaceFile
+	err = fs.WalkDir(w.root.FS(), dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir && d.IsDir() {
+			return nil
+		}
+		f := WorkspaceFile{Path: p, IsDir: d.IsDir()}
+		if !f.IsDir {
+			if info, err := d.Info(); err == nil {
+				f.Size = info.Size()
+			}
+		}
+		files = append(files, f)
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+// Size returns the total size in bytes of the files in the workspace.
+func (w *Workspace) Size() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size()
+}
+
+func (w *Workspace) size() (int64, error) {
+	var n int64
+	err := fs.WalkDir(w.root.FS(), ".", func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		n += info.Size()
+		return nil
+	})
+	return n, err
+}
+
+// CheckQuota returns an error matching ErrWorkspaceQuota if the workspace
+// holds more than its quota. Executors call it after running code, which
+// writes to the directory directly.
+func (w *Workspace) CheckQuota() error {
+	if w.quota <= 0 {
+		return nil
+	}
+	used, err := w.Size()
+	if err != nil {
+		return err
+	}
+	if used > w.quota {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrWorkspaceQuota, used, w.quota)
+	}
+	return nil
+}
+
+// Close releases the workspace, removing its directory if it was created
+// by NewTempWorkspace.
+func (w *Workspace) Close() error {
+	err := w.root.Close()
+	if w.temp {
+		if rerr := os.RemoveAll(w.dir); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+// cleanWorkspacePath normalizes a workspace path, rejecting absolute
+// paths and paths leaving the root.
+func cleanWorkspacePath(name string) (string, error) {
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid workspace path %q", name)
+	}
+	return name, nil
+}
+
+type workspaceKey struct{}
+
+// AttachWorkspace attaches w to ctx, for tools and executors called with
+// it. Agents with WithWorkspace do this for each run.
+func AttachWorkspace(ctx context.Context, w *Workspace) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, w)
+}
+
+// WorkspaceFromContext returns the workspace attached to ctx, or nil.
+func WorkspaceFromContext(ctx context.Context) *Workspace {
+	w, _ := ctx.Value(workspaceKey{}).(*Workspace)
+	return w
+}
+
+// WithWorkspace gives each run a workspace holding at most quota bytes,
+// or unlimited if quota is 0. With an empty dir every run gets a fresh
+// temporary directory, removed when the run ends; otherwise runs share
+// dir, which is kept. Managed agents without a workspace of their own use
+// their manager's. Executors from the exec package run code in the
+// workspace unless given a work directory of their own.
+func WithWorkspace(dir string, quota int64) AgentOption {
+	return func(a *BaseAgent) { a.workspace = &workspaceConfig{dir: dir, quota: quota} }
+}
+
+type workspaceConfig struct {
+	dir   string
+	quota int64
+}
+
+// startWorkspace attaches the run's workspace to ctx. A run with files
+// attached but no workspace gets a temporary one.
+func (a *BaseAgent) startWorkspace(ctx context.Context, options *RunOptions) context.Context {
+	c := a.workspace
+	if c == nil && len(options.Files) > 0 && WorkspaceFromContext(ctx) == nil {
+		c = &workspaceConfig{}
+	}
+	if c == nil {
+		return ctx
+	}
+	var w *Workspace
+	var err error
+	if c.dir == "" {
+		w, err = NewTempWorkspace(c.quota)
+	} else {
+		w, err = NewWorkspace(c.dir, c.quota)
+	}
+	if err != nil {
+		a.logger().Error("failed to create workspace", "error", err)
+		return ctx
+	}
+	ctx = AttachWorkspace(ctx, w)
+	AddCleanup(ctx, func(context.Context) error { return w.Close() })
+	return ctx
+}